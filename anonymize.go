@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	anonymizeAuthors = kingpin.Flag("anonymize-authors", "Replace names, usernames, avatars and profile links of gitlab users with pseudonyms (e.g. Contributor 7)").Default("false").Bool()
+	pseudonyms       = &pseudonymMap{numbers: map[string]int{}}
+	mentionMatcher   = regexp.MustCompile(`(^|[\s(])@(\w[\w.-]*\w|\w)`)
+)
+
+// pseudonymMap numbers users in order of appearance so that the same user gets the same pseudonym in all migrated
+// projects of the run
+type pseudonymMap struct {
+	mutex   sync.Mutex
+	numbers map[string]int
+}
+
+func (p *pseudonymMap) pseudonym(username string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	number, ok := p.numbers[username]
+	if !ok {
+		number = len(p.numbers) + 1
+		p.numbers[username] = number
+	}
+	return fmt.Sprintf("Contributor %d", number)
+}
+
+// prepareAuthor formats author of migrated item with avatar and link to gitlab profile
+func prepareAuthor(username string, name string, avatarURL string, webURL string) string {
+	if *anonymizeAuthors {
+		return pseudonyms.pseudonym(username)
+	}
+	return fmt.Sprintf("![%s](%s =24x24) [%s](%s)", name, avatarURL, name, webURL)
+}
+
+// prepareUserLink formats user mentioned in migrated item as a link to gitlab profile
+func prepareUserLink(username string, name string, webURL string) string {
+	if *anonymizeAuthors {
+		return pseudonyms.pseudonym(username)
+	}
+	return fmt.Sprintf("[%s](%s)", name, webURL)
+}
+
+// prepareUserSlug returns username or its pseudonym usable in branch names
+func prepareUserSlug(username string) string {
+	if *anonymizeAuthors {
+		return strings.ReplaceAll(strings.ToLower(pseudonyms.pseudonym(username)), " ", "-")
+	}
+	return username
+}
+
+func anonymizeMentions(line string) string {
+	return mentionMatcher.ReplaceAllStringFunc(line, func(mention string) string {
+		match := mentionMatcher.FindStringSubmatch(mention)
+		return fmt.Sprintf("%s@%s", match[1], pseudonyms.pseudonym(match[2]))
+	})
+}
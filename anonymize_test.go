@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	*anonymizeAuthors = true
+	pseudonyms = &pseudonymMap{numbers: map[string]int{}}
+	defer func() {
+		*anonymizeAuthors = false
+		pseudonyms = &pseudonymMap{numbers: map[string]int{}}
+	}()
+
+	if diff := deep.Equal("Contributor 1", prepareAuthor("john.doe", "John Doe", "https://avatar", "https://gitlab.com/john.doe")); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal("Contributor 2", prepareUserLink("jane", "Jane", "https://gitlab.com/jane")); diff != nil {
+		t.Error(diff)
+	}
+	expect := "thanks @Contributor 1, (@Contributor 3) mail john.doe@example.com"
+	if diff := deep.Equal(expect, convertLine("thanks @john.doe, (@bob) mail john.doe@example.com", markdownContext{})); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal("contributor-2", prepareUserSlug("jane")); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strconv"
+)
+
+// handling of gitlab projects archived before the migration
+const (
+	archivedMigrate   = "migrate"
+	archivedUnarchive = "unarchive"
+	archivedSkip      = "skip"
+)
+
+var archivedProjects = kingpin.Flag("archived-projects", "Gitlab projects archived already are migrated as they are (migrate, API calls writing to them fail), unarchived for their migration and archived again afterwards (unarchive) or skipped (skip)").Default(archivedMigrate).Enum(archivedMigrate, archivedUnarchive, archivedSkip)
+
+// skipArchivedProjects drops archived projects with --archived-projects=skip, they are in the report with the reason
+func skipArchivedProjects(projects []project) []project {
+	if *archivedProjects != archivedSkip {
+		return projects
+	}
+	var selected []project
+	for _, project := range projects {
+		if !project.gitlabProject.Archived {
+			selected = append(selected, project)
+			continue
+		}
+		report.project(project.gitlabProject.PathWithNamespace, project.AzdoProject).problem("project is archived in gitlab and skipped (--archived-projects=%s)", archivedSkip)
+	}
+	return selected
+}
+
+// unarchiveForMigration unarchives archived gitlab project with --archived-projects=unarchive, GitHub repositories
+// are migrated as they are
+func unarchiveForMigration(project project) error {
+	if *archivedProjects != archivedUnarchive || !project.gitlabProject.Archived {
+		return nil
+	}
+	if project.gitlab == nil {
+		project.report.problem("archived repository is migrated as it is, only gitlab projects can be unarchived")
+		return nil
+	}
+	log.Infof("unarchiving gitlab project %s for its migration", project.gitlabProject.PathWithNamespace)
+	if _, _, err := project.gitlab.client.Projects.UnarchiveProject(project.GitlabID); err != nil {
+		return fmt.Errorf("cannot unarchive gitlab project %s, migrate it with --archived-projects=%s: %w", project.gitlabProject.PathWithNamespace, archivedMigrate, err)
+	}
+	audit.record("gitlabProject.unarchive", project.AzdoProject, strconv.Itoa(project.GitlabID), map[string]interface{}{
+		"gitlabPath": project.gitlabProject.PathWithNamespace,
+	})
+	return nil
+}
+
+// mustRearchive tells whether the project unarchived for its migration is archived again, archive post action of
+// migrated project did it already
+func mustRearchive(project project, migrated bool) bool {
+	if *archivedProjects != archivedUnarchive || !project.gitlabProject.Archived || project.gitlab == nil {
+		return false
+	}
+	return !migrated || project.PostAction != postActionArchive
+}
+
+// rearchiveProject archives the project unarchived for its migration whether the migration succeeded or not
+func rearchiveProject(project project, migrated bool) {
+	if !mustRearchive(project, migrated) {
+		return
+	}
+	if err := archiveProject(project); err != nil {
+		project.report.problem("cannot archive gitlab project again, it was unarchived for the migration: %s", err)
+	}
+}
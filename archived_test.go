@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestSkipArchivedProjects(t *testing.T) {
+	projects := []project{
+		{AzdoProject: "Apps", gitlabProject: &gitlab.Project{PathWithNamespace: "group/app"}},
+		{AzdoProject: "Apps", gitlabProject: &gitlab.Project{PathWithNamespace: "group/old", Archived: true}},
+	}
+	if diff := deep.Equal(skipArchivedProjects(projects), projects); diff != nil {
+		t.Errorf("archived projects should be migrated by default: %+v", diff)
+	}
+
+	*archivedProjects = archivedSkip
+	defer func() { *archivedProjects = "" }()
+	previous := report
+	report = &runReport{}
+	defer func() { report = previous }()
+	if diff := deep.Equal(skipArchivedProjects(projects), projects[:1]); diff != nil {
+		t.Error(diff)
+	}
+	if len(report.Projects) != 1 || report.Projects[0].GitlabPath != "group/old" || len(report.Projects[0].Problems) != 1 {
+		t.Errorf("skipped project should be reported: %+v", report.Projects)
+	}
+}
+
+func TestMustRearchive(t *testing.T) {
+	*archivedProjects = archivedUnarchive
+	defer func() { *archivedProjects = "" }()
+	archived := &gitlab.Project{Archived: true}
+	instance := &gitlabInstance{}
+	tests := []struct {
+		label    string
+		project  project
+		migrated bool
+		expected bool
+	}{
+		{"active project", project{gitlab: instance, gitlabProject: &gitlab.Project{}}, true, false},
+		{"migrated project", project{gitlab: instance, gitlabProject: archived}, true, true},
+		{"failed project", project{gitlab: instance, gitlabProject: archived}, false, true},
+		{"archived by post action", project{gitlab: instance, gitlabProject: archived, PostAction: postActionArchive}, true, false},
+		{"post action of failed project", project{gitlab: instance, gitlabProject: archived, PostAction: postActionArchive}, false, true},
+		{"GitHub repository", project{gitlabProject: archived, source: &githubSource{}}, true, false},
+	}
+	for _, test := range tests {
+		if actual := mustRearchive(test.project, test.migrated); actual != test.expected {
+			t.Errorf("%s: expected %t", test.label, test.expected)
+		}
+	}
+}
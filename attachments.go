@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strconv"
+)
+
+var (
+	attachOriginal = kingpin.Flag("attach-original", "Attach gitlab merge request and discussions JSON to migrated pull requests for audit").Default("false").Bool()
+)
+
+// attachOriginalMergeRequest keeps the merge request as gitlab returned it, so that fields the translation drops can
+// be recovered later. Merge requests of sources which keep no original are not attached
+func attachOriginalMergeRequest(azdoCtx context.Context, azdoClient TargetClient, source SourceClient, project project, pullRequest *git.GitPullRequest, mr *MergeRequest) {
+	if !*attachOriginal {
+		return
+	}
+	if *anonymizeAuthors {
+		log.Debugf("original merge request %d is not attached, it would reveal anonymized authors", mr.IID)
+		return
+	}
+	if mr.Original == nil {
+		log.Debugf("original merge request %d is not attached, only gitlab merge requests are", mr.IID)
+		return
+	}
+	discussions, err := source.ListDiscussions(mr)
+	if err != nil {
+		project.report.problem("cannot attach discussions of merge request %d: %s", mr.IID, err)
+	}
+	attachments := map[string]interface{}{
+		fmt.Sprintf("gitlab-merge-request-%d.json", mr.IID): mr.Original,
+	}
+	if err == nil {
+		originals := []interface{}{}
+		for _, discussion := range discussions {
+			originals = append(originals, discussion.Original)
+		}
+		attachments[fmt.Sprintf("gitlab-discussions-%d.json", mr.IID)] = originals
+	}
+	for name, content := range attachments {
+		if err := attachJSON(azdoCtx, azdoClient, project, pullRequest, name, content); err != nil {
+			project.report.problem("cannot attach %s to pull request %d: %s", name, *pullRequest.PullRequestId, err)
+		}
+	}
+}
+
+func attachJSON(azdoCtx context.Context, azdoClient TargetClient, project project, pullRequest *git.GitPullRequest, name string, content interface{}) error {
+	encoded, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return err
+	}
+	return attachFile(azdoCtx, azdoClient, project.AzdoProject, pullRequest, name, encoded)
+}
+
+func attachFile(azdoCtx context.Context, azdoClient TargetClient, azdoProject string, pullRequest *git.GitPullRequest, name string, content []byte) error {
+	attachment, err := azdoClient.CreateAttachment(azdoCtx, git.CreateAttachmentArgs{
+		UploadStream:  bytes.NewReader(content),
+		FileName:      &name,
+		RepositoryId:  gitlab.String(pullRequest.Repository.Id.String()),
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       &azdoProject,
+	})
+	if err != nil {
+		return err
+	}
+	audit.record("attachment.create", azdoProject, strconv.Itoa(*attachment.Id), map[string]interface{}{
+		"pullRequestId": *pullRequest.PullRequestId,
+		"fileName":      name,
+	})
+	return nil
+}
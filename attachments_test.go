@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestCommentsFailed(t *testing.T) {
+	project := &projectReport{}
+	project.commentsFailed(7, fmt.Errorf("connection reset by peer"))
+	if diff := deep.Equal(project.FailedComments, []int{7}); diff != nil {
+		t.Error(diff)
+	}
+	if len(project.Problems) != 1 || project.Failed {
+		t.Errorf("failed comments should be a problem of the migrated project: %+v", project)
+	}
+	var outside *projectReport
+	outside.commentsFailed(7, fmt.Errorf("connection reset by peer"))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	auditLogFile = kingpin.Flag("audit-log", "Append-only JSONL file recording every write done in AzDO").Default("").String()
+	audit        = &auditLog{}
+)
+
+type auditEntry struct {
+	Time        time.Time              `json:"time"`
+	Action      string                 `json:"action"`
+	AzdoProject string                 `json:"azdoProject"`
+	ID          string                 `json:"id"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// auditLog appends one JSON line per AzDO write, it does nothing until opened
+type auditLog struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return &auditLog{}, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (a *auditLog) record(action string, azdoProject string, id string, details map[string]interface{}) {
+	if a.file == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	err := a.encoder.Encode(auditEntry{
+		Time:        time.Now().UTC(),
+		Action:      action,
+		AzdoProject: azdoProject,
+		ID:          id,
+		Details:     details,
+	})
+	if err != nil {
+		log.Errorf("cannot write audit log entry %s %s: %s", action, id, err)
+	}
+}
+
+func (a *auditLog) close() {
+	if a.file != nil {
+		a.file.Close()
+	}
+}
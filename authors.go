@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+	"sort"
+)
+
+// authorStats counts merge requests and comments a gitlab user authored in the project
+type authorStats struct {
+	MergeRequests int `json:"mergeRequests"`
+	Comments      int `json:"comments"`
+}
+
+// authored counts merge requests and comments of the user once they are migrated
+func (p *projectReport) authored(user *User, mergeRequests int, comments int) {
+	if p == nil || user == nil || user.Username == "" {
+		return
+	}
+	p.encountered(user)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.Authors == nil {
+		p.Authors = map[string]*authorStats{}
+	}
+	stats, ok := p.Authors[user.Username]
+	if !ok {
+		stats = &authorStats{}
+		p.Authors[user.Username] = stats
+	}
+	stats.MergeRequests += mergeRequests
+	stats.Comments += comments
+}
+
+// encountered notes users the identity map does not resolve, the list is what --identity-map lacks and who needs
+// AzDO license
+func (p *projectReport) encountered(user *User) {
+	if p == nil || user == nil || user.Username == "" {
+		return
+	}
+	if _, ok := identities.resolve(user); ok {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	index := sort.SearchStrings(p.UnmappedUsers, user.Username)
+	if index < len(p.UnmappedUsers) && p.UnmappedUsers[index] == user.Username {
+		return
+	}
+	p.UnmappedUsers = append(p.UnmappedUsers, "")
+	copy(p.UnmappedUsers[index+1:], p.UnmappedUsers[index:])
+	p.UnmappedUsers[index] = user.Username
+}
+
+// noteAuthor is the user of the note, notes carry the author in a struct of their own
+func noteAuthor(note *Note) *User {
+	return &User{ID: note.Author.ID, Username: note.Author.Username, Name: note.Author.Name}
+}
+
+// summarizeAuthors logs users of all projects, a user authoring in several projects is counted once
+func (r *runReport) summarizeAuthors() {
+	authors, unmapped := map[string]bool{}, map[string]bool{}
+	for _, project := range r.Projects {
+		for username := range project.Authors {
+			authors[username] = true
+		}
+		for _, username := range project.UnmappedUsers {
+			unmapped[username] = true
+		}
+	}
+	if len(authors) > 0 {
+		log.Infof("%d gitlab users authored migrated merge requests or comments", len(authors))
+	}
+	if len(unmapped) > 0 {
+		log.Warnf("%d gitlab users have no AzDO identity, see unmappedUsers of --report-file to complete --identity-map", len(unmapped))
+	}
+}
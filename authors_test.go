@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestAuthored(t *testing.T) {
+	identities = &identityMap{resolved: map[string]string{"alice": "alice-id", "carol": ""}}
+	defer func() { identities = &identityMap{} }()
+	project := &projectReport{GitlabPath: "group/php"}
+	project.authored(&User{Username: "alice"}, 1, 0)
+	project.authored(&User{Username: "carol"}, 0, 1)
+	project.authored(&User{Username: "alice"}, 0, 2)
+	project.authored(&User{Username: "bob"}, 1, 0)
+	project.encountered(&User{Username: "dave"})
+	project.encountered(&User{Username: "bob"})
+	project.authored(nil, 1, 0)
+
+	expected := map[string]*authorStats{
+		"alice": {MergeRequests: 1, Comments: 2},
+		"bob":   {MergeRequests: 1},
+		"carol": {Comments: 1},
+	}
+	if diff := deep.Equal(project.Authors, expected); diff != nil {
+		t.Errorf("authors: %+v", diff)
+	}
+	if diff := deep.Equal(project.UnmappedUsers, []string{"bob", "carol", "dave"}); diff != nil {
+		t.Errorf("unmapped users: %+v", diff)
+	}
+}
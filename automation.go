@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// envarPrefix prefixes environment variables every flag can be given by, e.g. MIGRATION_GITLAB_TOKEN
+	envarPrefix = "MIGRATION_"
+	// exit codes of --non-interactive runs, fatal errors (bad flags, config, tokens) exit with 1
+	exitFailedProjects = 2
+	exitProblems       = 3
+)
+
+var (
+	nonInteractive = kingpin.Flag("non-interactive", "Run unattended (CI pipeline, Kubernetes job) - never prompt, log JSON and exit with code 2 when a project failed or 3 when projects have problems").Default("false").Bool()
+	envarInvalid   = regexp.MustCompile(`[^A-Z0-9]+`)
+)
+
+// bindEnvars lets every flag of the application and its commands be given by environment variable, so that a container
+// can be configured without command line
+func bindEnvars(app *kingpin.Application) {
+	model := app.Model()
+	for _, flag := range model.Flags {
+		bindEnvar(app.GetFlag(flag.Name), flag.Name)
+	}
+	for _, command := range model.Commands {
+		for _, flag := range command.Flags {
+			bindEnvar(app.GetCommand(command.Name).GetFlag(flag.Name), flag.Name)
+		}
+	}
+}
+
+func bindEnvar(flag *kingpin.FlagClause, name string) {
+	if flag == nil || name == "help" || name == "version" {
+		return
+	}
+	flag.Envar(envarName(name))
+}
+
+func envarName(flag string) string {
+	return envarPrefix + strings.Trim(envarInvalid.ReplaceAllString(strings.ToUpper(flag), "_"), "_")
+}
+
+// initNonInteractive switches logs to JSON unless another format was chosen and rejects options which prompt
+func initNonInteractive() error {
+	if !*nonInteractive {
+		return nil
+	}
+	if format := kingpin.CommandLine.GetFlag("log.format"); format != nil && format.Model().Value.String() == "logger:stderr" {
+		if err := log.Base().SetFormat("logger:stderr?json=true"); err != nil {
+			return err
+		}
+	}
+	if *sampleSize > 0 {
+		return fmt.Errorf("--sample asks for confirmation and cannot be used with --non-interactive")
+	}
+	return nil
+}
+
+// exitCode tells automation running the migration how it went, failed projects win over problems
+func exitCode(r *runReport) int {
+	code := 0
+	for _, project := range r.Projects {
+		switch {
+		case project.Failed:
+			return exitFailedProjects
+		case len(project.Problems) > 0:
+			code = exitProblems
+		}
+	}
+	return code
+}
+
+// exitNonInteractive ends unattended run with exit code of the result
+func exitNonInteractive() {
+	if !*nonInteractive {
+		return
+	}
+	if code := exitCode(report); code != 0 {
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestEnvarName(t *testing.T) {
+	names := map[string]string{
+		"gitlab-token": "MIGRATION_GITLAB_TOKEN",
+		"log.format":   "MIGRATION_LOG_FORMAT",
+		"azdo-org":     "MIGRATION_AZDO_ORG",
+	}
+	for flag, expect := range names {
+		if diff := deep.Equal(envarName(flag), expect); diff != nil {
+			t.Errorf("%s: %+v", flag, diff)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	reports := []struct {
+		label  string
+		report *runReport
+		expect int
+	}{
+		{"no projects", &runReport{}, 0},
+		{"migrated", &runReport{Projects: []*projectReport{{}}}, 0},
+		{"problems", &runReport{Projects: []*projectReport{{Problems: []string{"missing branch"}}, {}}}, exitProblems},
+		{"failed", &runReport{Projects: []*projectReport{{Problems: []string{"missing branch"}}, {Failed: true}}}, exitFailedProjects},
+	}
+	for _, r := range reports {
+		if diff := deep.Equal(exitCode(r.report), r.expect); diff != nil {
+			t.Errorf("%s: %+v", r.label, diff)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strconv"
+)
+
+var (
+	backlinkMergeRequests = kingpin.Flag("backlink-merge-requests", "Comment on every migrated gitlab merge request with link to its AzDO pull request").Default("false").Bool()
+	closeMergeRequests    = kingpin.Flag("close-merge-requests", "Close migrated open gitlab merge requests (after the backlink comment)").Default("false").Bool()
+)
+
+func prepareBacklinkNote(mr mergeRequestMapping) string {
+	return fmt.Sprintf("This merge request was migrated to Azure DevOps, the discussion continues in [pull request %d](%s).", mr.PullRequestID, mr.AzdoURL)
+}
+
+// linkMergeRequests lets people following old links or notifications find the migrated pull request, it runs
+// before postAction as archived project cannot be commented
+func linkMergeRequests(project project, mapping projectMapping) {
+	if !*backlinkMergeRequests && !*closeMergeRequests || project.gitlab == nil {
+		return
+	}
+	client := project.gitlab.client
+	for _, mr := range mapping.MergeRequests {
+		//linked by the run which migrated it
+		if mr.existing {
+			continue
+		}
+		if *backlinkMergeRequests {
+			note, _, err := client.Notes.CreateMergeRequestNote(project.GitlabID, mr.IID, &gitlab.CreateMergeRequestNoteOptions{
+				Body: gitlab.String(prepareBacklinkNote(mr)),
+			})
+			if err != nil {
+				project.report.problem("cannot comment merge request %d with link to pull request %d: %s", mr.IID, mr.PullRequestID, err)
+				continue
+			}
+			audit.record("gitlabNote.create", project.AzdoProject, strconv.Itoa(note.ID), map[string]interface{}{
+				"mergeRequestUrl": mr.GitlabURL,
+				"pullRequestId":   mr.PullRequestID,
+			})
+		}
+		if *closeMergeRequests && mr.State == "opened" {
+			log.Debugf("closing merge request %d", mr.IID)
+			_, _, err := client.MergeRequests.UpdateMergeRequest(project.GitlabID, mr.IID, &gitlab.UpdateMergeRequestOptions{
+				StateEvent: gitlab.String("close"),
+			})
+			if err != nil {
+				project.report.problem("cannot close merge request %d: %s", mr.IID, err)
+				continue
+			}
+			audit.record("gitlabMergeRequest.close", project.AzdoProject, strconv.Itoa(mr.IID), map[string]interface{}{
+				"mergeRequestUrl": mr.GitlabURL,
+				"pullRequestId":   mr.PullRequestID,
+			})
+		}
+	}
+}
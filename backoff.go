@@ -0,0 +1,126 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// azdoThrottleRetries is how many times a throttled AzDO request is repeated before the response is returned
+	azdoThrottleRetries = 5
+	// azdoDefaultRetryAfter is the pause after a throttled response without Retry-After
+	azdoDefaultRetryAfter = 30 * time.Second
+)
+
+var throttledMetric = newCounterVec("throttled_total", "API responses throttled by the server (429 or Retry-After)", "host")
+
+// throttlingStats summarizes how much the server slowed the run down
+type throttlingStats struct {
+	Throttled int    `json:"throttled"`
+	Delayed   int    `json:"delayed"`
+	Paused    string `json:"paused"`
+
+	paused time.Duration
+}
+
+// backoffTransport honors AzDO throttling - 429 and Retry-After responses pause every request of the client, not only
+// the throttled one, and throttled requests are repeated once the pause is over
+type backoffTransport struct {
+	base        http.RoundTripper
+	mutex       sync.Mutex
+	pausedUntil time.Time
+}
+
+func (t *backoffTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(request); err != nil {
+			return nil, err
+		}
+		attemptRequest := request
+		if attempt > 0 && request.Body != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest = request.Clone(request.Context())
+			attemptRequest.Body = body
+		}
+		response, err := t.base.RoundTrip(attemptRequest)
+		if err != nil {
+			return nil, err
+		}
+		//AzDO delays requests of users consuming too many TSTUs before it starts to reject them
+		if response.Header.Get("X-RateLimit-Delay") != "" {
+			report.throttle(false, 0)
+		}
+		delay, throttled := retryAfter(response, time.Now())
+		if delay > 0 {
+			t.pause(delay)
+			report.throttle(throttled, delay)
+		}
+		if !throttled {
+			return response, nil
+		}
+		throttledMetric.WithLabelValues(request.URL.Host).Inc()
+		replayable := request.Body == nil || request.GetBody != nil
+		if attempt >= azdoThrottleRetries || !replayable {
+			return response, nil
+		}
+		log.Warnf("AzDO throttled %s %s, retrying in %s", request.Method, request.URL.Path, delay)
+		retriesMetric.WithLabelValues(request.URL.Host).Inc()
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+}
+
+func (t *backoffTransport) wait(request *http.Request) error {
+	t.mutex.Lock()
+	wait := time.Until(t.pausedUntil)
+	t.mutex.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-request.Context().Done():
+		return request.Context().Err()
+	}
+}
+
+func (t *backoffTransport) pause(delay time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if until := time.Now().Add(delay); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// retryAfter returns how long requests should pause and whether the response was rejected, AzDO sends Retry-After
+// with successful responses as well once the user is close to the limit
+func retryAfter(response *http.Response, now time.Time) (time.Duration, bool) {
+	throttled := response.StatusCode == http.StatusTooManyRequests
+	header := response.Header.Get("Retry-After")
+	if header == "" {
+		if throttled {
+			return azdoDefaultRetryAfter, true
+		}
+		return 0, false
+	}
+	throttled = throttled || response.StatusCode == http.StatusServiceUnavailable
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, throttled
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now), throttled
+	}
+	if throttled {
+		return azdoDefaultRetryAfter, true
+	}
+	return 0, false
+}
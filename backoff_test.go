@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffTransport(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+	defer func() { report = &runReport{} }()
+
+	client := &http.Client{Transport: &backoffTransport{base: http.DefaultTransport}}
+	response, err := client.Post(server.URL, "application/json", strings.NewReader(`{"title": "Foo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || string(body) != `{"title": "Foo"}` || attempts != 2 {
+		t.Errorf("throttled request should be repeated with its body, got %d %s after %d attempts", response.StatusCode, body, attempts)
+	}
+
+	now := time.Unix(1000, 0)
+	retry, throttled := retryAfter(&http.Response{StatusCode: http.StatusOK, Header: http.Header{"Retry-After": {"5"}}}, now)
+	if retry != 5*time.Second || throttled {
+		t.Errorf("successful response with Retry-After should only pause, got %s %v", retry, throttled)
+	}
+	retry, throttled = retryAfter(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}, now)
+	if retry != azdoDefaultRetryAfter || !throttled {
+		t.Errorf("429 without Retry-After should pause for the default, got %s %v", retry, throttled)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/build"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// gitlabBadge is gitlab pipeline or coverage badge image URL, groups are project URL, branch and badge kind
+const gitlabBadge = `\s*(https?://[^\s)]+?)(?:/-)?/badges/([^\s)]+)/(pipeline|coverage)\.svg[^\s)]*\s*`
+
+var (
+	fixupBadges = kingpin.Flag("fixup-badges", "After all projects are migrated, rewrite gitlab pipeline badges in README.md of migrated repositories to the badge of Azure Pipeline of the repository, coverage badges and badges of repositories without pipeline are removed").Default("false").Bool()
+	// linkedBadge is badge image wrapped in a link, plainBadge is image alone
+	linkedBadge = regexp.MustCompile(`\[!\[([^\]]*)\]\(` + gitlabBadge + `\)\]\([^)]*\)`)
+	plainBadge  = regexp.MustCompile(`!\[([^\]]*)\]\(` + gitlabBadge + `\)`)
+	readmeFiles = []string{"/README.md", "/readme.md", "/Readme.md"}
+)
+
+// pipelineBadge is Azure Pipeline gitlab pipeline badges are rewritten to, without one they are removed
+type pipelineBadge struct {
+	projectURL   string
+	definitionID int
+}
+
+// rewriteBadges replaces badges of the gitlab projects, badges of other projects are kept. It returns the content
+// with rewritten and removed badges
+func rewriteBadges(content string, gitlabURLs map[string]bool, pipeline *pipelineBadge) (string, int, []string) {
+	rewritten := 0
+	var removed []string
+	replace := func(badge string, alt string, match []string) string {
+		projectURL, branch, kind := match[0], match[1], match[2]
+		if !gitlabURLs[normalizeRepositoryURL(projectURL)] {
+			return badge
+		}
+		if kind != "pipeline" || pipeline == nil {
+			removed = append(removed, kind)
+			return ""
+		}
+		rewritten++
+		branch = url.QueryEscape(branch)
+		return fmt.Sprintf("[![%s](%s/_apis/build/status/%d?branchName=%s)](%s/_build/latest?definitionId=%d&branchName=%s)",
+			alt, pipeline.projectURL, pipeline.definitionID, branch, pipeline.projectURL, pipeline.definitionID, branch)
+	}
+	content = linkedBadge.ReplaceAllStringFunc(content, func(badge string) string {
+		match := linkedBadge.FindStringSubmatch(badge)
+		return replace(badge, match[1], match[2:])
+	})
+	content = plainBadge.ReplaceAllStringFunc(content, func(badge string) string {
+		match := plainBadge.FindStringSubmatch(badge)
+		return replace(badge, match[1], match[2:])
+	})
+	return content, rewritten, removed
+}
+
+// fixupRepositoryBadges is a second pass like submodules, pipelines of the repositories may be set up after the
+// repository is migrated and the pass can be repeated then
+func fixupRepositoryBadges(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoClient git.Client, mapping migrationMapping) {
+	buildClient, err := build.NewClient(azdoCtx, azdoConnection)
+	if err != nil {
+		log.Errorf("badges are not fixed: %s", err)
+		return
+	}
+	//projects combined into one repository share the README
+	repositories := map[string][]projectMapping{}
+	var order []string
+	for _, project := range mapping.Projects {
+		if _, ok := repositories[project.AzdoRepositoryID]; !ok {
+			order = append(order, project.AzdoRepositoryID)
+		}
+		repositories[project.AzdoRepositoryID] = append(repositories[project.AzdoRepositoryID], project)
+	}
+	for _, repositoryID := range order {
+		projects := repositories[repositoryID]
+		if err := fixupReadmeBadges(azdoCtx, buildClient, azdoClient, projects); err != nil {
+			log.Errorf("cannot fix badges of repository %s: %s", projects[0].AzdoRepositoryName, err)
+		}
+	}
+}
+
+func fixupReadmeBadges(azdoCtx context.Context, buildClient build.Client, azdoClient git.Client, projects []projectMapping) error {
+	project := projects[0]
+	repository, err := azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{
+		RepositoryId: &project.AzdoRepositoryID,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		return err
+	}
+	if repository.DefaultBranch == nil || *repository.DefaultBranch == "" {
+		return nil
+	}
+	branch := strings.TrimPrefix(*repository.DefaultBranch, "refs/heads/")
+	var item *git.GitItem
+	for _, path := range readmeFiles {
+		item, err = azdoClient.GetItem(azdoCtx, git.GetItemArgs{
+			RepositoryId:      &project.AzdoRepositoryID,
+			Path:              &path,
+			Project:           &project.AzdoProject,
+			IncludeContent:    gitlab.Bool(true),
+			VersionDescriptor: &git.GitVersionDescriptor{Version: &branch, VersionType: &git.GitVersionTypeValues.Branch},
+		})
+		if !isNotFound(err) {
+			break
+		}
+	}
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read README: %s", err)
+	}
+	if item.Content == nil {
+		return nil
+	}
+	pipeline, err := findPipelineBadge(azdoCtx, buildClient, project)
+	if err != nil {
+		return fmt.Errorf("cannot list pipelines: %s", err)
+	}
+	gitlabURLs := map[string]bool{}
+	for _, project := range projects {
+		gitlabURLs[normalizeRepositoryURL(project.GitlabURL)] = true
+	}
+	content, rewritten, removed := rewriteBadges(*item.Content, gitlabURLs, pipeline)
+	if rewritten == 0 && len(removed) == 0 {
+		return nil
+	}
+	if len(removed) > 0 {
+		log.Warnf("repository %s: %s badges are removed from %s, Azure Pipelines have no equivalent", project.AzdoRepositoryName, strings.Join(removed, ", "), *item.Path)
+	}
+	head, err := azdoClient.GetBranch(azdoCtx, git.GetBranchArgs{
+		RepositoryId: &project.AzdoRepositoryID,
+		Name:         &branch,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read head of %s: %s", branch, err)
+	}
+	log.Infof("rewriting %d and removing %d badges of repository %s", rewritten, len(removed), project.AzdoRepositoryName)
+	push, err := azdoClient.CreatePush(azdoCtx, git.CreatePushArgs{
+		Push: &git.GitPush{
+			RefUpdates: &[]git.GitRefUpdate{{Name: repository.DefaultBranch, OldObjectId: head.Commit.CommitId}},
+			Commits: &[]git.GitCommitRef{{
+				Comment: gitlab.String(prepareBadgeCommitMessage(rewritten, removed)),
+				Changes: &[]interface{}{git.GitChange{
+					ChangeType: &git.VersionControlChangeTypeValues.Edit,
+					Item:       git.GitItem{Path: item.Path},
+					NewContent: &git.ItemContent{Content: &content, ContentType: &git.ItemContentTypeValues.RawText},
+				}},
+			}},
+		},
+		RepositoryId: &project.AzdoRepositoryID,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		return err
+	}
+	audit.record("repository.push", project.AzdoProject, project.AzdoRepositoryID, map[string]interface{}{
+		"pushId":    *push.PushId,
+		"branch":    branch,
+		"rewritten": rewritten,
+		"removed":   len(removed),
+	})
+	return nil
+}
+
+// findPipelineBadge returns the first pipeline of the repository by name, repositories without one get no badge
+func findPipelineBadge(azdoCtx context.Context, buildClient build.Client, project projectMapping) (*pipelineBadge, error) {
+	definitions, err := buildClient.GetDefinitions(azdoCtx, build.GetDefinitionsArgs{
+		Project:        &project.AzdoProject,
+		RepositoryId:   &project.AzdoRepositoryID,
+		RepositoryType: gitlab.String("TfsGit"),
+		QueryOrder:     &build.DefinitionQueryOrderValues.DefinitionNameAscending,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(definitions.Value) == 0 || definitions.Value[0].Id == nil {
+		return nil, nil
+	}
+	return &pipelineBadge{
+		projectURL:   strings.TrimSuffix(*azdoOrganization, "/") + "/" + url.PathEscape(project.AzdoProject),
+		definitionID: *definitions.Value[0].Id,
+	}, nil
+}
+
+// prepareBadgeCommitMessage notes removed badges so that the README history tells why they are gone
+func prepareBadgeCommitMessage(rewritten int, removed []string) string {
+	message := "Point badges to Azure Pipelines"
+	if rewritten == 0 {
+		message = "Remove gitlab badges"
+	}
+	if len(removed) > 0 {
+		message += "\n\nRemoved " + strings.Join(removed, ", ") + " badges of gitlab, Azure Pipelines have no equivalent of them or the repository has no pipeline yet."
+	}
+	return message
+}
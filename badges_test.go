@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestRewriteBadges(t *testing.T) {
+	content := `# Shop
+[![pipeline status](https://gitlab.com/drmax/shop/badges/main/pipeline.svg)](https://gitlab.com/drmax/shop/-/commits/main) ![coverage report](https://gitlab.com/drmax/shop/-/badges/main/coverage.svg?job=test)
+![pipeline](https://gitlab.com/drmax/lib/badges/main/pipeline.svg)
+`
+	gitlabURLs := map[string]bool{normalizeRepositoryURL("https://gitlab.com/drmax/shop"): true}
+	tests := []struct {
+		label     string
+		pipeline  *pipelineBadge
+		expected  string
+		rewritten int
+		removed   []string
+	}{
+		{"pipeline", &pipelineBadge{projectURL: "https://dev.azure.com/drmax/Web", definitionID: 12}, `# Shop
+[![pipeline status](https://dev.azure.com/drmax/Web/_apis/build/status/12?branchName=main)](https://dev.azure.com/drmax/Web/_build/latest?definitionId=12&branchName=main) 
+![pipeline](https://gitlab.com/drmax/lib/badges/main/pipeline.svg)
+`, 1, []string{"coverage"}},
+		{"no pipeline", nil, `# Shop
+ 
+![pipeline](https://gitlab.com/drmax/lib/badges/main/pipeline.svg)
+`, 0, []string{"pipeline", "coverage"}},
+	}
+	for _, test := range tests {
+		actual, rewritten, removed := rewriteBadges(content, gitlabURLs, test.pipeline)
+		if diff := deep.Equal([]interface{}{actual, rewritten, removed}, []interface{}{test.expected, test.rewritten, test.removed}); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/work"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// defaultAzdoBoard is the backlog board of the Agile process
+const defaultAzdoBoard = "Stories"
+
+var (
+	migrateBoards = kingpin.Flag("migrate-boards", "Configure columns and card styles of the AzDO board (azdoBoard of the project) from label lists of the first gitlab issue board").Default("false").Bool()
+	// cardRulesLocation is the board card rules API, SDK drops settings (colors) of the rules
+	cardRulesLocation = uuid.MustParse("b044a3d9-02ea-49c7-91a1-b730949cc896")
+)
+
+// cardRuleSettings are style rules of board cards by rule type (fill, tagStyle)
+type cardRuleSettings struct {
+	Rules map[string][]cardRule `json:"rules"`
+}
+
+type cardRule struct {
+	Name      string              `json:"name"`
+	IsEnabled string              `json:"isEnabled"`
+	Filter    string              `json:"filter,omitempty"`
+	Clauses   []work.FilterClause `json:"clauses,omitempty"`
+	Settings  map[string]string   `json:"settings"`
+}
+
+// migrateBoard adds label lists of gitlab board to in-progress columns of the board, incoming and outgoing columns
+// stay as AzDO requires them. Cards tagged by a list label get its color
+func migrateBoard(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project) {
+	if !*migrateBoards || project.gitlab == nil {
+		return
+	}
+	boards, _, err := project.gitlab.client.Boards.ListIssueBoards(project.gitlabProject.ID, nil)
+	if err != nil {
+		project.report.problem("cannot list issue boards, board is not migrated: %s", err)
+		return
+	}
+	if len(boards) == 0 {
+		return
+	}
+	if len(boards) > 1 {
+		project.report.problem("only the first of %d issue boards (%s) is migrated", len(boards), boards[0].Name)
+	}
+	lists := labelLists(boards[0])
+	if len(lists) == 0 {
+		return
+	}
+	board := project.AzdoBoard
+	if board == "" {
+		board = defaultAzdoBoard
+	}
+	workClient, err := work.NewClient(azdoCtx, azdoConnection)
+	if err != nil {
+		project.report.problem("board is not migrated: %s", err)
+		return
+	}
+	existing, err := workClient.GetBoardColumns(azdoCtx, work.GetBoardColumnsArgs{Project: &project.AzdoProject, Board: &board})
+	if err != nil {
+		project.report.problem("cannot read columns of board %s: %s", board, err)
+		return
+	}
+	columns := prepareBoardColumns(lists, *existing)
+	if _, err := workClient.UpdateBoardColumns(azdoCtx, work.UpdateBoardColumnsArgs{BoardColumns: &columns, Project: &project.AzdoProject, Board: &board}); err != nil {
+		project.report.problem("cannot update columns of board %s: %s", board, err)
+		return
+	}
+	audit.record("board.columns", project.AzdoProject, board, map[string]interface{}{
+		"columns": len(columns),
+	})
+	if err := updateCardRules(azdoCtx, azdoConnection, project.AzdoProject, board, lists); err != nil {
+		project.report.problem("cannot update card styles of board %s: %s", board, err)
+		return
+	}
+	log.Debugf("board %s configured from %d label lists of issue board %s", board, len(lists), boards[0].Name)
+}
+
+// labelLists returns lists of the board by their position, lists of assignees or milestones have no AzDO counterpart
+func labelLists(board *gitlab.IssueBoard) []*gitlab.BoardList {
+	var lists []*gitlab.BoardList
+	for _, list := range board.Lists {
+		if list.Label == nil {
+			log.Debugf("list %d of issue board %s is not a label list, it is not migrated", list.ID, board.Name)
+			continue
+		}
+		lists = append(lists, list)
+	}
+	sort.SliceStable(lists, func(i, j int) bool {
+		return lists[i].Position < lists[j].Position
+	})
+	return lists
+}
+
+// prepareBoardColumns adds a column of every list after in-progress columns of the board, in-progress columns named
+// like a list are updated in place and others are kept as they are so that their cards stay. New columns map to
+// states of the first in-progress column or of the incoming one
+func prepareBoardColumns(lists []*gitlab.BoardList, existing []work.BoardColumn) []work.BoardColumn {
+	var incoming, outgoing, inProgress []work.BoardColumn
+	for _, column := range existing {
+		switch *column.ColumnType {
+		case work.BoardColumnTypeValues.Incoming:
+			incoming = append(incoming, column)
+		case work.BoardColumnTypeValues.Outgoing:
+			outgoing = append(outgoing, column)
+		default:
+			inProgress = append(inProgress, column)
+		}
+	}
+	var stateMappings *map[string]string
+	if len(inProgress) > 0 {
+		stateMappings = inProgress[0].StateMappings
+	} else if len(incoming) > 0 {
+		stateMappings = incoming[0].StateMappings
+	}
+	kept := map[string]int{}
+	for i, column := range inProgress {
+		kept[*column.Name] = i
+	}
+	for _, list := range lists {
+		i, ok := kept[list.Label.Name]
+		if !ok {
+			name := list.Label.Name
+			inProgress = append(inProgress, work.BoardColumn{
+				Name:          &name,
+				ColumnType:    &work.BoardColumnTypeValues.InProgress,
+				ItemLimit:     gitlab.Int(0),
+				IsSplit:       gitlab.Bool(false),
+				StateMappings: stateMappings,
+			})
+			i = len(inProgress) - 1
+			kept[name] = i
+		}
+		if list.Label.Description != "" {
+			description := list.Label.Description
+			inProgress[i].Description = &description
+		}
+	}
+	columns := append(incoming, inProgress...)
+	return append(columns, outgoing...)
+}
+
+func updateCardRules(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoProject string, board string, lists []*gitlab.BoardList) error {
+	client, err := azdoConnection.GetClientByResourceAreaId(azdoCtx, work.ResourceAreaId)
+	if err != nil {
+		return err
+	}
+	routeValues := map[string]string{"project": azdoProject, "board": board}
+	response, err := client.Send(azdoCtx, http.MethodGet, cardRulesLocation, "5.1", routeValues, nil, nil, "", "application/json", nil)
+	if err != nil {
+		return err
+	}
+	existing := cardRuleSettings{}
+	if err := client.UnmarshalBody(response, &existing); err != nil {
+		return err
+	}
+	body, err := json.Marshal(prepareCardRules(lists, existing))
+	if err != nil {
+		return err
+	}
+	if _, err := client.Send(azdoCtx, http.MethodPatch, cardRulesLocation, "5.1", routeValues, nil, bytes.NewReader(body), "application/json", "application/json", nil); err != nil {
+		return err
+	}
+	audit.record("board.cardRules", azdoProject, board, map[string]interface{}{
+		"labels": len(lists),
+	})
+	return nil
+}
+
+// prepareCardRules fills cards and colors tags of list labels like gitlab colors the labels, other rules of the board
+// are kept
+func prepareCardRules(lists []*gitlab.BoardList, existing cardRuleSettings) cardRuleSettings {
+	labels := map[string]bool{}
+	for _, list := range lists {
+		labels[list.Label.Name] = true
+	}
+	rules := map[string][]cardRule{}
+	for ruleType, existingRules := range existing.Rules {
+		for _, rule := range existingRules {
+			if !labels[rule.Name] {
+				rules[ruleType] = append(rules[ruleType], rule)
+			}
+		}
+	}
+	for _, list := range lists {
+		label := list.Label
+		rules["fill"] = append(rules["fill"], cardRule{
+			Name:      label.Name,
+			IsEnabled: "true",
+			Filter:    fmt.Sprintf("System.Tags CONTAINS '%s'", strings.ReplaceAll(label.Name, "'", "''")),
+			Clauses: []work.FilterClause{{
+				FieldName:       gitlab.String("System.Tags"),
+				Index:           gitlab.Int(1),
+				LogicalOperator: gitlab.String(""),
+				Operator:        gitlab.String("CONTAINS"),
+				Value:           gitlab.String(label.Name),
+			}},
+			Settings: map[string]string{"background-color": label.Color, "title-color": label.TextColor},
+		})
+		rules["tagStyle"] = append(rules["tagStyle"], cardRule{
+			Name:      label.Name,
+			IsEnabled: "true",
+			Settings:  map[string]string{"background-color": label.Color, "color": label.TextColor},
+		})
+	}
+	return cardRuleSettings{Rules: rules}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/work"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func setupBoardLists() []*gitlab.BoardList {
+	board := &gitlab.IssueBoard{Name: "Development", Lists: []*gitlab.BoardList{
+		{ID: 3, Position: 1, Label: &gitlab.Label{Name: "Review", Color: "#428bca", TextColor: "#FFFFFF"}},
+		{ID: 1, Position: 2},
+		{ID: 2, Position: 0, Label: &gitlab.Label{Name: "Doing", Color: "#ff0000", TextColor: "#FFFFFF", Description: "Work in progress"}},
+	}}
+	return labelLists(board)
+}
+
+func TestPrepareBoardColumns(t *testing.T) {
+	active := map[string]string{"User Story": "Active"}
+	doingID := uuid.New()
+	existing := []work.BoardColumn{
+		{Name: gitlab.String("New"), ColumnType: &work.BoardColumnTypeValues.Incoming},
+		{Name: gitlab.String("Active"), ColumnType: &work.BoardColumnTypeValues.InProgress, StateMappings: &active},
+		{Id: &doingID, Name: gitlab.String("Doing"), ColumnType: &work.BoardColumnTypeValues.InProgress, StateMappings: &active},
+		{Name: gitlab.String("Closed"), ColumnType: &work.BoardColumnTypeValues.Outgoing},
+	}
+	var actual []interface{}
+	for _, column := range prepareBoardColumns(setupBoardLists(), existing) {
+		actual = append(actual, *column.Name, column.Id != nil)
+		if column.Description != nil {
+			actual = append(actual, *column.Description)
+		}
+		if *column.ColumnType == work.BoardColumnTypeValues.InProgress && (*column.StateMappings)["User Story"] != "Active" {
+			t.Errorf("column %s should map to Active state", *column.Name)
+		}
+	}
+	expected := []interface{}{"New", false, "Active", false, "Doing", true, "Work in progress", "Review", false, "Closed", false}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrepareCardRules(t *testing.T) {
+	existing := cardRuleSettings{Rules: map[string][]cardRule{
+		"fill": {{Name: "Blocked", IsEnabled: "true"}, {Name: "Doing", IsEnabled: "false"}},
+	}}
+	rules := prepareCardRules(setupBoardLists(), existing)
+	var fill, tagStyle []interface{}
+	for _, rule := range rules.Rules["fill"] {
+		fill = append(fill, rule.Name, rule.IsEnabled, rule.Settings["background-color"])
+	}
+	for _, rule := range rules.Rules["tagStyle"] {
+		tagStyle = append(tagStyle, rule.Name, rule.Settings["color"])
+	}
+	expected := []interface{}{
+		[]interface{}{"Blocked", "true", "", "Doing", "true", "#ff0000", "Review", "true", "#428bca"},
+		[]interface{}{"Doing", "#FFFFFF", "Review", "#FFFFFF"},
+	}
+	if diff := deep.Equal([]interface{}{fill, tagStyle}, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	quoted := []*gitlab.BoardList{{Label: &gitlab.Label{Name: "Won't fix"}}}
+	rule := prepareCardRules(quoted, cardRuleSettings{}).Rules["fill"][0]
+	if diff := deep.Equal([]string{rule.Filter, *rule.Clauses[0].Value}, []string{"System.Tags CONTAINS 'Won''t fix'", "Won't fix"}); diff != nil {
+		t.Error(diff)
+	}
+}
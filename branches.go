@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	restoreSourceBranches = kingpin.Flag("restore-source-branches", "Recreate deleted merge request source branches in AzDO from gitlab merge request head ref (requires git)").Default("false").Bool()
+	forkBranchPrefix      = kingpin.Flag("fork-branch-prefix", "Namespace for branches of merge requests coming from forks, they are pushed as <prefix>/<user>/<branch> (requires git)").Default("fork").String()
+)
+
+// prepareSourceBranch returns branch the pull request is created from, branches of forks are not part of the imported
+// repository so they get their own namespace to avoid clashes with branches of the same name
+func prepareSourceBranch(mr *MergeRequest) (string, bool) {
+	if mr.SourceProjectID == mr.TargetProjectID {
+		return mr.SourceBranch, false
+	}
+	return fmt.Sprintf("%s/%s/%s", *forkBranchPrefix, prepareUserSlug(mr.Author.Username), mr.SourceBranch), true
+}
+
+// ensureBranches makes sure both pull request branches exist in AzDO repository, missing source branch can be
+// recreated from refs/merge-requests/<iid>/head which gitlab keeps even when the branch is gone. Branches of
+// repositories with rewritten history are not recreated, their gitlab commits would bring back stripped files and have
+// history unrelated to the repository
+func ensureBranches(azdoCtx context.Context, azdoClient TargetClient, project project, mr *MergeRequest, repository *git.GitRepository, sourceBranch string, fork bool) error {
+	exists, err := branchExists(azdoCtx, azdoClient, repository, mr.TargetBranch)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("target branch %s does not exist in the repository", mr.TargetBranch)
+	}
+
+	exists, err = branchExists(azdoCtx, azdoClient, repository, sourceBranch)
+	if err != nil || exists {
+		return err
+	}
+	if !fork && !*restoreSourceBranches {
+		return fmt.Errorf("source branch %s does not exist in the repository, use --restore-source-branches to recreate it", sourceBranch)
+	}
+	if rewritesHistory(project) {
+		return fmt.Errorf("source branch %s does not exist in the repository, it is not recreated from gitlab as history of the repository is rewritten", sourceBranch)
+	}
+	//the source keeps head of merge requests from forks in the target project as well
+	log.Infof("restoring source branch %s of merge request %d", sourceBranch, mr.IID)
+	err = pushSourceRef(project.gitlabProject.HTTPURLToRepo, project.source, project.source.HeadRef(mr), *repository.RemoteUrl, sourceBranch)
+	if err != nil {
+		return fmt.Errorf("cannot restore source branch %s: %s", sourceBranch, err)
+	}
+	audit.record("ref.create", *repository.Project.Name, "refs/heads/"+sourceBranch, map[string]interface{}{
+		"repositoryId":    repository.Id.String(),
+		"mergeRequestIid": mr.IID,
+	})
+	return nil
+}
+
+func branchExists(azdoCtx context.Context, azdoClient TargetClient, repository *git.GitRepository, branch string) (bool, error) {
+	refs, err := azdoClient.GetRefs(azdoCtx, git.GetRefsArgs{
+		RepositoryId: gitlab.String(repository.Id.String()),
+		Project:      repository.Project.Name,
+		Filter:       gitlab.String("heads/" + branch),
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot list branches: %s", err)
+	}
+	//filter matches prefix only, feature/a would match feature/ab as well
+	for _, ref := range refs.Value {
+		if *ref.Name == "refs/heads/"+branch {
+			return true, nil
+		}
+	}
+	return false, nil
+}
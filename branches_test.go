@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"github.com/go-test/deep"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"strings"
+	"testing"
+)
+
+func TestPrepareSourceBranch(t *testing.T) {
+	*forkBranchPrefix = "fork"
+	mr := setupOpenMergeRequest()
+	mr.SourceProjectID, mr.TargetProjectID = 1, 1
+	if branch, fork := prepareSourceBranch(&mr); fork || branch != "develop" {
+		t.Errorf("same project merge request: %s %t", branch, fork)
+	}
+
+	mr.SourceProjectID = 2
+	branch, fork := prepareSourceBranch(&mr)
+	if diff := deep.Equal("fork/john-doe/develop", branch); diff != nil || !fork {
+		t.Errorf("fork merge request: %+v", diff)
+	}
+}
+
+func TestEnsureBranchesOfRewrittenHistory(t *testing.T) {
+	*restoreSourceBranches = true
+	defer func() { *restoreSourceBranches = false }()
+	mr := setupOpenMergeRequest()
+	mr.TargetBranch = "master"
+	id := uuid.New()
+	repository := &git.GitRepository{Id: &id, Project: &core.TeamProjectReference{Name: gitlab.String("Apps")}}
+	project := project{Prefix: "api", gitlabProject: &gitlab.Project{HTTPURLToRepo: "https://gitlab.com/group/api.git"}}
+	//pushing gitlab ref would fail without git remote, the error has to come before it
+	err := ensureBranches(context.Background(), &stubTarget{branches: []string{"master"}}, project, &mr, repository, "develop", false)
+	if err == nil || !strings.Contains(err.Error(), "history of the repository is rewritten") {
+		t.Errorf("source branch of rewritten history should not be restored, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"time"
+)
+
+var bulkImport = kingpin.Flag("bulk-import", "Number of import requests running at once, merge requests of projects are migrated in config order once their imports finish. 0 imports repositories one by one").Default("0").Int()
+
+// pendingImport is an import request AzDO transfers the repository by, service endpoint is removed once it finishes
+type pendingImport struct {
+	project        project
+	repository     *git.GitRepository
+	request        *git.GitImportRequest
+	removeEndpoint func()
+	// done and err are result of the import waiting for imports of projects configured before it
+	done bool
+	err  error
+}
+
+// poll tells whether the import request finished, failed request is returned as an error
+func (p *pendingImport) poll(azdoCtx context.Context, azdoClient git.Client) (bool, error) {
+	current, err := azdoClient.GetImportRequest(azdoCtx, git.GetImportRequestArgs{
+		Project:         &p.project.AzdoProject,
+		RepositoryId:    gitlab.String(p.repository.Id.String()),
+		ImportRequestId: p.request.ImportRequestId,
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot check import request of %s: %w", p.project.gitlabProject.PathWithNamespace, err)
+	}
+	if current == nil || *current.Status == git.GitAsyncOperationStatusValues.Completed {
+		log.Debugf("import of %s finished", p.project.gitlabProject.PathWithNamespace)
+		return true, nil
+	}
+	if *current.Status == git.GitAsyncOperationStatusValues.Abandoned {
+		return false, fmt.Errorf("import request of %s abandoned", p.project.gitlabProject.PathWithNamespace)
+	}
+	if *current.Status == git.GitAsyncOperationStatusValues.Failed {
+		message := ""
+		if current.DetailedStatus != nil && current.DetailedStatus.ErrorMessage != nil {
+			message = *current.DetailedStatus.ErrorMessage
+		}
+		return false, fmt.Errorf("import request of %s failed: %s", p.project.gitlabProject.PathWithNamespace, message)
+	}
+	return false, nil
+}
+
+// migrateInBulk keeps up to --bulk-import import requests running and polls them together, the rest of the project is
+// migrated once its import and the projects configured before it finish, so that the run creates the same AzDO
+// artifacts in the same order as one by one migration does
+func migrateInBulk(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoClient git.Client, projects []project, complete func(project, *projectMapping)) {
+	var pending []*pendingImport
+	next := 0
+	//declined sample lets transfers started already finish, their merge requests are not migrated
+	for (next < len(projects) && !sample.isDeclined()) || len(pending) > 0 {
+		for ; next < len(projects) && len(pending) < *bulkImport && !sample.isDeclined(); next++ {
+			project := projects[next]
+			log.Infof("processing project %s (%d/%d)", project.gitlabProject.PathWithNamespace, next+1, len(projects))
+			project.report = report.project(project.gitlabProject.PathWithNamespace, project.AzdoProject)
+			transfer := &pendingImport{project: project, done: true}
+			if err := checkRepositoryLimits(project); err != nil {
+				transfer.err = fmt.Errorf("cannot migrate %s: %w", project.gitlabProject.PathWithNamespace, err)
+				pending = append(pending, transfer)
+				continue
+			}
+			if err := unarchiveForMigration(project); err != nil {
+				transfer.err = err
+				pending = append(pending, transfer)
+				continue
+			}
+			repository, started := startRepositoryTransfer(azdoCtx, azdoConnection, project, project.gitlabProject, azdoClient)
+			switch {
+			case repository == nil:
+				//the failure is logged already, the project is completed in its turn
+			case started == nil:
+				//mirrored and reused repositories are transferred already
+				transfer.repository = repository
+			default:
+				transfer = started
+			}
+			pending = append(pending, transfer)
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		log.Debugf("waiting for %d imports to finish", len(pending))
+		progressed := false
+		for _, transfer := range pending {
+			if transfer.done {
+				continue
+			}
+			done, err := transfer.poll(azdoCtx, azdoClient)
+			if !done && err == nil {
+				continue
+			}
+			transfer.removeEndpoint()
+			transfer.done, transfer.err = true, err
+			progressed = true
+		}
+		finished := 0
+		for ; finished < len(pending) && pending[finished].done; finished++ {
+			transfer := pending[finished]
+			if transfer.err != nil {
+				transfer.project.report.failWith(transfer.err)
+			}
+			if transfer.err != nil || transfer.repository == nil {
+				complete(transfer.project, nil)
+				continue
+			}
+			project := transfer.project
+			complete(project, finishProject(azdoCtx, azdoConnection, project, azdoClient, transfer.repository))
+		}
+		if finished == 0 && !progressed {
+			time.Sleep(importPollPeriod)
+		}
+		pending = pending[finished:]
+	}
+}
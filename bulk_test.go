@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+// importStatusClient answers import request status from the list, other methods of git.Client are not expected
+type importStatusClient struct {
+	git.Client
+	statuses []*git.GitImportRequest
+	err      error
+}
+
+func (c *importStatusClient) GetImportRequest(ctx context.Context, args git.GetImportRequestArgs) (*git.GitImportRequest, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	status := c.statuses[0]
+	c.statuses = c.statuses[1:]
+	return status, nil
+}
+
+func TestPollImport(t *testing.T) {
+	id := uuid.New()
+	pending := &pendingImport{
+		project:    project{AzdoProject: "Apps", gitlabProject: &gitlab.Project{PathWithNamespace: "group/app"}},
+		repository: &git.GitRepository{Id: &id},
+		request:    &git.GitImportRequest{ImportRequestId: gitlab.Int(1)},
+	}
+	importRequest := func(status git.GitAsyncOperationStatus, message string) *git.GitImportRequest {
+		request := &git.GitImportRequest{Status: &status}
+		if message != "" {
+			request.DetailedStatus = &git.GitImportStatusDetail{ErrorMessage: &message}
+		}
+		return request
+	}
+
+	polls := []struct {
+		label  string
+		client *importStatusClient
+		done   bool
+		err    string
+	}{
+		{"in progress", &importStatusClient{statuses: []*git.GitImportRequest{importRequest(git.GitAsyncOperationStatusValues.InProgress, "")}}, false, ""},
+		{"completed", &importStatusClient{statuses: []*git.GitImportRequest{importRequest(git.GitAsyncOperationStatusValues.Completed, "")}}, true, ""},
+		{"failed", &importStatusClient{statuses: []*git.GitImportRequest{importRequest(git.GitAsyncOperationStatusValues.Failed, "empty repository")}}, false, "import request of group/app failed: empty repository"},
+		{"abandoned", &importStatusClient{statuses: []*git.GitImportRequest{importRequest(git.GitAsyncOperationStatusValues.Abandoned, "")}}, false, "import request of group/app abandoned"},
+		{"unreachable", &importStatusClient{err: fmt.Errorf("connection refused")}, false, "cannot check import request of group/app: connection refused"},
+	}
+	for _, poll := range polls {
+		done, err := pending.poll(context.Background(), poll.client)
+		message := ""
+		if err != nil {
+			message = err.Error()
+		}
+		if diff := deep.Equal([]interface{}{done, message}, []interface{}{poll.done, poll.err}); diff != nil {
+			t.Errorf("%s: %+v", poll.label, diff)
+		}
+	}
+}
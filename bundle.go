@@ -0,0 +1,121 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// exportBundle is the repository in gitlab project export archive
+	exportBundle = "project.bundle"
+	// exportTimeout is how long an export may stay unfinished, gitlab drops exports stuck in its queue
+	exportTimeout = 6 * time.Hour
+)
+
+// fetchBundle fetches branches and tags of the gitlab project from its export, the repository gets into AzDO without
+// AzDO or git of the operator reaching gitlab, only API of gitlab is used
+func fetchBundle(project project, repository *localRepository) error {
+	client := project.gitlab.client
+	archive := filepath.Join(repository.dir, "export.tar.gz")
+	if err := exportProject(client, project, archive); err != nil {
+		return err
+	}
+	bundle := filepath.Join(repository.dir, exportBundle)
+	if err := extractBundle(archive, bundle); err != nil {
+		return err
+	}
+	defer os.Remove(bundle)
+	os.Remove(archive)
+	_, err := repository.run("fetch", "--quiet", bundle, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	return err
+}
+
+// exportProject schedules export of the project and downloads the archive once gitlab finishes it, the archive is
+// streamed into the file as it can be as large as the repository
+func exportProject(client *gitlab.Client, project project, archive string) error {
+	projectID := project.gitlabProject.ID
+	log.Debugf("exporting gitlab project %s", project.gitlabProject.PathWithNamespace)
+	if _, err := client.ProjectImportExport.ScheduleExport(projectID, nil); err != nil {
+		return fmt.Errorf("cannot export gitlab project, the export needs maintainer role: %w", err)
+	}
+	audit.record("gitlabProject.export", project.AzdoProject, strconv.Itoa(projectID), map[string]interface{}{
+		"gitlabPath": project.gitlabProject.PathWithNamespace,
+	})
+	deadline := time.Now().Add(exportTimeout)
+	for {
+		status, _, err := client.ProjectImportExport.ExportStatus(projectID)
+		if err != nil {
+			return fmt.Errorf("cannot check export of gitlab project: %w", err)
+		}
+		if status.ExportStatus == "finished" {
+			break
+		}
+		if status.ExportStatus == "failed" {
+			return fmt.Errorf("export of gitlab project failed: %s", status.Message)
+		}
+		if time.Now().After(deadline) {
+			return classified(failureTransient, fmt.Errorf("export of gitlab project is %s after %s", status.ExportStatus, exportTimeout))
+		}
+		log.Debugf("export of gitlab project %d is %s", projectID, status.ExportStatus)
+		time.Sleep(importPollPeriod)
+	}
+
+	file, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	request, err := client.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/export/download", projectID), nil, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := client.Do(request, file); err != nil {
+		return fmt.Errorf("cannot download export of gitlab project: %w", err)
+	}
+	return file.Close()
+}
+
+// extractBundle writes the repository bundle of the export archive into the file, empty repositories are exported
+// without one
+func extractBundle(archive string, bundle string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("invalid export archive: %s", err)
+	}
+	entries := tar.NewReader(compressed)
+	for {
+		header, err := entries.Next()
+		if err == io.EOF {
+			return classified(failureValidation, fmt.Errorf("export has no %s, the repository is empty", exportBundle))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid export archive: %s", err)
+		}
+		if filepath.Clean(header.Name) != exportBundle {
+			continue
+		}
+		target, err := os.Create(bundle)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(target, entries); err != nil {
+			target.Close()
+			return err
+		}
+		return target.Close()
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupExportArchive(t *testing.T, files map[string]string) []byte {
+	var archive bytes.Buffer
+	compressed := gzip.NewWriter(&archive)
+	entries := tar.NewWriter(compressed)
+	for name, content := range files {
+		if err := entries.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		entries.Write([]byte(content))
+	}
+	entries.Close()
+	compressed.Close()
+	return archive.Bytes()
+}
+
+func TestExportBundle(t *testing.T) {
+	archive := setupExportArchive(t, map[string]string{"tree/project.json": "{}", "./project.bundle": "# v2 git bundle"})
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//the client probes the API root for its rate limit
+		if r.URL.Path == "/api/v4/" {
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v4/projects/7/export":
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			w.Write([]byte(`{"id":7,"export_status":"finished"}`))
+		case "/api/v4/projects/7/export/download":
+			w.Write(archive)
+		}
+	}))
+	defer server.Close()
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := project{AzdoProject: "Apps", gitlabProject: &gitlab.Project{ID: 7, PathWithNamespace: "group/app"}}
+	if err := exportProject(client, project, filepath.Join(dir, "export.tar.gz")); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"POST /api/v4/projects/7/export", "GET /api/v4/projects/7/export", "GET /api/v4/projects/7/export/download"}
+	if diff := deep.Equal(requests, expected); diff != nil {
+		t.Error(diff)
+	}
+	bundle := filepath.Join(dir, exportBundle)
+	if err := extractBundle(filepath.Join(dir, "export.tar.gz"), bundle); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(bundle); string(content) != "# v2 git bundle" {
+		t.Errorf("unexpected bundle %q", content)
+	}
+
+	empty := filepath.Join(dir, "empty.tar.gz")
+	ioutil.WriteFile(empty, setupExportArchive(t, map[string]string{"tree/project.json": "{}"}), 0644)
+	if err := extractBundle(empty, bundle); failureClass(err) != failureValidation {
+		t.Errorf("export of empty repository should fail validation, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"sync"
+)
+
+var (
+	cleanupFailed = kingpin.Flag("cleanup-failed", "Delete AzDO repository created by the run when migration of its merge requests stops as credentials are rejected (e.g. token revoked mid-run) and mark the project to be retried from scratch in the report and --retry-file, repositories the run did not create are kept").Default("false").Bool()
+	// createdRepositories are IDs of repositories reinitAzdoRepository created during the run, only they may be deleted
+	createdRepositories      = map[string]bool{}
+	createdRepositoriesMutex sync.Mutex
+)
+
+// rememberCreatedRepository marks the repository as created by the run
+func rememberCreatedRepository(repository *git.GitRepository) {
+	createdRepositoriesMutex.Lock()
+	defer createdRepositoriesMutex.Unlock()
+	createdRepositories[repository.Id.String()] = true
+}
+
+func createdByRun(repository *git.GitRepository) bool {
+	createdRepositoriesMutex.Lock()
+	defer createdRepositoriesMutex.Unlock()
+	return repository.Id != nil && createdRepositories[repository.Id.String()]
+}
+
+// rejectsCredentials tells whether the API refused credentials of the request, forbidden requests lack permissions
+// and do not mean the token is gone
+func rejectsCredentials(err error) bool {
+	return failureStatus(err) == http.StatusUnauthorized
+}
+
+// abandonRepository deletes repository whose merge requests were migrated only partially as migration of merge
+// requests stopped with rejected credentials, the next run migrates the project from scratch instead of needing
+// --recreate-repo. Abandoned project has to fail even when the repository cannot be deleted
+func abandonRepository(azdoCtx context.Context, azdoClient git.Client, project project, repository *git.GitRepository, failure error) bool {
+	if !*cleanupFailed || failure == nil || !rejectsCredentials(failure) {
+		return false
+	}
+	//repositories found, continued or shared by other projects are not deleted, mirrored ones are pushed by the run
+	//again
+	if !createdByRun(repository) || !phaseSelected(phaseRepo) || *reuseRepository || project.Prefix != "" {
+		return false
+	}
+	project.report.retryFromScratch()
+	err := azdoClient.DeleteRepository(azdoCtx, git.DeleteRepositoryArgs{
+		RepositoryId: repository.Id,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		project.report.problem("credentials were rejected while merge requests were migrated and half-migrated repository %s cannot be deleted, migrate it again with --recreate-repo: %s", *repository.Name, err)
+		return true
+	}
+	audit.record("repository.delete", project.AzdoProject, repository.Id.String(), map[string]interface{}{
+		"name": *repository.Name,
+	})
+	project.report.problem("credentials were rejected while merge requests were migrated (%s), repository %s is deleted to be migrated from scratch", failure, *repository.Name)
+	return true
+}
+
+// retryFromScratch marks project whose half-migrated repository was abandoned
+func (p *projectReport) retryFromScratch() {
+	if p == nil {
+		log.Warn("project has to be migrated again from scratch")
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.RetryFromScratch = true
+	p.FailureClass = failureAuth
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"testing"
+)
+
+// deletingClient records deleted repositories, other methods of git.Client are not expected
+type deletingClient struct {
+	git.Client
+	deleted []uuid.UUID
+	err     error
+}
+
+func (c *deletingClient) DeleteRepository(ctx context.Context, args git.DeleteRepositoryArgs) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.deleted = append(c.deleted, *args.RepositoryId)
+	return nil
+}
+
+func TestAbandonRepository(t *testing.T) {
+	*cleanupFailed = true
+	defer func() { *cleanupFailed = false }()
+	*transferMode = transferImport
+	defer func() { *transferMode = "" }()
+	id := uuid.New()
+	repository := &git.GitRepository{Id: &id, Name: gitlab.String("app")}
+	unauthorized := fmt.Errorf("cannot create pull request: %w", azuredevops.WrappedError{StatusCode: gitlab.Int(http.StatusUnauthorized)})
+	forbidden := azuredevops.WrappedError{StatusCode: gitlab.Int(http.StatusForbidden)}
+
+	client := &deletingClient{}
+	project := project{AzdoProject: "Apps", report: &projectReport{}}
+	if abandonRepository(context.Background(), client, project, repository, unauthorized) {
+		t.Error("repository the run did not create should be kept")
+	}
+	rememberCreatedRepository(repository)
+	if abandonRepository(context.Background(), client, project, repository, nil) {
+		t.Error("repository should be kept when merge requests did not fail")
+	}
+	if abandonRepository(context.Background(), client, project, repository, forbidden) {
+		t.Error("forbidden response should not abandon the repository")
+	}
+	selectedPhases = map[string]bool{phaseMRs: true}
+	if abandonRepository(context.Background(), client, project, repository, unauthorized) {
+		t.Error("repository of an earlier run should be kept when the repo phase is skipped")
+	}
+	selectedPhases = nil
+
+	if !abandonRepository(context.Background(), client, project, repository, unauthorized) {
+		t.Error("repository should be abandoned once credentials were rejected")
+	}
+	*transferMode = transferMirror
+	if !abandonRepository(context.Background(), client, project, repository, unauthorized) {
+		t.Error("mirrored repository should be abandoned as well")
+	}
+	*transferMode = transferImport
+	if diff := deep.Equal(client.deleted, []uuid.UUID{id, id}); diff != nil {
+		t.Error(diff)
+	}
+	if !project.report.RetryFromScratch {
+		t.Error("project should be marked to be retried from scratch")
+	}
+
+	shared := project
+	shared.Prefix = "app"
+	if abandonRepository(context.Background(), client, shared, repository, unauthorized) {
+		t.Error("shared repository should be kept")
+	}
+	failing := &deletingClient{err: fmt.Errorf("unauthorized")}
+	undeleted := project
+	undeleted.report = &projectReport{}
+	if !abandonRepository(context.Background(), failing, undeleted, repository, unauthorized) {
+		t.Error("project should be abandoned even when its repository cannot be deleted")
+	}
+	if !undeleted.report.RetryFromScratch || len(undeleted.report.Problems) != 1 {
+		t.Errorf("repository which cannot be deleted should be reported: %+v", undeleted.report.Problems)
+	}
+}
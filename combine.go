@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// prefixIndexFilter moves every file of every commit under the prefix kept in a file inside of the rewritten
+// repository, commits without files leave no new index behind
+const prefixIndexFilter = `git ls-files -s | sed "s#\t\"*#&$(cat "$GIT_DIR/combine-prefix")/#" | GIT_INDEX_FILE=$GIT_INDEX_FILE.new git update-index --index-info && if [ -f "$GIT_INDEX_FILE.new" ]; then mv "$GIT_INDEX_FILE.new" "$GIT_INDEX_FILE"; fi`
+
+var (
+	prefixMatcher = regexp.MustCompile(`^[\w-][\w.-]*(/[\w-][\w.-]*)*$`)
+	// combinedRepositories keeps repositories created in this run for projects combined into them
+	combinedRepositories = map[string]*git.GitRepository{}
+	combinedMutex        sync.Mutex
+	// combineIdentity authors merge commits of combined repositories, local git may have no identity configured
+	combineIdentity = []string{
+		"GIT_AUTHOR_NAME=gitlab-azdo-migration", "GIT_AUTHOR_EMAIL=gitlab-azdo-migration@localhost",
+		"GIT_COMMITTER_NAME=gitlab-azdo-migration", "GIT_COMMITTER_EMAIL=gitlab-azdo-migration@localhost",
+	}
+)
+
+// validatePrefix checks configuration of a project combined into shared repository
+func validatePrefix(project project) error {
+	if project.Prefix == "" {
+		return nil
+	}
+	if project.AzdoRepository == "" {
+		return fmt.Errorf("prefix %s needs azdoRepository the project is combined into", project.Prefix)
+	}
+	if !prefixMatcher.MatchString(project.Prefix) {
+		return fmt.Errorf("prefix %s must be a relative path of letters, digits, '.', '_' and '-', segments cannot start with '.'", project.Prefix)
+	}
+	return nil
+}
+
+// combinedRepository returns repository created earlier in this run for another project combined into it
+func combinedRepository(project project) *git.GitRepository {
+	combinedMutex.Lock()
+	defer combinedMutex.Unlock()
+	return combinedRepositories[project.AzdoProject+"/"+project.azdoRepositoryName()]
+}
+
+func rememberCombinedRepository(project project, repository *git.GitRepository) {
+	if project.Prefix == "" {
+		return
+	}
+	combinedMutex.Lock()
+	defer combinedMutex.Unlock()
+	combinedRepositories[project.AzdoProject+"/"+project.azdoRepositoryName()] = repository
+}
+
+// moveUnderPrefix rewrites history of the local repository so that all files live in the prefix directory
+func moveUnderPrefix(repository *localRepository, project project) error {
+	if project.Prefix == "" {
+		return nil
+	}
+	if err := ioutil.WriteFile(filepath.Join(repository.dir, "combine-prefix"), []byte(project.Prefix+"\n"), 0644); err != nil {
+		return err
+	}
+	log.Infof("moving history of %s under %s/", project.gitlabProject.PathWithNamespace, project.Prefix)
+	return repository.rewriteHistory("--index-filter", prefixIndexFilter)
+}
+
+// prepareRefspecs returns what gets pushed into AzDO and the default branch, combined projects push branches and
+// tags under their prefix and their default branch is merged into the default branch of the combined repository
+func prepareRefspecs(repository *localRepository, project project, azdoRepository *git.GitRepository, target string) ([]string, string, error) {
+	if project.Prefix == "" {
+		return []string{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}, project.gitlabProject.DefaultBranch, nil
+	}
+	refspecs := []string{
+		fmt.Sprintf("refs/heads/*:refs/heads/%s/*", project.Prefix),
+		fmt.Sprintf("refs/tags/*:refs/tags/%s/*", project.Prefix),
+	}
+	defaultBranch := project.gitlabProject.DefaultBranch
+	if azdoRepository.DefaultBranch != nil && *azdoRepository.DefaultBranch != "" {
+		defaultBranch = strings.TrimPrefix(*azdoRepository.DefaultBranch, "refs/heads/")
+	}
+	if project.gitlabProject.DefaultBranch == "" || defaultBranch == "" {
+		return refspecs, defaultBranch, nil
+	}
+	head, err := repository.run("rev-parse", "--verify", "refs/heads/"+project.gitlabProject.DefaultBranch)
+	if err != nil {
+		return nil, "", err
+	}
+	combined, err := mergeIntoCombined(repository, project, target, defaultBranch, head)
+	if err != nil {
+		return nil, "", err
+	}
+	return append(refspecs, fmt.Sprintf("%s:refs/heads/%s", combined, defaultBranch)), defaultBranch, nil
+}
+
+// mergeIntoCombined creates merge commit of default branch of the combined repository and the project head, trees
+// do not overlap so the merged tree is just both of them side by side
+func mergeIntoCombined(repository *localRepository, project project, target string, defaultBranch string, head string) (string, error) {
+	remoteBranch, err := repository.run("ls-remote", target, "refs/heads/"+defaultBranch)
+	if err != nil {
+		return "", err
+	}
+	if remoteBranch == "" {
+		//the first project of the combined repository
+		return head, nil
+	}
+	if _, err := repository.run("fetch", "--quiet", target, "+refs/heads/"+defaultBranch+":refs/combined/base"); err != nil {
+		return "", err
+	}
+	//read-tree --prefix refuses to run without work tree even though it does not touch it
+	workTree := filepath.Join(repository.dir, "combine-worktree")
+	if err := os.Mkdir(workTree, 0755); err != nil {
+		return "", err
+	}
+	env := append([]string{"GIT_INDEX_FILE=" + filepath.Join(repository.dir, "combine-index"), "GIT_WORK_TREE=" + workTree}, combineIdentity...)
+	if _, err := repository.runWith(env, "read-tree", "refs/combined/base"); err != nil {
+		return "", err
+	}
+	if _, err := repository.runWith(env, "read-tree", "--prefix="+project.Prefix+"/", head+":"+project.Prefix); err != nil {
+		return "", fmt.Errorf("cannot combine into %s, is the prefix used by another project? %s", project.Prefix, err)
+	}
+	tree, err := repository.runWith(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	message := fmt.Sprintf("Combine %s into %s/", project.gitlabProject.PathWithNamespace, project.Prefix)
+	return repository.runWith(env, "commit-tree", tree, "-p", "refs/combined/base", "-p", head, "-m", message)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	if err := validatePrefix(project{Prefix: "libs/a", AzdoRepository: "platform"}); err != nil {
+		t.Error(err)
+	}
+	if err := validatePrefix(project{Prefix: "libs/a"}); err == nil {
+		t.Error("prefix without azdoRepository should be refused")
+	}
+	if err := validatePrefix(project{Prefix: "../a", AzdoRepository: "platform"}); err == nil {
+		t.Error("prefix outside of the repository should be refused")
+	}
+	expect := map[string]string{"libs/a/main": "c1", "libs/a/feature/x": "c2"}
+	if diff := deep.Equal(prefixRefs(map[string]string{"main": "c1", "feature/x": "c2"}, "libs/a"), expect); diff != nil {
+		t.Error(diff)
+	}
+}
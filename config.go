@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type config struct {
+	GitlabInstances map[string]*gitlabInstance `json:"gitlabInstances"`
+	// Labels are the label mapping of all projects, labels of a project override it label by label
+	Labels   map[string]labelMapping `json:"labels,omitempty"`
+	Projects []project               `json:"projects"`
+}
+
+// gitlabInstance is an additional gitlab server, projects refer to it by name and the default one is configured by
+// --gitlab-url and --gitlab-token flags
+type gitlabInstance struct {
+	URL      string `json:"url"`
+	TokenEnv string `json:"tokenEnv"`
+
+	token    string
+	jobToken string
+	client   *gitlab.Client
+}
+
+type project struct {
+	GitlabID          int                     `json:"gitlabID,omitempty"`
+	GitlabProject     string                  `json:"gitlabProject,omitempty"`
+	AzdoProject       string                  `json:"azdoProject"`
+	MigrateMRs        bool                    `json:"migrateMRs"`
+	GitlabInstance    string                  `json:"gitlabInstance,omitempty"`
+	ExcludeRefs       []string                `json:"excludeRefs,omitempty"`
+	StripPaths        []string                `json:"stripPaths,omitempty"`
+	Subdirectory      string                  `json:"subdirectory,omitempty"`
+	AzdoRepository    string                  `json:"azdoRepository,omitempty"`
+	Prefix            string                  `json:"prefix,omitempty"`
+	PostAction        string                  `json:"postAction,omitempty"`
+	GithubRepository  string                  `json:"githubRepository,omitempty"`
+	NoiseAuthors      []string                `json:"noiseAuthors,omitempty"`
+	NoisePatterns     []string                `json:"noisePatterns,omitempty"`
+	CollapseNoise     bool                    `json:"collapseNoise,omitempty"`
+	WorkItemFields    map[string]string       `json:"workItemFields,omitempty"`
+	AzdoBoard         string                  `json:"azdoBoard,omitempty"`
+	Labels            map[string]labelMapping `json:"labels,omitempty"`
+	AzdoFeed          string                  `json:"azdoFeed,omitempty"`
+	SystemNotes       []string                `json:"systemNotes,omitempty"`
+	ServiceEndpointID string                  `json:"serviceEndpointId,omitempty"`
+
+	gitlab        *gitlabInstance
+	gitlabProject *gitlab.Project
+	report        *projectReport
+	noise         *noiseFilter
+	source        SourceClient
+	origin        configOrigin
+}
+
+// configOrigin is where the project is written in the config, lines of its fields are kept for diagnostics
+type configOrigin struct {
+	file   string
+	line   int
+	fields map[string]int
+}
+
+// gitlabKey identifies the project in gitlab API - either numeric ID or path with namespace
+func (p project) gitlabKey() interface{} {
+	if p.GitlabProject != "" {
+		return p.GitlabProject
+	}
+	return p.GitlabID
+}
+
+// azdoRepositoryName defaults to gitlab project path, a subdirectory split out of a monorepo is named after the
+// directory. Every name starts with --repo-prefix
+func (p project) azdoRepositoryName() string {
+	if p.AzdoRepository != "" {
+		return *repoPrefix + p.AzdoRepository
+	}
+	if p.Subdirectory != "" {
+		return *repoPrefix + path.Base(strings.Trim(p.Subdirectory, "/"))
+	}
+	return *repoPrefix + p.gitlabProject.Path
+}
+
+// configFragment is a config file as written, projects get defaults applied and fragments are merged by loadConfig
+type configFragment struct {
+	Include         []string                   `json:"include"`
+	Defaults        json.RawMessage            `json:"defaults"`
+	GitlabInstances map[string]*gitlabInstance `json:"gitlabInstances"`
+	Labels          map[string]labelMapping    `json:"labels"`
+	Projects        []json.RawMessage          `json:"projects"`
+}
+
+// envReference matches ${VAR} references expanded before the config is parsed
+var envReference = regexp.MustCompile(`\$\{(\w+)\}`)
+
+func readConfig() config {
+	configFile, _, err := loadConfig(*configFile, map[string]bool{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return configFile
+}
+
+// loadConfig reads config file with its includes, projects of included files come first and their defaults apply to
+// projects of the including file as well. Included paths are relative to the including file, - reads standard input
+func loadConfig(file string, loading map[string]bool) (config, []json.RawMessage, error) {
+	loaded := config{GitlabInstances: map[string]*gitlabInstance{}}
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return loaded, nil, err
+	}
+	if loading[absolute] {
+		return loaded, nil, fmt.Errorf("config %s includes itself", file)
+	}
+	loading[absolute] = true
+	defer delete(loading, absolute)
+
+	var content []byte
+	if file == "-" {
+		content, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		content, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return loaded, nil, fmt.Errorf("cannot read config: %s", err)
+	}
+	content, err = expandEnv(content)
+	if err != nil {
+		return loaded, nil, fmt.Errorf("config %s: %s", file, err)
+	}
+	fragment := configFragment{}
+	if err := json.Unmarshal(content, &fragment); err != nil {
+		return loaded, nil, fmt.Errorf("config %s: %s", file, err)
+	}
+
+	var defaults []json.RawMessage
+	for _, include := range fragment.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(file), include)
+		}
+		included, includedDefaults, err := loadConfig(include, loading)
+		if err != nil {
+			return loaded, nil, err
+		}
+		for name, instance := range included.GitlabInstances {
+			loaded.GitlabInstances[name] = instance
+		}
+		loaded.Labels = mergeLabels(loaded.Labels, included.Labels)
+		loaded.Projects = append(loaded.Projects, included.Projects...)
+		defaults = append(defaults, includedDefaults...)
+	}
+	for name, instance := range fragment.GitlabInstances {
+		loaded.GitlabInstances[name] = instance
+	}
+	loaded.Labels = mergeLabels(loaded.Labels, fragment.Labels)
+	if len(fragment.Defaults) > 0 {
+		defaults = append(defaults, fragment.Defaults)
+	}
+	origins := locateProjects(file, content)
+	for i, raw := range fragment.Projects {
+		project := project{}
+		if i < len(origins) {
+			project.origin = origins[i]
+		}
+		//fields present in later JSON override the earlier ones
+		for _, layer := range append(defaults, raw) {
+			if err := json.Unmarshal(layer, &project); err != nil {
+				return loaded, nil, fmt.Errorf("config %s project #%d: %s", file, i+1, err)
+			}
+		}
+		loaded.Projects = append(loaded.Projects, project)
+	}
+	for i := range loaded.Projects {
+		loaded.Projects[i].Labels = mergeLabels(loaded.Labels, loaded.Projects[i].Labels)
+	}
+	return loaded, defaults, nil
+}
+
+// locateProjects finds lines of projects and their fields in the config, expanded environment variables are JSON
+// escaped so they do not shift lines. The config is parsed already so tokens are not checked for errors
+func locateProjects(file string, content []byte) []configOrigin {
+	var origins []configOrigin
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil
+	}
+	for decoder.More() {
+		key, _ := decoder.Token()
+		if key != "projects" {
+			var skipped json.RawMessage
+			if decoder.Decode(&skipped) != nil {
+				return origins
+			}
+			continue
+		}
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return origins
+		}
+		for decoder.More() {
+			offset := decoder.InputOffset()
+			var raw json.RawMessage
+			if decoder.Decode(&raw) != nil {
+				return origins
+			}
+			//the offset is where the previous token ends, the project starts after separators
+			start := offset + int64(bytes.Index(content[offset:], raw))
+			origins = append(origins, configOrigin{file: file, line: lineAt(content, start), fields: locateFields(content, start, raw)})
+		}
+		return origins
+	}
+	return origins
+}
+
+// locateFields returns lines of fields of the JSON object starting at the offset of the content
+func locateFields(content []byte, start int64, object json.RawMessage) map[string]int {
+	fields := map[string]int{}
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fields
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return fields
+		}
+		if name, ok := key.(string); ok {
+			fields[name] = lineAt(content, start+decoder.InputOffset())
+		}
+		var skipped json.RawMessage
+		if decoder.Decode(&skipped) != nil {
+			return fields
+		}
+	}
+	return fields
+}
+
+func lineAt(content []byte, offset int64) int {
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// expandEnv replaces ${VAR} references by JSON escaped values of environment variables, undefined variables are
+// reported rather than silently expanded to empty string
+func expandEnv(content []byte) ([]byte, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllFunc(content, func(reference []byte) []byte {
+		name := string(envReference.FindSubmatch(reference)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return reference
+		}
+		escaped, _ := json.Marshal(value)
+		return escaped[1 : len(escaped)-1]
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("undefined environment variables %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// resolveProjects looks up every selected project in gitlab before migration starts so that typos in paths and
+// missing permissions are reported at once, only selected projects are kept and the number of those which cannot be
+// resolved is returned. Projects excluded by their configuration are dropped without being looked up
+func resolveProjects(defaultInstance *gitlabInstance, config *config, filter projectFilter) int {
+	unresolved := 0
+	for i := range config.Projects {
+		project := &config.Projects[i]
+		if filter.excludesConfigured(*project) {
+			continue
+		}
+		if err := resolveProject(defaultInstance, config.GitlabInstances, project); err != nil && filter.matches(*project) {
+			log.Errorf("project #%d: %s", i+1, err)
+			unresolved++
+		}
+	}
+	var selected []project
+	for _, project := range config.Projects {
+		if !filter.excludesConfigured(project) {
+			selected = append(selected, project)
+		}
+	}
+	config.Projects = filter.apply(selected)
+	return unresolved
+}
+
+func resolveProject(defaultInstance *gitlabInstance, instances map[string]*gitlabInstance, project *project) error {
+	overrideTargetProject(project)
+	if project.GitlabID == 0 && project.GitlabProject == "" && project.GithubRepository == "" {
+		return fmt.Errorf("either gitlabID, gitlabProject or githubRepository is required")
+	}
+	if err := validatePrefix(*project); err != nil {
+		return err
+	}
+	if err := validatePostAction(*project); err != nil {
+		return err
+	}
+	if err := validateWorkItemFields(*project); err != nil {
+		return err
+	}
+	if err := validateSystemNotes(*project); err != nil {
+		return err
+	}
+	if err := validateServiceEndpoint(*project); err != nil {
+		return err
+	}
+	noise, err := newNoiseFilter(*project)
+	if err != nil {
+		return err
+	}
+	project.noise = noise
+	if project.GithubRepository != "" {
+		if project.PostAction != "" && project.PostAction != postActionNone {
+			return fmt.Errorf("postAction is supported only for gitlab projects")
+		}
+		return resolveGithubProject(project)
+	}
+	project.gitlab = defaultInstance
+	if project.GitlabInstance != "" {
+		instance, err := initGitlabInstance(instances, project.GitlabInstance)
+		if err != nil {
+			return err
+		}
+		project.gitlab = instance
+	}
+	gitlabProject, _, err := project.gitlab.client.Projects.GetProject(project.gitlabKey(), &gitlab.GetProjectOptions{Statistics: gitlab.Bool(true)})
+	if err != nil {
+		return fmt.Errorf("couldn't find gitlab project %v does your API key have permission to the project? %s", project.gitlabKey(), err)
+	}
+	project.GitlabID = gitlabProject.ID
+	project.gitlabProject = gitlabProject
+	project.source = newGitlabSource(project.gitlab, gitlabProject)
+	return nil
+}
+
+// initGitlabInstance creates client of the named instance on first use so that unused instances need no token
+func initGitlabInstance(instances map[string]*gitlabInstance, name string) (*gitlabInstance, error) {
+	instance, ok := instances[name]
+	if !ok {
+		return nil, fmt.Errorf("gitlab instance %s is not defined in gitlabInstances", name)
+	}
+	if instance.client != nil {
+		return instance, nil
+	}
+	instance.token = os.Getenv(instance.TokenEnv)
+	if instance.token == "" {
+		return nil, fmt.Errorf("gitlab instance %s has no token, set environment variable from tokenEnv", name)
+	}
+	redactor.add(instance.token)
+	client, err := newGitlabClient(instance.URL, instance.token)
+	if err != nil {
+		return nil, fmt.Errorf("gitlab instance %s: %s", name, err)
+	}
+	instance.client = client
+	return instance, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"shared/defaults.json": `{
+			"defaults": {"azdoProject": "Shared", "migrateMRs": true, "excludeRefs": ["refs/heads/tmp/*"]},
+			"gitlabInstances": {"selfhosted": {"url": "${TEST_CONFIG_GITLAB}", "tokenEnv": "TOKEN"}},
+			"labels": {"bug": {"tag": "Bug"}, "team::payments": {"areaPath": "Shop"}}
+		}`,
+		"shared/legacy.json": `{"projects": [{"gitlabID": 1, "azdoProject": "Legacy"}]}`,
+		"wave.json": `{
+			"include": ["shared/defaults.json", "shared/legacy.json"],
+			"projects": [
+				{"gitlabProject": "group/app"},
+				{"gitlabProject": "group/lib", "migrateMRs": false, "excludeRefs": [], "labels": {"bug": {"tag": "Defect"}}}
+			]
+		}`,
+		"cycle.json":   `{"include": ["cycle.json"]}`,
+		"missing.json": `{"projects": [{"gitlabProject": "${TEST_CONFIG_UNDEFINED}"}]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("TEST_CONFIG_GITLAB", `https://gitlab.example.com/"quoted"`)
+	defer os.Unsetenv("TEST_CONFIG_GITLAB")
+
+	loaded, _, err := loadConfig(filepath.Join(dir, "wave.json"), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]labelMapping{"bug": {Tag: gitlab.String("Bug")}, "team::payments": {AreaPath: "Shop"}}
+	expect := config{
+		GitlabInstances: map[string]*gitlabInstance{"selfhosted": {URL: `https://gitlab.example.com/"quoted"`, TokenEnv: "TOKEN"}},
+		Labels:          labels,
+		Projects: []project{
+			{GitlabID: 1, AzdoProject: "Legacy", Labels: labels},
+			{GitlabProject: "group/app", AzdoProject: "Shared", MigrateMRs: true, ExcludeRefs: []string{"refs/heads/tmp/*"}, Labels: labels},
+			{GitlabProject: "group/lib", AzdoProject: "Shared", MigrateMRs: false, ExcludeRefs: []string{}, Labels: map[string]labelMapping{
+				"bug":            {Tag: gitlab.String("Defect")},
+				"team::payments": {AreaPath: "Shop"},
+			}},
+		},
+	}
+	if diff := deep.Equal(loaded, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	for _, name := range []string{"cycle.json", "missing.json", "nonexistent.json"} {
+		if _, _, err := loadConfig(filepath.Join(dir, name), map[string]bool{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"sync"
+)
+
+// what happens with merge requests whose source branch lives in another project (a fork or another project of the
+// fork network)
+const (
+	crossProjectPush       = "push"
+	crossProjectConfigured = "configured"
+	crossProjectSkip       = "skip"
+)
+
+var (
+	crossProjectMRs = kingpin.Flag("cross-project-mrs", "Merge requests from another project of the fork network - push their source branch into the target repository under --fork-branch-prefix, do so only when the source project is configured as well or skip them").Default(crossProjectPush).Enum(crossProjectPush, crossProjectConfigured, crossProjectSkip)
+	// configuredProjects are gitlab projects of the run by instance and ID, source projects of cross-project merge
+	// requests are looked up in them
+	configuredProjects      = map[gitlabProjectKey]bool{}
+	configuredProjectsMutex sync.Mutex
+)
+
+// gitlabProjectKey identifies a project across gitlab instances, IDs are unique within an instance only
+type gitlabProjectKey struct {
+	instance *gitlabInstance
+	id       int
+}
+
+func rememberConfiguredProjects(projects []project) {
+	configuredProjectsMutex.Lock()
+	defer configuredProjectsMutex.Unlock()
+	for _, project := range projects {
+		if project.gitlabProject != nil {
+			configuredProjects[gitlabProjectKey{project.gitlab, project.gitlabProject.ID}] = true
+		}
+	}
+}
+
+func isConfiguredProject(instance *gitlabInstance, id int) bool {
+	configuredProjectsMutex.Lock()
+	defer configuredProjectsMutex.Unlock()
+	return configuredProjects[gitlabProjectKey{instance, id}]
+}
+
+// checkCrossProject returns why the merge request is not migrated, merge requests targeting another project than the
+// migrated one would be created against a wrong repository
+func checkCrossProject(project project, mr *MergeRequest) error {
+	if mr.TargetProjectID != 0 && mr.TargetProjectID != project.gitlabProject.ID {
+		return fmt.Errorf("merge request %d targets project %d instead of %s, it belongs to the migration of that project", mr.IID, mr.TargetProjectID, project.gitlabProject.PathWithNamespace)
+	}
+	if mr.SourceProjectID == mr.TargetProjectID {
+		return nil
+	}
+	switch {
+	case *crossProjectMRs == crossProjectSkip:
+		return fmt.Errorf("merge request %d comes from project %d, cross-project merge requests are skipped (--cross-project-mrs)", mr.IID, mr.SourceProjectID)
+	case *crossProjectMRs == crossProjectConfigured && !isConfiguredProject(project.gitlab, mr.SourceProjectID):
+		return fmt.Errorf("merge request %d comes from project %d which is not configured, it is skipped (--cross-project-mrs)", mr.IID, mr.SourceProjectID)
+	}
+	return nil
+}
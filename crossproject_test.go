@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestCheckCrossProject(t *testing.T) {
+	instance := &gitlabInstance{URL: "https://gitlab.com"}
+	other := &gitlabInstance{URL: "https://gitlab.example.com"}
+	migrated := project{gitlab: instance, gitlabProject: &gitlab.Project{ID: 1, PathWithNamespace: "group/app"}}
+	configuredFork := project{gitlab: instance, gitlabProject: &gitlab.Project{ID: 2}}
+	elsewhere := project{gitlab: other, gitlabProject: &gitlab.Project{ID: 3}}
+	rememberConfiguredProjects([]project{migrated, configuredFork, elsewhere})
+	defer func() { configuredProjects = map[gitlabProjectKey]bool{} }()
+	defer func() { *crossProjectMRs = crossProjectPush }()
+
+	tests := []struct {
+		label    string
+		mode     string
+		source   int
+		target   int
+		expected string
+	}{
+		{"same project", crossProjectSkip, 1, 1, ""},
+		{"another target", crossProjectPush, 1, 4, "merge request 7 targets project 4 instead of group/app, it belongs to the migration of that project"},
+		{"pushed", crossProjectPush, 5, 1, ""},
+		{"skipped", crossProjectSkip, 2, 1, "merge request 7 comes from project 2, cross-project merge requests are skipped (--cross-project-mrs)"},
+		{"configured", crossProjectConfigured, 2, 1, ""},
+		{"not configured", crossProjectConfigured, 5, 1, "merge request 7 comes from project 5 which is not configured, it is skipped (--cross-project-mrs)"},
+		{"configured on another instance", crossProjectConfigured, 3, 1, "merge request 7 comes from project 3 which is not configured, it is skipped (--cross-project-mrs)"},
+	}
+	for _, test := range tests {
+		*crossProjectMRs = test.mode
+		message := ""
+		if err := checkCrossProject(migrated, &MergeRequest{IID: 7, SourceProjectID: test.source, TargetProjectID: test.target}); err != nil {
+			message = err.Error()
+		}
+		if diff := deep.Equal(message, test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+}
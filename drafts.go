@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strings"
+)
+
+// gitlabDraftPrefix matches prefixes gitlab marks merge requests as draft by
+const gitlabDraftPrefix = `(?i)^\s*((draft|wip)\s*:|\[(draft|wip)\]|\((draft|wip)\))\s*`
+
+var draftTitlePrefix = kingpin.Flag("draft-title-prefix", "Regular expression of the prefix removed from titles of draft merge requests, AzDO marks draft pull requests by its own state. Empty keeps titles as they are").Default(gitlabDraftPrefix).String()
+
+// draftPrefix is compiled --draft-title-prefix, nil keeps titles
+var draftPrefix *regexp.Regexp
+
+// initDraftPrefix compiles the prefix once the flags are parsed
+func initDraftPrefix() error {
+	if *draftTitlePrefix == "" {
+		draftPrefix = nil
+		return nil
+	}
+	var err error
+	if draftPrefix, err = regexp.Compile(*draftTitlePrefix); err != nil {
+		return fmt.Errorf("invalid --draft-title-prefix: %s", err)
+	}
+	return nil
+}
+
+// prepareTitle strips draft prefixes from the title of draft merge request, titles of other merge requests and title
+// of nothing but the prefix are kept
+func prepareTitle(mr *MergeRequest) string {
+	if draftPrefix == nil || !mr.WorkInProgress {
+		return mr.Title
+	}
+	title := mr.Title
+	for {
+		stripped := strings.TrimSpace(draftPrefix.ReplaceAllString(title, ""))
+		if stripped == "" {
+			return title
+		}
+		if stripped == title {
+			return stripped
+		}
+		title = stripped
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestPrepareTitle(t *testing.T) {
+	*draftTitlePrefix = gitlabDraftPrefix
+	if err := initDraftPrefix(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		*draftTitlePrefix = ""
+		initDraftPrefix()
+	}()
+	tests := []struct {
+		title    string
+		draft    bool
+		expected string
+	}{
+		{"Draft: Add login", true, "Add login"},
+		{"WIP: Add login", true, "Add login"},
+		{"draft:Add login", true, "Add login"},
+		{"[Draft] Add login", true, "Add login"},
+		{"(WIP) Add login", true, "Add login"},
+		{"Draft: WIP: Add login", true, "Add login"},
+		{"Add draft: login", true, "Add draft: login"},
+		{"Draft:", true, "Draft:"},
+		{"Draft: Add login", false, "Draft: Add login"},
+		{"Drafted login", true, "Drafted login"},
+	}
+	for _, test := range tests {
+		if diff := deep.Equal(prepareTitle(&MergeRequest{Title: test.title, WorkInProgress: test.draft}), test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.title, diff)
+		}
+	}
+
+	*draftTitlePrefix = "^\\[skip\\] "
+	if err := initDraftPrefix(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(prepareTitle(&MergeRequest{Title: "[skip] Draft: Add login", WorkInProgress: true}), "Draft: Add login"); diff != nil {
+		t.Errorf("custom prefix: %+v", diff)
+	}
+	*draftTitlePrefix = "("
+	if err := initDraftPrefix(); err == nil {
+		t.Error("invalid prefix should fail")
+	}
+}
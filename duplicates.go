@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+)
+
+var (
+	reuseRepository = kingpin.Flag("reuse-repo", "Continue into existing AzDO repository instead of failing, transfer is skipped and already migrated merge requests are detected").Default("false").Bool()
+	// migratedMarker is the first line of every migrated pull request description
+	migratedMarker = regexp.MustCompile(`^\*Migrated from \[Gitlab\]\(([^)\s]+)\)`)
+)
+
+// findReusableRepository returns existing repository of the project with --reuse-repo, so that a repeated run
+// continues where the previous one stopped
+func findReusableRepository(azdoCtx context.Context, project project, azdoClient git.Client) *git.GitRepository {
+	if !*reuseRepository || *recreateRepository {
+		return nil
+	}
+	repository, err := azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{
+		RepositoryId: gitlab.String(project.azdoRepositoryName()),
+		Project:      &project.AzdoProject,
+	})
+	if err != nil || repository == nil {
+		return nil
+	}
+	return repository
+}
+
+// listMigratedPullRequests finds pull requests created by earlier runs by the merge request URL in their properties or
+// description, there is no other state of what was migrated already. Properties which cannot be read are replaced by
+// the description marker
+func listMigratedPullRequests(azdoCtx context.Context, azdoClient TargetClient, repository *git.GitRepository) (map[string]git.GitPullRequest, error) {
+	migrated := map[string]git.GitPullRequest{}
+	args := git.GetPullRequestsArgs{
+		RepositoryId:   gitlab.String(repository.Id.String()),
+		Project:        repository.Project.Name,
+		SearchCriteria: &git.GitPullRequestSearchCriteria{Status: &git.PullRequestStatusValues.All},
+		Skip:           gitlab.Int(0),
+		Top:            gitlab.Int(100),
+	}
+	for {
+		page, err := azdoClient.GetPullRequests(azdoCtx, args)
+		if err != nil {
+			return nil, err
+		}
+		for _, pullRequest := range *page {
+			if !carriesMigrationMark(pullRequest) {
+				continue
+			}
+			properties, err := readMigrationProperties(azdoCtx, azdoClient, repository, *pullRequest.PullRequestId)
+			if err != nil {
+				log.Warnf("cannot read properties of pull request %d, it is matched by its description: %s", *pullRequest.PullRequestId, err)
+			}
+			if mergeRequestURL := migratedMergeRequestURL(pullRequest, properties); mergeRequestURL != "" {
+				migrated[mergeRequestURL] = pullRequest
+			}
+		}
+		if len(*page) < *args.Top {
+			return migrated, nil
+		}
+		*args.Skip += *args.Top
+	}
+}
+
+// prepareExistingMapping maps merge request to the pull request created by an earlier run, its notes are not known
+func prepareExistingMapping(mr *MergeRequest, pullRequest git.GitPullRequest, repository *git.GitRepository) *mergeRequestMapping {
+	log.Infof("merge request %d was migrated already as pull request %d, skipped", mr.IID, *pullRequest.PullRequestId)
+	return &mergeRequestMapping{
+		IID:           mr.IID,
+		GitlabURL:     mr.WebURL,
+		State:         mr.State,
+		PullRequestID: *pullRequest.PullRequestId,
+		AzdoURL:       preparePullRequestURL(*repository.WebUrl, *pullRequest.PullRequestId),
+		existing:      true,
+	}
+}
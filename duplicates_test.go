@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMigratedMarker(t *testing.T) {
+	mr := setupOpenMergeRequest()
+	description := preparePullRequestDescription(&mr)
+	match := migratedMarker.FindStringSubmatch(description)
+	if match == nil || match[1] != mr.WebURL {
+		t.Errorf("gitlab URL not found in %s", description)
+	}
+	if migratedMarker.MatchString("Fixes [Gitlab](https://gitlab.com/group/php/-/merge_requests/1) issue") {
+		t.Error("only the first line marker should match")
+	}
+}
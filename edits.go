@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"time"
+)
+
+// editGrace is how long after creation a note can be updated without being considered edited, gitlab updates notes
+// right after creation (e.g. by rendering references)
+const editGrace = time.Minute
+
+var markEdited = kingpin.Flag("mark-edited", "Note in the header of migrated comments that the gitlab note was edited and when, gitlab API does not expose previous versions of notes").Default("false").Bool()
+
+// prepareEditedMarker tells when the note was last edited, resolving updates resolved notes as well and the edit
+// cannot be told apart from it so they are never marked
+func prepareEditedMarker(note *Note) string {
+	if !*markEdited || note.CreatedAt == nil || note.UpdatedAt == nil || note.Resolved {
+		return ""
+	}
+	if note.UpdatedAt.Sub(*note.CreatedAt) <= editGrace {
+		return ""
+	}
+	return fmt.Sprintf(" (edited on %s)", note.UpdatedAt.Format("2006-01-02"))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+	"time"
+)
+
+func TestPrepareEditedMarker(t *testing.T) {
+	*markEdited = true
+	defer func() { *markEdited = false }()
+	created := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := func(after time.Duration) *time.Time {
+		updated := created.Add(after)
+		return &updated
+	}
+	tests := []struct {
+		label    string
+		note     Note
+		expected string
+	}{
+		{"edited", Note{CreatedAt: &created, UpdatedAt: at(72 * time.Hour)}, " (edited on 2021-03-04)"},
+		{"not edited", Note{CreatedAt: &created, UpdatedAt: &created}, ""},
+		{"updated right after creation", Note{CreatedAt: &created, UpdatedAt: at(10 * time.Second)}, ""},
+		{"resolved", Note{CreatedAt: &created, UpdatedAt: at(72 * time.Hour), Resolvable: true, Resolved: true}, ""},
+		{"unresolved", Note{CreatedAt: &created, UpdatedAt: at(72 * time.Hour), Resolvable: true}, " (edited on 2021-03-04)"},
+		{"without times", Note{}, ""},
+	}
+	for _, test := range tests {
+		if diff := deep.Equal(prepareEditedMarker(&test.note), test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+	*markEdited = false
+	if marker := prepareEditedMarker(&Note{CreatedAt: &created, UpdatedAt: at(72 * time.Hour)}); marker != "" {
+		t.Errorf("marked without --mark-edited: %s", marker)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var (
+	smtpServer   = kingpin.Flag("smtp-server", "SMTP server (host:port) the report of the run is mailed through to --email-to when the run finishes").Default("").String()
+	smtpUser     = kingpin.Flag("smtp-user", "SMTP user, the server is used without authentication when empty").Default("").String()
+	smtpPassword = kingpin.Flag("smtp-password", "SMTP password").Default("").String()
+	emailFrom    = kingpin.Flag("email-from", "Sender of the report email").Default("").String()
+	emailTo      = kingpin.Flag("email-to", "Recipients of the report email (comma separated or repeated), e.g. a distribution list").Strings()
+	// sendMail is replaced by tests
+	sendMail = smtp.SendMail
+)
+
+// initEmail checks the report email can be sent before anything is migrated, an unattended run would find out only
+// at its end
+func initEmail() error {
+	if *smtpServer == "" && len(*emailTo) == 0 {
+		return nil
+	}
+	if *smtpServer == "" || len(emailRecipients()) == 0 || *emailFrom == "" {
+		return fmt.Errorf("--smtp-server, --email-from and --email-to are required to email the report")
+	}
+	if _, _, err := net.SplitHostPort(*smtpServer); err != nil {
+		return fmt.Errorf("invalid --smtp-server %s, use host:port: %s", *smtpServer, err)
+	}
+	redactor.add(*smtpPassword)
+	return nil
+}
+
+func emailRecipients() []string {
+	var recipients []string
+	for _, value := range *emailTo {
+		for _, recipient := range strings.Split(value, ",") {
+			if recipient = strings.TrimSpace(recipient); recipient != "" {
+				recipients = append(recipients, recipient)
+			}
+		}
+	}
+	return recipients
+}
+
+// emailReport mails summary of the run with projects in an attached CSV, failure to send it does not fail the run
+func emailReport() {
+	if *smtpServer == "" {
+		return
+	}
+	recipients := emailRecipients()
+	message, err := report.email(*emailFrom, recipients, time.Now())
+	if err != nil {
+		log.Errorf("cannot prepare report email: %s", err)
+		return
+	}
+	var auth smtp.Auth
+	if *smtpUser != "" {
+		host, _, _ := net.SplitHostPort(*smtpServer)
+		auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+	}
+	if err := sendMail(*smtpServer, auth, *emailFrom, recipients, message); err != nil {
+		log.Errorf("cannot email the report to %s: %s", strings.Join(recipients, ", "), err)
+		return
+	}
+	log.Infof("report emailed to %s", strings.Join(recipients, ", "))
+}
+
+// email is MIME message with summary of the run in its text and every project in attached CSV
+func (r *runReport) email(from string, to []string, sent time.Time) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	migrated, failed, problems := r.counts()
+	var message bytes.Buffer
+	body := multipart.NewWriter(&message)
+	fmt.Fprintf(&message, "From: %s\r\nTo: %s\r\nSubject: Migration report: %d migrated, %d failed, %d with problems\r\n", from, strings.Join(to, ", "), migrated, failed, problems)
+	fmt.Fprintf(&message, "Date: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n", sent.Format(time.RFC1123Z), body.Boundary())
+
+	text, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(text, r.emailText(migrated, failed, problems)); err != nil {
+		return nil, err
+	}
+	attachment, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/csv; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+		"Content-Disposition":       {`attachment; filename="migration-report.csv"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	table, err := r.csv()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(attachment, table); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
+
+// emailText lists failed projects and projects with problems first, they are what the reader has to act on
+func (r *runReport) emailText(migrated, failed, problems int) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Migration finished: %d projects, %d migrated, %d failed, %d with problems.\n", len(r.Projects), migrated, failed, problems)
+	sections := []struct {
+		title   string
+		matches func(*projectReport) bool
+	}{
+		{"Failed", func(p *projectReport) bool { return p.Failed }},
+		{"With problems", func(p *projectReport) bool { return !p.Failed && len(p.Problems) > 0 }},
+		{"Migrated", func(p *projectReport) bool { return !p.Failed && len(p.Problems) == 0 }},
+	}
+	for _, section := range sections {
+		written := false
+		for _, project := range r.Projects {
+			if !section.matches(project) {
+				continue
+			}
+			if !written {
+				fmt.Fprintf(&text, "\n%s:\n", section.title)
+				written = true
+			}
+			fmt.Fprintf(&text, "- %s -> %s", project.GitlabPath, project.AzdoProject)
+			if project.AzdoRepositoryURL != "" {
+				fmt.Fprintf(&text, " %s", project.AzdoRepositoryURL)
+			}
+			text.WriteString("\n")
+			for _, problem := range project.Problems {
+				fmt.Fprintf(&text, "    %s\n", problem)
+			}
+		}
+	}
+	if *reportFile != "" {
+		fmt.Fprintf(&text, "\nFull report: %s\n", *reportFile)
+	}
+	return text.String()
+}
+
+func (r *runReport) csv() (string, error) {
+	var table bytes.Buffer
+	writer := csv.NewWriter(&table)
+	writer.Write([]string{"gitlabPath", "azdoProject", "azdoRepositoryUrl", "status", "problems"})
+	for _, project := range r.Projects {
+		status := "migrated"
+		switch {
+		case project.Failed:
+			status = "failed"
+		case len(project.Problems) > 0:
+			status = "problems"
+		}
+		writer.Write([]string{project.GitlabPath, project.AzdoProject, project.AzdoRepositoryURL, status, strings.Join(project.Problems, "\n")})
+	}
+	writer.Flush()
+	return table.String(), writer.Error()
+}
+
+func writeQuotedPrintable(part io.Writer, content string) error {
+	writer := quotedprintable.NewWriter(part)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestInitEmail(t *testing.T) {
+	defer func() { *smtpServer, *emailFrom, *emailTo = "", "", nil }()
+	tests := []struct {
+		server string
+		from   string
+		to     []string
+		fails  bool
+	}{
+		{"", "", nil, false},
+		{"smtp.example.com:587", "migration@example.com", []string{"devops@example.com, leads@example.com"}, false},
+		{"smtp.example.com:587", "", []string{"devops@example.com"}, true},
+		{"", "migration@example.com", []string{"devops@example.com"}, true},
+		{"smtp.example.com", "migration@example.com", []string{"devops@example.com"}, true},
+	}
+	for _, test := range tests {
+		*smtpServer, *emailFrom, *emailTo = test.server, test.from, test.to
+		if err := initEmail(); (err != nil) != test.fails {
+			t.Errorf("%+v: unexpected error %v", test, err)
+		}
+	}
+}
+
+func TestEmailReport(t *testing.T) {
+	defer func(previous *runReport) { report = previous }(report)
+	defer func() {
+		*smtpServer, *emailFrom, *emailTo = "", "", nil
+		sendMail = smtp.SendMail
+	}()
+	*smtpServer, *emailFrom, *emailTo = "smtp.example.com:25", "migration@example.com", []string{"devops@example.com,leads@example.com"}
+	report = &runReport{}
+	report.project("group/app", "Apps").migrated("https://dev.azure.com/org/Apps/_git/app")
+	report.project("group/lib", "Apps").problem("label %s is not migrated", "bug")
+	report.project("group/api", "Apps").fail()
+
+	var recipients []string
+	var sent []byte
+	sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		recipients, sent = to, msg
+		return nil
+	}
+	emailReport()
+
+	if diff := deep.Equal(recipients, []string{"devops@example.com", "leads@example.com"}); diff != nil {
+		t.Error(diff)
+	}
+	message, err := mail.ReadMessage(strings.NewReader(string(sent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "Migration report: 2 migrated, 1 failed, 1 with problems" {
+		t.Errorf("unexpected subject %s", subject)
+	}
+	_, params, _ := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	var contents []string
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		content, _ := ioutil.ReadAll(quotedprintable.NewReader(part))
+		//quoted-printable text has CRLF line endings
+		contents = append(contents, strings.ReplaceAll(string(content), "\r\n", "\n"))
+	}
+	expected := []string{
+		"Migration finished: 3 projects, 2 migrated, 1 failed, 1 with problems.\n\nFailed:\n- group/api -> Apps\n\nWith problems:\n- group/lib -> Apps\n    label bug is not migrated\n\nMigrated:\n- group/app -> Apps https://dev.azure.com/org/Apps/_git/app\n",
+		"gitlabPath,azdoProject,azdoRepositoryUrl,status,problems\ngroup/app,Apps,https://dev.azure.com/org/Apps/_git/app,migrated,\ngroup/lib,Apps,,problems,label bug is not migrated\ngroup/api,Apps,,failed,\n",
+	}
+	if diff := deep.Equal(contents, expected); diff != nil {
+		t.Error(diff)
+	}
+}
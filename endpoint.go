@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/serviceendpoint"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var createServiceEndpoint = kingpin.Flag("azdo-create-endpoint", "Create temporary \"Other Git\" service connection with the gitlab token for every import and delete it afterwards").Default("false").Bool()
+
+// serviceEndpoint is the service endpoint of the project, service connections are scoped to AzDO project so projects
+// migrated into different AzDO projects need their own
+func (p project) serviceEndpoint() string {
+	if p.ServiceEndpointID != "" {
+		return p.ServiceEndpointID
+	}
+	return *azdoServiceEndpoint
+}
+
+// validateServiceEndpoint refuses endpoints which are not UUIDs, the import would fail only after the repository is
+// created
+func validateServiceEndpoint(project project) error {
+	if project.ServiceEndpointID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(project.ServiceEndpointID); err != nil {
+		return fmt.Errorf("serviceEndpointId %s is not a valid UUID, copy resourceId from the service connection URL", project.ServiceEndpointID)
+	}
+	return nil
+}
+
+// prepareServiceEndpoint returns service endpoint the import request authenticates with and a function removing it
+// once the import is finished, endpoint is created only when requested otherwise the configured one is used
+func prepareServiceEndpoint(azdoCtx context.Context, connection *azuredevops.Connection, project project) (*uuid.UUID, func(), error) {
+	if !*createServiceEndpoint {
+		endpoint := project.serviceEndpoint()
+		if endpoint == "" {
+			//import requests do not accept inline credentials, private project cannot be imported without an endpoint
+			if project.gitlabProject.Visibility != gitlab.PublicVisibility {
+				log.Warnf("project %s is not public and no service endpoint is configured, import will fail - use serviceEndpointId, --azdo-endpoint or --azdo-create-endpoint", project.gitlabProject.PathWithNamespace)
+			}
+			return nil, func() {}, nil
+		}
+		endpointID, err := uuid.Parse(endpoint)
+		if err != nil {
+			return nil, nil, classified(failureValidation, fmt.Errorf("service endpoint %s of %s is not a valid UUID: %s", endpoint, project.gitlabProject.PathWithNamespace, err))
+		}
+		return &endpointID, func() {}, nil
+	}
+
+	endpointClient, err := serviceendpoint.NewClient(azdoCtx, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+	username, password := project.source.GitCredentials()
+	endpoint, err := endpointClient.CreateServiceEndpoint(azdoCtx, serviceendpoint.CreateServiceEndpointArgs{
+		Endpoint: &serviceendpoint.ServiceEndpoint{
+			Name: gitlab.String(fmt.Sprintf("gitlab-migration %s", project.gitlabProject.PathWithNamespace)),
+			Type: gitlab.String("git"),
+			Url:  &project.gitlabProject.HTTPURLToRepo,
+			Authorization: &serviceendpoint.EndpointAuthorization{
+				Scheme: gitlab.String("UsernamePassword"),
+				Parameters: &map[string]string{
+					"username": username,
+					"password": password,
+				},
+			},
+		},
+		Project: &project.AzdoProject,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create service endpoint for %s: %w", project.gitlabProject.PathWithNamespace, err)
+	}
+	audit.record("serviceEndpoint.create", project.AzdoProject, endpoint.Id.String(), map[string]interface{}{
+		"name": *endpoint.Name,
+	})
+
+	cleanup := func() {
+		err := endpointClient.DeleteServiceEndpoint(azdoCtx, serviceendpoint.DeleteServiceEndpointArgs{
+			Project:    &project.AzdoProject,
+			EndpointId: endpoint.Id,
+		})
+		if err != nil {
+			log.Errorf("cannot delete service endpoint %s, remove it manually: %s", endpoint.Id, err)
+			return
+		}
+		audit.record("serviceEndpoint.delete", project.AzdoProject, endpoint.Id.String(), nil)
+	}
+	return endpoint.Id, cleanup, nil
+}
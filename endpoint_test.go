@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestServiceEndpoint(t *testing.T) {
+	*azdoServiceEndpoint = "3b6e4a52-6c1f-4f4e-9a8e-0d4b3f1c2a10"
+	defer func() { *azdoServiceEndpoint = "" }()
+	if endpoint := (project{}).serviceEndpoint(); endpoint != *azdoServiceEndpoint {
+		t.Errorf("project without serviceEndpointId should use --azdo-endpoint, got %s", endpoint)
+	}
+	configured := project{ServiceEndpointID: "9f1d2c3b-0a4e-4b5f-8c6d-7e8f9a0b1c2d"}
+	if endpoint := configured.serviceEndpoint(); endpoint != configured.ServiceEndpointID {
+		t.Errorf("serviceEndpointId should win, got %s", endpoint)
+	}
+	if err := validateServiceEndpoint(configured); err != nil {
+		t.Error(err)
+	}
+	if err := validateServiceEndpoint(project{ServiceEndpointID: "AzDO migration"}); err == nil {
+		t.Error("endpoint name instead of UUID should be refused")
+	}
+}
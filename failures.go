@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/xanzy/go-gitlab"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// classes of project failures, transient and rate-limit failures are likely to pass when the project is migrated again
+const (
+	failureAuth       = "auth"
+	failureNotFound   = "not-found"
+	failureRateLimit  = "rate-limit"
+	failureValidation = "validation"
+	failureTransient  = "transient"
+	failureOther      = "other"
+)
+
+// classifiedError is error whose class is known where it happens, errors of APIs are classified by their status
+type classifiedError struct {
+	class string
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// classified marks the error with the class
+func classified(class string, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// failureClass is class of the first classified error in the chain, then of the first API status in it. Timeouts and
+// network errors are transient
+func failureClass(err error) string {
+	var known *classifiedError
+	if errors.As(err, &known) {
+		return known.class
+	}
+	if status := failureStatus(err); status != 0 {
+		return statusFailureClass(status)
+	}
+	var networkError net.Error
+	if errors.Is(err, errHTTPTimeout) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &networkError) {
+		return failureTransient
+	}
+	return failureOther
+}
+
+// failureStatus is the first API status in the chain, 0 when the error did not come from an API response
+func failureStatus(err error) int {
+	var azdoError azuredevops.WrappedError
+	if errors.As(err, &azdoError) && azdoError.StatusCode != nil {
+		return *azdoError.StatusCode
+	}
+	var azdoErrorReference *azuredevops.WrappedError
+	if errors.As(err, &azdoErrorReference) && azdoErrorReference.StatusCode != nil {
+		return *azdoErrorReference.StatusCode
+	}
+	var gitlabError *gitlab.ErrorResponse
+	if errors.As(err, &gitlabError) && gitlabError.Response != nil {
+		return gitlabError.Response.StatusCode
+	}
+	return 0
+}
+
+func statusFailureClass(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return failureAuth
+	case status == http.StatusNotFound:
+		return failureNotFound
+	case status == http.StatusTooManyRequests:
+		return failureRateLimit
+	case status >= http.StatusInternalServerError || status == http.StatusRequestTimeout:
+		return failureTransient
+	case status >= http.StatusBadRequest:
+		return failureValidation
+	}
+	return failureOther
+}
+
+// retryableFailure tells whether the failure is worth migrating the project again without changing anything
+func retryableFailure(class string) bool {
+	return class == failureTransient || class == failureRateLimit
+}
+
+// countFailure counts failed project, failures logged before they were classified are counted as other
+func (r *runReport) countFailure(class string) {
+	if class == "" {
+		class = failureOther
+	}
+	if r.FailureClasses == nil {
+		r.FailureClasses = map[string]int{}
+	}
+	r.FailureClasses[class]++
+}
+
+func formatFailureClasses(classes map[string]int) string {
+	var counts []string
+	for _, class := range []string{failureAuth, failureNotFound, failureRateLimit, failureValidation, failureTransient, failureOther} {
+		if classes[class] > 0 {
+			counts = append(counts, fmt.Sprintf("%s %d", class, classes[class]))
+		}
+	}
+	return strings.Join(counts, ", ")
+}
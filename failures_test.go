@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/xanzy/go-gitlab"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestFailureClass(t *testing.T) {
+	azdoError := func(status int) error {
+		return azuredevops.WrappedError{StatusCode: gitlab.Int(status), Message: gitlab.String("failed")}
+	}
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{fmt.Errorf("could not initiate repository app: %w", azdoError(http.StatusUnauthorized)), failureAuth},
+		{fmt.Errorf("cannot count tags: %w", &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}), failureNotFound},
+		{&azuredevops.WrappedError{StatusCode: gitlab.Int(http.StatusTooManyRequests)}, failureRateLimit},
+		{azdoError(http.StatusConflict), failureValidation},
+		{azdoError(http.StatusServiceUnavailable), failureTransient},
+		{fmt.Errorf("cannot check import request: %w", errHTTPTimeout), failureTransient},
+		{fmt.Errorf("cannot check import request: %w", context.DeadlineExceeded), failureTransient},
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, failureTransient},
+		{fmt.Errorf("cannot migrate app: %w", classified(failureValidation, azdoError(http.StatusServiceUnavailable))), failureValidation},
+		{fmt.Errorf("import request of app failed: source repository is empty"), failureOther},
+	}
+	for _, test := range tests {
+		if class := failureClass(test.err); class != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.err, test.expected, class)
+		}
+	}
+}
+
+func TestSummarizeFailureClasses(t *testing.T) {
+	run := &runReport{}
+	run.project("group/app", "Apps").failWith(fmt.Errorf("could not initiate repository app: %w", &azuredevops.WrappedError{StatusCode: gitlab.Int(http.StatusBadGateway)}))
+	run.project("group/api", "Apps").failWith(fmt.Errorf("cannot reach gitlab: %w", errHTTPTimeout))
+	run.project("group/lib", "Apps").fail()
+	run.project("group/web", "Apps")
+	run.summarize()
+	if diff := deep.Equal(run.FailureClasses, map[string]int{failureTransient: 2, failureOther: 1}); diff != nil {
+		t.Error(diff)
+	}
+	if run.Projects[0].Failure == "" || !run.Projects[0].Failed {
+		t.Errorf("failure should be recorded: %+v", run.Projects[0])
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	onlyProjects   = kingpin.Flag("only-projects", "Migrate only given projects (gitlab IDs or paths, comma separated or repeated)").Strings()
+	skipProjects   = kingpin.Flag("skip-projects", "Skip given projects (gitlab IDs or paths, comma separated or repeated)").Strings()
+	includePattern = kingpin.Flag("include-regex", "Migrate only projects whose gitlab path matches the regex").Regexp()
+	excludePattern = kingpin.Flag("exclude-regex", "Skip projects whose gitlab path matches the regex").Regexp()
+	startFrom      = kingpin.Flag("start-from", "Skip selected projects before the given one (gitlab ID, path or position N of 'processing project (N/total)' log)").Default("").String()
+)
+
+// projectFilter selects subset of configured projects so that a large configuration can be run partially
+type projectFilter struct {
+	only    map[string]bool
+	skip    map[string]bool
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	start   string
+}
+
+func newProjectFilter() projectFilter {
+	return projectFilter{
+		only:    splitProjectList(*onlyProjects),
+		skip:    splitProjectList(*skipProjects),
+		include: *includePattern,
+		exclude: *excludePattern,
+		start:   strings.TrimSpace(*startFrom),
+	}
+}
+
+func splitProjectList(values []string) map[string]bool {
+	projects := map[string]bool{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				projects[item] = true
+			}
+		}
+	}
+	return projects
+}
+
+func (f projectFilter) matches(p project) bool {
+	path := p.GitlabProject
+	if p.gitlabProject != nil {
+		path = p.gitlabProject.PathWithNamespace
+	}
+	listed := func(list map[string]bool) bool {
+		return list[path] || list[strconv.Itoa(p.GitlabID)]
+	}
+	if len(f.only) > 0 && !listed(f.only) {
+		return false
+	}
+	if listed(f.skip) {
+		return false
+	}
+	if f.include != nil && !f.include.MatchString(path) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.MatchString(path)
+}
+
+// excludesConfigured tells whether the configured gitlab path and ID exclude the project already so that it need not
+// be looked up, a project configured by path only may still be listed by its ID and the other way round
+func (f projectFilter) excludesConfigured(p project) bool {
+	path := p.GitlabProject
+	if path == "" {
+		path = p.GithubRepository
+	}
+	id := ""
+	if p.GitlabID != 0 {
+		id = strconv.Itoa(p.GitlabID)
+	}
+	// listed returns whether the list names the project and whether that is known before the project is resolved
+	listed := func(list map[string]bool) (bool, bool) {
+		if (path != "" && list[path]) || (id != "" && list[id]) {
+			return true, true
+		}
+		for item := range list {
+			if _, err := strconv.Atoi(item); (err == nil && id == "") || (err != nil && path == "") {
+				return false, false
+			}
+		}
+		return false, true
+	}
+	if onlyListed, known := listed(f.only); len(f.only) > 0 && known && !onlyListed {
+		return true
+	}
+	if skipped, _ := listed(f.skip); skipped {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+	if f.include != nil && !f.include.MatchString(path) {
+		return true
+	}
+	return f.exclude != nil && f.exclude.MatchString(path)
+}
+
+func (f projectFilter) apply(projects []project) []project {
+	var selected []project
+	for _, project := range projects {
+		if f.matches(project) {
+			selected = append(selected, project)
+		}
+	}
+	return selected
+}
+
+// startAt drops selected projects before the start project so that an interrupted run can be resumed, gitlab ID or
+// path wins over position
+func (f projectFilter) startAt(projects []project) ([]project, error) {
+	if f.start == "" {
+		return projects, nil
+	}
+	for i, project := range projects {
+		if f.start == strconv.Itoa(project.GitlabID) || (project.gitlabProject != nil && f.start == project.gitlabProject.PathWithNamespace) {
+			return projects[i:], nil
+		}
+	}
+	if position, err := strconv.Atoi(f.start); err == nil && position >= 1 && position <= len(projects) {
+		return projects[position-1:], nil
+	}
+	return nil, fmt.Errorf("--start-from %s is neither gitlab ID, path nor position of any of %d selected projects", f.start, len(projects))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"regexp"
+	"testing"
+)
+
+func TestProjectFilter(t *testing.T) {
+	projects := []project{
+		{GitlabID: 1, gitlabProject: &gitlab.Project{PathWithNamespace: "group/php"}},
+		{GitlabID: 2, gitlabProject: &gitlab.Project{PathWithNamespace: "group/java"}},
+		{GitlabID: 3, gitlabProject: &gitlab.Project{PathWithNamespace: "legacy/php"}},
+	}
+	filters := []struct {
+		label  string
+		filter projectFilter
+		expect []int
+	}{
+		{"no filter", projectFilter{}, []int{1, 2, 3}},
+		{"only IDs and paths", projectFilter{only: splitProjectList([]string{"1,legacy/php"})}, []int{1, 3}},
+		{"skip", projectFilter{skip: splitProjectList([]string{"group/java"})}, []int{1, 3}},
+		{"include regex", projectFilter{include: regexp.MustCompile("^group/")}, []int{1, 2}},
+		{"exclude regex", projectFilter{exclude: regexp.MustCompile("php$")}, []int{2}},
+	}
+
+	for _, filter := range filters {
+		var selected []int
+		for _, project := range filter.filter.apply(projects) {
+			selected = append(selected, project.GitlabID)
+		}
+		if diff := deep.Equal(filter.expect, selected); diff != nil {
+			t.Errorf("%s: %+v", filter.label, diff)
+		}
+	}
+}
+
+func TestStartAt(t *testing.T) {
+	projects := []project{
+		{GitlabID: 1, gitlabProject: &gitlab.Project{PathWithNamespace: "group/php"}},
+		{GitlabID: 2, gitlabProject: &gitlab.Project{PathWithNamespace: "group/java"}},
+		{GitlabID: 3, gitlabProject: &gitlab.Project{PathWithNamespace: "legacy/php"}},
+	}
+	starts := map[string]int{"": 3, "2": 2, "group/java": 2, "legacy/php": 1}
+	for start, expect := range starts {
+		selected, err := projectFilter{start: start}.startAt(projects)
+		if err != nil || len(selected) != expect {
+			t.Errorf("start from %q should select %d projects, got %d: %v", start, expect, len(selected), err)
+		}
+	}
+	//gitlab ID wins over position
+	selected, _ := projectFilter{start: "3"}.startAt(projects)
+	if len(selected) != 1 || selected[0].GitlabID != 3 {
+		t.Errorf("unexpected selection %v", selected)
+	}
+	if _, err := (projectFilter{start: "missing/project"}).startAt(projects); err == nil {
+		t.Error("unknown start project should fail")
+	}
+}
+
+func TestExcludesConfigured(t *testing.T) {
+	byPath := project{GitlabProject: "group/php"}
+	byID := project{GitlabID: 2}
+	filters := []struct {
+		label    string
+		filter   projectFilter
+		project  project
+		excluded bool
+	}{
+		{"no filter", projectFilter{}, byPath, false},
+		{"only other path", projectFilter{only: splitProjectList([]string{"group/java"})}, byPath, true},
+		{"only listed path", projectFilter{only: splitProjectList([]string{"group/php"})}, byPath, false},
+		{"only IDs may list path", projectFilter{only: splitProjectList([]string{"1"})}, byPath, false},
+		{"only other ID", projectFilter{only: splitProjectList([]string{"1"})}, byID, true},
+		{"only paths may list ID", projectFilter{only: splitProjectList([]string{"group/php"})}, byID, false},
+		{"skip ID", projectFilter{skip: splitProjectList([]string{"2"})}, byID, true},
+		{"exclude regex", projectFilter{exclude: regexp.MustCompile("php$")}, byPath, true},
+		{"regex needs path", projectFilter{include: regexp.MustCompile("^legacy/")}, byID, false},
+	}
+	for _, filter := range filters {
+		if excluded := filter.filter.excludesConfigured(filter.project); excluded != filter.excluded {
+			t.Errorf("%s: expected excluded %v, got %v", filter.label, filter.excluded, excluded)
+		}
+	}
+}
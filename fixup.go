@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	fixupLinks            = kingpin.Flag("fixup-links", "After all projects are migrated, rewrite !123 merge request references into links to migrated pull requests").Default("false").Bool()
+	mergeRequestReference = regexp.MustCompile(`(^|[\s(])((?:[\w.-]+/)*[\w.-]+)?!(\d+)\b`)
+)
+
+// pullRequestLinks resolves gitlab project path and merge request IID to the migrated pull request URL
+type pullRequestLinks map[string]map[int]string
+
+func preparePullRequestLinks(mapping migrationMapping) pullRequestLinks {
+	links := pullRequestLinks{}
+	for _, project := range mapping.Projects {
+		links[project.GitlabPath] = map[int]string{}
+		for _, mr := range project.MergeRequests {
+			links[project.GitlabPath][mr.IID] = mr.AzdoURL
+		}
+	}
+	return links
+}
+
+// rewriteMergeRequestReferences replaces !123, project!123 and group/project!123 references outside of code blocks
+// with links to migrated pull requests, unknown references are left untouched
+func rewriteMergeRequestReferences(text string, projectPath string, links pullRequestLinks) string {
+	lines := strings.Split(text, "\n")
+	fence := ""
+	for i, line := range lines {
+		if match := fenceMatcher.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if match[1] == fence {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		lines[i] = mergeRequestReference.ReplaceAllStringFunc(line, func(reference string) string {
+			match := mergeRequestReference.FindStringSubmatch(reference)
+			target := projectPath
+			if match[2] != "" {
+				target = match[2]
+				if !strings.Contains(target, "/") {
+					target = path.Join(path.Dir(projectPath), target)
+				}
+			}
+			iid, _ := strconv.Atoi(match[3])
+			url, ok := links[target][iid]
+			if !ok {
+				return reference
+			}
+			return fmt.Sprintf("%s[%s!%s](%s)", match[1], match[2], match[3], url)
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fixupMergeRequestReferences is a second pass over migrated pull requests, it can run only once the mapping of all
+// projects is known
+func fixupMergeRequestReferences(azdoCtx context.Context, azdoClient TargetClient, mapping migrationMapping) {
+	links := preparePullRequestLinks(mapping)
+	for _, project := range mapping.Projects {
+		for _, mr := range project.MergeRequests {
+			log.Debugf("fixup merge request references in pull request %d", mr.PullRequestID)
+			fixupPullRequest(azdoCtx, azdoClient, project, mr, links)
+			fixupThreads(azdoCtx, azdoClient, project, mr, links)
+		}
+	}
+}
+
+func fixupPullRequest(azdoCtx context.Context, azdoClient TargetClient, project projectMapping, mr mergeRequestMapping, links pullRequestLinks) {
+	pullRequest, err := azdoClient.GetPullRequestById(azdoCtx, git.GetPullRequestByIdArgs{
+		PullRequestId: &mr.PullRequestID,
+		Project:       &project.AzdoProject,
+	})
+	if err != nil {
+		log.Errorf("cannot fetch pull request %d for link fixup: %s", mr.PullRequestID, err)
+		return
+	}
+	if pullRequest.Description == nil {
+		return
+	}
+	description := rewriteMergeRequestReferences(*pullRequest.Description, project.GitlabPath, links)
+	if description == *pullRequest.Description {
+		return
+	}
+	_, err = azdoClient.UpdatePullRequest(azdoCtx, git.UpdatePullRequestArgs{
+		GitPullRequestToUpdate: &git.GitPullRequest{Description: &description},
+		RepositoryId:           &project.AzdoRepositoryID,
+		PullRequestId:          &mr.PullRequestID,
+		Project:                &project.AzdoProject,
+	})
+	if err != nil {
+		log.Errorf("cannot update pull request %d description: %s", mr.PullRequestID, err)
+		return
+	}
+	audit.record("pullRequest.update", project.AzdoProject, strconv.Itoa(mr.PullRequestID), map[string]interface{}{
+		"repositoryId": project.AzdoRepositoryID,
+		"field":        "description",
+	})
+}
+
+func fixupThreads(azdoCtx context.Context, azdoClient TargetClient, project projectMapping, mr mergeRequestMapping, links pullRequestLinks) {
+	threads, err := azdoClient.GetThreads(azdoCtx, git.GetThreadsArgs{
+		RepositoryId:  &project.AzdoRepositoryID,
+		PullRequestId: &mr.PullRequestID,
+		Project:       &project.AzdoProject,
+	})
+	if err != nil {
+		log.Errorf("cannot fetch threads of pull request %d for link fixup: %s", mr.PullRequestID, err)
+		return
+	}
+	for _, thread := range *threads {
+		if thread.Comments == nil {
+			continue
+		}
+		for _, comment := range *thread.Comments {
+			if comment.Content == nil || (comment.CommentType != nil && *comment.CommentType == git.CommentTypeValues.System) {
+				continue
+			}
+			content := rewriteMergeRequestReferences(*comment.Content, project.GitlabPath, links)
+			if content == *comment.Content {
+				continue
+			}
+			_, err = azdoClient.UpdateComment(azdoCtx, git.UpdateCommentArgs{
+				Comment:       &git.Comment{Content: &content},
+				RepositoryId:  &project.AzdoRepositoryID,
+				PullRequestId: &mr.PullRequestID,
+				ThreadId:      thread.Id,
+				CommentId:     comment.Id,
+				Project:       &project.AzdoProject,
+			})
+			if err != nil {
+				log.Errorf("cannot update comment %d of thread %d: %s", *comment.Id, *thread.Id, err)
+				continue
+			}
+			audit.record("comment.update", project.AzdoProject, strconv.Itoa(*comment.Id), map[string]interface{}{
+				"pullRequestId": mr.PullRequestID,
+				"threadId":      *thread.Id,
+			})
+		}
+	}
+}
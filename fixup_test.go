@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestRewriteMergeRequestReferences(t *testing.T) {
+	links := pullRequestLinks{
+		"group/php":  {1: "https://dev.azure.com/org/project/_git/php/pullrequest/11"},
+		"group/java": {2: "https://dev.azure.com/org/project/_git/java/pullrequest/12"},
+	}
+	texts := []struct {
+		label  string
+		text   string
+		expect string
+	}{
+		{
+			"same project reference",
+			"depends on !1.",
+			"depends on [!1](https://dev.azure.com/org/project/_git/php/pullrequest/11).",
+		},
+		{
+			"cross project references",
+			"see group/java!2 and (java!2)",
+			"see [group/java!2](https://dev.azure.com/org/project/_git/java/pullrequest/12) and ([java!2](https://dev.azure.com/org/project/_git/java/pullrequest/12))",
+		},
+		{
+			"unknown references and images",
+			"!3 other/php!1 ![image](/uploads/a.png)",
+			"!3 other/php!1 ![image](/uploads/a.png)",
+		},
+		{
+			"already rewritten and code blocks",
+			"[!1](https://dev.azure.com/org/project/_git/php/pullrequest/11)\n```\n!1\n```",
+			"[!1](https://dev.azure.com/org/project/_git/php/pullrequest/11)\n```\n!1\n```",
+		},
+	}
+
+	for _, text := range texts {
+		if diff := deep.Equal(text.expect, rewriteMergeRequestReferences(text.text, "group/php", links)); diff != nil {
+			t.Errorf("%s: %+v", text.label, diff)
+		}
+	}
+}
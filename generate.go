@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+var (
+	generateConfigCommand = kingpin.Command("generate-config", "Generate projects configuration file from a gitlab group")
+	generateGroup         = generateConfigCommand.Flag("gitlab-group", "Gitlab group (ID or path) whose projects should be migrated").Required().String()
+	generateAzdoProject   = generateConfigCommand.Flag("azdo-project", "AzDO project all repositories should be migrated to").Required().String()
+	generateOutput        = generateConfigCommand.Flag("output", "Where to write the configuration").Default("projects.json").String()
+	generateSubgroups     = generateConfigCommand.Flag("include-subgroups", "Include projects of subgroups").Default("true").Bool()
+	generateArchived      = generateConfigCommand.Flag("include-archived", "Include archived projects").Default("false").Bool()
+	generateMigrateMRs    = generateConfigCommand.Flag("migrate-mrs", "Default of migrateMRs for generated projects").Default("true").Bool()
+	generateForce         = generateConfigCommand.Flag("force", "Overwrite existing output file").Default("false").Bool()
+)
+
+func generateConfig(gitlabClient *gitlab.Client) {
+	if _, err := os.Stat(*generateOutput); err == nil && !*generateForce {
+		log.Fatalf("%s already exists, use --force to overwrite it", *generateOutput)
+	}
+	projects, err := listGroupProjects(gitlabClient, *generateGroup)
+	if err != nil {
+		log.Fatalf("cannot list projects of group %s: %s", *generateGroup, err)
+	}
+	generated := prepareGeneratedConfig(projects, *generateAzdoProject, *generateMigrateMRs)
+	content, err := json.MarshalIndent(generated, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*generateOutput, append(content, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%d projects written to %s", len(generated.Projects), *generateOutput)
+}
+
+func listGroupProjects(gitlabClient *gitlab.Client, group string) ([]*gitlab.Project, error) {
+	options := gitlab.ListGroupProjectsOptions{
+		ListOptions:      gitlab.ListOptions{Page: 1, PerPage: 100},
+		IncludeSubgroups: generateSubgroups,
+	}
+	if !*generateArchived {
+		options.Archived = gitlab.Bool(false)
+	}
+	var projects []*gitlab.Project
+	for {
+		page, response, err := gitlabClient.Groups.ListGroupProjects(group, &options)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %s", options.Page, err)
+		}
+		projects = append(projects, page...)
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		return projects, nil
+	}
+}
+
+// prepareGeneratedConfig uses project paths rather than IDs so that the generated file is easy to review and edit
+func prepareGeneratedConfig(projects []*gitlab.Project, azdoProject string, migrateMRs bool) config {
+	generated := config{Projects: []project{}}
+	for _, gitlabProject := range projects {
+		generated.Projects = append(generated.Projects, project{
+			GitlabProject: gitlabProject.PathWithNamespace,
+			AzdoProject:   azdoProject,
+			MigrateMRs:    migrateMRs && gitlabProject.MergeRequestsEnabled,
+		})
+	}
+	sort.Slice(generated.Projects, func(i, j int) bool {
+		return generated.Projects[i].GitlabProject < generated.Projects[j].GitlabProject
+	})
+	return generated
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPrepareGeneratedConfig(t *testing.T) {
+	projects := []*gitlab.Project{
+		{PathWithNamespace: "group/php", MergeRequestsEnabled: true},
+		{PathWithNamespace: "group/docs", MergeRequestsEnabled: false},
+	}
+	expect := config{Projects: []project{
+		{GitlabProject: "group/docs", AzdoProject: "my-project", MigrateMRs: false},
+		{GitlabProject: "group/php", AzdoProject: "my-project", MigrateMRs: true},
+	}}
+	if diff := deep.Equal(expect, prepareGeneratedConfig(projects, "my-project", true)); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	githubPageSize = 100
+	// githubMaxFiles is the most files GitHub lists of a pull request
+	githubMaxFiles = 3000
+)
+
+var (
+	githubURL   = kingpin.Flag("github-url", "GitHub API URL projects with githubRepository are read from, https://<host>/api/v3 for GitHub Enterprise").Default("https://api.github.com").String()
+	githubToken = kingpin.Flag("github-token", "GitHub token with read access to repositories of projects with githubRepository").Default("").String()
+
+	defaultGithub *githubAPI
+)
+
+// githubAPI reads GitHub or GitHub Enterprise REST API, projects of all repositories share it
+type githubAPI struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// githubSource is SourceClient of GitHub repository, pull requests are translated into merge requests and the
+// repository into gitlab project so that the rest of the migration handles them alike. Features only gitlab has
+// (approvals, diff versions, members, post actions) are skipped for GitHub projects as they have no gitlab instance
+type githubSource struct {
+	api        *githubAPI
+	repository string
+	projectID  int
+}
+
+type githubUser struct {
+	Login     string `json:"login"`
+	HTMLURL   string `json:"html_url"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type githubRepository struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Description   string `json:"description"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	Archived      bool   `json:"archived"`
+	// Size is in kilobytes
+	Size int64 `json:"size"`
+}
+
+type githubBranch struct {
+	Ref  string            `json:"ref"`
+	SHA  string            `json:"sha"`
+	Repo *githubRepository `json:"repo"`
+}
+
+type githubPullRequest struct {
+	Number         int          `json:"number"`
+	Title          string       `json:"title"`
+	Body           string       `json:"body"`
+	State          string       `json:"state"`
+	Draft          bool         `json:"draft"`
+	HTMLURL        string       `json:"html_url"`
+	User           githubUser   `json:"user"`
+	CreatedAt      *time.Time   `json:"created_at"`
+	UpdatedAt      *time.Time   `json:"updated_at"`
+	MergedAt       *time.Time   `json:"merged_at"`
+	MergedBy       *githubUser  `json:"merged_by"`
+	MergeCommitSHA string       `json:"merge_commit_sha"`
+	Head           githubBranch `json:"head"`
+	Base           githubBranch `json:"base"`
+	Labels         []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// githubCommit is a commit of the repository
+type githubCommit struct {
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+// githubFile is a file changed by the pull request
+type githubFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"`
+}
+
+// githubComment is a review comment on the diff or a comment of the pull request conversation
+type githubComment struct {
+	ID           int        `json:"id"`
+	Body         string     `json:"body"`
+	User         githubUser `json:"user"`
+	CreatedAt    *time.Time `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+	Path         string     `json:"path"`
+	Line         int        `json:"line"`
+	OriginalLine int        `json:"original_line"`
+	StartLine    int        `json:"start_line"`
+	Side         string     `json:"side"`
+	InReplyToID  int        `json:"in_reply_to_id"`
+}
+
+type githubPullRequestReview struct {
+	ID          int        `json:"id"`
+	Body        string     `json:"body"`
+	State       string     `json:"state"`
+	User        githubUser `json:"user"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+}
+
+// initGithub creates the GitHub API client on first use so that gitlab only runs need no GitHub token
+func initGithub() (*githubAPI, error) {
+	if defaultGithub != nil {
+		return defaultGithub, nil
+	}
+	if *githubToken == "" {
+		return nil, fmt.Errorf("--github-token is required to migrate projects with githubRepository")
+	}
+	redactor.add(*githubToken)
+	defaultGithub = &githubAPI{
+		apiURL: strings.TrimSuffix(*githubURL, "/"),
+		token:  *githubToken,
+		client: &http.Client{Transport: &instrumentedTransport{base: &pacedTransport{base: traced(timed(baseTransport))}}},
+	}
+	return defaultGithub, nil
+}
+
+// resolveGithubProject fetches the repository as gitlab project, the project has no gitlab instance and everything
+// is read through its source
+func resolveGithubProject(project *project) error {
+	api, err := initGithub()
+	if err != nil {
+		return err
+	}
+	var repository githubRepository
+	if err := api.get("/repos/"+project.GithubRepository, nil, &repository); err != nil {
+		return fmt.Errorf("couldn't find GitHub repository %s does your token have access to it? %s", project.GithubRepository, err)
+	}
+	project.source = &githubSource{api: api, repository: project.GithubRepository, projectID: repository.ID}
+	project.gitlabProject = translateGithubRepository(repository)
+	project.GitlabID = repository.ID
+	return nil
+}
+
+func (a *githubAPI) get(path string, query url.Values, result interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, a.apiURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "token "+a.token)
+	request.Header.Set("Accept", "application/vnd.github.v3+json")
+	response, err := a.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("GET %s responded %s: %s", path, response.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// getAll fetches every page of the list into items which is a pointer to a slice
+func (a *githubAPI) getAll(path string, query url.Values, items interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	list := reflect.ValueOf(items).Elem()
+	for page := 1; ; page++ {
+		query.Set("per_page", strconv.Itoa(githubPageSize))
+		query.Set("page", strconv.Itoa(page))
+		pageItems := reflect.New(list.Type())
+		if err := a.get(path, query, pageItems.Interface()); err != nil {
+			return err
+		}
+		list.Set(reflect.AppendSlice(list, pageItems.Elem()))
+		if pageItems.Elem().Len() < githubPageSize {
+			return nil
+		}
+	}
+}
+
+// ListMergeRequests returns open pull requests from the oldest one, closed ones are not migrated and merged ones only
+// with --migrate-merged
+func (s *githubSource) ListMergeRequests() ([]*MergeRequest, error) {
+	var pullRequests []githubPullRequest
+	state := "open"
+	if *migrateMerged {
+		state = "all"
+	}
+	query := url.Values{"state": {state}, "sort": {"created"}, "direction": {"asc"}}
+	if err := s.api.getAll(fmt.Sprintf("/repos/%s/pulls", s.repository), query, &pullRequests); err != nil {
+		return nil, err
+	}
+	var mergeRequests []*MergeRequest
+	for _, pullRequest := range pullRequests {
+		mergeRequests = append(mergeRequests, translateGithubPullRequest(pullRequest, s.projectID))
+	}
+	return mergeRequests, nil
+}
+
+// ListDiscussions returns review comments, conversation comments and reviews of the pull request as discussions
+func (s *githubSource) ListDiscussions(mr *MergeRequest) ([]*Discussion, error) {
+	var reviewComments, issueComments []githubComment
+	var reviews []githubPullRequestReview
+	if err := s.api.getAll(fmt.Sprintf("/repos/%s/pulls/%d/comments", s.repository, mr.IID), nil, &reviewComments); err != nil {
+		return nil, err
+	}
+	if err := s.api.getAll(fmt.Sprintf("/repos/%s/issues/%d/comments", s.repository, mr.IID), nil, &issueComments); err != nil {
+		return nil, err
+	}
+	if err := s.api.getAll(fmt.Sprintf("/repos/%s/pulls/%d/reviews", s.repository, mr.IID), nil, &reviews); err != nil {
+		return nil, err
+	}
+	return translateGithubComments(mr.WebURL, reviewComments, issueComments, reviews), nil
+}
+
+// PrefetchDiscussions prefetches nothing, GitHub has no batch of comments of several pull requests
+func (s *githubSource) PrefetchDiscussions(mergeRequests []*MergeRequest) map[int][]*Discussion {
+	return nil
+}
+
+// ListApprovers lists nobody, reviews are migrated as comments
+func (s *githubSource) ListApprovers(mr *MergeRequest) ([]*User, error) {
+	return nil, nil
+}
+
+// ListChangesRequesters returns users whose latest approving or change requesting review requests changes
+func (s *githubSource) ListChangesRequesters(mr *MergeRequest) ([]*User, error) {
+	var reviews []githubPullRequestReview
+	if err := s.api.getAll(fmt.Sprintf("/repos/%s/pulls/%d/reviews", s.repository, mr.IID), nil, &reviews); err != nil {
+		return nil, err
+	}
+	latest := map[string]githubPullRequestReview{}
+	var order []string
+	for _, review := range reviews {
+		if review.State != "APPROVED" && review.State != "CHANGES_REQUESTED" && review.State != "DISMISSED" {
+			continue
+		}
+		if _, seen := latest[review.User.Login]; !seen {
+			order = append(order, review.User.Login)
+		}
+		latest[review.User.Login] = review
+	}
+	var requesters []*User
+	for _, login := range order {
+		if review := latest[login]; review.State == "CHANGES_REQUESTED" {
+			requesters = append(requesters, &User{Username: login, Name: login, AvatarURL: review.User.AvatarURL, WebURL: review.User.HTMLURL})
+		}
+	}
+	return requesters, nil
+}
+
+// ListChanges returns files of the pull request, GitHub lists at most githubMaxFiles of them
+func (s *githubSource) ListChanges(mr *MergeRequest) ([]FileChange, error) {
+	var files []githubFile
+	if err := s.api.getAll(fmt.Sprintf("/repos/%s/pulls/%d/files", s.repository, mr.IID), nil, &files); err != nil {
+		return nil, err
+	}
+	if len(files) >= githubMaxFiles {
+		return nil, nil
+	}
+	changes := []FileChange{}
+	for _, file := range files {
+		change := FileChange{OldPath: file.Filename, NewPath: file.Filename, Deleted: file.Status == "removed"}
+		if file.Status == "renamed" {
+			change.OldPath, change.Renamed = file.PreviousFilename, true
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// ListVersionHeads lists no versions, GitHub does not keep heads of earlier pushes
+func (s *githubSource) ListVersionHeads(mr *MergeRequest) ([]string, error) {
+	return nil, nil
+}
+
+// HeadRef is kept by GitHub for pull requests from forks as well
+func (s *githubSource) HeadRef(mr *MergeRequest) string {
+	return fmt.Sprintf("refs/pull/%d/head", mr.IID)
+}
+
+func (s *githubSource) IssuePath() string {
+	return "/issues/"
+}
+
+func (s *githubSource) GitCredentials() (string, string) {
+	return "oauth2", s.api.token
+}
+
+// GetMergeDetails fetches the pull request as lists of pull requests leave out who merged it, GitHub does not tell
+// squash commits from merge commits
+func (s *githubSource) GetMergeDetails(mr *MergeRequest) (*MergeDetails, error) {
+	details := &MergeDetails{MergedAt: mr.MergedAt}
+	var pullRequest githubPullRequest
+	if err := s.api.get(fmt.Sprintf("/repos/%s/pulls/%d", s.repository, mr.IID), nil, &pullRequest); err != nil {
+		return details, err
+	}
+	if user := pullRequest.MergedBy; user != nil {
+		details.MergedBy = &User{Username: user.Login, Name: user.Login, AvatarURL: user.AvatarURL, WebURL: user.HTMLURL}
+	}
+	if pullRequest.MergeCommitSHA == "" {
+		return details, nil
+	}
+	var commit githubCommit
+	if err := s.api.get(fmt.Sprintf("/repos/%s/commits/%s", s.repository, pullRequest.MergeCommitSHA), nil, &commit); err != nil {
+		return details, fmt.Errorf("cannot fetch commit %s: %w", pullRequest.MergeCommitSHA, err)
+	}
+	details.Commits = []MergeCommit{{SHA: pullRequest.MergeCommitSHA, Message: commit.Commit.Message}}
+	return details, nil
+}
+
+func translateGithubRepository(repository githubRepository) *gitlab.Project {
+	visibility := gitlab.PublicVisibility
+	if repository.Private {
+		visibility = gitlab.PrivateVisibility
+	}
+	return &gitlab.Project{
+		ID:                repository.ID,
+		Name:              repository.Name,
+		Path:              repository.Name,
+		PathWithNamespace: repository.FullName,
+		Description:       repository.Description,
+		WebURL:            repository.HTMLURL,
+		HTTPURLToRepo:     repository.CloneURL,
+		DefaultBranch:     repository.DefaultBranch,
+		Visibility:        visibility,
+		Archived:          repository.Archived,
+		Statistics:        &gitlab.ProjectStatistics{StorageStatistics: gitlab.StorageStatistics{RepositorySize: repository.Size * 1024}},
+	}
+}
+
+// translateGithubPullRequest maps the pull request to merge request, pull requests from deleted forks keep no source
+// project and are treated as forks
+func translateGithubPullRequest(pullRequest githubPullRequest, projectID int) *MergeRequest {
+	state := "opened"
+	if pullRequest.MergedAt != nil {
+		state = "merged"
+	} else if pullRequest.State == "closed" {
+		state = "closed"
+	}
+	sourceProjectID := 0
+	if pullRequest.Head.Repo != nil {
+		sourceProjectID = pullRequest.Head.Repo.ID
+	}
+	var labels []string
+	for _, label := range pullRequest.Labels {
+		labels = append(labels, label.Name)
+	}
+	return &MergeRequest{
+		IID:             pullRequest.Number,
+		ProjectID:       projectID,
+		Title:           pullRequest.Title,
+		Description:     pullRequest.Body,
+		State:           state,
+		WorkInProgress:  pullRequest.Draft,
+		SourceBranch:    pullRequest.Head.Ref,
+		TargetBranch:    pullRequest.Base.Ref,
+		SourceProjectID: sourceProjectID,
+		TargetProjectID: projectID,
+		SHA:             pullRequest.Head.SHA,
+		MergeCommitSHA:  pullRequest.MergeCommitSHA,
+		WebURL:          pullRequest.HTMLURL,
+		Labels:          labels,
+		CreatedAt:       pullRequest.CreatedAt,
+		UpdatedAt:       pullRequest.UpdatedAt,
+		MergedAt:        pullRequest.MergedAt,
+		Author: &User{
+			Username:  pullRequest.User.Login,
+			Name:      pullRequest.User.Login,
+			AvatarURL: pullRequest.User.AvatarURL,
+			WebURL:    pullRequest.User.HTMLURL,
+		},
+	}
+}
+
+// translateGithubComments groups review comments into threads by the comment they reply to, conversation comments
+// and reviews with a verdict or summary become discussions of a single note. Discussions are ordered by creation and
+// their notes link the comments in the pull request conversation
+func translateGithubComments(pullRequestURL string, reviewComments []githubComment, issueComments []githubComment, reviews []githubPullRequestReview) []*Discussion {
+	var discussions []*Discussion
+	threads := map[int]*Discussion{}
+	for _, comment := range reviewComments {
+		note := translateGithubComment(comment, fmt.Sprintf("%s#discussion_r%d", pullRequestURL, comment.ID))
+		if thread, ok := threads[comment.InReplyToID]; ok {
+			thread.Notes = append(thread.Notes, note)
+			continue
+		}
+		line := comment.Line
+		if line == 0 {
+			line = comment.OriginalLine
+		}
+		note.Position = &NotePosition{NewPath: comment.Path, OldPath: comment.Path, NewLine: line}
+		if comment.Side == "LEFT" {
+			note.Position.NewLine, note.Position.OldLine = 0, line
+		} else if comment.StartLine > 0 {
+			note.Position.LineRange = &LineRange{
+				StartRange: &LinePosition{NewLine: comment.StartLine},
+				EndRange:   &LinePosition{NewLine: line},
+			}
+		}
+		thread := &Discussion{ID: strconv.Itoa(comment.ID), Notes: []*Note{note}}
+		threads[comment.ID] = thread
+		discussions = append(discussions, thread)
+	}
+	for _, comment := range issueComments {
+		discussions = append(discussions, &Discussion{ID: strconv.Itoa(comment.ID), Notes: []*Note{translateGithubComment(comment, fmt.Sprintf("%s#issuecomment-%d", pullRequestURL, comment.ID))}})
+	}
+	for _, review := range reviews {
+		//pending reviews are drafts their author has not submitted yet
+		if review.State == "PENDING" || review.SubmittedAt == nil {
+			continue
+		}
+		body := prepareReviewVerdict(review.State)
+		if body == "" && review.Body == "" {
+			continue
+		}
+		if review.Body != "" {
+			body = strings.TrimSpace(body + "\n\n" + review.Body)
+		}
+		comment := githubComment{ID: review.ID, Body: body, User: review.User, CreatedAt: review.SubmittedAt, UpdatedAt: review.SubmittedAt}
+		discussions = append(discussions, &Discussion{ID: strconv.Itoa(review.ID), Notes: []*Note{translateGithubComment(comment, fmt.Sprintf("%s#pullrequestreview-%d", pullRequestURL, review.ID))}})
+	}
+	//discussions without creation date go last
+	sort.SliceStable(discussions, func(i, j int) bool {
+		first, second := discussions[i].Notes[0].CreatedAt, discussions[j].Notes[0].CreatedAt
+		return first != nil && (second == nil || first.Before(*second))
+	})
+	return discussions
+}
+
+func translateGithubComment(comment githubComment, webURL string) *Note {
+	note := &Note{
+		ID:        comment.ID,
+		Body:      comment.Body,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+		WebURL:    webURL,
+	}
+	if note.UpdatedAt == nil {
+		note.UpdatedAt = note.CreatedAt
+	}
+	note.Author.Username = comment.User.Login
+	note.Author.Name = comment.User.Login
+	note.Author.AvatarURL = comment.User.AvatarURL
+	note.Author.WebURL = comment.User.HTMLURL
+	return note
+}
+
+func prepareReviewVerdict(state string) string {
+	switch state {
+	case "APPROVED":
+		return "✅ approved"
+	case "CHANGES_REQUESTED":
+		return "🔴 changes requested"
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-test/deep"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTranslateGithubPullRequest(t *testing.T) {
+	_, createdAt := setupDates()
+	pullRequest := githubPullRequest{
+		Number:  7,
+		Title:   "Add login",
+		Body:    "Adds login",
+		State:   "open",
+		Draft:   true,
+		HTMLURL: "https://github.com/org/app/pull/7",
+		User:    githubUser{Login: "john-doe", HTMLURL: "https://github.com/john-doe", AvatarURL: "https://avatars.githubusercontent.com/u/1"},
+		Head:    githubBranch{Ref: "feature/login", SHA: "abc", Repo: &githubRepository{ID: 2}},
+		Base:    githubBranch{Ref: "main", Repo: &githubRepository{ID: 1}},
+		Labels: []struct {
+			Name string `json:"name"`
+		}{{Name: "security"}},
+		CreatedAt: &createdAt,
+		UpdatedAt: &createdAt,
+	}
+	expected := &MergeRequest{
+		IID:             7,
+		ProjectID:       1,
+		Title:           "Add login",
+		Description:     "Adds login",
+		State:           "opened",
+		WorkInProgress:  true,
+		SourceBranch:    "feature/login",
+		TargetBranch:    "main",
+		SourceProjectID: 2,
+		TargetProjectID: 1,
+		SHA:             "abc",
+		WebURL:          "https://github.com/org/app/pull/7",
+		Labels:          []string{"security"},
+		CreatedAt:       &createdAt,
+		UpdatedAt:       &createdAt,
+		Author:          &User{Username: "john-doe", Name: "john-doe", AvatarURL: "https://avatars.githubusercontent.com/u/1", WebURL: "https://github.com/john-doe"},
+	}
+	if diff := deep.Equal(translateGithubPullRequest(pullRequest, 1), expected); diff != nil {
+		t.Error(diff)
+	}
+	if _, fork := prepareSourceBranch(expected); !fork {
+		t.Error("pull request from another repository is not a fork")
+	}
+}
+
+func TestTranslateGithubComments(t *testing.T) {
+	at := func(minute int) *time.Time {
+		date := time.Date(2019, 11, 4, 15, minute, 0, 0, time.UTC)
+		return &date
+	}
+	reviewComments := []githubComment{
+		{ID: 1, Body: "Rename it", Path: "main.go", Line: 5, StartLine: 3, Side: "RIGHT", CreatedAt: at(1)},
+		{ID: 2, Body: "Why removed?", Path: "old.go", OriginalLine: 9, Side: "LEFT", CreatedAt: at(3)},
+		{ID: 3, Body: "Done", InReplyToID: 1, CreatedAt: at(4)},
+	}
+	issueComments := []githubComment{{ID: 11, Body: "Undated"}, {ID: 10, Body: "Thanks", CreatedAt: at(2)}}
+	reviews := []githubPullRequestReview{
+		{ID: 20, State: "APPROVED", SubmittedAt: at(5)},
+		{ID: 21, State: "COMMENTED", SubmittedAt: at(6)},
+		{ID: 22, State: "PENDING", Body: "Draft"},
+	}
+
+	discussions := translateGithubComments("https://github.com/org/app/pull/7", reviewComments, issueComments, reviews)
+	var notes [][]int
+	for _, discussion := range discussions {
+		var ids []int
+		for _, note := range discussion.Notes {
+			ids = append(ids, note.ID)
+		}
+		notes = append(notes, ids)
+	}
+	if diff := deep.Equal(notes, [][]int{{1, 3}, {10}, {2}, {20}, {11}}); diff != nil {
+		t.Errorf("discussions: %+v", diff)
+	}
+	if diff := deep.Equal(threadAnchor(discussions[0].Notes[0]), &lineRange{start: 3, end: 5}); diff != nil {
+		t.Errorf("multiline anchor: %+v", diff)
+	}
+	if diff := deep.Equal(*discussions[2].Notes[0].Position, NotePosition{NewPath: "old.go", OldPath: "old.go", OldLine: 9}); diff != nil {
+		t.Errorf("removed line: %+v", diff)
+	}
+	if diff := deep.Equal(discussions[3].Notes[0].Body, "✅ approved"); diff != nil {
+		t.Errorf("review: %+v", diff)
+	}
+	mr := MergeRequest{WebURL: "https://github.com/org/app/pull/7"}
+	links := []string{prepareNoteLink(discussions[0].Notes[0], &mr), prepareNoteLink(discussions[1].Notes[0], &mr), prepareNoteLink(discussions[3].Notes[0], &mr)}
+	expected := []string{"https://github.com/org/app/pull/7#discussion_r1", "https://github.com/org/app/pull/7#issuecomment-10", "https://github.com/org/app/pull/7#pullrequestreview-20"}
+	if diff := deep.Equal(links, expected); diff != nil {
+		t.Errorf("links: %+v", diff)
+	}
+}
+
+func TestGithubGetAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size := githubPageSize
+		if page == 2 {
+			size = 1
+		}
+		comments := make([]githubComment, size)
+		for i := range comments {
+			comments[i].ID = (page-1)*githubPageSize + i + 1
+		}
+		json.NewEncoder(w).Encode(comments)
+	}))
+	defer server.Close()
+	api := &githubAPI{apiURL: server.URL, token: "secret", client: server.Client()}
+
+	var comments []githubComment
+	if err := api.getAll("/repos/org/app/issues/1/comments", nil, &comments); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal([]int{len(comments), comments[githubPageSize].ID}, []int{githubPageSize + 1, githubPageSize + 1}); diff != nil {
+		t.Error(diff)
+	}
+
+	api.token = "wrong"
+	if err := api.getAll("/repos/org/app/issues/1/comments", nil, &comments); err == nil {
+		t.Error("unauthorized request did not fail")
+	}
+}
+
+func TestGithubListChanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]githubFile{
+			{Filename: "src/app.go", Status: "modified"},
+			{Filename: "src/login.go", PreviousFilename: "src/auth.go", Status: "renamed"},
+			{Filename: "README", Status: "removed"},
+		})
+	}))
+	defer server.Close()
+	source := &githubSource{api: &githubAPI{apiURL: server.URL, client: server.Client()}, repository: "org/app"}
+
+	changes, err := source.ListChanges(&MergeRequest{IID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FileChange{
+		{OldPath: "src/app.go", NewPath: "src/app.go"},
+		{OldPath: "src/auth.go", NewPath: "src/login.go", Renamed: true},
+		{OldPath: "README", NewPath: "README", Deleted: true},
+	}
+	if diff := deep.Equal(changes, expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// discussionPageAttempts is how many times a page of discussions is fetched before comments of the merge request fail
+const discussionPageAttempts = 3
+
+// discussionRetryPause is the pause before a failed page of discussions is fetched again
+var discussionRetryPause = 10 * time.Second
+
+// gitlabAPI is the part of gitlab REST and GraphQL API gitlabSource reads, tests and the simulation serve it from
+// memory
+type gitlabAPI interface {
+	ListProjectMergeRequests(projectID int, options *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, *gitlab.Response, error)
+	ListMergeRequestDiscussions(projectID int, iid int, options *gitlab.ListMergeRequestDiscussionsOptions) ([]*gitlab.Discussion, *gitlab.Response, error)
+	GetApprovalConfiguration(projectID int, iid int) (*gitlab.MergeRequestApprovals, error)
+	GetMergeRequestChanges(projectID int, iid int) (*gitlab.MergeRequest, error)
+	GetMergeRequestDiffVersions(projectID int, iid int) ([]*gitlab.MergeRequestDiffVersion, error)
+	QueryGraphQL(query graphqlRequest) (*discussionsResponse, error)
+	GetCommit(projectID int, sha string) (*gitlab.Commit, error)
+	ListMergeRequestReviewers(projectID int, iid int) ([]*gitlabReviewerState, error)
+}
+
+// gitlabReviewerState is review state of merge request reviewer, the client does not know the reviewers API yet
+type gitlabReviewerState struct {
+	User  *gitlab.BasicUser `json:"user"`
+	State string            `json:"state"`
+}
+
+// gitlabRequestedChanges is the state of reviewer whose review requests changes
+const gitlabRequestedChanges = "requested_changes"
+
+// gitlabClientAPI is gitlabAPI of the instance client
+type gitlabClientAPI struct {
+	client *gitlab.Client
+}
+
+func (a gitlabClientAPI) ListProjectMergeRequests(projectID int, options *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, *gitlab.Response, error) {
+	return a.client.MergeRequests.ListProjectMergeRequests(projectID, options)
+}
+
+func (a gitlabClientAPI) ListMergeRequestDiscussions(projectID int, iid int, options *gitlab.ListMergeRequestDiscussionsOptions) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	return a.client.Discussions.ListMergeRequestDiscussions(projectID, iid, options)
+}
+
+func (a gitlabClientAPI) GetApprovalConfiguration(projectID int, iid int) (*gitlab.MergeRequestApprovals, error) {
+	approvals, _, err := a.client.MergeRequestApprovals.GetConfiguration(projectID, iid)
+	return approvals, err
+}
+
+func (a gitlabClientAPI) GetMergeRequestChanges(projectID int, iid int) (*gitlab.MergeRequest, error) {
+	changes, _, err := a.client.MergeRequests.GetMergeRequestChanges(projectID, iid, nil)
+	return changes, err
+}
+
+func (a gitlabClientAPI) GetMergeRequestDiffVersions(projectID int, iid int) ([]*gitlab.MergeRequestDiffVersion, error) {
+	versions, _, err := a.client.MergeRequests.GetMergeRequestDiffVersions(projectID, iid, nil)
+	return versions, err
+}
+
+func (a gitlabClientAPI) ListMergeRequestReviewers(projectID int, iid int) ([]*gitlabReviewerState, error) {
+	request, err := a.client.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/merge_requests/%d/reviewers", projectID, iid), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var reviewers []*gitlabReviewerState
+	_, err = a.client.Do(request, &reviewers)
+	return reviewers, err
+}
+
+func (a gitlabClientAPI) GetCommit(projectID int, sha string) (*gitlab.Commit, error) {
+	commit, _, err := a.client.Commits.GetCommit(projectID, sha)
+	return commit, err
+}
+
+func (a gitlabClientAPI) QueryGraphQL(query graphqlRequest) (*discussionsResponse, error) {
+	request, err := a.client.NewRequest(http.MethodPost, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	//GraphQL lives next to REST API, /api/graphql instead of /api/v4/
+	request.URL.Path = strings.TrimSuffix(strings.TrimSuffix(a.client.BaseURL().Path, "/"), "/v4") + "/graphql"
+	request.URL.RawPath = ""
+	response := &discussionsResponse{}
+	if _, err := a.client.Do(request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// gitlabSource is SourceClient of gitlab project, merge requests and discussions keep what gitlab returned as their
+// original
+type gitlabSource struct {
+	api      gitlabAPI
+	instance *gitlabInstance
+	project  *gitlab.Project
+}
+
+func newGitlabSource(instance *gitlabInstance, gitlabProject *gitlab.Project) *gitlabSource {
+	return &gitlabSource{api: gitlabClientAPI{client: instance.client}, instance: instance, project: gitlabProject}
+}
+
+// ListMergeRequests returns merge requests by IID, gitlab orders by time only and merge requests imported into gitlab
+// keep creation times out of IID order
+func (s *gitlabSource) ListMergeRequests() ([]*MergeRequest, error) {
+	options := gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+		OrderBy: gitlab.String("created_at"),
+		Sort:    gitlab.String("asc"),
+	}
+	var mergeRequests []*MergeRequest
+	for {
+		page, response, err := s.api.ListProjectMergeRequests(s.project.ID, &options)
+		if err != nil {
+			return nil, err
+		}
+		for _, mr := range page {
+			mergeRequests = append(mergeRequests, translateGitlabMergeRequest(mr))
+		}
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		break
+	}
+	sortMergeRequests(mergeRequests)
+	return mergeRequests, nil
+}
+
+// ListDiscussions fetches all pages of discussions, failed pages are fetched again
+func (s *gitlabSource) ListDiscussions(mr *MergeRequest) ([]*Discussion, error) {
+	var discussions []*Discussion
+	options := gitlab.ListMergeRequestDiscussionsOptions{Page: 1, PerPage: 100}
+	for {
+		page, response, err := s.fetchDiscussionPage(mr, &options)
+		if err != nil {
+			return nil, err
+		}
+		for _, discussion := range page {
+			discussions = append(discussions, translateGitlabDiscussion(discussion, mr))
+		}
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		return discussions, nil
+	}
+}
+
+// fetchDiscussionPage retries the page after transient failures, gitlab client repeats only throttled and failed
+// responses and not requests which got no response at all. Rejected credentials or missing merge requests fail at once
+func (s *gitlabSource) fetchDiscussionPage(mr *MergeRequest, options *gitlab.ListMergeRequestDiscussionsOptions) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	for attempt := 1; ; attempt++ {
+		page, response, err := s.api.ListMergeRequestDiscussions(mr.ProjectID, mr.IID, options)
+		if err == nil {
+			return page, response, nil
+		}
+		if !retryableFailure(failureClass(err)) {
+			return nil, nil, fmt.Errorf("cannot fetch page %d of discussions: %w", options.Page, err)
+		}
+		if attempt >= discussionPageAttempts {
+			return nil, nil, fmt.Errorf("cannot fetch page %d of discussions after %d attempts: %w", options.Page, attempt, err)
+		}
+		log.Warnf("cannot fetch page %d of discussions of merge request %d, retrying in %s: %s", options.Page, mr.IID, discussionRetryPause, err)
+		time.Sleep(discussionRetryPause)
+	}
+}
+
+func (s *gitlabSource) ListApprovers(mr *MergeRequest) ([]*User, error) {
+	approvals, err := s.api.GetApprovalConfiguration(mr.ProjectID, mr.IID)
+	if err != nil {
+		return nil, err
+	}
+	var approvers []*User
+	for _, approver := range approvals.ApprovedBy {
+		approvers = append(approvers, translateGitlabUser(approver.User))
+	}
+	return approvers, nil
+}
+
+// ListChangesRequesters asks gitlab only when the merge request has reviewers, review states are known since gitlab 16.9
+func (s *gitlabSource) ListChangesRequesters(mr *MergeRequest) ([]*User, error) {
+	if len(mr.Reviewers) == 0 {
+		return nil, nil
+	}
+	reviewers, err := s.api.ListMergeRequestReviewers(mr.ProjectID, mr.IID)
+	if err != nil {
+		return nil, err
+	}
+	var requesters []*User
+	for _, reviewer := range reviewers {
+		if reviewer.State == gitlabRequestedChanges && reviewer.User != nil {
+			requesters = append(requesters, translateGitlabUser(reviewer.User))
+		}
+	}
+	return requesters, nil
+}
+
+// ListChanges returns no changes when gitlab truncated them
+func (s *gitlabSource) ListChanges(mr *MergeRequest) ([]FileChange, error) {
+	changes, err := s.api.GetMergeRequestChanges(mr.ProjectID, mr.IID)
+	if err != nil || changes.Overflow {
+		return nil, err
+	}
+	var files []FileChange
+	for _, change := range changes.Changes {
+		files = append(files, FileChange{OldPath: change.OldPath, NewPath: change.NewPath, Renamed: change.RenamedFile, Deleted: change.DeletedFile})
+	}
+	return files, nil
+}
+
+func (s *gitlabSource) ListVersionHeads(mr *MergeRequest) ([]string, error) {
+	versions, err := s.api.GetMergeRequestDiffVersions(mr.ProjectID, mr.IID)
+	if err != nil {
+		return nil, err
+	}
+	return prepareVersionHeads(versions), nil
+}
+
+// HeadRef is kept in the target project for merge requests from forks as well
+func (s *gitlabSource) HeadRef(mr *MergeRequest) string {
+	return fmt.Sprintf("refs/merge-requests/%d/head", mr.IID)
+}
+
+func (s *gitlabSource) IssuePath() string {
+	return "/-/issues/"
+}
+
+func (s *gitlabSource) GitCredentials() (string, string) {
+	return s.instance.gitCredentials()
+}
+
+// GetMergeDetails reads messages of the squash and merge commits, gitlab keeps both when squashed merge request was
+// merged by a merge commit
+func (s *gitlabSource) GetMergeDetails(mr *MergeRequest) (*MergeDetails, error) {
+	details := &MergeDetails{MergedBy: mr.MergedBy, MergedAt: mr.MergedAt}
+	commits := []MergeCommit{{SHA: mr.SquashCommitSHA, Squash: true}, {SHA: mr.MergeCommitSHA}}
+	for _, commit := range commits {
+		if commit.SHA == "" {
+			continue
+		}
+		gitlabCommit, err := s.api.GetCommit(s.project.ID, commit.SHA)
+		if err != nil {
+			return details, fmt.Errorf("cannot fetch commit %s: %w", commit.SHA, err)
+		}
+		commit.Message = gitlabCommit.Message
+		details.Commits = append(details.Commits, commit)
+	}
+	return details, nil
+}
+
+func translateGitlabMergeRequest(mr *gitlab.MergeRequest) *MergeRequest {
+	translated := &MergeRequest{
+		IID:             mr.IID,
+		ProjectID:       mr.ProjectID,
+		SourceProjectID: mr.SourceProjectID,
+		TargetProjectID: mr.TargetProjectID,
+		Title:           mr.Title,
+		Description:     mr.Description,
+		State:           mr.State,
+		WorkInProgress:  mr.WorkInProgress,
+		SourceBranch:    mr.SourceBranch,
+		TargetBranch:    mr.TargetBranch,
+		SHA:             mr.SHA,
+		MergeCommitSHA:  mr.MergeCommitSHA,
+		SquashCommitSHA: mr.SquashCommitSHA,
+		DiffHeadSHA:     mr.DiffRefs.HeadSha,
+		WebURL:          mr.WebURL,
+		Labels:          mr.Labels,
+		CreatedAt:       mr.CreatedAt,
+		UpdatedAt:       mr.UpdatedAt,
+		MergedAt:        mr.MergedAt,
+		MergedBy:        translateGitlabUser(mr.MergedBy),
+		Author:          translateGitlabUser(mr.Author),
+		UserNotesCount:  mr.UserNotesCount,
+		Original:        mr,
+	}
+	for _, user := range mr.Assignees {
+		translated.Assignees = append(translated.Assignees, translateGitlabUser(user))
+	}
+	for _, user := range mr.Reviewers {
+		translated.Reviewers = append(translated.Reviewers, translateGitlabUser(user))
+	}
+	if status := mr.TaskCompletionStatus; status != nil {
+		translated.TaskCompletionStatus = &TaskCompletionStatus{Count: status.Count, CompletedCount: status.CompletedCount}
+	}
+	return translated
+}
+
+func translateGitlabUser(user *gitlab.BasicUser) *User {
+	if user == nil {
+		return nil
+	}
+	return &User{ID: user.ID, Username: user.Username, Name: user.Name, AvatarURL: user.AvatarURL, WebURL: user.WebURL}
+}
+
+func translateGitlabDiscussion(discussion *gitlab.Discussion, mr *MergeRequest) *Discussion {
+	translated := &Discussion{ID: discussion.ID, Original: discussion}
+	for _, note := range discussion.Notes {
+		translated.Notes = append(translated.Notes, translateGitlabNote(note, mr))
+	}
+	return translated
+}
+
+func translateGitlabNote(note *gitlab.Note, mr *MergeRequest) *Note {
+	translated := &Note{
+		ID:         note.ID,
+		Body:       note.Body,
+		System:     note.System,
+		CreatedAt:  note.CreatedAt,
+		UpdatedAt:  note.UpdatedAt,
+		Resolvable: note.Resolvable,
+		Resolved:   note.Resolved,
+		WebURL:     fmt.Sprintf("%s/diffs#note_%d", mr.WebURL, note.ID),
+	}
+	author, resolver := note.Author, note.ResolvedBy
+	translated.Author = User{ID: author.ID, Username: author.Username, Name: author.Name, AvatarURL: author.AvatarURL, WebURL: author.WebURL}
+	translated.ResolvedBy = User{ID: resolver.ID, Username: resolver.Username, Name: resolver.Name, AvatarURL: resolver.AvatarURL, WebURL: resolver.WebURL}
+	if position := note.Position; position != nil {
+		translated.Position = &NotePosition{
+			HeadSHA: position.HeadSHA,
+			NewPath: position.NewPath,
+			NewLine: position.NewLine,
+			OldPath: position.OldPath,
+			OldLine: position.OldLine,
+		}
+		if lines := position.LineRange; lines != nil && lines.StartRange != nil && lines.EndRange != nil {
+			translated.Position.LineRange = &LineRange{
+				StartRange: &LinePosition{OldLine: lines.StartRange.OldLine, NewLine: lines.StartRange.NewLine},
+				EndRange:   &LinePosition{OldLine: lines.EndRange.OldLine, NewLine: lines.EndRange.NewLine},
+			}
+		}
+	}
+	return translated
+}
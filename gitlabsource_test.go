@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// stubGitlabAPI serves merge requests and their discussions in pages of one, other methods of gitlabAPI are not
+// expected
+type stubGitlabAPI struct {
+	gitlabAPI
+	mergeRequests []*gitlab.MergeRequest
+	discussions   map[int][]*gitlab.Discussion
+}
+
+func (s *stubGitlabAPI) ListProjectMergeRequests(projectID int, options *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, *gitlab.Response, error) {
+	if options.Page > len(s.mergeRequests) {
+		return nil, stubResponse(options.Page, len(s.mergeRequests)), nil
+	}
+	return s.mergeRequests[options.Page-1 : options.Page], stubResponse(options.Page, len(s.mergeRequests)), nil
+}
+
+func (s *stubGitlabAPI) ListMergeRequestDiscussions(projectID int, iid int, options *gitlab.ListMergeRequestDiscussionsOptions) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	discussions := s.discussions[iid]
+	if options.Page > len(discussions) {
+		return nil, stubResponse(options.Page, len(discussions)), nil
+	}
+	return discussions[options.Page-1 : options.Page], stubResponse(options.Page, len(discussions)), nil
+}
+
+func stubResponse(page int, pages int) *gitlab.Response {
+	response := &gitlab.Response{CurrentPage: page}
+	if page < pages {
+		response.NextPage = page + 1
+	}
+	return response
+}
+
+// flakyGitlabAPI fails the first requests of every page of discussions with the failure
+type flakyGitlabAPI struct {
+	stubGitlabAPI
+	failures map[int]int
+	failure  error
+}
+
+func (s *flakyGitlabAPI) ListMergeRequestDiscussions(projectID int, iid int, options *gitlab.ListMergeRequestDiscussionsOptions) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	if s.failures[options.Page] > 0 {
+		s.failures[options.Page]--
+		return nil, nil, s.failure
+	}
+	return s.stubGitlabAPI.ListMergeRequestDiscussions(projectID, iid, options)
+}
+
+func TestListMergeRequests(t *testing.T) {
+	api := &stubGitlabAPI{mergeRequests: []*gitlab.MergeRequest{{IID: 3}, {IID: 1}, {IID: 2}}}
+	source := &gitlabSource{api: api, project: &gitlab.Project{ID: 1}}
+	mergeRequests, err := source.ListMergeRequests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var iids []int
+	for _, mr := range mergeRequests {
+		iids = append(iids, mr.IID)
+	}
+	if diff := deep.Equal(iids, []int{1, 2, 3}); diff != nil {
+		t.Error(diff)
+	}
+	if mergeRequests[0].Original != api.mergeRequests[1] {
+		t.Error("merge request should keep what gitlab returned")
+	}
+}
+
+func TestListDiscussions(t *testing.T) {
+	pause := discussionRetryPause
+	discussionRetryPause = 0
+	defer func() { discussionRetryPause = pause }()
+	mr := &MergeRequest{IID: 7}
+	api := &flakyGitlabAPI{
+		stubGitlabAPI: stubGitlabAPI{discussions: map[int][]*gitlab.Discussion{7: {{ID: "a"}, {ID: "b"}}}},
+		failures:      map[int]int{2: discussionPageAttempts - 1},
+		failure:       &net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")},
+	}
+	source := &gitlabSource{api: api, project: &gitlab.Project{ID: 1}}
+	discussions, err := source.ListDiscussions(mr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, discussion := range discussions {
+		ids = append(ids, discussion.ID)
+	}
+	if diff := deep.Equal(ids, []string{"a", "b"}); diff != nil {
+		t.Error(diff)
+	}
+
+	api.failures = map[int]int{2: discussionPageAttempts}
+	if discussions, err := source.ListDiscussions(mr); err == nil || discussions != nil {
+		t.Errorf("discussions should not be returned without all pages, got %v", discussions)
+	}
+
+	request := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/api/v4/projects/1/merge_requests/7/discussions"}}
+	api.failures = map[int]int{2: discussionPageAttempts - 1}
+	api.failure = &gitlab.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnauthorized, Request: request}}
+	if _, err := source.ListDiscussions(mr); err == nil || api.failures[2] != discussionPageAttempts-2 {
+		t.Errorf("rejected credentials should not be retried, %d attempts left", api.failures[2])
+	}
+}
+
+// reviewersGitlabAPI serves review states of merge request reviewers
+type reviewersGitlabAPI struct {
+	stubGitlabAPI
+	reviewers []*gitlabReviewerState
+}
+
+func (s *reviewersGitlabAPI) ListMergeRequestReviewers(projectID int, iid int) ([]*gitlabReviewerState, error) {
+	return s.reviewers, nil
+}
+
+func TestListChangesRequesters(t *testing.T) {
+	api := &reviewersGitlabAPI{reviewers: []*gitlabReviewerState{
+		{User: &gitlab.BasicUser{Username: "alice"}, State: "approved"},
+		{User: &gitlab.BasicUser{Username: "bob"}, State: gitlabRequestedChanges},
+	}}
+	source := &gitlabSource{api: api, project: &gitlab.Project{ID: 1}}
+	requesters, err := source.ListChangesRequesters(&MergeRequest{IID: 1, Reviewers: []*User{{Username: "alice"}, {Username: "bob"}}})
+	if err != nil || len(requesters) != 1 || requesters[0].Username != "bob" {
+		t.Errorf("only bob requested changes, got %v: %v", requesters, err)
+	}
+	if requesters, _ := source.ListChangesRequesters(&MergeRequest{IID: 2}); requesters != nil {
+		t.Errorf("merge request without reviewers should not be looked up, got %v", requesters)
+	}
+}
+
+func TestTranslateGitlabNote(t *testing.T) {
+	note := &gitlab.Note{ID: 5, Body: "Rename it", Position: &gitlab.NotePosition{
+		HeadSHA: "abc",
+		NewPath: "main.go",
+		NewLine: 5,
+		LineRange: &gitlab.LineRange{
+			StartRange: &gitlab.LinePosition{NewLine: 3},
+			EndRange:   &gitlab.LinePosition{NewLine: 5},
+		},
+	}}
+	note.Author.Username = "john-doe"
+	mr := &MergeRequest{WebURL: "https://gitlab.com/group/php/-/merge_requests/2"}
+	expected := &Note{ID: 5, Body: "Rename it", Author: User{Username: "john-doe"}, WebURL: mr.WebURL + "/diffs#note_5", Position: &NotePosition{
+		HeadSHA:   "abc",
+		NewPath:   "main.go",
+		NewLine:   5,
+		LineRange: &LineRange{StartRange: &LinePosition{NewLine: 3}, EndRange: &LinePosition{NewLine: 5}},
+	}}
+	if diff := deep.Equal(translateGitlabNote(note, mr), expected); diff != nil {
+		t.Error(diff)
+	}
+}
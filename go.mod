@@ -4,10 +4,15 @@ go 1.17
 
 require (
 	github.com/go-test/deep v1.0.8
+	github.com/golang/mock v1.3.1-0.20190508161146-9fa652df1129
+	github.com/gomodule/redigo v1.8.9
 	github.com/google/uuid v1.1.1
 	github.com/microsoft/azure-devops-go-api/azuredevops v1.0.0-b5
+	github.com/prometheus/client_golang v1.0.0
 	github.com/prometheus/common v0.9.1
+	github.com/sirupsen/logrus v1.4.2
 	github.com/xanzy/go-gitlab v0.54.4
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 )
 
@@ -21,15 +26,12 @@ require (
 	github.com/hashicorp/go-retryablehttp v0.6.8 // indirect
 	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
-	github.com/prometheus/client_golang v1.0.0 // indirect
 	github.com/prometheus/client_model v0.2.0 // indirect
 	github.com/prometheus/procfs v0.0.2 // indirect
-	github.com/sirupsen/logrus v1.4.2 // indirect
 	golang.org/x/lint v0.0.0-20210508222113-6edffad5e616 // indirect
 	golang.org/x/net v0.0.0-20211015210444-4f30a5c0130f // indirect
 	golang.org/x/oauth2 v0.0.0-20181106182150-f42d05182288 // indirect
 	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
-	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
 	golang.org/x/tools v0.0.0-20200130002326-2f3ba24bd6e7 // indirect
 	google.golang.org/appengine v1.3.0 // indirect
 )
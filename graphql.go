@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// discussionsQuery fetches discussions of a batch of merge requests at once, REST needs a request per merge request
+// and page of discussions
+const discussionsQuery = `query($project: ID!, $iids: [String!], $after: String) {
+  project(fullPath: $project) {
+    mergeRequests(iids: $iids, first: 20, after: $after) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        iid
+        discussions(first: 100) {
+          pageInfo { hasNextPage }
+          nodes {
+            id
+            notes(first: 100) {
+              pageInfo { hasNextPage }
+              nodes {
+                id body system resolvable resolved createdAt updatedAt
+                resolvedBy { username name webUrl }
+                author { id username name avatarUrl webUrl }
+                position { oldPath oldLine newPath newLine diffRefs { headSha } }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+var gitlabGraphQL = kingpin.Flag("gitlab-graphql", "Prefetch merge request discussions in batches by gitlab GraphQL API, --no-gitlab-graphql uses REST API only").Default("true").Bool()
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type graphqlNote struct {
+	ID         string     `json:"id"`
+	Body       string     `json:"body"`
+	System     bool       `json:"system"`
+	Resolvable bool       `json:"resolvable"`
+	Resolved   bool       `json:"resolved"`
+	CreatedAt  *time.Time `json:"createdAt"`
+	UpdatedAt  *time.Time `json:"updatedAt"`
+	Author     *struct {
+		ID        string `json:"id"`
+		Username  string `json:"username"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatarUrl"`
+		WebURL    string `json:"webUrl"`
+	} `json:"author"`
+	ResolvedBy *struct {
+		Username string `json:"username"`
+		Name     string `json:"name"`
+		WebURL   string `json:"webUrl"`
+	} `json:"resolvedBy"`
+	Position *struct {
+		OldPath  string `json:"oldPath"`
+		OldLine  int    `json:"oldLine"`
+		NewPath  string `json:"newPath"`
+		NewLine  int    `json:"newLine"`
+		DiffRefs struct {
+			HeadSHA string `json:"headSha"`
+		} `json:"diffRefs"`
+	} `json:"position"`
+}
+
+type graphqlDiscussion struct {
+	ID    string `json:"id"`
+	Notes struct {
+		PageInfo graphqlPageInfo `json:"pageInfo"`
+		Nodes    []graphqlNote   `json:"nodes"`
+	} `json:"notes"`
+}
+
+type graphqlMergeRequest struct {
+	IID         string `json:"iid"`
+	Discussions struct {
+		PageInfo graphqlPageInfo     `json:"pageInfo"`
+		Nodes    []graphqlDiscussion `json:"nodes"`
+	} `json:"discussions"`
+}
+
+type discussionsResponse struct {
+	Data struct {
+		Project *struct {
+			MergeRequests struct {
+				PageInfo graphqlPageInfo       `json:"pageInfo"`
+				Nodes    []graphqlMergeRequest `json:"nodes"`
+			} `json:"mergeRequests"`
+		} `json:"project"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// PrefetchDiscussions returns discussions of the merge requests by IID. Merge requests with more discussions or
+// notes than fit into the batch are left out and fetched by REST. GraphQL does not expose line ranges, multiline
+// comments are anchored to their last line as gitlab positions them.
+func (s *gitlabSource) PrefetchDiscussions(mergeRequests []*MergeRequest) map[int][]*Discussion {
+	if !*gitlabGraphQL {
+		return nil
+	}
+	var iids []string
+	byIID := map[int]*MergeRequest{}
+	for _, mr := range mergeRequests {
+		if isMigrated(mr) {
+			iids = append(iids, strconv.Itoa(mr.IID))
+			byIID[mr.IID] = mr
+		}
+	}
+	if len(iids) == 0 {
+		return nil
+	}
+	prefetched := map[int][]*Discussion{}
+	variables := map[string]interface{}{"project": s.project.PathWithNamespace, "iids": iids}
+	for {
+		response, err := queryGraphQL(s.api, graphqlRequest{Query: discussionsQuery, Variables: variables})
+		if err != nil {
+			log.Warnf("cannot prefetch discussions by GraphQL, falling back to REST: %s", err)
+			return prefetched
+		}
+		for _, graphqlMR := range response.Data.Project.MergeRequests.Nodes {
+			iid, err := strconv.Atoi(graphqlMR.IID)
+			if err != nil || byIID[iid] == nil {
+				continue
+			}
+			if discussions, ok := translateGraphQLDiscussions(graphqlMR, byIID[iid]); ok {
+				sortDiscussions(discussions)
+				prefetched[iid] = discussions
+			}
+		}
+		pageInfo := response.Data.Project.MergeRequests.PageInfo
+		if !pageInfo.HasNextPage {
+			return prefetched
+		}
+		variables["after"] = pageInfo.EndCursor
+	}
+}
+
+func queryGraphQL(api gitlabAPI, query graphqlRequest) (*discussionsResponse, error) {
+	response, err := api.QueryGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("%s", response.Errors[0].Message)
+	}
+	if response.Data.Project == nil {
+		return nil, fmt.Errorf("project %s not found", query.Variables["project"])
+	}
+	return response, nil
+}
+
+// translateGraphQLDiscussions converts discussions of the merge request as REST ones are, incomplete ones are refused
+func translateGraphQLDiscussions(graphqlMR graphqlMergeRequest, mr *MergeRequest) ([]*Discussion, bool) {
+	if graphqlMR.Discussions.PageInfo.HasNextPage {
+		return nil, false
+	}
+	discussions := []*Discussion{}
+	for _, graphqlDiscussion := range graphqlMR.Discussions.Nodes {
+		if graphqlDiscussion.Notes.PageInfo.HasNextPage {
+			return nil, false
+		}
+		discussion := &Discussion{ID: globalIDSuffix(graphqlDiscussion.ID), Original: graphqlDiscussion}
+		for _, graphqlNote := range graphqlDiscussion.Notes.Nodes {
+			id, _ := strconv.Atoi(globalIDSuffix(graphqlNote.ID))
+			note := &Note{
+				ID:         id,
+				Body:       graphqlNote.Body,
+				System:     graphqlNote.System,
+				Resolvable: graphqlNote.Resolvable,
+				Resolved:   graphqlNote.Resolved,
+				CreatedAt:  graphqlNote.CreatedAt,
+				UpdatedAt:  graphqlNote.UpdatedAt,
+				WebURL:     fmt.Sprintf("%s/diffs#note_%d", mr.WebURL, id),
+			}
+			if author := graphqlNote.Author; author != nil {
+				note.Author.ID, _ = strconv.Atoi(globalIDSuffix(author.ID))
+				note.Author.Username = author.Username
+				note.Author.Name = author.Name
+				note.Author.AvatarURL = author.AvatarURL
+				note.Author.WebURL = author.WebURL
+			}
+			if resolver := graphqlNote.ResolvedBy; resolver != nil {
+				note.ResolvedBy.Username = resolver.Username
+				note.ResolvedBy.Name = resolver.Name
+				note.ResolvedBy.WebURL = resolver.WebURL
+			}
+			if position := graphqlNote.Position; position != nil {
+				note.Position = &NotePosition{
+					HeadSHA: position.DiffRefs.HeadSHA,
+					NewPath: position.NewPath,
+					NewLine: position.NewLine,
+					OldPath: position.OldPath,
+					OldLine: position.OldLine,
+				}
+			}
+			discussion.Notes = append(discussion.Notes, note)
+		}
+		if len(discussion.Notes) > 0 {
+			discussions = append(discussions, discussion)
+		}
+	}
+	return discussions, true
+}
+
+// globalIDSuffix returns the ID part of GraphQL global ID, e.g. 123 of gid://gitlab/Note/123
+func globalIDSuffix(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefetchDiscussions(t *testing.T) {
+	*gitlabGraphQL = true
+	defer func() { *gitlabGraphQL = false }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		//client configures its rate limiter by the first request to REST API
+		if request.URL.Path != "/api/graphql" {
+			http.NotFound(w, request)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": {"project": {"mergeRequests": {"pageInfo": {"hasNextPage": false}, "nodes": [
+			{"iid": "1", "discussions": {"pageInfo": {"hasNextPage": false}, "nodes": [
+				{"id": "gid://gitlab/Discussion/abc", "notes": {"pageInfo": {"hasNextPage": false}, "nodes": [
+					{"id": "gid://gitlab/Note/7", "body": "LGTM", "resolved": true, "createdAt": "2021-01-02T03:04:05Z", "updatedAt": "2021-01-02T03:04:05Z",
+					 "author": {"id": "gid://gitlab/User/3", "username": "john-doe", "name": "John Doe", "webUrl": "https://gitlab.com/john-doe"}}
+				]}}
+			]}},
+			{"iid": "2", "discussions": {"pageInfo": {"hasNextPage": false}, "nodes": [
+				{"id": "gid://gitlab/Discussion/def", "notes": {"pageInfo": {"hasNextPage": false}, "nodes": [
+					{"id": "gid://gitlab/Note/8", "body": "typo", "position": {"oldPath": "README.md", "newPath": "README.md", "newLine": 3, "diffRefs": {"headSha": "c2"}}}
+				]}}
+			]}},
+			{"iid": "3", "discussions": {"pageInfo": {"hasNextPage": true}, "nodes": []}}
+		]}}}}`)
+	}))
+	defer server.Close()
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &gitlabSource{api: gitlabClientAPI{client: client}, project: &gitlab.Project{PathWithNamespace: "group/php"}}
+	mergeRequests := []*MergeRequest{{IID: 1, State: "opened"}, {IID: 2, State: "opened"}, {IID: 3, State: "opened"}}
+	prefetched := source.PrefetchDiscussions(mergeRequests)
+
+	if len(prefetched) != 2 || len(prefetched[1]) != 1 || len(prefetched[2]) != 1 {
+		t.Fatalf("merge requests 1 and 2 should be prefetched, got %v", prefetched)
+	}
+	discussion := prefetched[1][0]
+	note := discussion.Notes[0]
+	actual := []interface{}{discussion.ID, note.ID, note.Body, note.Resolved, note.Author.ID, note.Author.Username, note.CreatedAt.Year()}
+	if diff := deep.Equal(actual, []interface{}{"abc", 7, "LGTM", true, 3, "john-doe", 2021}); diff != nil {
+		t.Error(diff)
+	}
+	expected := &NotePosition{HeadSHA: "c2", OldPath: "README.md", NewPath: "README.md", NewLine: 3}
+	if diff := deep.Equal(prefetched[2][0].Notes[0].Position, expected); diff != nil {
+		t.Error(diff)
+	}
+}
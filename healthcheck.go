@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	healthTimeout = 15 * time.Second
+	// minimumAzdoAPIVersion is the API version the AzDO SDK of the migration sends
+	minimumAzdoAPIVersion = "5.1"
+	// azdoPullRequestsArea and azdoPullRequestsResource locate the API the migration depends on the most
+	azdoPullRequestsArea     = "git"
+	azdoPullRequestsResource = "pullRequests"
+)
+
+var healthcheckCommand = kingpin.Command("healthcheck", "Verify reachability, TLS, authentication and API versions of gitlab instances and AzDO organization and print identities of their tokens")
+
+// healthEndpoint is an endpoint the migration talks to, checks of unreachable endpoint stop at the first failure
+type healthEndpoint struct {
+	name  string
+	url   string
+	token string
+	api   func(client *http.Client, baseURL string, token string) (string, string, error)
+}
+
+// healthcheck answers whether the endpoints work from here, it returns number of failed checks
+func healthcheck() int {
+	failures := 0
+	check := func(err error, subject string, detail string) bool {
+		if err != nil {
+			failures++
+			log.Errorf("✘ %s: %s", subject, err)
+			return false
+		}
+		log.Infof("✔ %s: %s", subject, detail)
+		return true
+	}
+	endpoints := []healthEndpoint{{name: "gitlab", url: *gitlabURL, token: *gitlabToken, api: checkGitlabAPI}}
+	instances, err := healthGitlabInstances()
+	if err != nil {
+		check(err, "gitlab instances of "+*configFile, "")
+	}
+	endpoints = append(endpoints, instances...)
+	if *azdoOrganization == "" || *azdoToken == "" {
+		check(fmt.Errorf("--azdo-org and --azdo-token are required"), "AzDO", "")
+	} else {
+		endpoints = append(endpoints, healthEndpoint{name: "AzDO", url: *azdoOrganization, token: *azdoToken, api: checkAzdoAPI})
+	}
+
+	client := &http.Client{Transport: traced(baseTransport), Timeout: healthTimeout}
+	for _, endpoint := range endpoints {
+		subject := fmt.Sprintf("%s %s", endpoint.name, endpoint.url)
+		if endpoint.token == "" {
+			check(fmt.Errorf("token is not set"), subject, "")
+			continue
+		}
+		parsed, err := url.Parse(endpoint.url)
+		if err == nil && parsed.Host == "" {
+			err = fmt.Errorf("URL has no host")
+		}
+		if err != nil {
+			check(err, subject+" URL", "")
+			continue
+		}
+		detail, err := checkReachability(parsed)
+		if !check(err, subject+" is reachable", detail) {
+			continue
+		}
+		if parsed.Scheme == "https" {
+			detail, err := checkTLS(parsed)
+			if !check(err, subject+" TLS", detail) {
+				continue
+			}
+		}
+		//identity is known once the token is accepted, API version can fail after it
+		identity, apiVersion, err := endpoint.api(client, strings.TrimSuffix(endpoint.url, "/"), endpoint.token)
+		if identity == "" {
+			check(err, subject+" authentication", "")
+			continue
+		}
+		check(nil, subject+" authentication", "authenticated as "+identity)
+		check(err, subject+" API version", apiVersion)
+	}
+	return failures
+}
+
+// healthGitlabInstances returns gitlab instances of the config file, healthcheck works without one
+func healthGitlabInstances() ([]healthEndpoint, error) {
+	if *configFile == "-" {
+		return nil, nil
+	}
+	if _, err := os.Stat(*configFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	loaded, _, err := loadConfig(*configFile, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range loaded.GitlabInstances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var endpoints []healthEndpoint
+	for _, name := range names {
+		instance := loaded.GitlabInstances[name]
+		token := os.Getenv(instance.TokenEnv)
+		redactor.add(token)
+		endpoints = append(endpoints, healthEndpoint{name: "gitlab instance " + name, url: instance.URL, token: token, api: checkGitlabAPI})
+	}
+	return endpoints, nil
+}
+
+// healthProxy returns the proxy requests to the endpoint go through like clients of the migration do, tests replace it
+var healthProxy = http.ProxyFromEnvironment
+
+// endpointPort returns port of the endpoint or the default one of its scheme
+func endpointPort(endpoint *url.URL) string {
+	if port := endpoint.Port(); port != "" {
+		return port
+	}
+	if endpoint.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// checkReachability connects to the endpoint, or to its proxy from HTTP(S)_PROXY as API requests do
+func checkReachability(endpoint *url.URL) (string, error) {
+	proxy, err := healthProxy(&http.Request{URL: endpoint})
+	if err != nil {
+		return "", fmt.Errorf("invalid proxy in HTTP(S)_PROXY: %s", err)
+	}
+	host, port, via := endpoint.Hostname(), endpointPort(endpoint), ""
+	if proxy != nil {
+		host, port, via = proxy.Hostname(), endpointPort(proxy), " through proxy "+proxy.Host
+	}
+	addresses, err := net.LookupHost(host)
+	if err != nil && proxy != nil {
+		return "", fmt.Errorf("DNS lookup of proxy %s from HTTP(S)_PROXY failed: %s", proxy.Host, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("DNS lookup failed, is a proxy or VPN needed? %s", err)
+	}
+	start := time.Now()
+	connection, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), healthTimeout)
+	if err != nil && proxy != nil {
+		return "", fmt.Errorf("cannot connect to proxy %s from HTTP(S)_PROXY: %s", proxy.Host, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot connect to port %s of %s, is it blocked by a firewall? %s", port, strings.Join(addresses, ", "), err)
+	}
+	defer connection.Close()
+	return fmt.Sprintf("%s connected in %s%s", connection.RemoteAddr(), time.Since(start).Round(time.Millisecond), via), nil
+}
+
+// checkTLS verifies certificate of the endpoint, through a tunnel of the HTTP proxy when requests use one
+func checkTLS(endpoint *url.URL) (string, error) {
+	proxy, err := healthProxy(&http.Request{URL: endpoint})
+	if err != nil {
+		return "", fmt.Errorf("invalid proxy in HTTP(S)_PROXY: %s", err)
+	}
+	if proxy != nil && proxy.Scheme != "http" {
+		return fmt.Sprintf("not checked through %s proxy %s", proxy.Scheme, proxy.Host), nil
+	}
+	address := net.JoinHostPort(endpoint.Hostname(), endpointPort(endpoint))
+	var connection net.Conn
+	if proxy == nil {
+		connection, err = net.DialTimeout("tcp", address, healthTimeout)
+	} else {
+		connection, err = dialTunnel(proxy, address)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer connection.Close()
+	connection.SetDeadline(time.Now().Add(healthTimeout))
+	client := tls.Client(connection, &tls.Config{ServerName: endpoint.Hostname()})
+	if err := client.Handshake(); err != nil {
+		return "", explainTLSError(err)
+	}
+	certificate := client.ConnectionState().PeerCertificates[0]
+	detail := fmt.Sprintf("%s issued by %s valid until %s", certificate.Subject.CommonName, certificate.Issuer.CommonName, certificate.NotAfter.Format("2006-01-02"))
+	if proxy != nil {
+		detail += " through proxy " + proxy.Host
+	}
+	return detail, nil
+}
+
+// dialTunnel opens a connection to the address by CONNECT request of the HTTP proxy
+func dialTunnel(proxy *url.URL, address string) (net.Conn, error) {
+	connection, err := net.DialTimeout("tcp", net.JoinHostPort(proxy.Hostname(), endpointPort(proxy)), healthTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to proxy %s from HTTP(S)_PROXY: %s", proxy.Host, err)
+	}
+	connection.SetDeadline(time.Now().Add(healthTimeout))
+	request := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: address}, Host: address, Header: http.Header{}}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		request.SetBasicAuth(proxy.User.Username(), password)
+		request.Header.Set("Proxy-Authorization", request.Header.Get("Authorization"))
+		request.Header.Del("Authorization")
+	}
+	if err := request.Write(connection); err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("cannot request tunnel from proxy %s: %s", proxy.Host, err)
+	}
+	response, err := http.ReadResponse(bufio.NewReader(connection), request)
+	if err != nil {
+		connection.Close()
+		return nil, fmt.Errorf("cannot request tunnel from proxy %s: %s", proxy.Host, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		connection.Close()
+		return nil, fmt.Errorf("proxy %s refused tunnel to %s: %s", proxy.Host, address, response.Status)
+	}
+	return connection, nil
+}
+
+// explainTLSError tells apart the usual causes, TLS inspecting proxies replace certificates by ones of a private CA
+func explainTLSError(err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("certificate is issued by unknown authority %s, add the CA to the system trust store or set SSL_CERT_FILE: %s", unknownAuthority.Cert.Issuer.CommonName, err)
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return fmt.Errorf("certificate expired or the system clock is wrong: %s", err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("certificate is not valid for the host, is a proxy intercepting TLS? %s", err)
+	}
+	return err
+}
+
+// checkGitlabAPI returns the token owner and gitlab version, version endpoint needs read_api scope as well
+func checkGitlabAPI(client *http.Client, baseURL string, token string) (string, string, error) {
+	if !strings.HasSuffix(baseURL, "/api/v4") {
+		baseURL += "/api/v4"
+	}
+	get := func(path string, v interface{}) error {
+		request, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("PRIVATE-TOKEN", token)
+		return healthRequest(client, request, v)
+	}
+	var user struct {
+		Username string `json:"username"`
+		Name     string `json:"name"`
+		IsAdmin  bool   `json:"is_admin"`
+	}
+	if err := get("/user", &user); err != nil {
+		return "", "", err
+	}
+	identity := fmt.Sprintf("%s (%s)", user.Username, user.Name)
+	if user.IsAdmin {
+		identity += ", administrator"
+	}
+	var version struct {
+		Version  string `json:"version"`
+		Revision string `json:"revision"`
+	}
+	if err := get("/version", &version); err != nil {
+		return identity, "", fmt.Errorf("cannot read gitlab version, does the token have read_api scope? %s", err)
+	}
+	return identity, fmt.Sprintf("gitlab %s (%s), API v4", version.Version, version.Revision), nil
+}
+
+// checkAzdoAPI returns the token owner and API version of pull requests the organization serves, AzDO Server older
+// than the SDK of the migration does not serve it
+func checkAzdoAPI(client *http.Client, organization string, token string) (string, string, error) {
+	send := func(method string, path string, v interface{}) error {
+		request, err := http.NewRequest(method, organization+path, nil)
+		if err != nil {
+			return err
+		}
+		request.SetBasicAuth("", token)
+		request.Header.Set("Accept", "application/json")
+		return healthRequest(client, request, v)
+	}
+	var connectionData struct {
+		AuthenticatedUser struct {
+			ProviderDisplayName string `json:"providerDisplayName"`
+			Properties          struct {
+				Account struct {
+					Value string `json:"$value"`
+				} `json:"Account"`
+			} `json:"properties"`
+		} `json:"authenticatedUser"`
+		DeploymentType string `json:"deploymentType"`
+	}
+	if err := send(http.MethodGet, "/_apis/connectionData", &connectionData); err != nil {
+		return "", "", err
+	}
+	user := connectionData.AuthenticatedUser
+	identity := fmt.Sprintf("%s (%s), %s deployment", user.Properties.Account.Value, user.ProviderDisplayName, connectionData.DeploymentType)
+	var locations struct {
+		Value []struct {
+			Area         string `json:"area"`
+			ResourceName string `json:"resourceName"`
+			MaxVersion   string `json:"maxVersion"`
+		} `json:"value"`
+	}
+	if err := send(http.MethodOptions, "/_apis/", &locations); err != nil {
+		return identity, "", fmt.Errorf("cannot read API locations: %s", err)
+	}
+	for _, location := range locations.Value {
+		if !strings.EqualFold(location.Area, azdoPullRequestsArea) || !strings.EqualFold(location.ResourceName, azdoPullRequestsResource) {
+			continue
+		}
+		if compareVersions(location.MaxVersion, minimumAzdoAPIVersion) < 0 {
+			return identity, "", fmt.Errorf("pull requests API %s is older than %s the migration uses", location.MaxVersion, minimumAzdoAPIVersion)
+		}
+		return identity, "pull requests API " + location.MaxVersion, nil
+	}
+	return identity, "", fmt.Errorf("organization does not serve pull requests API")
+}
+
+// healthRequest decodes JSON response, AzDO answers invalid tokens by a sign-in page instead of an error
+func healthRequest(client *http.Client, request *http.Request, v interface{}) error {
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case response.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%s responded %s, the token is invalid or expired", request.URL.Path, response.Status)
+	case response.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s responded %s, the token lacks scope: %s", request.URL.Path, response.Status, strings.TrimSpace(string(content)))
+	case response.StatusCode >= 300:
+		return fmt.Errorf("%s responded %s: %s", request.URL.Path, response.Status, strings.TrimSpace(string(content)))
+	case response.StatusCode == http.StatusNonAuthoritativeInfo || strings.Contains(response.Header.Get("Content-Type"), "text/html"):
+		return fmt.Errorf("%s responded with a sign-in page, the token is invalid or expired", request.URL.Path)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s responded with unexpected content: %s", request.URL.Path, err)
+	}
+	return nil
+}
+
+// compareVersions compares dotted versions of API and releases, v prefix and preview suffix are ignored
+func compareVersions(a string, b string) int {
+	left := strings.Split(strings.SplitN(strings.TrimPrefix(a, "v"), "-", 2)[0], ".")
+	right := strings.Split(strings.SplitN(strings.TrimPrefix(b, "v"), "-", 2)[0], ".")
+	for i := 0; i < len(left) || i < len(right); i++ {
+		var l, r int
+		if i < len(left) {
+			l, _ = strconv.Atoi(left[i])
+		}
+		if i < len(right) {
+			r, _ = strconv.Atoi(right[i])
+		}
+		if l != r {
+			if l < r {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckGitlabAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v4/user":
+			w.Write([]byte(`{"username": "migration", "name": "Migration Bot"}`))
+		case "/api/v4/version":
+			w.Write([]byte(`{"version": "14.3.0-ee", "revision": "ceec8accb09"}`))
+		}
+	}))
+	defer server.Close()
+	identity, apiVersion, err := checkGitlabAPI(server.Client(), server.URL, "token")
+	if diff := deep.Equal([]interface{}{identity, apiVersion, err}, []interface{}{"migration (Migration Bot)", "gitlab 14.3.0-ee (ceec8accb09), API v4", nil}); diff != nil {
+		t.Error(diff)
+	}
+	if identity, _, err := checkGitlabAPI(server.Client(), server.URL, "expired"); identity != "" || err == nil {
+		t.Error("rejected token should fail authentication")
+	}
+}
+
+func TestCheckAzdoAPI(t *testing.T) {
+	maxVersion := "6.1-preview.1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "token" {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+			return
+		}
+		switch {
+		case r.URL.Path == "/org/_apis/connectionData":
+			w.Write([]byte(`{"authenticatedUser": {"providerDisplayName": "Migration Bot", "properties": {"Account": {"$value": "bot@drmax.eu"}}}, "deploymentType": "hosted"}`))
+		case r.URL.Path == "/org/_apis/" && r.Method == http.MethodOptions:
+			w.Write([]byte(`{"value": [{"area": "git", "resourceName": "repositories", "maxVersion": "6.1"}, {"area": "git", "resourceName": "pullRequests", "maxVersion": "` + maxVersion + `"}]}`))
+		}
+	}))
+	defer server.Close()
+	identity, apiVersion, err := checkAzdoAPI(server.Client(), server.URL+"/org", "token")
+	if diff := deep.Equal([]interface{}{identity, apiVersion, err}, []interface{}{"bot@drmax.eu (Migration Bot), hosted deployment", "pull requests API 6.1-preview.1", nil}); diff != nil {
+		t.Error(diff)
+	}
+	maxVersion = "5.0"
+	if _, _, err := checkAzdoAPI(server.Client(), server.URL+"/org", "token"); err == nil {
+		t.Error("API older than the SDK should fail")
+	}
+	if identity, _, err := checkAzdoAPI(server.Client(), server.URL+"/org", "expired"); identity != "" || err == nil {
+		t.Error("sign-in page should fail authentication")
+	}
+}
+
+func TestCheckThroughProxy(t *testing.T) {
+	endpoint := httptest.NewTLSServer(http.NotFoundHandler())
+	defer endpoint.Close()
+	var tunnels []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		tunnels = append(tunnels, request.Host)
+		target, err := net.Dial("tcp", request.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		client, _, _ := w.(http.Hijacker).Hijack()
+		io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			io.Copy(target, client)
+			target.Close()
+		}()
+		io.Copy(client, target)
+		client.Close()
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	healthProxy = http.ProxyURL(proxyURL)
+	defer func() { healthProxy = http.ProxyFromEnvironment }()
+
+	//the endpoint host is unreachable directly, only the proxy connects to it
+	endpointURL, _ := url.Parse(endpoint.URL)
+	detail, err := checkReachability(endpointURL)
+	if err != nil || !strings.HasSuffix(detail, "through proxy "+proxyURL.Host) {
+		t.Errorf("proxy should be connected, got %q: %v", detail, err)
+	}
+	//certificate of the test server is self-signed, failing verification proves the handshake went through the tunnel
+	if _, err := checkTLS(endpointURL); err == nil || !strings.Contains(err.Error(), "unknown authority") {
+		t.Errorf("certificate should be verified through the proxy: %v", err)
+	}
+	if diff := deep.Equal(tunnels, []string{endpointURL.Host}); diff != nil {
+		t.Error(diff)
+	}
+}
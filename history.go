@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// backfillCommitFormat identifies commits by everything truncation keeps, the parents are what it changes
+const backfillCommitFormat = "%H %P%x00%T %ae %at %ce %ct"
+
+var (
+	historyDepth = kingpin.Flag("history-depth", "Push only the last N commits of every branch and tag with --transfer-mode=mirror so that teams of huge repositories can start working sooner, commits at the depth become roots and --backfill adds the older history later, 0 pushes the full history").Default("0").Uint()
+	backfill     = kingpin.Flag("backfill", "Instead of migrating, attach full gitlab history to repositories migrated with --history-depth, the truncated roots get replace refs (refs/replace/*) pointing at their original parents").Default("false").Bool()
+)
+
+// initHistory checks the backfill can fetch full history of the projects
+func initHistory() error {
+	if *backfill && *transferMode == transferImport {
+		return fmt.Errorf("--backfill needs --transfer-mode=%s or %s", transferMirror, transferBundle)
+	}
+	return nil
+}
+
+// truncatesHistory tells whether the mirror is fetched shallow, the backfill fetches full history of the same projects
+func truncatesHistory() bool {
+	return *historyDepth > 0 && *transferMode == transferMirror && !*backfill
+}
+
+// fetchDepth are arguments of git fetch limiting the fetched history
+func fetchDepth() []string {
+	if !truncatesHistory() {
+		return nil
+	}
+	return []string{"--depth", strconv.FormatUint(uint64(*historyDepth), 10)}
+}
+
+// truncateHistory turns commits at the boundary of the shallow fetch into roots, AzDO does not accept push of
+// shallow repository. Commits above them get new SHAs as their parents change
+func truncateHistory(repository *localRepository, project project) error {
+	shallow := filepath.Join(repository.dir, "shallow")
+	if _, err := os.Stat(shallow); os.IsNotExist(err) {
+		return nil
+	}
+	log.Debugf("truncating history of %s to %d commits", project.gitlabProject.PathWithNamespace, *historyDepth)
+	if err := repository.rewriteHistory(); err != nil {
+		return err
+	}
+	if err := os.Remove(shallow); err != nil {
+		return err
+	}
+	project.report.problem("history is truncated to the last %d commits, run with --backfill to attach the rest", *historyDepth)
+	return nil
+}
+
+// backfillHistories attaches full history to repositories of projects migrated with --history-depth
+func backfillHistories(azdoCtx context.Context, azdoClient git.Client, projects []project) {
+	for i, project := range projects {
+		log.Infof("backfilling history of %s (%d/%d)", project.gitlabProject.PathWithNamespace, i+1, len(projects))
+		project.report = report.project(project.gitlabProject.PathWithNamespace, project.AzdoProject)
+		if err := backfillHistory(azdoCtx, azdoClient, project); err != nil {
+			project.report.failWith(fmt.Errorf("cannot backfill history of %s: %w", project.gitlabProject.PathWithNamespace, err))
+			recordResult(projectsMetric, "project", false)
+			continue
+		}
+		recordResult(projectsMetric, "project", true)
+	}
+}
+
+// backfillHistory pushes replace refs grafting the roots of the AzDO repository onto parents of the commits they were
+// truncated from, commits already pushed keep their SHAs so that pull requests and clones stay valid
+func backfillHistory(azdoCtx context.Context, azdoClient git.Client, project project) error {
+	if rewritesHistory(project) {
+		return classified(failureValidation, fmt.Errorf("history is rewritten by subdirectory, prefix or stripped files, its commits cannot be matched"))
+	}
+	azdoRepository, err := findMigratedRepository(azdoCtx, project, azdoClient)
+	if err != nil {
+		return err
+	}
+	target, err := azdoRemote(*azdoRepository.RemoteUrl)
+	if err != nil {
+		return err
+	}
+	repository, err := newLocalRepository()
+	if err != nil {
+		return err
+	}
+	defer repository.remove()
+
+	if err := fetchSource(project, repository); err != nil {
+		return err
+	}
+	if _, err := repository.run("fetch", "--quiet", target, "+refs/heads/*:refs/azdo/heads/*", "+refs/tags/*:refs/azdo/tags/*"); err != nil {
+		return err
+	}
+	roots, err := repository.run("log", "--max-parents=0", "--glob=refs/azdo", "--format="+backfillCommitFormat)
+	if err != nil {
+		return err
+	}
+	finder := newGraftFinder(roots)
+	if err := repository.stream(finder.consume, "log", "--branches", "--tags", "--format="+backfillCommitFormat); err != nil {
+		return err
+	}
+	var refspecs []string
+	for _, root := range finder.sortedRoots() {
+		if _, err := repository.run(append([]string{"replace", "--force", "--graft", root}, finder.grafts[root]...)...); err != nil {
+			return err
+		}
+		refspecs = append(refspecs, fmt.Sprintf("+refs/replace/%s:refs/replace/%s", root, root))
+	}
+	if len(refspecs) == 0 {
+		log.Infof("history of %s is complete already", *azdoRepository.Name)
+		project.report.migrated(*azdoRepository.WebUrl)
+		return nil
+	}
+	log.Debugf("pushing %d replace refs into %s", len(refspecs), *azdoRepository.Name)
+	if err := repository.push(target, refspecs...); err != nil {
+		return err
+	}
+	audit.record("repository.backfill", project.AzdoProject, azdoRepository.Id.String(), map[string]interface{}{
+		"sourceUrl": project.gitlabProject.HTTPURLToRepo,
+		"grafts":    len(refspecs),
+	})
+	project.report.migrated(*azdoRepository.WebUrl)
+	return nil
+}
+
+// graftFinder matches roots of the truncated history to commits of the full history they were rewritten from, the
+// full history is streamed through consume as it does not fit into memory of huge repositories
+type graftFinder struct {
+	// roots are root commits of the AzDO repository by their identity
+	roots map[string]string
+	// grafts are original parents of the roots
+	grafts map[string][]string
+}
+
+func newGraftFinder(roots string) *graftFinder {
+	finder := &graftFinder{roots: map[string]string{}, grafts: map[string][]string{}}
+	for _, line := range strings.Split(roots, "\n") {
+		if sha, _, identity, ok := parseBackfillCommit(line); ok {
+			finder.roots[identity] = sha
+		}
+	}
+	return finder
+}
+
+// consume remembers parents of the original commit a root was truncated from, roots which are roots in gitlab too
+// are kept as they are
+func (f *graftFinder) consume(line string) {
+	sha, parents, identity, ok := parseBackfillCommit(line)
+	if !ok || len(parents) == 0 {
+		return
+	}
+	root, ok := f.roots[identity]
+	if !ok || root == sha {
+		return
+	}
+	if _, found := f.grafts[root]; !found {
+		f.grafts[root] = parents
+	}
+}
+
+func (f *graftFinder) sortedRoots() []string {
+	var roots []string
+	for root := range f.grafts {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// parseBackfillCommit reads a line of backfillCommitFormat
+func parseBackfillCommit(line string) (string, []string, string, bool) {
+	parts := strings.SplitN(line, "\x00", 2)
+	if len(parts) != 2 {
+		return "", nil, "", false
+	}
+	commits := strings.Fields(parts[0])
+	if len(commits) == 0 {
+		return "", nil, "", false
+	}
+	return commits[0], commits[1:], parts[1], true
+}
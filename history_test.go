@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"strings"
+	"testing"
+)
+
+func TestFetchDepth(t *testing.T) {
+	*transferMode = transferMirror
+	defer func() { *transferMode = "" }()
+	if depth := fetchDepth(); depth != nil {
+		t.Errorf("full history should be fetched without --history-depth, got %v", depth)
+	}
+	*historyDepth = 50
+	defer func() { *historyDepth = 0 }()
+	if diff := deep.Equal(fetchDepth(), []string{"--depth", "50"}); diff != nil {
+		t.Error(diff)
+	}
+	if !rewritesHistory(project{}) {
+		t.Error("truncated history should not be expected to match gitlab SHAs")
+	}
+
+	*backfill = true
+	if depth := fetchDepth(); depth != nil {
+		t.Errorf("backfill should fetch full history, got %v", depth)
+	}
+	*backfill = false
+	*transferMode = transferBundle
+	if depth := fetchDepth(); depth != nil {
+		t.Errorf("export bundle should be fetched whole, got %v", depth)
+	}
+}
+
+func TestInitHistory(t *testing.T) {
+	*backfill = true
+	defer func() { *backfill = false }()
+	*transferMode = transferImport
+	defer func() { *transferMode = "" }()
+	if err := initHistory(); err == nil {
+		t.Error("backfill should need local mirror")
+	}
+	*transferMode = transferMirror
+	if err := initHistory(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGraftFinder(t *testing.T) {
+	line := func(sha string, parents string, identity string) string {
+		return strings.TrimSpace(sha+" "+parents) + "\x00" + identity
+	}
+	roots := strings.Join([]string{
+		line("r1", "", "t5 dev@example.com 5 dev@example.com 5"),
+		line("c1", "", "t1 dev@example.com 1 dev@example.com 1"),
+		line("r2", "", "t9 ops@example.com 9 ops@example.com 9"),
+	}, "\n")
+	finder := newGraftFinder(roots)
+	for _, original := range []string{
+		line("c6", "o5", "t6 dev@example.com 6 dev@example.com 6"),
+		line("o5", "o4", "t5 dev@example.com 5 dev@example.com 5"),
+		line("o4", "o3 o2", "t4 dev@example.com 4 dev@example.com 4"),
+		line("c1", "", "t1 dev@example.com 1 dev@example.com 1"),
+		line("x5", "o4", "t5 dev@example.com 5 dev@example.com 5"),
+		"",
+	} {
+		finder.consume(original)
+	}
+	if diff := deep.Equal(finder.grafts, map[string][]string{"r1": {"o4"}}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(finder.sortedRoots(), []string{"r1"}); diff != nil {
+		t.Error(diff)
+	}
+}
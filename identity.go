@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/identity"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/location"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	identityMapFile = kingpin.Flag("identity-map", "JSON file mapping gitlab usernames to AzDO user emails or principal names").Default("").String()
+	identities      = &identityMap{}
+)
+
+// identityMap resolves gitlab users to AzDO identity IDs, it resolves nothing until loaded and every user is looked up
+// in AzDO only once
+type identityMap struct {
+	mutex    sync.Mutex
+	azdoCtx  context.Context
+	client   identity.Client
+	users    map[string]string
+	resolved map[string]string
+	ownerID  string
+}
+
+func loadIdentityMap(azdoCtx context.Context, connection *azuredevops.Connection, path string) (*identityMap, error) {
+	if path == "" {
+		return &identityMap{}, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]string{}
+	if err := json.Unmarshal(content, &users); err != nil {
+		return nil, fmt.Errorf("cannot parse identity map %s: %s", path, err)
+	}
+	client, err := identity.NewClient(azdoCtx, connection)
+	if err != nil {
+		return nil, err
+	}
+	connectionData, err := location.NewClient(azdoCtx, connection).GetConnectionData(azdoCtx, location.GetConnectionDataArgs{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read identity of the AzDO token owner: %s", err)
+	}
+	return &identityMap{
+		azdoCtx:  azdoCtx,
+		client:   client,
+		users:    users,
+		resolved: map[string]string{},
+		ownerID:  connectionData.AuthenticatedUser.Id.String(),
+	}, nil
+}
+
+// resolve returns AzDO identity ID of gitlab user, users missing in the map or in AzDO are not resolved
+func (m *identityMap) resolve(user *User) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if id, ok := m.resolved[user.Username]; ok {
+		return id, id != ""
+	}
+	//users of the starter map written by users command are empty until somebody fills them in
+	account, ok := m.users[user.Username]
+	if !ok || account == "" {
+		return "", false
+	}
+	id := ""
+	found, err := m.client.ReadIdentities(m.azdoCtx, identity.ReadIdentitiesArgs{
+		SearchFilter: gitlab.String("General"),
+		FilterValue:  &account,
+	})
+	switch {
+	case err != nil:
+		log.Warnf("cannot look up AzDO identity %s of gitlab user %s: %s", account, user.Username, err)
+	case found == nil || len(*found) == 0 || (*found)[0].Id == nil:
+		log.Warnf("AzDO identity %s of gitlab user %s does not exist", account, user.Username)
+	default:
+		id = (*found)[0].Id.String()
+	}
+	m.resolved[user.Username] = id
+	return id, id != ""
+}
+
+// isOwner tells whether the identity is the one the AzDO token belongs to, AzDO lets only the owner act as it
+func (m *identityMap) isOwner(id string) bool {
+	return m.ownerID != "" && m.ownerID == id
+}
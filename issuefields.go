@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/workitemtracking"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"sort"
+	"strconv"
+)
+
+const (
+	issueWeight       = "weight"
+	issueTimeEstimate = "timeEstimate"
+	issueTimeSpent    = "timeSpent"
+)
+
+var (
+	issueFields = kingpin.Flag("issue-fields", "Copy weight, time estimate and time spent of gitlab issues of the work item map to fields of their work items, workItemFields of the project map them to fields of its process").Default("false").Bool()
+	// defaultWorkItemFields are fields of the Agile process, Scrum keeps the weight in Microsoft.VSTS.Scheduling.Effort
+	defaultWorkItemFields = map[string]string{
+		issueWeight:       "Microsoft.VSTS.Scheduling.StoryPoints",
+		issueTimeEstimate: "Microsoft.VSTS.Scheduling.OriginalEstimate",
+		issueTimeSpent:    "Microsoft.VSTS.Scheduling.CompletedWork",
+	}
+)
+
+func validateWorkItemFields(project project) error {
+	for name := range project.WorkItemFields {
+		if _, ok := defaultWorkItemFields[name]; !ok {
+			return fmt.Errorf("workItemFields %s is not one of %s, %s, %s", name, issueWeight, issueTimeEstimate, issueTimeSpent)
+		}
+	}
+	return nil
+}
+
+// setIssueFields copies weight and time tracking (--issue-fields) and labels (--issue-labels) of the issue to its work
+// item, fields the issue does not use are left alone
+func setIssueFields(azdoCtx context.Context, workItemClient workitemtracking.Client, project project, iid int, workItemID int) {
+	issue, _, err := project.gitlab.client.Issues.GetIssue(project.gitlabProject.ID, iid)
+	if err != nil {
+		project.report.problem("cannot fetch issue %d, its fields are not migrated: %s", iid, err)
+		return
+	}
+	patch := []webapi.JsonPatchOperation{}
+	if *issueFields {
+		patch = append(patch, *prepareFieldPatch(project, issue)...)
+	}
+	if *issueLabels && len(issue.Labels) > 0 {
+		workItem, err := workItemClient.GetWorkItem(azdoCtx, workitemtracking.GetWorkItemArgs{Id: &workItemID, Fields: &[]string{"System.Tags"}})
+		if err != nil {
+			project.report.problem("cannot read tags of work item %d, labels of issue %d are not migrated: %s", workItemID, iid, err)
+		} else {
+			existingTags := ""
+			if workItem.Fields != nil {
+				existingTags, _ = (*workItem.Fields)["System.Tags"].(string)
+			}
+			patch = append(patch, prepareLabelPatch(project, issue, existingTags)...)
+		}
+	}
+	if len(patch) == 0 {
+		return
+	}
+	if _, err := workItemClient.UpdateWorkItem(azdoCtx, workitemtracking.UpdateWorkItemArgs{Id: &workItemID, Document: &patch}); err != nil {
+		project.report.problem("cannot set fields of work item %d of issue %d: %s", workItemID, iid, err)
+		return
+	}
+	log.Debugf("issue %d: %d fields set on work item %d", iid, len(patch), workItemID)
+	audit.record("workItem.fields", project.AzdoProject, strconv.Itoa(workItemID), map[string]interface{}{
+		"issueIid": iid,
+		"fields":   len(patch),
+	})
+}
+
+// prepareFieldPatch sets mapped fields, times are converted from seconds to hours AzDO tracks work in and fields
+// mapped to empty name are skipped
+func prepareFieldPatch(project project, issue *gitlab.Issue) *[]webapi.JsonPatchOperation {
+	values := map[string]interface{}{}
+	if issue.Weight > 0 {
+		values[issueWeight] = issue.Weight
+	}
+	if issue.TimeStats != nil && issue.TimeStats.TimeEstimate > 0 {
+		values[issueTimeEstimate] = float64(issue.TimeStats.TimeEstimate) / 3600
+	}
+	if issue.TimeStats != nil && issue.TimeStats.TotalTimeSpent > 0 {
+		values[issueTimeSpent] = float64(issue.TimeStats.TotalTimeSpent) / 3600
+	}
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	patch := []webapi.JsonPatchOperation{}
+	for _, name := range names {
+		field, ok := project.WorkItemFields[name]
+		if !ok {
+			field = defaultWorkItemFields[name]
+		}
+		if field == "" {
+			continue
+		}
+		patch = append(patch, webapi.JsonPatchOperation{
+			Op:    &webapi.OperationValues.Add,
+			Path:  gitlab.String("/fields/" + field),
+			Value: values[name],
+		})
+	}
+	return &patch
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPrepareFieldPatch(t *testing.T) {
+	issue := &gitlab.Issue{Weight: 3, TimeStats: &gitlab.TimeStats{TimeEstimate: 7200, TotalTimeSpent: 5400}}
+	cases := []struct {
+		label    string
+		fields   map[string]string
+		issue    *gitlab.Issue
+		expected []interface{}
+	}{
+		{"default fields", nil, issue, []interface{}{
+			"/fields/Microsoft.VSTS.Scheduling.OriginalEstimate", 2.0,
+			"/fields/Microsoft.VSTS.Scheduling.CompletedWork", 1.5,
+			"/fields/Microsoft.VSTS.Scheduling.StoryPoints", 3,
+		}},
+		{"custom fields", map[string]string{issueWeight: "Microsoft.VSTS.Scheduling.Effort", issueTimeSpent: ""}, issue, []interface{}{
+			"/fields/Microsoft.VSTS.Scheduling.OriginalEstimate", 2.0,
+			"/fields/Microsoft.VSTS.Scheduling.Effort", 3,
+		}},
+		{"untracked issue", nil, &gitlab.Issue{}, nil},
+	}
+	for _, c := range cases {
+		var actual []interface{}
+		for _, operation := range *prepareFieldPatch(project{WorkItemFields: c.fields}, c.issue) {
+			actual = append(actual, *operation.Path, operation.Value)
+		}
+		if diff := deep.Equal(actual, c.expected); diff != nil {
+			t.Errorf("%s: %+v", c.label, diff)
+		}
+	}
+}
+
+func TestValidateWorkItemFields(t *testing.T) {
+	if err := validateWorkItemFields(project{WorkItemFields: map[string]string{issueWeight: "Custom.Size"}}); err != nil {
+		t.Error(err)
+	}
+	if err := validateWorkItemFields(project{WorkItemFields: map[string]string{"labels": "System.Tags"}}); err == nil {
+		t.Error("unknown issue field should be rejected")
+	}
+}
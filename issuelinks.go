@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/workitemtracking"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	issueRelations = kingpin.Flag("issue-relations", "Recreate links between gitlab issues of the work item map (relates to, blocks, is blocked by, duplicate of) as relations of their AzDO work items").Default("false").Bool()
+	// duplicateNote is the system note of an issue closed as duplicate
+	duplicateNote = regexp.MustCompile(`^marked this issue as a duplicate of (` + issueReference + `)`)
+	workItemURL   = regexp.MustCompile(`(?i)/workItems/(\d+)$`)
+	// relationTypes are AzDO relations of gitlab link types, AzDO adds the reverse relation to the other work item
+	relationTypes = map[string]string{
+		"relates_to":    "System.LinkTypes.Related",
+		"blocks":        "System.LinkTypes.Dependency-Forward",
+		"is_blocked_by": "System.LinkTypes.Dependency-Reverse",
+		"duplicate_of":  "System.LinkTypes.Duplicate-Reverse",
+	}
+)
+
+// issueLink is linked issue as returned by gitlab issue links API, go-gitlab drops the link type
+type issueLink struct {
+	WebURL   string `json:"web_url"`
+	LinkType string `json:"link_type"`
+}
+
+// workItemRelation is a relation of the work item to work item of another issue
+type workItemRelation struct {
+	rel    string
+	target int
+}
+
+// migrateMappedIssues adds relations and fields to work items of mapped issues of the project
+func migrateMappedIssues(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project) {
+	if !*issueRelations && !*issueFields && !*issueLabels || len(workItems) == 0 || project.gitlab == nil {
+		return
+	}
+	workItemClient, err := workitemtracking.NewClient(azdoCtx, azdoConnection)
+	if err != nil {
+		project.report.problem("mapped issues are not migrated: %s", err)
+		return
+	}
+	projectURL := strings.TrimSuffix(project.gitlabProject.WebURL, "/")
+	for _, iid := range listMappedIssues(projectURL) {
+		workItemID := workItems[fmt.Sprintf("%s/-/issues/%d", projectURL, iid)]
+		if *issueRelations {
+			linkIssueRelations(azdoCtx, workItemClient, project, iid, workItemID)
+		}
+		if *issueFields || *issueLabels {
+			setIssueFields(azdoCtx, workItemClient, project, iid, workItemID)
+		}
+	}
+}
+
+// linkIssueRelations adds relations to the work item of the issue, relations existing in AzDO are left as they are
+// so that the reverse relation AzDO created or a repeated run does not duplicate them
+func linkIssueRelations(azdoCtx context.Context, workItemClient workitemtracking.Client, project project, iid int, workItemID int) {
+	links, err := listIssueLinks(project.gitlab.client, project.gitlabProject.ID, iid)
+	if err != nil {
+		project.report.problem("cannot list links of issue %d: %s", iid, err)
+		return
+	}
+	notes, err := listIssueNotes(project.gitlab.client, project.gitlabProject.ID, iid)
+	if err != nil {
+		project.report.problem("cannot list notes of issue %d, duplicates are not linked: %s", iid, err)
+	}
+	relations := prepareWorkItemRelations(project, links, notes)
+	if len(relations) == 0 {
+		return
+	}
+	workItem, err := workItemClient.GetWorkItem(azdoCtx, workitemtracking.GetWorkItemArgs{
+		Id:     &workItemID,
+		Expand: &workitemtracking.WorkItemExpandValues.Relations,
+	})
+	if err != nil {
+		project.report.problem("cannot read work item %d of issue %d: %s", workItemID, iid, err)
+		return
+	}
+	patch := prepareRelationPatch(relations, workItem.Relations)
+	if len(*patch) == 0 {
+		return
+	}
+	if _, err := workItemClient.UpdateWorkItem(azdoCtx, workitemtracking.UpdateWorkItemArgs{Id: &workItemID, Document: patch}); err != nil {
+		project.report.problem("cannot add relations to work item %d of issue %d: %s", workItemID, iid, err)
+		return
+	}
+	log.Debugf("issue %d: %d relations added to work item %d", iid, len(*patch), workItemID)
+	audit.record("workItem.relate", project.AzdoProject, strconv.Itoa(workItemID), map[string]interface{}{
+		"issueIid":  iid,
+		"relations": len(*patch),
+	})
+}
+
+// listMappedIssues returns IIDs of issues of the project in the work item map
+func listMappedIssues(projectURL string) []int {
+	var iids []int
+	for issue := range workItems {
+		if iid, err := strconv.Atoi(strings.TrimPrefix(issue, projectURL+"/-/issues/")); err == nil {
+			iids = append(iids, iid)
+		}
+	}
+	sort.Ints(iids)
+	return iids
+}
+
+func listIssueLinks(gitlabClient *gitlab.Client, projectID int, iid int) ([]issueLink, error) {
+	request, err := gitlabClient.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/issues/%d/links", projectID, iid), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var links []issueLink
+	if _, err := gitlabClient.Do(request, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func listIssueNotes(gitlabClient *gitlab.Client, projectID int, iid int) ([]*gitlab.Note, error) {
+	var notes []*gitlab.Note
+	options := gitlab.ListIssueNotesOptions{ListOptions: gitlab.ListOptions{Page: 1, PerPage: 100}}
+	for {
+		page, response, err := gitlabClient.Notes.ListIssueNotes(projectID, iid, &options)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, page...)
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		return notes, nil
+	}
+}
+
+// prepareWorkItemRelations translates links and duplicate marks of the issue to relations, issues without work item
+// are reported
+func prepareWorkItemRelations(project project, links []issueLink, notes []*gitlab.Note) []workItemRelation {
+	var relations []workItemRelation
+	add := func(linkType string, issue string) {
+		rel, ok := relationTypes[linkType]
+		if !ok {
+			log.Debugf("link %s to %s is not migrated, its type is unknown", linkType, issue)
+			return
+		}
+		target, ok := workItems[issue]
+		if !ok {
+			project.report.problem("linked issue %s is not in the work item map, its relation is not migrated", issue)
+			return
+		}
+		relations = append(relations, workItemRelation{rel: rel, target: target})
+	}
+	for _, link := range links {
+		add(link.LinkType, link.WebURL)
+	}
+	for _, note := range notes {
+		if !note.System {
+			continue
+		}
+		if match := duplicateNote.FindStringSubmatch(note.Body); match != nil {
+			reference := issueMatcher.FindStringSubmatch(match[1])
+			add("duplicate_of", resolveIssueReference(reference, project.gitlabProject.WebURL, project.gitlabProject.PathWithNamespace, "/-/issues/"))
+		}
+	}
+	return relations
+}
+
+// prepareRelationPatch adds relations the work item does not have yet
+func prepareRelationPatch(relations []workItemRelation, existing *[]workitemtracking.WorkItemRelation) *[]webapi.JsonPatchOperation {
+	//AzDO returns URLs with project ID, relations are compared by the work item ID
+	present := map[workItemRelation]bool{}
+	if existing != nil {
+		for _, relation := range *existing {
+			if match := workItemURL.FindStringSubmatch(*relation.Url); match != nil {
+				target, _ := strconv.Atoi(match[1])
+				present[workItemRelation{rel: *relation.Rel, target: target}] = true
+			}
+		}
+	}
+	patch := []webapi.JsonPatchOperation{}
+	for _, relation := range relations {
+		if present[relation] {
+			continue
+		}
+		present[relation] = true
+		patch = append(patch, webapi.JsonPatchOperation{
+			Op:   &webapi.OperationValues.Add,
+			Path: gitlab.String("/relations/-"),
+			Value: map[string]interface{}{
+				"rel": relation.rel,
+				"url": fmt.Sprintf("%s/_apis/wit/workItems/%d", strings.TrimSuffix(*azdoOrganization, "/"), relation.target),
+			},
+		})
+	}
+	return &patch
+}
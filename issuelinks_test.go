@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/workitemtracking"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPrepareWorkItemRelations(t *testing.T) {
+	workItems = map[string]int{
+		"https://gitlab.com/group/app/-/issues/1": 101,
+		"https://gitlab.com/group/app/-/issues/2": 102,
+		"https://gitlab.com/group/lib/-/issues/3": 103,
+	}
+	defer func() { workItems = map[string]int{} }()
+	project := project{gitlabProject: &gitlab.Project{WebURL: "https://gitlab.com/group/app", PathWithNamespace: "group/app"}}
+	links := []issueLink{
+		{WebURL: "https://gitlab.com/group/app/-/issues/2", LinkType: "blocks"},
+		{WebURL: "https://gitlab.com/group/lib/-/issues/3", LinkType: "relates_to"},
+		{WebURL: "https://gitlab.com/group/app/-/issues/4", LinkType: "relates_to"},
+	}
+	notes := []*gitlab.Note{
+		{System: true, Body: "marked this issue as a duplicate of #2"},
+		{Body: "marked this issue as a duplicate of #1"},
+	}
+	expected := []workItemRelation{
+		{rel: "System.LinkTypes.Dependency-Forward", target: 102},
+		{rel: "System.LinkTypes.Related", target: 103},
+		{rel: "System.LinkTypes.Duplicate-Reverse", target: 102},
+	}
+	//relations have unexported fields only which deep does not compare
+	if diff := deep.Equal(fmt.Sprint(prepareWorkItemRelations(project, links, notes)), fmt.Sprint(expected)); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrepareRelationPatch(t *testing.T) {
+	*azdoOrganization = "https://dev.azure.com/org/"
+	defer func() { *azdoOrganization = "" }()
+	relations := []workItemRelation{
+		{rel: "System.LinkTypes.Related", target: 102},
+		{rel: "System.LinkTypes.Related", target: 103},
+		{rel: "System.LinkTypes.Related", target: 103},
+	}
+	existing := []workitemtracking.WorkItemRelation{
+		{Rel: gitlab.String("System.LinkTypes.Related"), Url: gitlab.String("https://dev.azure.com/org/4c1c7b4e/_apis/wit/workItems/102")},
+	}
+	var actual []interface{}
+	for _, operation := range *prepareRelationPatch(relations, &existing) {
+		actual = append(actual, *operation.Path, operation.Value)
+	}
+	expected := []interface{}{"/relations/-", map[string]interface{}{"rel": "System.LinkTypes.Related", "url": "https://dev.azure.com/org/_apis/wit/workItems/103"}}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strings"
+)
+
+var prIterations = kingpin.Flag("pr-iterations", "Recreate diff versions of merge requests as pull request iterations by pushing their heads into the source branch one by one (requires git)").Default("false").Bool()
+
+// iterationReplay pushes heads of merge request diff versions into the source branch, AzDO records every push into
+// the branch of an open pull request as a new iteration
+type iterationReplay struct {
+	repository  *localRepository
+	azdoProject string
+	target      string
+	branch      string
+	heads       []string
+	original    string
+}
+
+// prepareVersionHeads returns distinct head commits of diff versions from the oldest one, gitlab lists the newest first
+func prepareVersionHeads(versions []*gitlab.MergeRequestDiffVersion) []string {
+	var heads []string
+	for i := len(versions) - 1; i >= 0; i-- {
+		head := versions[i].HeadCommitSHA
+		if head == "" || (len(heads) > 0 && heads[len(heads)-1] == head) {
+			continue
+		}
+		heads = append(heads, head)
+	}
+	return heads
+}
+
+// startIterations moves the source branch to head of the first diff version before the pull request is created,
+// nil is returned when the merge request has a single version or its history cannot be replayed. Versions of
+// repositories with rewritten history are not replayed, gitlab commits would bring back stripped files
+func startIterations(source SourceClient, project project, mr *MergeRequest, repository *git.GitRepository, branch string) *iterationReplay {
+	if !*prIterations {
+		return nil
+	}
+	if rewritesHistory(project) {
+		project.report.problem("merge request %d: diff versions are not replayed as history of the repository is rewritten, pull request has a single iteration", mr.IID)
+		return nil
+	}
+	heads, err := source.ListVersionHeads(mr)
+	if err != nil {
+		project.report.problem("merge request %d: cannot list diff versions, pull request has a single iteration: %s", mr.IID, err)
+		return nil
+	}
+	if len(heads) < 2 {
+		return nil
+	}
+	replay, err := prepareIterationReplay(project, mr, repository, branch, heads)
+	if err != nil {
+		project.report.problem("merge request %d: diff versions cannot be replayed, pull request has a single iteration: %s", mr.IID, err)
+		return nil
+	}
+	if err := replay.push(replay.heads[0]); err != nil {
+		project.report.problem("merge request %d: diff versions cannot be replayed, pull request has a single iteration: %s", mr.IID, err)
+		replay.finish()
+		return nil
+	}
+	replay.heads = replay.heads[1:]
+	return replay
+}
+
+// prepareIterationReplay fetches version heads from the source, versions whose commits are gone are skipped
+func prepareIterationReplay(project project, mr *MergeRequest, repository *git.GitRepository, branch string, heads []string) (*iterationReplay, error) {
+	source, err := sourceRemote(project.gitlabProject.HTTPURLToRepo, project.source)
+	if err != nil {
+		return nil, err
+	}
+	target, err := azdoRemote(*repository.RemoteUrl)
+	if err != nil {
+		return nil, err
+	}
+	local, err := newLocalRepository()
+	if err != nil {
+		return nil, err
+	}
+	replay := &iterationReplay{repository: local, azdoProject: *repository.Project.Name, target: target, branch: branch}
+	current, err := local.run("ls-remote", target, "refs/heads/"+branch)
+	if err != nil || current == "" {
+		local.remove()
+		return nil, fmt.Errorf("cannot read head of %s: %v", branch, err)
+	}
+	replay.original = strings.Fields(current)[0]
+	for _, head := range heads {
+		if _, err := local.run("fetch", "--quiet", source, head); err != nil {
+			log.Debugf("merge request %d: diff version %s is not available: %s", mr.IID, head, err)
+			continue
+		}
+		replay.heads = append(replay.heads, head)
+	}
+	if len(replay.heads) < 2 {
+		local.remove()
+		return nil, fmt.Errorf("commits of older diff versions are not available in gitlab")
+	}
+	return replay, nil
+}
+
+func (r *iterationReplay) push(head string) error {
+	if err := r.repository.push(r.target, fmt.Sprintf("+%s:refs/heads/%s", head, r.branch)); err != nil {
+		return err
+	}
+	audit.record("ref.update", r.azdoProject, "refs/heads/"+r.branch, map[string]interface{}{
+		"objectId": head,
+	})
+	return nil
+}
+
+// replay pushes remaining versions into the branch of created pull request, the branch ends where it was before
+func (r *iterationReplay) replay(project project, mr *MergeRequest) {
+	if r == nil {
+		return
+	}
+	defer r.finish()
+	heads := r.heads
+	if heads[len(heads)-1] != r.original {
+		//the branch has to end at its original head, it may have moved since the last version
+		heads = append(heads, r.original)
+	}
+	for _, head := range heads {
+		if err := r.push(head); err != nil {
+			project.report.problem("merge request %d: cannot push diff version %s: %s", mr.IID, head, err)
+		}
+	}
+}
+
+// abort returns the branch to its original head when the pull request cannot be created
+func (r *iterationReplay) abort(project project, mr *MergeRequest) {
+	if r == nil {
+		return
+	}
+	defer r.finish()
+	if err := r.push(r.original); err != nil {
+		project.report.problem("merge request %d: cannot return branch %s to %s: %s", mr.IID, r.branch, r.original, err)
+	}
+}
+
+func (r *iterationReplay) finish() {
+	r.repository.remove()
+}
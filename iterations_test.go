@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPrepareVersionHeads(t *testing.T) {
+	versions := []*gitlab.MergeRequestDiffVersion{
+		{HeadCommitSHA: "c3"},
+		{HeadCommitSHA: "c2"},
+		{HeadCommitSHA: "c2"},
+		{HeadCommitSHA: ""},
+		{HeadCommitSHA: "c1"},
+	}
+	if diff := deep.Equal(prepareVersionHeads(versions), []string{"c1", "c2", "c3"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(len(prepareVersionHeads(nil)), 0); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestStartIterationsOfRewrittenHistory(t *testing.T) {
+	*prIterations = true
+	defer func() { *prIterations = false }()
+	project := project{Subdirectory: "services/api", gitlabProject: &gitlab.Project{ID: 1}, report: &projectReport{}}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	//mock source expects no diff versions to be listed, the replay must not get that far
+	if replay := startIterations(NewMockSourceClient(ctrl), project, &MergeRequest{IID: 3}, nil, "feature"); replay != nil {
+		t.Error("diff versions of rewritten history should not be replayed")
+	}
+	if len(project.report.Problems) != 1 {
+		t.Errorf("skipped replay should be reported: %v", project.report.Problems)
+	}
+}
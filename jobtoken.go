@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"strings"
+)
+
+// jobTokenUser is the git user gitlab accepts CI_JOB_TOKEN with
+const jobTokenUser = "gitlab-ci-token"
+
+var gitlabJobToken = kingpin.Flag("gitlab-job-token", "Fetch repositories of the --gitlab-url instance with CI_JOB_TOKEN of the running gitlab CI job, --gitlab-token is then used only for API calls").Default("false").Bool()
+
+// gitCredentials returns user and password git authenticates to the instance with, job token replaces API token only
+// for git as it cannot read projects and merge requests through the API
+func (i *gitlabInstance) gitCredentials() (string, string) {
+	if i.jobToken != "" {
+		return jobTokenUser, i.jobToken
+	}
+	return "oauth2", i.token
+}
+
+// initJobToken reads job token of the running CI job into the default instance and explains what still needs
+// --gitlab-token
+func initJobToken(instance *gitlabInstance) error {
+	if !*gitlabJobToken {
+		return nil
+	}
+	instance.jobToken = os.Getenv("CI_JOB_TOKEN")
+	if instance.jobToken == "" {
+		return fmt.Errorf("--gitlab-job-token requires CI_JOB_TOKEN, run the migration as gitlab CI job")
+	}
+	redactor.add(instance.jobToken)
+	if server := os.Getenv("CI_SERVER_URL"); server != "" && strings.TrimSuffix(server, "/") != strings.TrimSuffix(instance.URL, "/") {
+		return fmt.Errorf("CI_JOB_TOKEN is valid only for %s where the job runs, set --gitlab-url=%s", server, server)
+	}
+	log.Info("git transfers of the default gitlab instance use CI_JOB_TOKEN, --gitlab-token needs read_api scope for project and merge request lookups")
+	if *backlinkMergeRequests || *closeMergeRequests {
+		log.Info("--backlink-merge-requests and --close-merge-requests write to gitlab API, --gitlab-token needs api scope")
+	}
+	return nil
+}
+
+// jobTokenHint explains failed git fetch by the job token, source projects have to allow access from the project
+// running the migration
+func jobTokenHint(instance *gitlabInstance) string {
+	if instance == nil || instance.jobToken == "" {
+		return ""
+	}
+	return " (CI_JOB_TOKEN can fetch only projects which list the project running the migration in Settings > CI/CD > Token Access)"
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/go-test/deep"
+	"os"
+	"testing"
+)
+
+func TestInitJobToken(t *testing.T) {
+	*gitlabJobToken = true
+	defer func() { *gitlabJobToken = false }()
+	defer os.Unsetenv("CI_JOB_TOKEN")
+	defer os.Unsetenv("CI_SERVER_URL")
+
+	os.Unsetenv("CI_JOB_TOKEN")
+	if err := initJobToken(&gitlabInstance{URL: "https://gitlab.example.com"}); err == nil {
+		t.Error("expected error without CI_JOB_TOKEN")
+	}
+
+	os.Setenv("CI_JOB_TOKEN", "job-secret")
+	os.Setenv("CI_SERVER_URL", "https://gitlab.example.com")
+	if err := initJobToken(&gitlabInstance{URL: "https://gitlab.com"}); err == nil {
+		t.Error("expected error when the job runs on another instance")
+	}
+
+	instance := &gitlabInstance{URL: "https://gitlab.example.com/", token: "api-secret"}
+	if err := initJobToken(instance); err != nil {
+		t.Fatal(err)
+	}
+	username, password := instance.gitCredentials()
+	if diff := deep.Equal([]string{username, password}, []string{jobTokenUser, "job-secret"}); diff != nil {
+		t.Error(diff)
+	}
+	remote, err := gitlabRemote("https://gitlab.example.com/group/app.git", instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("gitlab-ci-token:job-secret"))
+	if diff := deep.Equal([]string{remote, remoteCredentials[remote]}, []string{"https://gitlab.example.com/group/app.git", header}); diff != nil {
+		t.Error(diff)
+	}
+
+	username, password = (&gitlabInstance{token: "api-secret"}).gitCredentials()
+	if diff := deep.Equal([]string{username, password}, []string{"oauth2", "api-secret"}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strings"
+)
+
+var issueLabels = kingpin.Flag("issue-labels", "Add labels of gitlab issues of the work item map to tags of their work items and set state and area path the labels map to, labels of the config translate them").Default("false").Bool()
+
+// labelMapping is what a gitlab label means in AzDO, labels without mapping are kept as tags of the same name
+type labelMapping struct {
+	// Tag replaces the label name, empty tag drops the label
+	Tag *string `json:"tag,omitempty"`
+	// State and AreaPath are set on work items of open issues with the label
+	State    string `json:"state,omitempty"`
+	AreaPath string `json:"areaPath,omitempty"`
+}
+
+// mergeLabels returns the label mapping with mappings of the override replacing those of the same label, neither of
+// them is changed
+func mergeLabels(mapping map[string]labelMapping, override map[string]labelMapping) map[string]labelMapping {
+	if len(mapping) == 0 {
+		return override
+	}
+	merged := map[string]labelMapping{}
+	for label, labelMapping := range mapping {
+		merged[label] = labelMapping
+	}
+	for label, labelMapping := range override {
+		merged[label] = labelMapping
+	}
+	return merged
+}
+
+// prepareTags translates labels to tags, labels mapped to the same tag give it once
+func prepareTags(project project, labels []string) []string {
+	var tags []string
+	found := map[string]bool{}
+	for _, label := range labels {
+		tag := label
+		if mapping, ok := project.Labels[label]; ok && mapping.Tag != nil {
+			tag = *mapping.Tag
+		}
+		if tag == "" || found[strings.ToLower(tag)] {
+			continue
+		}
+		//AzDO tags are case insensitive
+		found[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// appendTags adds tags missing in the tags field of the work item, it tells whether any was added
+func appendTags(existingTags string, tags []string) ([]string, bool) {
+	var merged []string
+	found := map[string]bool{}
+	for _, tag := range strings.Split(existingTags, ";") {
+		if tag = strings.TrimSpace(tag); tag != "" && !found[strings.ToLower(tag)] {
+			found[strings.ToLower(tag)] = true
+			merged = append(merged, tag)
+		}
+	}
+	added := false
+	for _, tag := range tags {
+		if !found[strings.ToLower(tag)] {
+			found[strings.ToLower(tag)] = true
+			merged = append(merged, tag)
+			added = true
+		}
+	}
+	return merged, added
+}
+
+// preparePullRequestLabels adds tags of merge request labels to the migration label
+func preparePullRequestLabels(project project, mr *MergeRequest) *[]core.WebApiTagDefinition {
+	labels := *prepareMigrationLabels()
+	for _, tag := range prepareTags(project, mr.Labels) {
+		if !strings.EqualFold(tag, migratedLabel) {
+			labels = append(labels, core.WebApiTagDefinition{Name: gitlab.String(tag)})
+		}
+	}
+	return &labels
+}
+
+// prepareLabelPatch adds tags of the issue labels to tags the work item has, state and area path of the first label
+// defining them are set while the issue is open. Labels defining different ones are reported
+func prepareLabelPatch(project project, issue *gitlab.Issue, existingTags string) []webapi.JsonPatchOperation {
+	var patch []webapi.JsonPatchOperation
+	set := func(field string, value string) {
+		patch = append(patch, webapi.JsonPatchOperation{
+			Op:    &webapi.OperationValues.Add,
+			Path:  gitlab.String("/fields/" + field),
+			Value: value,
+		})
+	}
+	if tags, added := appendTags(existingTags, prepareTags(project, issue.Labels)); added {
+		set("System.Tags", strings.Join(tags, "; "))
+	}
+	if issue.State != "opened" {
+		return patch
+	}
+	var state, areaPath string
+	for _, label := range issue.Labels {
+		mapping := project.Labels[label]
+		if mapping.State != "" && state != "" && mapping.State != state {
+			project.report.problem("issue %d: label %s maps to state %s, state %s of an earlier label is used", issue.IID, label, mapping.State, state)
+		} else if mapping.State != "" {
+			state = mapping.State
+		}
+		if mapping.AreaPath != "" && areaPath != "" && mapping.AreaPath != areaPath {
+			project.report.problem("issue %d: label %s maps to area path %s, area path %s of an earlier label is used", issue.IID, label, mapping.AreaPath, areaPath)
+		} else if mapping.AreaPath != "" {
+			areaPath = mapping.AreaPath
+		}
+	}
+	if state != "" {
+		set("System.State", state)
+	}
+	if areaPath != "" {
+		set("System.AreaPath", areaPath)
+	}
+	return patch
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func setupLabelProject() project {
+	return project{Labels: map[string]labelMapping{
+		"bug":             {Tag: gitlab.String("Bug")},
+		"type::bug":       {Tag: gitlab.String("Bug")},
+		"workflow::doing": {Tag: gitlab.String(""), State: "Active"},
+		"workflow::ready": {Tag: gitlab.String(""), State: "New"},
+		"team::payments":  {AreaPath: `Shop\Payments`},
+	}}
+}
+
+func TestPrepareTags(t *testing.T) {
+	tags := prepareTags(setupLabelProject(), []string{"bug", "type::bug", "workflow::doing", "team::payments", "frontend"})
+	if diff := deep.Equal(tags, []string{"Bug", "team::payments", "frontend"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPreparePullRequestLabels(t *testing.T) {
+	var actual []string
+	for _, label := range *preparePullRequestLabels(setupLabelProject(), &MergeRequest{Labels: gitlab.Labels{"bug", migratedLabel}}) {
+		actual = append(actual, *label.Name)
+	}
+	if diff := deep.Equal(actual, []string{migratedLabel, "Bug"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrepareLabelPatch(t *testing.T) {
+	cases := []struct {
+		label    string
+		issue    *gitlab.Issue
+		existing string
+		expected []interface{}
+	}{
+		{"open issue", &gitlab.Issue{State: "opened", Labels: gitlab.Labels{"bug", "workflow::doing", "workflow::ready", "team::payments"}}, "", []interface{}{
+			"/fields/System.Tags", "Bug; team::payments",
+			"/fields/System.State", "Active",
+			"/fields/System.AreaPath", `Shop\Payments`,
+		}},
+		{"closed issue", &gitlab.Issue{State: "closed", Labels: gitlab.Labels{"bug", "workflow::doing"}}, "", []interface{}{
+			"/fields/System.Tags", "Bug",
+		}},
+		{"tags of the work item", &gitlab.Issue{State: "closed", Labels: gitlab.Labels{"bug", "frontend"}}, "customer; bug", []interface{}{
+			"/fields/System.Tags", "customer; bug; frontend",
+		}},
+		{"tagged already", &gitlab.Issue{State: "closed", Labels: gitlab.Labels{"bug"}}, "Bug", nil},
+		{"no labels", &gitlab.Issue{State: "opened"}, "", nil},
+	}
+	for _, c := range cases {
+		var actual []interface{}
+		for _, operation := range prepareLabelPatch(setupLabelProject(), c.issue, c.existing) {
+			actual = append(actual, *operation.Path, operation.Value)
+		}
+		if diff := deep.Equal(actual, c.expected); diff != nil {
+			t.Errorf("%s: %+v", c.label, diff)
+		}
+	}
+}
+
+func TestMergeLabels(t *testing.T) {
+	shared := map[string]labelMapping{"bug": {Tag: gitlab.String("Bug")}, "team::payments": {AreaPath: `Shop\Payments`}}
+	project := map[string]labelMapping{"bug": {Tag: gitlab.String("Defect")}}
+	expected := map[string]labelMapping{"bug": {Tag: gitlab.String("Defect")}, "team::payments": {AreaPath: `Shop\Payments`}}
+	if diff := deep.Equal(mergeLabels(shared, project), expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(*shared["bug"].Tag, "Bug"); diff != nil {
+		t.Errorf("shared mapping should not change: %v", diff)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// handling of files which belong to LFS
+const (
+	lfsOff     = "off"
+	lfsAdvise  = "advise"
+	lfsMigrate = "migrate"
+)
+
+var (
+	lfsMode      = kingpin.Flag("lfs", "Find files of mirrored repositories which belong to LFS - off, report .gitattributes patterns tracking them with repository size (advise) or move them to LFS by git lfs migrate import before the push (migrate, requires git-lfs and rewrites history)").Default(lfsOff).Enum(lfsOff, lfsAdvise, lfsMigrate)
+	lfsThreshold = kingpin.Flag("lfs-threshold", "Files larger than the size in any commit are LFS candidates of --lfs, all files with their extension (files without one by path) are tracked").Default("10MB").Bytes()
+)
+
+// lfsCandidate are files tracked by the pattern, Size counts all their versions
+type lfsCandidate struct {
+	Pattern string `json:"pattern"`
+	Files   int    `json:"files"`
+	Size    int64  `json:"size"`
+}
+
+// lfsSummary is the outcome of --lfs in the report, sizes are disk usage of objects reachable from pushed refs and
+// SizeAfter is set only when the files were migrated
+type lfsSummary struct {
+	Candidates []lfsCandidate `json:"candidates"`
+	SizeBefore int64          `json:"sizeBefore"`
+	SizeAfter  int64          `json:"sizeAfter,omitempty"`
+	LFSSize    int64          `json:"lfsSize,omitempty"`
+}
+
+// migratesLFS tells whether files get rewritten into LFS pointers
+func migratesLFS(project project) bool {
+	return mirrorsRepository(project) && *lfsMode == lfsMigrate
+}
+
+// adviseLFS finds LFS candidates of the local repository and with --lfs=migrate moves them to LFS, before and after
+// sizes go into the report
+func adviseLFS(repository *localRepository, project project) error {
+	if *lfsMode != lfsAdvise && !migratesLFS(project) {
+		return nil
+	}
+	before, err := repositorySize(repository)
+	if err != nil {
+		return err
+	}
+	blobs, err := findStrippedBlobs(repository, int64(*lfsThreshold), nil)
+	if err != nil {
+		return err
+	}
+	candidates := selectLFSCandidates(blobs)
+	if len(candidates) == 0 {
+		return nil
+	}
+	summary := lfsSummary{Candidates: candidates, SizeBefore: before}
+	var patterns []string
+	var size int64
+	for _, candidate := range candidates {
+		patterns = append(patterns, candidate.Pattern)
+		size += candidate.Size
+	}
+	if *lfsMode == lfsAdvise {
+		project.report.problem("files matching %s take %s of %s repository, track them by LFS in .gitattributes, e.g. %s filter=lfs diff=lfs merge=lfs -text", strings.Join(patterns, ", "), formatSize(size), formatSize(before), patterns[0])
+		project.report.reportLFS(summary)
+		return nil
+	}
+
+	log.Infof("moving files matching %s of %s to LFS", strings.Join(patterns, ", "), project.gitlabProject.PathWithNamespace)
+	if _, err := repository.run("lfs", "migrate", "import", "--everything", "--include="+strings.Join(patterns, ",")); err != nil {
+		return fmt.Errorf("cannot move files to LFS, is git-lfs installed? %w", err)
+	}
+	if summary.SizeAfter, err = repositorySize(repository); err != nil {
+		return err
+	}
+	if summary.LFSSize, err = lfsObjectsSize(repository); err != nil {
+		return err
+	}
+	project.report.problem("history rewritten, files matching %s moved to LFS - repository shrank from %s to %s, LFS objects take %s", strings.Join(patterns, ", "), formatSize(before), formatSize(summary.SizeAfter), formatSize(summary.LFSSize))
+	project.report.reportLFS(summary)
+	return nil
+}
+
+// selectLFSCandidates groups large blobs by the pattern tracking them, patterns taking the most space go first
+func selectLFSCandidates(blobs []strippedBlob) []lfsCandidate {
+	byPattern := map[string]*lfsCandidate{}
+	files := map[string]bool{}
+	for _, blob := range blobs {
+		pattern := lfsPattern(blob.path)
+		candidate, ok := byPattern[pattern]
+		if !ok {
+			candidate = &lfsCandidate{Pattern: pattern}
+			byPattern[pattern] = candidate
+		}
+		candidate.Size += blob.size
+		if !files[blob.path] {
+			files[blob.path] = true
+			candidate.Files++
+		}
+	}
+	var candidates []lfsCandidate
+	for _, candidate := range byPattern {
+		candidates = append(candidates, *candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Size != candidates[j].Size {
+			return candidates[i].Size > candidates[j].Size
+		}
+		return candidates[i].Pattern < candidates[j].Pattern
+	})
+	return candidates
+}
+
+// lfsPattern is the .gitattributes pattern of the file, files without extension are tracked alone
+func lfsPattern(file string) string {
+	if extension := path.Ext(path.Base(file)); extension != "" && extension != path.Base(file) {
+		return "*" + extension
+	}
+	return file
+}
+
+// repositorySize is disk usage of objects reachable from refs of the repository, objects left behind by rewrites are
+// not pushed and do not count
+func repositorySize(repository *localRepository) (int64, error) {
+	usage, err := repository.run("rev-list", "--objects", "--all", "--disk-usage")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(usage, 10, 64)
+}
+
+func lfsObjectsSize(repository *localRepository) (int64, error) {
+	var size int64
+	err := filepath.Walk(filepath.Join(repository.dir, "lfs", "objects"), func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// pushLFSObjects uploads LFS objects of migrated files before the refs pointing at them are pushed, AzDO accepts the
+// pointers without them but the files would be missing
+func pushLFSObjects(repository *localRepository, project project, target string) error {
+	if !migratesLFS(project) {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(repository.dir, "lfs", "objects")); os.IsNotExist(err) {
+		return nil
+	}
+	env, err := pushEnvironment()
+	if err != nil {
+		return err
+	}
+	log.Debugf("pushing LFS objects of %s", project.gitlabProject.PathWithNamespace)
+	_, err = repository.runWith(env, "lfs", "push", "--all", target)
+	return err
+}
+
+// reportLFS keeps LFS candidates and repository sizes of the project
+func (p *projectReport) reportLFS(summary lfsSummary) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.LFS = &summary
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestSelectLFSCandidates(t *testing.T) {
+	blobs := []strippedBlob{
+		{id: "b1", path: "assets/intro.mp4", size: 300},
+		{id: "b2", path: "assets/intro.mp4", size: 200},
+		{id: "b3", path: "assets/outro.mp4", size: 100},
+		{id: "b4", path: "design/logo.psd", size: 700},
+		{id: "b5", path: "tools/protoc", size: 50},
+	}
+	expect := []lfsCandidate{
+		{Pattern: "*.psd", Files: 1, Size: 700},
+		{Pattern: "*.mp4", Files: 2, Size: 600},
+		{Pattern: "tools/protoc", Files: 1, Size: 50},
+	}
+	if diff := deep.Equal(selectLFSCandidates(blobs), expect); diff != nil {
+		t.Error(diff)
+	}
+	if candidates := selectLFSCandidates(nil); candidates != nil {
+		t.Errorf("no candidates expected without large files, got %v", candidates)
+	}
+}
+
+func TestLFSPattern(t *testing.T) {
+	cases := map[string]string{
+		"bin/app.exe":   "*.exe",
+		"Data.BIN":      "*.BIN",
+		"data/.archive": "data/.archive",
+		"release.d/app": "release.d/app",
+	}
+	for file, expected := range cases {
+		if pattern := lfsPattern(file); pattern != expected {
+			t.Errorf("expected pattern %s of %s, got %s", expected, file, pattern)
+		}
+	}
+}
+
+func TestMigratesLFS(t *testing.T) {
+	*lfsMode = lfsMigrate
+	defer func() { *lfsMode = "" }()
+	*transferMode = transferImport
+	defer func() { *transferMode = "" }()
+	if migratesLFS(project{}) {
+		t.Error("import request cannot move files to LFS")
+	}
+	*transferMode = transferMirror
+	if !migratesLFS(project{}) || !rewritesHistory(project{}) {
+		t.Error("mirrored repository should be moved to LFS with rewritten history")
+	}
+}
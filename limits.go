@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	sizeCheckOff  = "off"
+	sizeCheckWarn = "warn"
+	sizeCheckFail = "fail"
+
+	// azdoPushLimit is the largest push AzDO accepts, import request transfers the repository at once as well
+	azdoPushLimit int64 = 5 * 1024 * 1024 * 1024
+	// largeFileLimit is the size above which files belong to LFS, git and AzDO web handle them poorly
+	largeFileLimit int64 = 100 * 1024 * 1024
+	// manyRefs is the number of branches and tags which makes the import slow and AzDO branch views unusable
+	manyRefs = 10000
+	// uncountedRefs stands for lists gitlab stopped counting, they are longer than manyRefs
+	uncountedRefs = -1
+)
+
+var sizeCheck = kingpin.Flag("size-check", "Compare repository size, largest file and number of refs with AzDO limits before the transfer - warn or fail the project").Default(sizeCheckWarn).Enum(sizeCheckOff, sizeCheckWarn, sizeCheckFail)
+
+// checkRepositoryLimits compares gitlab project statistics with AzDO limits before hours are spent on an import which
+// cannot succeed, problems are reported and in fail mode the error stops the project
+func checkRepositoryLimits(project project) error {
+	if *sizeCheck == sizeCheckOff {
+		return nil
+	}
+	refs := 0
+	//only gitlab counts branches and tags, the size is checked for other sources
+	if project.gitlab != nil {
+		var err error
+		if refs, err = countRefs(project.gitlab.client, project.GitlabID); err != nil {
+			return err
+		}
+	}
+	return reportLimitProblems(project, findLimitProblems(project.gitlabProject.Statistics, refs))
+}
+
+// countRefs returns number of branches and tags, gitlab does not count lists over 10000 items and omits their total so
+// they are uncountedRefs
+func countRefs(gitlabClient *gitlab.Client, projectID int) (int, error) {
+	_, branches, err := gitlabClient.Branches.ListBranches(projectID, &gitlab.ListBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: 1}})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count branches: %w", err)
+	}
+	_, tags, err := gitlabClient.Tags.ListTags(projectID, &gitlab.ListTagsOptions{ListOptions: gitlab.ListOptions{PerPage: 1}})
+	if err != nil {
+		return 0, fmt.Errorf("cannot count tags: %w", err)
+	}
+	if uncounted(branches) || uncounted(tags) {
+		return uncountedRefs, nil
+	}
+	return branches.TotalItems + tags.TotalItems, nil
+}
+
+// uncounted tells whether gitlab omitted total of the list which has more pages
+func uncounted(response *gitlab.Response) bool {
+	return response.TotalItems == 0 && response.NextPage != 0
+}
+
+// findLimitProblems lists limits the repository exceeds with suggestions how to get under them, statistics are
+// visible to reporters and above only
+func findLimitProblems(statistics *gitlab.ProjectStatistics, refs int) []string {
+	var problems []string
+	if statistics != nil && statistics.RepositorySize > azdoPushLimit {
+		problems = append(problems, fmt.Sprintf("repository size %s exceeds AzDO push limit %s, move large files to LFS or strip them by --transfer-mode mirror with --strip-blobs-larger-than or stripPaths", formatSize(statistics.RepositorySize), formatSize(azdoPushLimit)))
+	}
+	if refs > manyRefs || refs == uncountedRefs {
+		count := fmt.Sprintf("%d", refs)
+		if refs == uncountedRefs {
+			count = fmt.Sprintf("more than %d", manyRefs)
+		}
+		problems = append(problems, fmt.Sprintf("repository has %s branches and tags, leave obsolete ones behind by --transfer-mode mirror with excludeRefs", count))
+	}
+	return problems
+}
+
+// checkLargeFiles reports files of mirrored repository which should be in LFS, it is skipped for repositories whose
+// files get stripped or moved to LFS as that is the remedy
+func checkLargeFiles(repository *localRepository, project project) error {
+	if *sizeCheck == sizeCheckOff || stripsBlobs(project) || (migratesLFS(project) && int64(*lfsThreshold) <= largeFileLimit) {
+		return nil
+	}
+	blobs, err := findStrippedBlobs(repository, largeFileLimit, nil)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for i, blob := range blobs {
+		if i == 0 || blobs[i-1].path != blob.path {
+			problems = append(problems, fmt.Sprintf("file %s is %s, move it to LFS or strip it by --strip-blobs-larger-than", blob.path, formatSize(blob.size)))
+		}
+	}
+	return reportLimitProblems(project, problems)
+}
+
+func reportLimitProblems(project project, problems []string) error {
+	for _, problem := range problems {
+		project.report.problem("%s", problem)
+	}
+	if *sizeCheck == sizeCheckFail && len(problems) > 0 {
+		return classified(failureValidation, fmt.Errorf("repository exceeds AzDO limits, see %d problems above", len(problems)))
+	}
+	return nil
+}
+
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestFindLimitProblems(t *testing.T) {
+	statistics := &gitlab.ProjectStatistics{StorageStatistics: gitlab.StorageStatistics{RepositorySize: 6 * 1024 * 1024 * 1024}}
+	expected := []string{
+		"repository size 6.0GB exceeds AzDO push limit 5.0GB, move large files to LFS or strip them by --transfer-mode mirror with --strip-blobs-larger-than or stripPaths",
+		"repository has 12000 branches and tags, leave obsolete ones behind by --transfer-mode mirror with excludeRefs",
+	}
+	if diff := deep.Equal(findLimitProblems(statistics, 12000), expected); diff != nil {
+		t.Error(diff)
+	}
+	expected = []string{"repository has more than 10000 branches and tags, leave obsolete ones behind by --transfer-mode mirror with excludeRefs"}
+	if diff := deep.Equal(findLimitProblems(nil, uncountedRefs), expected); diff != nil {
+		t.Errorf("uncounted refs: %+v", diff)
+	}
+	if !uncounted(&gitlab.Response{NextPage: 2}) || uncounted(&gitlab.Response{TotalItems: 5, NextPage: 2}) || uncounted(&gitlab.Response{}) {
+		t.Error("only list with more pages and no total is uncounted")
+	}
+	if problems := findLimitProblems(nil, 10); problems != nil {
+		t.Errorf("small repository without statistics should have no problems, got %v", problems)
+	}
+	if size := formatSize(1536); size != "1.5KB" {
+		t.Errorf("unexpected size %s", size)
+	}
+}
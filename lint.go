@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	configCommand               = kingpin.Command("config", "Work with the projects configuration")
+	configLintCommand           = configCommand.Command("lint", "Check the configuration for missing and unknown fields, invalid values, duplicate projects and repositories and projects gitlab cannot find, without migrating anything")
+	configLintOffline           = configLintCommand.Flag("offline", "Skip looking projects up in gitlab and GitHub, only the file is checked").Default("false").Bool()
+	configLintOutput  io.Writer = os.Stdout
+)
+
+// configDiagnostic is a problem of a field of a configured project, field is empty when it concerns the whole project
+type configDiagnostic struct {
+	file    string
+	line    int
+	field   string
+	message string
+}
+
+func (d configDiagnostic) String() string {
+	location := d.file
+	if d.line > 0 {
+		location += ":" + strconv.Itoa(d.line)
+	}
+	if d.field == "" {
+		return fmt.Sprintf("%s: %s", location, d.message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, d.field, d.message)
+}
+
+// projectValidations are checks resolveProject does before the project is looked up, by the field they check
+var projectValidations = []struct {
+	field    string
+	validate func(project) error
+}{
+	{"prefix", validatePrefix},
+	{"postAction", validatePostAction},
+	{"workItemFields", validateWorkItemFields},
+	{"systemNotes", validateSystemNotes},
+	{"serviceEndpointId", validateServiceEndpoint},
+	{"noisePatterns", func(project project) error {
+		_, err := newNoiseFilter(project)
+		return err
+	}},
+}
+
+// lintConfig prints diagnostics of the configuration and returns their number, projects with problems in the file are
+// not looked up as the migration would refuse them anyway
+func lintConfig(defaultInstance *gitlabInstance, config config) int {
+	var diagnostics []configDiagnostic
+	known := projectFields()
+	for i := range config.Projects {
+		project := &config.Projects[i]
+		found := lintProject(config, *project, known)
+		if len(found) == 0 && !*configLintOffline {
+			if err := resolveProject(defaultInstance, config.GitlabInstances, project); err != nil {
+				found = append(found, project.diagnose(project.keyField(), err.Error()))
+			}
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+	diagnostics = append(diagnostics, lintDuplicates(config.Projects)...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].file != diagnostics[j].file {
+			return diagnostics[i].file < diagnostics[j].file
+		}
+		return diagnostics[i].line < diagnostics[j].line
+	})
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintln(configLintOutput, diagnostic)
+	}
+	return len(diagnostics)
+}
+
+// lintProject checks the project as written, known are JSON names of project fields in lower case as the parser
+// matches them case insensitively
+func lintProject(config config, project project, known map[string]bool) []configDiagnostic {
+	var diagnostics []configDiagnostic
+	report := func(field string, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, project.diagnose(field, fmt.Sprintf(format, args...)))
+	}
+	var unknown []string
+	for field := range project.origin.fields {
+		if !known[strings.ToLower(field)] {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		report(field, "unknown field, it is ignored")
+	}
+	if project.GitlabID == 0 && project.GitlabProject == "" && project.GithubRepository == "" {
+		report("", "either gitlabID, gitlabProject or githubRepository is required")
+	}
+	if project.AzdoProject == "" {
+		report("azdoProject", "is required, it is the AzDO project the repository is created in")
+	}
+	if project.GitlabInstance != "" && config.GitlabInstances[project.GitlabInstance] == nil {
+		report("gitlabInstance", "%s is not defined in gitlabInstances", project.GitlabInstance)
+	}
+	for _, validation := range projectValidations {
+		if err := validation.validate(project); err != nil {
+			report(validation.field, "%s", err)
+		}
+	}
+	return diagnostics
+}
+
+// lintDuplicates finds projects migrated twice and repositories several projects would be migrated into, projects
+// combined by prefix share their repository on purpose
+func lintDuplicates(projects []project) []configDiagnostic {
+	var diagnostics []configDiagnostic
+	sources := map[string]project{}
+	repositories := map[string]project{}
+	for _, project := range projects {
+		if source := project.sourceKey(); source != "" {
+			if first, ok := sources[source]; ok {
+				diagnostics = append(diagnostics, project.diagnose(project.keyField(), fmt.Sprintf("the project is configured already at %s", first.origin.location())))
+			} else {
+				sources[source] = project
+			}
+		}
+		name := lintRepositoryName(project)
+		if name == "" || project.Prefix != "" {
+			continue
+		}
+		repository := strings.ToLower(project.AzdoProject + "/" + name)
+		if first, ok := repositories[repository]; ok {
+			diagnostics = append(diagnostics, project.diagnose("azdoRepository", fmt.Sprintf("repository %s/%s is the target of the project at %s already, set a different azdoRepository", project.AzdoProject, name, first.origin.location())))
+		} else {
+			repositories[repository] = project
+		}
+	}
+	return diagnostics
+}
+
+// lintRepositoryName is the AzDO repository name of the project, unresolved projects are named after the last part of
+// their path and have no name when configured by ID
+func lintRepositoryName(project project) string {
+	if project.gitlabProject != nil || project.AzdoRepository != "" || project.Subdirectory != "" {
+		return project.azdoRepositoryName()
+	}
+	if project.GitlabProject != "" {
+		return *repoPrefix + path.Base(project.GitlabProject)
+	}
+	if project.GithubRepository != "" {
+		return *repoPrefix + path.Base(project.GithubRepository)
+	}
+	return ""
+}
+
+// sourceKey identifies the migrated project across the configuration, subdirectories split out of one project are
+// different sources
+func (p project) sourceKey() string {
+	var key string
+	switch {
+	case p.GithubRepository != "":
+		key = "github:" + strings.ToLower(p.GithubRepository)
+	case p.GitlabID != 0:
+		key = p.GitlabInstance + ":" + strconv.Itoa(p.GitlabID)
+	case p.GitlabProject != "":
+		key = p.GitlabInstance + ":" + strings.ToLower(p.GitlabProject)
+	default:
+		return ""
+	}
+	return key + ":" + strings.Trim(p.Subdirectory, "/")
+}
+
+// keyField is the field the project is identified by in the config
+func (p project) keyField() string {
+	switch {
+	case p.origin.fields["gitlabProject"] > 0:
+		return "gitlabProject"
+	case p.origin.fields["gitlabID"] > 0:
+		return "gitlabID"
+	case p.origin.fields["githubRepository"] > 0:
+		return "githubRepository"
+	}
+	return ""
+}
+
+// diagnose points at the field when it is written in the project, fields set by defaults point at the project
+func (p project) diagnose(field string, message string) configDiagnostic {
+	line := p.origin.line
+	if fieldLine, ok := p.origin.fields[field]; ok {
+		line = fieldLine
+	}
+	return configDiagnostic{file: p.origin.file, line: line, field: field, message: message}
+}
+
+func (o configOrigin) location() string {
+	return fmt.Sprintf("%s:%d", o.file, o.line)
+}
+
+// projectFields are JSON names of configurable project fields in lower case
+func projectFields() map[string]bool {
+	fields := map[string]bool{}
+	projectType := reflect.TypeOf(project{})
+	for i := 0; i < projectType.NumField(); i++ {
+		if name := strings.Split(projectType.Field(i).Tag.Get("json"), ",")[0]; name != "" {
+			fields[strings.ToLower(name)] = true
+		}
+	}
+	return fields
+}
+
+// runConfigLint fails the command when the configuration has problems
+func runConfigLint(defaultInstance *gitlabInstance, config config) {
+	if problems := lintConfig(defaultInstance, config); problems > 0 {
+		log.Fatalf("config %s has %d problems", *configFile, problems)
+	}
+	log.Infof("config %s has no problems", *configFile)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"github.com/go-test/deep"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintConfig(t *testing.T) {
+	*configLintOffline = true
+	defer func() { *configLintOffline = false }()
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	shared := filepath.Join(dir, "shared.json")
+	wave := filepath.Join(dir, "wave.json")
+	files := map[string]string{
+		shared: `{
+  "defaults": {"azdoProject": "Apps"},
+  "projects": [{"gitlabID": 1}]
+}`,
+		wave: `{
+  "include": ["shared.json"],
+  "projects": [
+    {"gitlabProject": "group/app", "migrateMrs": true},
+    {"gitlabID": 1, "azdoRepository": "app"},
+    {
+      "gitlabProject": "other/App",
+      "azdoProject": "",
+      "postAction": "delete",
+      "gitlabInstance": "selfhosted",
+      "migrateMergeRequests": true
+    },
+    {"gitlabProject": "group/lib", "prefix": "lib", "azdoRepository": "app"},
+    {"azdoProject": "Apps"}
+  ]
+}`,
+	}
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, _, err := loadConfig(wave, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	configLintOutput = output
+	defer func() { configLintOutput = os.Stdout }()
+
+	if problems := lintConfig(nil, loaded); problems != 7 {
+		t.Errorf("expected 7 problems, got %d", problems)
+	}
+	expected := []string{
+		wave + ":5: gitlabID: the project is configured already at " + shared + ":3",
+		wave + ":5: azdoRepository: repository Apps/app is the target of the project at " + wave + ":4 already, set a different azdoRepository",
+		wave + ":8: azdoProject: is required, it is the AzDO project the repository is created in",
+		wave + ":9: postAction: postAction delete is not one of lock, archive, none",
+		wave + ":10: gitlabInstance: selfhosted is not defined in gitlabInstances",
+		wave + ":11: migrateMergeRequests: unknown field, it is ignored",
+		wave + ":14: either gitlabID, gitlabProject or githubRepository is required",
+	}
+	if diff := deep.Equal(strings.Split(strings.TrimSpace(output.String()), "\n"), expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// remoteCredentials are Authorization headers of remotes by their URL without user, git gets them through its
+// environment as command line arguments are visible to every local user
+var (
+	remoteCredentials      = map[string]string{}
+	remoteCredentialsMutex sync.Mutex
+)
+
+// localRepository is a temporary bare repository used to move refs AzDO import request cannot transfer
+type localRepository struct {
+	dir string
+}
+
+func newLocalRepository() (*localRepository, error) {
+	dir, err := ioutil.TempDir("", "gitlab-azdo-migration-")
+	if err != nil {
+		return nil, err
+	}
+	repository := &localRepository{dir: dir}
+	if _, err := repository.run("init", "--bare", "--quiet"); err != nil {
+		repository.remove()
+		return nil, err
+	}
+	return repository, nil
+}
+
+func (r *localRepository) run(args ...string) (string, error) {
+	return r.runWith(nil, args...)
+}
+
+// push sends refs to the remote through the bandwidth limiting proxy when --push-bandwidth-limit is set
+func (r *localRepository) push(remote string, refspecs ...string) error {
+	env, err := pushEnvironment()
+	if err != nil {
+		return err
+	}
+	_, err = r.runWith(env, append([]string{"push", "--quiet", remote}, refspecs...)...)
+	return err
+}
+
+func (r *localRepository) runWith(env []string, args ...string) (string, error) {
+	log.Debugf("git %s", args[0])
+	command := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	command.Env = append(append(append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), gitTimeoutEnvironment()...), credentialsEnvironment()...), env...)
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// stream runs git command passing its output line by line to the consumer, for outputs too big to be kept in memory
+func (r *localRepository) stream(consume func(line string), args ...string) error {
+	log.Debugf("git %s", args[0])
+	command := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	command.Env = append(append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), gitTimeoutEnvironment()...), credentialsEnvironment()...)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := command.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		consume(scanner.Text())
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		//drain the output so that git does not block on a full pipe
+		io.Copy(ioutil.Discard, stdout)
+	}
+	if err := command.Wait(); err != nil {
+		return fmt.Errorf("git %s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return scanErr
+}
+
+// rewriteHistory runs filter-branch with the filter over all refs, backup refs/original are dropped so that the
+// original history is not scanned or pushed later
+func (r *localRepository) rewriteHistory(filter ...string) error {
+	args := append(append([]string{"filter-branch", "--force"}, filter...), "--tag-name-filter", "cat", "--", "--all")
+	if _, err := r.run(args...); err != nil {
+		return err
+	}
+	backups, err := r.run("for-each-ref", "--format=%(refname)", "refs/original/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range strings.Fields(backups) {
+		if _, err := r.run("update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *localRepository) remove() {
+	if err := os.RemoveAll(r.dir); err != nil {
+		log.Warnf("cannot remove temporary repository %s: %s", r.dir, err)
+	}
+}
+
+// authenticatedURL remembers credentials of the git remote for every later git command and returns the remote without
+// user, AzDO remote URLs carry organization as a user already
+func authenticatedURL(remote string, username string, password string) (string, error) {
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		return "", err
+	}
+	parsed.User = nil
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	redactor.add(credentials)
+	remoteCredentialsMutex.Lock()
+	defer remoteCredentialsMutex.Unlock()
+	remoteCredentials[parsed.String()] = "Authorization: Basic " + credentials
+	return parsed.String(), nil
+}
+
+// credentialsEnvironment configures http.<remote>.extraHeader of remembered remotes by GIT_CONFIG_COUNT (git 2.31),
+// the headers are sent only to their remote
+func credentialsEnvironment() []string {
+	remoteCredentialsMutex.Lock()
+	defer remoteCredentialsMutex.Unlock()
+	var remotes []string
+	for remote := range remoteCredentials {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+	env := []string{fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(remotes))}
+	for i, remote := range remotes {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=http.%s.extraHeader", i, remote), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, remoteCredentials[remote]))
+	}
+	return env
+}
+
+func gitlabRemote(httpURL string, instance *gitlabInstance) (string, error) {
+	username, password := instance.gitCredentials()
+	return authenticatedURL(httpURL, username, password)
+}
+
+// sourceRemote is the URL the repository is fetched from with credentials of its source
+func sourceRemote(httpURL string, source SourceClient) (string, error) {
+	username, password := source.GitCredentials()
+	return authenticatedURL(httpURL, username, password)
+}
+
+func azdoRemote(remoteURL string) (string, error) {
+	return authenticatedURL(remoteURL, "pat", *azdoToken)
+}
+
+// pushSourceRef fetches a single ref from the source (e.g. refs/merge-requests/1/head which is not a branch and thus
+// not imported) and pushes it into AzDO repository as the given branch
+func pushSourceRef(sourceURL string, sourceClient SourceClient, sourceRef string, azdoURL string, branch string) error {
+	source, err := sourceRemote(sourceURL, sourceClient)
+	if err != nil {
+		return err
+	}
+	target, err := azdoRemote(azdoURL)
+	if err != nil {
+		return err
+	}
+	repository, err := newLocalRepository()
+	if err != nil {
+		return err
+	}
+	defer repository.remove()
+
+	localRef := "refs/heads/" + branch
+	if _, err := repository.run("fetch", "--quiet", source, fmt.Sprintf("+%s:%s", sourceRef, localRef)); err != nil {
+		return err
+	}
+	return repository.push(target, fmt.Sprintf("%s:%s", localRef, localRef))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/go-test/deep"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticatedURL(t *testing.T) {
+	remotes := []struct {
+		label  string
+		remote string
+		expect string
+	}{
+		{
+			"gitlab remote",
+			"https://gitlab.com/gitlab-examples/php.git",
+			"https://gitlab.com/gitlab-examples/php.git",
+		},
+		{
+			"AzDO remote with organization user",
+			"https://myorg@dev.azure.com/myorg/my-project/_git/php",
+			"https://dev.azure.com/myorg/my-project/_git/php",
+		},
+	}
+
+	for _, remote := range remotes {
+		authenticated, err := authenticatedURL(remote.remote, "user", "secret")
+		if err != nil {
+			t.Errorf("%s: %s", remote.label, err)
+		}
+		if diff := deep.Equal(remote.expect, authenticated); diff != nil {
+			t.Errorf("%s: %+v", remote.label, diff)
+		}
+	}
+	env := strings.Join(credentialsEnvironment(), "\n")
+	header := "=Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	if !strings.Contains(env, "=http.https://dev.azure.com/myorg/my-project/_git/php.extraHeader") || !strings.Contains(env, header) {
+		t.Errorf("credentials should be passed by environment: %s", env)
+	}
+}
+
+func TestRemoteCredentialsReachGit(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	remote, err := authenticatedURL(server.URL+"/group/app.git", "oauth2", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository, err := newLocalRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repository.remove()
+	if _, err := repository.run("ls-remote", remote); err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("missing repository should fail without the token in the error: %v", err)
+	}
+	if diff := deep.Equal(authorization, "Basic "+base64.StdEncoding.EncodeToString([]byte("oauth2:secret"))); diff != nil {
+		t.Error(diff)
+	}
+}
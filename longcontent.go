@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"unicode/utf8"
+)
+
+const (
+	// azdoDescriptionLimit and azdoCommentLimit are the longest pull request description and comment AzDO accepts
+	azdoDescriptionLimit = 4000
+	azdoCommentLimit     = 150000
+)
+
+// truncateContent shortens content over the limit so that AzDO accepts it, the notice points to the attachment with
+// the full text which is returned as well (empty when the content fits)
+func truncateContent(content string, limit int, attachment string) (string, string) {
+	if utf8.RuneCountInString(content) <= limit {
+		return content, ""
+	}
+	notice := fmt.Sprintf("\n\n✂️ *Too long for AzDO - truncated, full text attached as `%s`*", attachment)
+	kept := []rune(content)[:limit-utf8.RuneCountInString(notice)]
+	return string(kept) + notice, content
+}
+
+// truncateComments shortens comments of the thread over the limit and returns their full texts by attachment name,
+// comments are numbered in the order of notes
+func truncateComments(mr *MergeRequest, discussion *Discussion, threads ...*git.GitPullRequestCommentThread) map[string]string {
+	originals := map[string]string{}
+	for _, thread := range threads {
+		if thread == nil {
+			continue
+		}
+		for i, comment := range *thread.Comments {
+			name := fmt.Sprintf("gitlab-note-%d-%d.md", mr.IID, discussion.Notes[*comment.Id-1].ID)
+			content, original := truncateContent(*comment.Content, azdoCommentLimit, name)
+			if original == "" {
+				continue
+			}
+			(*thread.Comments)[i].Content = &content
+			originals[name] = original
+		}
+	}
+	return originals
+}
+
+// attachOriginals attaches full texts of truncated description or comments to the pull request
+func attachOriginals(azdoCtx context.Context, azdoClient TargetClient, pullRequest *git.GitPullRequest, originals map[string]string) {
+	for name, original := range originals {
+		if err := attachFile(azdoCtx, azdoClient, *pullRequest.Repository.Project.Name, pullRequest, name, []byte(original)); err != nil {
+			log.Errorf("cannot attach full text %s to pull request %d: %s", name, *pullRequest.PullRequestId, err)
+		}
+	}
+}
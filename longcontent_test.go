@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateContent(t *testing.T) {
+	content, original := truncateContent("short", 10, "note.md")
+	if diff := deep.Equal([]string{content, original}, []string{"short", ""}); diff != nil {
+		t.Errorf("fits: %+v", diff)
+	}
+
+	long := strings.Repeat("žluťoučký kůň ", 400)
+	content, original = truncateContent(long, azdoDescriptionLimit, "gitlab-description-1.md")
+	if original != long || utf8.RuneCountInString(content) != azdoDescriptionLimit || !utf8.ValidString(content) ||
+		!strings.HasSuffix(content, "\n\n✂️ *Too long for AzDO - truncated, full text attached as `gitlab-description-1.md`*") {
+		t.Errorf("too long: %d characters %s", utf8.RuneCountInString(content), content[len(content)-120:])
+	}
+}
+
+func TestTruncateComments(t *testing.T) {
+	mr := MergeRequest{IID: 3}
+	discussion := &Discussion{Notes: []*Note{{ID: 10}, {ID: 11}}}
+	short, long := "short", strings.Repeat("x", azdoCommentLimit+1)
+	threadInit := &git.GitPullRequestCommentThread{Comments: &[]git.Comment{{Id: gitlab.Int(1), Content: &short}}}
+	fullThread := &git.GitPullRequestCommentThread{Comments: &[]git.Comment{{Id: gitlab.Int(2), Content: &long}}}
+
+	originals := truncateComments(&mr, discussion, threadInit, fullThread)
+	if diff := deep.Equal(originals, map[string]string{"gitlab-note-3-11.md": long}); diff != nil {
+		t.Error(diff)
+	}
+	if content := *(*fullThread.Comments)[0].Content; utf8.RuneCountInString(content) != azdoCommentLimit {
+		t.Errorf("comment was not truncated to %d characters", azdoCommentLimit)
+	}
+	if diff := deep.Equal(*(*threadInit.Comments)[0].Content, short); diff != nil {
+		t.Error(diff)
+	}
+}
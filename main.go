@@ -2,162 +2,544 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/microsoft/azure-devops-go-api/azuredevops"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
 	"github.com/prometheus/common/log"
-	"github.com/prometheus/common/version"
 	"github.com/xanzy/go-gitlab"
 	"gopkg.in/alecthomas/kingpin.v2"
-	"io/ioutil"
-	"regexp"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	gitlabToken         = kingpin.Flag("gitlab-token", "Gitlab API token").Required().String()
-	azdoOrganization    = kingpin.Flag("azdo-org", "Azure DevOps organization URL (https://dev.azure.com/myorg)").Required().String()
-	azdoToken           = kingpin.Flag("azdo-token", "Azure DevOps Personal Access Token").Required().String()
-	azdoServiceEndpoint = kingpin.Flag("azdo-endpoint", "Azure DevOps service endpoint for gitlab").Default("").String()
-	configFile          = kingpin.Flag("config", "Projects configuration file").Default("projects.json").String()
+	gitlabToken         = kingpin.Flag("gitlab-token", "Gitlab API token, required by commands reading gitlab").String()
+	gitlabURL           = kingpin.Flag("gitlab-url", "Gitlab URL, projects of other gitlab instances can be configured in gitlabInstances").Default("https://gitlab.com").String()
+	azdoOrganization    = kingpin.Flag("azdo-org", "Azure DevOps organization URL (https://dev.azure.com/myorg)").String()
+	azdoToken           = kingpin.Flag("azdo-token", "Azure DevOps Personal Access Token").String()
+	azdoServiceEndpoint = kingpin.Flag("azdo-endpoint", "Azure DevOps service endpoint for gitlab, used by projects without serviceEndpointId in the config").Default("").String()
+	configFile          = kingpin.Flag("config", "Projects configuration file, - reads it from standard input").Default("projects.json").String()
 	recreateRepository  = kingpin.Flag("recreate-repo", "If true, repository in azdo will be deleted first and created again. Use with caution").Default("false").Bool()
-	//SuggestionReplacer Regex to match gitlab suggestion schema so that it can be replaced to azdo schema
-	SuggestionReplacer = regexp.MustCompile("```suggestion:.*")
+	migrateCommand      = kingpin.Command("migrate", "Migrate configured projects").Default()
+	preflightCommand    = kingpin.Command("preflight", "Verify tokens, scopes and permissions for configured projects without migrating anything")
 )
 
-type config struct {
-	Projects []project `json:"projects"`
-}
-
-type project struct {
-	GitlabID    int    `json:"gitlabID"`
-	AzdoProject string `json:"azdoProject"`
-	MigrateMRs  bool   `json:"migrateMRs"`
-}
-
 func main() {
 	log.AddFlags(kingpin.CommandLine)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Version(version.Version)
-	kingpin.Parse()
+	kingpin.Version(versionInfo())
+	bindEnvars(kingpin.CommandLine)
+	command := kingpin.Parse()
+	if err := initNonInteractive(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initDraftPrefix(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initPhases(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initRepoPrefix(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initEmail(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initTokenExpiry(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initHistory(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initTransport(); err != nil {
+		log.Fatal(err)
+	}
+	log.AddHook(redactor)
+	serveMetrics()
+	redactor.add(*gitlabToken)
+	redactor.add(*azdoToken)
+	if command == selfUpdateCommand.FullCommand() {
+		if err := selfUpdate(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *checkUpdate {
+		warnAboutUpdate()
+	}
+	//nothing is migrated, simulated requests are not audited
+	if *simulateRecordings != "" {
+		simulateMigration(*simulateRecordings)
+		return
+	}
+	if command == healthcheckCommand.FullCommand() {
+		if failures := healthcheck(); failures > 0 {
+			log.Fatalf("healthcheck failed with %d problems", failures)
+		}
+		return
+	}
 
-	gitlabClient := initGitlab()
-	azdoCtx, azdoClient := initAzdo()
-	configFile := readConfig()
+	var err error
+	audit, err = openAuditLog(*auditLogFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	//deferred first so that it exits after the audit log is closed
+	defer exitNonInteractive()
+	defer audit.close()
 
-	for i, project := range configFile.Projects {
-		log.Infof("processing project %d (%d/%d)", project.GitlabID, i+1, len(configFile.Projects))
-		processProject(azdoCtx, project, gitlabClient, azdoClient)
+	defaultGitlab := initGitlab()
+	if command == generateConfigCommand.FullCommand() {
+		generateConfig(defaultGitlab.client)
+		return
+	}
+	if command == collectCommand.FullCommand() {
+		collectQueue()
+		return
 	}
-}
 
-func processProject(azdoCtx context.Context, project project, gitlabClient *gitlab.Client, azdoClient git.Client) {
-	gitlabProject, _, err := gitlabClient.Projects.GetProject(project.GitlabID, &gitlab.GetProjectOptions{})
+	azdoCtx, azdoConnection, azdoClient := initAzdo()
+	identities, err = loadIdentityMap(azdoCtx, azdoConnection, *identityMapFile)
 	if err != nil {
-		log.Errorf("couldn't find gitlab project %d does your API key have permission to the project?", project.GitlabID)
+		log.Fatal(err)
+	}
+	if workItems, err = loadWorkItemMap(*workItemMapFile); err != nil {
+		log.Fatal(err)
+	}
+	if command == serveCommand.FullCommand() {
+		serveAPI(azdoCtx, azdoConnection, azdoClient, defaultGitlab)
+		return
+	}
+	if command == workerCommand.FullCommand() {
+		runWorker(azdoCtx, azdoConnection, azdoClient, defaultGitlab, readConfig().GitlabInstances)
+		return
+	}
+
+	configFile := readConfig()
+	if command == retryCommand.FullCommand() {
+		if configFile.Projects, err = selectRetriedProjects(*retryFile, configFile.Projects); err != nil {
+			log.Fatal(err)
+		}
+		if len(configFile.Projects) == 0 {
+			log.Infof("retry queue %s is empty", *retryFile)
+			return
+		}
+	}
+	if command == reverseCommand.FullCommand() {
+		reverseProjects(azdoCtx, azdoClient, defaultGitlab, configFile)
+		finishReport()
+		return
+	}
+	if command == enqueueCommand.FullCommand() {
+		enqueueProjects(configFile)
+		return
+	}
+	if command == configLintCommand.FullCommand() {
+		runConfigLint(defaultGitlab, configFile)
+		return
+	}
+	filter := newProjectFilter()
+	unresolved := resolveProjects(defaultGitlab, &configFile, filter)
+
+	if command == preflightCommand.FullCommand() {
+		if failures := preflight(azdoCtx, azdoConnection, configFile) + unresolved; failures > 0 {
+			log.Fatalf("preflight failed with %d problems", failures)
+		}
+		return
+	}
+	if command == planCommand.FullCommand() {
+		planMigration(configFile)
+		return
+	}
+	if command == usersCommand.FullCommand() {
+		listUsers(azdoCtx, azdoConnection, configFile)
 		return
 	}
+	if unresolved > 0 {
+		log.Fatalf("%d gitlab projects in the configuration cannot be resolved, fix them before migration", unresolved)
+	}
+
+	if configFile.Projects, err = filter.startAt(configFile.Projects); err != nil {
+		log.Fatal(err)
+	}
+	checkTransferMode()
+	if *backfill {
+		backfillHistories(azdoCtx, azdoClient, configFile.Projects)
+		finishReport()
+		return
+	}
+	checkTokenExpiry(configFile.Projects)
+	mapping, stopped := migrateProjects(azdoCtx, azdoConnection, azdoClient, configFile.Projects)
+
+	if *mappingFile != "" {
+		if err := writeMapping(*mappingFile, mapping); err != nil {
+			log.Errorf("cannot write mapping file %s: %s", *mappingFile, err)
+		}
+	}
+
+	if *retryFile != "" {
+		if err := retries.write(*retryFile); err != nil {
+			log.Errorf("cannot write retry file %s: %s", *retryFile, err)
+		}
+	}
+
+	if *redirectMap != "" {
+		if err := writeRedirectMap(*redirectMap, *redirectFormat, mapping); err != nil {
+			log.Errorf("cannot write redirect map %s: %s", *redirectMap, err)
+		}
+	}
+
+	finishReport()
+	if stopped != nil {
+		log.Warn(stopped)
+	}
+}
+
+// migrateProjects migrates resolved projects one by one into the global report and fixes cross-project references
+// once all of them are migrated. Declined sample stops it after the project of the sample with errSampleDeclined
+func migrateProjects(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoClient git.Client, projects []project) (migrationMapping, error) {
+	mapping := migrationMapping{}
+	rememberConfiguredProjects(projects)
+	projects = skipArchivedProjects(projects)
+	complete := func(project project, projectMapping *projectMapping) {
+		recordResult(projectsMetric, "project", projectMapping != nil)
+		defer rearchiveProject(project, projectMapping != nil)
+		if projectMapping == nil {
+			project.report.fail()
+			retries.add(project)
+			return
+		}
+		project.report.migrated(projectMapping.AzdoRepositoryURL)
+		linkMergeRequests(project, *projectMapping)
+		runPostAction(project)
+		mapping.Projects = append(mapping.Projects, *projectMapping)
+	}
+	if *bulkImport > 0 {
+		migrateInBulk(azdoCtx, azdoConnection, azdoClient, projects, complete)
+	} else {
+		for i, project := range projects {
+			log.Infof("processing project %s (%d/%d)", project.gitlabProject.PathWithNamespace, i+1, len(projects))
+			project.report = report.project(project.gitlabProject.PathWithNamespace, project.AzdoProject)
+			complete(project, processProject(azdoCtx, azdoConnection, project, azdoClient))
+			if sample.isDeclined() {
+				break
+			}
+		}
+	}
+
+	if *fixupLinks {
+		fixupMergeRequestReferences(azdoCtx, azdoClient, mapping)
+	}
+
+	if *fixupSubmodules {
+		fixupSubmoduleURLs(azdoCtx, azdoClient, mapping)
+	}
+
+	if *fixupBadges {
+		fixupRepositoryBadges(azdoCtx, azdoConnection, azdoClient, mapping)
+	}
+
+	publishWikiIndex(azdoCtx, azdoConnection, mapping)
+	if sample.isDeclined() {
+		return mapping, errSampleDeclined
+	}
+	return mapping, nil
+}
+
+func processProject(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project, azdoClient git.Client) *projectMapping {
+	gitlabProject := project.gitlabProject
+	if err := checkRepositoryLimits(project); err != nil {
+		project.report.failWith(fmt.Errorf("cannot migrate %s: %w", gitlabProject.PathWithNamespace, err))
+		return nil
+	}
+	if err := unarchiveForMigration(project); err != nil {
+		project.report.failWith(err)
+		return nil
+	}
 
 	log.Debugf("creating import request for %s to project %s", gitlabProject.HTTPURLToRepo, project.AzdoProject)
-	repository := importRepository(azdoCtx, project, gitlabProject, azdoClient)
+	repository := importRepository(azdoCtx, azdoConnection, project, gitlabProject, azdoClient)
 	if repository == nil {
-		return
+		return nil
+	}
+	return finishProject(azdoCtx, azdoConnection, project, azdoClient, repository)
+}
+
+// finishProject migrates everything but the repository once it is transferred
+func finishProject(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project, azdoClient git.Client, repository *git.GitRepository) *projectMapping {
+	gitlabProject := project.gitlabProject
+	if phaseSelected(phaseRepo) {
+		verifyRepository(azdoCtx, azdoClient, project, repository)
+		provisionRepositoryPermissions(azdoCtx, azdoConnection, project, repository)
+		migrateProtectedTags(azdoCtx, azdoConnection, project, repository)
+	}
+	mapping := projectMapping{
+		GitlabProjectID:    gitlabProject.ID,
+		GitlabPath:         gitlabProject.PathWithNamespace,
+		GitlabURL:          gitlabProject.WebURL,
+		AzdoProject:        project.AzdoProject,
+		AzdoRepositoryID:   repository.Id.String(),
+		AzdoRepositoryName: *repository.Name,
+		AzdoRepositoryURL:  *repository.WebUrl,
 	}
 
-	if project.MigrateMRs {
-		importMergeRequests(azdoCtx, project, gitlabClient, azdoClient, gitlabProject, repository)
+	var failure error
+	migrateMRs := project.MigrateMRs && migratesPullRequests()
+	if migrateMRs && project.Prefix != "" {
+		project.report.problem("merge requests of projects combined into shared repository are not migrated, their branches are pushed under %s/", project.Prefix)
+	} else if migrateMRs {
+		mapping.MergeRequests, failure = importMergeRequests(azdoCtx, project, project.source, azdoClient, gitlabProject, repository)
 	}
+	if errors.Is(failure, errSampleDeclined) {
+		project.report.problem("merge requests after the sample are not migrated, %s", failure)
+	}
+	if abandonRepository(azdoCtx, azdoClient, project, repository, failure) {
+		return nil
+	}
+	if allPhasesSelected() {
+		migrateMappedIssues(azdoCtx, azdoConnection, project)
+		migrateBoard(azdoCtx, azdoConnection, project)
+		migratePackageRegistry(project)
+	}
+	if phaseSelected(phasePolicies) {
+		migrateApprovalRules(azdoCtx, azdoConnection, project, repository)
+	}
+	return &mapping
 }
 
-func importMergeRequests(azdoCtx context.Context, project project, gitlabClient *gitlab.Client, azdoClient git.Client, gitlabProject *gitlab.Project, repository *git.GitRepository) {
+// importMergeRequests migrates merge requests of the project, the error is the failure which stopped the migration
+// of all of them. Single merge requests which fail are reported and skipped unless credentials were rejected
+func importMergeRequests(azdoCtx context.Context, project project, source SourceClient, azdoClient TargetClient, gitlabProject *gitlab.Project, repository *git.GitRepository) ([]mergeRequestMapping, error) {
+	var mappings []mergeRequestMapping
 	log.Debugf("migrate merge requests for repo %s", *repository.Name)
-	gitlabMROptions := gitlab.ListProjectMergeRequestsOptions{
-		ListOptions: gitlab.ListOptions{
-			Page:    1,
-			PerPage: 100,
-		},
-		OrderBy: gitlab.String("created_at"),
-		Sort:    gitlab.String("asc"),
+	migrated, err := listMigratedPullRequests(azdoCtx, azdoClient, repository)
+	if err != nil {
+		project.report.problem("merge requests are not migrated, cannot check pull requests migrated already: %s", err)
+		return nil, err
 	}
-	for {
-		mergeRequests, response, err := gitlabClient.MergeRequests.ListProjectMergeRequests(gitlabProject.ID, &gitlabMROptions)
-		if err != nil {
-			log.Errorf("could not fetch MRs page %d: %s", gitlabMROptions.Page, err.Error())
-		}
-		for _, mr := range mergeRequests {
-			importMergeRequest(azdoCtx, azdoClient, gitlabClient, project, mr, repository)
+	mergeRequests, err := source.ListMergeRequests()
+	if err != nil {
+		project.report.problem("merge requests are not migrated, cannot list them: %s", err)
+		return nil, err
+	}
+	for start := 0; start < len(mergeRequests); start += mergeRequestBatch {
+		end := start + mergeRequestBatch
+		if end > len(mergeRequests) {
+			end = len(mergeRequests)
 		}
-		if response.NextPage > response.CurrentPage {
-			gitlabMROptions.Page++
-			continue
+		batch := mergeRequests[start:end]
+		prefetched := source.PrefetchDiscussions(batch)
+		for _, mr := range batch {
+			if pullRequest, ok := migrated[mr.WebURL]; ok {
+				existing := prepareExistingMapping(mr, pullRequest, repository)
+				if !phaseSelected(phaseMRs) {
+					revisitPullRequest(azdoCtx, azdoClient, source, project, mr, &pullRequest, prefetched[mr.IID], existing)
+				}
+				mappings = append(mappings, *existing)
+				continue
+			}
+			if !phaseSelected(phaseMRs) {
+				continue
+			}
+			if sample.isDeclined() {
+				return mappings, errSampleDeclined
+			}
+			if err := checkCrossProject(project, mr); err != nil && isMigrated(mr) {
+				project.report.problem("%s", err)
+				continue
+			}
+			mapping, err := importMergeRequest(azdoCtx, azdoClient, source, project, gitlabProject, mr, repository, prefetched[mr.IID])
+			if rejectsCredentials(err) {
+				project.report.problem("migration of merge requests stopped at merge request %d, credentials were rejected: %s", mr.IID, err)
+				return mappings, err
+			}
+			if mapping != nil {
+				mappings = append(mappings, *mapping)
+				if sampleReady(len(mappings), false) {
+					if err := confirmSample(mappings); err != nil {
+						return mappings, err
+					}
+				}
+			}
 		}
-		break
 	}
+	if sampleReady(len(mappings), true) {
+		return mappings, confirmSample(mappings)
+	}
+	return mappings, nil
 }
 
-func importMergeRequest(azdoCtx context.Context, azdoClient git.Client, gitlabClient *gitlab.Client, project project, mr *gitlab.MergeRequest, repository *git.GitRepository) {
+// importMergeRequest creates pull request of the merge request, discussions are fetched unless they are prefetched.
+// The error is returned when the pull request cannot be created, skipped merge requests have neither mapping nor error
+func importMergeRequest(azdoCtx context.Context, azdoClient TargetClient, source SourceClient, project project, gitlabProject *gitlab.Project, mr *MergeRequest, repository *git.GitRepository, discussions []*Discussion) (*mergeRequestMapping, error) {
 	azdoRequest := translatePullRequest(mr, repository)
 	if azdoRequest == nil {
-		return
+		return nil, nil
+	}
+	sourceBranch, fork := prepareSourceBranch(mr)
+	if err := ensureBranches(azdoCtx, azdoClient, project, mr, repository, sourceBranch, fork); err != nil {
+		log.Errorf("cannot migrate merge request %d: %s", mr.IID, err)
+		recordResult(mergeRequestsMetric, "merge_request", false)
+		return nil, err
+	}
+	azdoRequest.SourceRefName = gitlab.String("refs/heads/" + sourceBranch)
+	azdoRequest.WorkItemRefs = prepareWorkItemRefs(project, mr)
+	if phaseSelected(phaseLabels) {
+		azdoRequest.Labels = preparePullRequestLabels(project, mr)
+	}
+	approvedBy, changesRequestedBy := fetchReviewStates(source, project, mr)
+	reviewers := prepareReviewers(mr, approvedBy, changesRequestedBy)
+	if summary := prepareReviewSummary(reviewers); summary != "" {
+		description := *azdoRequest.Description + "\n\n" + summary
+		azdoRequest.Description = &description
+	}
+	azdoRequest.Reviewers = translateReviewers(reviewers)
+	descriptionName := fmt.Sprintf("gitlab-description-%d.md", mr.IID)
+	description, originalDescription := truncateContent(*azdoRequest.Description, azdoDescriptionLimit, descriptionName)
+	if originalDescription != "" {
+		project.report.problem("description of merge request %d is too long for AzDO, it is truncated and attached", mr.IID)
+		azdoRequest.Description = &description
 	}
 	pullRequestArgs := git.CreatePullRequestArgs{
 		GitPullRequestToCreate: azdoRequest,
 		RepositoryId:           gitlab.String(repository.Id.String()),
 		Project:                &project.AzdoProject,
-		SupportsIterations:     gitlab.Bool(false),
+		SupportsIterations:     gitlab.Bool(true),
 	}
 
+	iterations := startIterations(source, project, mr, repository, sourceBranch)
 	pullRequest, err := azdoClient.CreatePullRequest(azdoCtx, pullRequestArgs)
 	if err != nil {
 		log.Errorf("cannot migrate merge request %d: %s", mr.IID, err.Error())
-		return
+		recordResult(mergeRequestsMetric, "merge_request", false)
+		iterations.abort(project, mr)
+		return nil, err
 	}
-	importComments(azdoCtx, mr, pullRequest, gitlabClient, azdoClient)
+	iterations.replay(project, mr)
+	if originalDescription != "" {
+		attachOriginals(azdoCtx, azdoClient, pullRequest, map[string]string{descriptionName: originalDescription})
+	}
+	recordResult(mergeRequestsMetric, "merge_request", true)
+	audit.record("pullRequest.create", project.AzdoProject, strconv.Itoa(*pullRequest.PullRequestId), map[string]interface{}{
+		"repositoryId":    repository.Id.String(),
+		"gitlabProjectId": mr.ProjectID,
+		"mergeRequestIid": mr.IID,
+		"mergeRequestUrl": mr.WebURL,
+	})
+	setMigrationProperties(azdoCtx, azdoClient, project, pullRequest, mr)
+	project.report.authored(mr.Author, 1, 0)
+	for _, reviewer := range reviewers {
+		project.report.encountered(reviewer.user)
+	}
+	attachOriginalMergeRequest(azdoCtx, azdoClient, source, project, pullRequest, mr)
+	voteReviewers(azdoCtx, azdoClient, project, pullRequest, reviewers)
+	mapping := &mergeRequestMapping{
+		IID:           mr.IID,
+		GitlabURL:     mr.WebURL,
+		State:         mr.State,
+		PullRequestID: *pullRequest.PullRequestId,
+		AzdoURL:       preparePullRequestURL(*repository.WebUrl, *pullRequest.PullRequestId),
+	}
+	if phaseSelected(phaseComments) {
+		mapping.Notes = importComments(azdoCtx, project, mr, pullRequest, source, azdoClient, discussions)
+	}
+	if mr.State == "merged" {
+		closeMergedPullRequest(azdoCtx, azdoClient, source, project, mr, pullRequest)
+	}
+	return mapping, nil
 }
 
-func importComments(azdoCtx context.Context, mr *gitlab.MergeRequest, pullRequest *git.GitPullRequest, gitlabClient *gitlab.Client, azdoClient git.Client) {
-	log.Debugf("migrate discussions for merge request %d", mr.IID)
-	discussionOptions := gitlab.ListMergeRequestDiscussionsOptions{
-		Page:    1,
-		PerPage: 100,
-	}
-	for {
-		discussions, response, err := gitlabClient.Discussions.ListMergeRequestDiscussions(mr.ProjectID, mr.IID, &discussionOptions)
-		if err != nil {
-			log.Errorf("could not fetch Discussion page %d: %s", discussionOptions.Page, err.Error())
+func importComments(azdoCtx context.Context, project project, mr *MergeRequest, pullRequest *git.GitPullRequest, source SourceClient, azdoClient TargetClient, prefetched []*Discussion) []noteMapping {
+	var mappings []noteMapping
+	var collapsed []*Note
+	paths := fetchFilePaths(source, mr)
+	head := newHeadFiles(azdoCtx, azdoClient, pullRequest)
+	importDiscussion := func(discussion *Discussion) {
+		discussion, skipped := project.noise.filterDiscussion(discussion)
+		if len(skipped) > 0 {
+			log.Debugf("skip %d comments of bots in merge request %d", len(skipped), mr.IID)
+			collapsed = append(collapsed, skipped...)
 		}
-		for _, discussion := range discussions {
-			importCommentThread(azdoCtx, azdoClient, mr, pullRequest, discussion)
+		if discussion == nil {
+			return
 		}
-		if response.NextPage > response.CurrentPage {
-			discussionOptions.Page++
-			continue
+		if firstNote := discussion.Notes[0]; firstNote.System {
+			if keepSystemNote(project, firstNote) {
+				mappings = append(mappings, importSystemNote(azdoCtx, azdoClient, mr, pullRequest, firstNote)...)
+			}
+			return
+		}
+		imported := importCommentThread(azdoCtx, azdoClient, mr, pullRequest, discussion, paths, head)
+		mappings = append(mappings, imported...)
+		for _, note := range discussion.Notes {
+			for _, mapping := range imported {
+				if mapping.NoteID == note.ID {
+					project.report.authored(noteAuthor(note), 0, 1)
+				}
+			}
+		}
+	}
+	log.Debugf("migrate discussions for merge request %d", mr.IID)
+	if prefetched != nil {
+		for _, discussion := range prefetched {
+			importDiscussion(discussion)
 		}
-		break
+		return append(mappings, collapseNoise(azdoCtx, project.noise, azdoClient, mr, pullRequest, collapsed)...)
+	}
+	discussions, err := source.ListDiscussions(mr)
+	if err != nil {
+		project.report.commentsFailed(mr.IID, err)
+		return nil
+	}
+	sortDiscussions(discussions)
+	for _, discussion := range discussions {
+		importDiscussion(discussion)
 	}
+	return append(mappings, collapseNoise(azdoCtx, project.noise, azdoClient, mr, pullRequest, collapsed)...)
 }
 
-func importCommentThread(azdoCtx context.Context, azdoClient git.Client, mr *gitlab.MergeRequest, pullRequest *git.GitPullRequest, discussion *gitlab.Discussion) {
-	threadInit, fullThread := translateDiscussion(mr, discussion)
+func importCommentThread(azdoCtx context.Context, azdoClient TargetClient, mr *MergeRequest, pullRequest *git.GitPullRequest, discussion *Discussion, paths *filePaths, head *headFiles) []noteMapping {
+	threadInit, fullThread := translateDiscussion(mr, discussion, paths, head.placement(discussion.Notes[0], paths))
 	if threadInit == nil {
-		return
+		return nil
 	}
-	threadArgs := git.CreateThreadArgs{
-		CommentThread: threadInit,
-		RepositoryId:  pullRequest.Repository.Name,
-		PullRequestId: pullRequest.PullRequestId,
-		Project:       pullRequest.Repository.Project.Name,
+	originals := truncateComments(mr, discussion, threadInit, fullThread)
+	createdThread, replies, err := createThread(azdoCtx, azdoClient, pullRequest, threadInit, fullThread)
+	//rejected positioned thread is retried on the file and then as a general thread, stale lines or paths must not
+	//lose the comment
+	for err != nil && threadInit.ThreadContext != nil {
+		placement := placeFile
+		if threadInit.ThreadContext.RightFileStart == nil {
+			placement = placeGeneral
+		}
+		log.Warnf("cannot anchor thread (%s), retrying as %s thread: %s", prepareNoteLink(discussion.Notes[0], mr), placement, err)
+		threadInit, fullThread = translateDiscussion(mr, discussion, paths, placement)
+		originals = truncateComments(mr, discussion, threadInit, fullThread)
+		createdThread, replies, err = createThread(azdoCtx, azdoClient, pullRequest, threadInit, fullThread)
 	}
-	createdThread, err := azdoClient.CreateThread(azdoCtx, threadArgs)
 	if err != nil {
 		log.Errorf("cannot create thread (%s): %s", prepareNoteLink(discussion.Notes[0], mr), err)
-		return
+		recordResult(threadsMetric, "thread", false)
+		return nil
 	}
-	if fullThread != nil {
+	recordResult(threadsMetric, "thread", true)
+	audit.record("thread.create", *pullRequest.Repository.Project.Name, strconv.Itoa(*createdThread.Id), map[string]interface{}{
+		"pullRequestId": *pullRequest.PullRequestId,
+		"noteId":        discussion.Notes[0].ID,
+		"comments":      len(discussion.Notes) - len(replies),
+	})
+	if len(originals) > 0 {
+		log.Warnf("%d comments of thread (%s) are too long for AzDO, they are truncated and attached", len(originals), prepareNoteLink(discussion.Notes[0], mr))
+		attachOriginals(azdoCtx, azdoClient, pullRequest, originals)
+	}
+	if len(replies) > 0 {
 		fullThread.Id = createdThread.Id
+		fullThread.Comments = &replies
 		updateThreadArgs := git.UpdateThreadArgs{
 			CommentThread: fullThread,
 			RepositoryId:  pullRequest.Repository.Name,
@@ -168,13 +550,59 @@ func importCommentThread(azdoCtx context.Context, azdoClient git.Client, mr *git
 		_, err = azdoClient.UpdateThread(azdoCtx, updateThreadArgs)
 		if err != nil {
 			log.Errorf("cannot update thread (%s): %s", prepareNoteLink(discussion.Notes[0], mr), err)
-			return
+			return prepareNoteMappings(discussion.Notes[:len(discussion.Notes)-len(replies)], *createdThread.Id)
+		}
+		audit.record("thread.update", *pullRequest.Repository.Project.Name, strconv.Itoa(*createdThread.Id), map[string]interface{}{
+			"pullRequestId": *pullRequest.PullRequestId,
+			"comments":      len(replies),
+		})
+	}
+	return prepareNoteMappings(discussion.Notes, *createdThread.Id)
+}
+
+// createThread creates the thread with the whole comment chain in one request. When AzDO rejects it or creates only
+// some of the comments, the thread is created with the first comment and the replies it misses are returned to be
+// added by an update
+func createThread(azdoCtx context.Context, azdoClient TargetClient, pullRequest *git.GitPullRequest, threadInit *git.GitPullRequestCommentThread, fullThread *git.GitPullRequestCommentThread) (*git.GitPullRequestCommentThread, []git.Comment, error) {
+	threadArgs := git.CreateThreadArgs{
+		CommentThread: threadInit,
+		RepositoryId:  pullRequest.Repository.Name,
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       pullRequest.Repository.Project.Name,
+	}
+	if fullThread != nil {
+		comments := append(append([]git.Comment{}, *threadInit.Comments...), *fullThread.Comments...)
+		chain := *threadInit
+		chain.Comments = &comments
+		threadArgs.CommentThread = &chain
+		createdThread, err := azdoClient.CreateThread(azdoCtx, threadArgs)
+		if err == nil {
+			if createdThread.Comments == nil || len(*createdThread.Comments) == 0 || len(*createdThread.Comments) >= len(comments) {
+				return createdThread, nil, nil
+			}
+			return createdThread, comments[len(*createdThread.Comments):], nil
 		}
+		log.Debugf("cannot create thread with %d comments at once, replies are added separately: %s", len(comments), err)
+		threadArgs.CommentThread = threadInit
+	}
+	createdThread, err := azdoClient.CreateThread(azdoCtx, threadArgs)
+	if err != nil || fullThread == nil {
+		return createdThread, nil, err
+	}
+	return createdThread, *fullThread.Comments, nil
+}
+
+// prepareNoteMappings maps notes to thread comments, comments are numbered in the order of notes
+func prepareNoteMappings(notes []*Note, threadID int) []noteMapping {
+	var mappings []noteMapping
+	for i, note := range notes {
+		mappings = append(mappings, noteMapping{NoteID: note.ID, ThreadID: threadID, CommentID: i + 1})
 	}
+	return mappings
 }
 
-func translateDiscussion(mr *gitlab.MergeRequest, discussion *gitlab.Discussion) (*git.GitPullRequestCommentThread, *git.GitPullRequestCommentThread) {
-	status := git.CommentThreadStatusValues.Fixed
+func translateDiscussion(mr *MergeRequest, discussion *Discussion, paths *filePaths, placement threadPlacement) (*git.GitPullRequestCommentThread, *git.GitPullRequestCommentThread) {
+	status := threadStatus(mr, discussion)
 	firstNote := discussion.Notes[0]
 	if firstNote.System {
 		return nil, nil
@@ -182,40 +610,63 @@ func translateDiscussion(mr *gitlab.MergeRequest, discussion *gitlab.Discussion)
 	var comments []git.Comment
 	thread := git.GitPullRequestCommentThread{
 		PullRequestThreadContext: nil,
-		PublishedDate:            &azuredevops.Time{Time: *firstNote.CreatedAt},
+		PublishedDate:            prepareAzdoTime(firstNote.CreatedAt),
 	}
-	if firstNote.Position != nil && firstNote.Position.NewPath != "" {
-		line := firstNote.Position.NewLine
-		if firstNote.Position.LineRange != nil {
-			line = firstNote.Position.LineRange.StartRange.NewLine
+	anchor := threadAnchor(firstNote)
+	original := ""
+	if anchor != nil {
+		//comments on removed lines cannot be anchored to lines and files no longer in the pull request cannot be
+		//anchored at all, AzDO rejects them
+		path, ok := paths.resolve(firstNote.Position)
+		outdated := positionOutdated(mr, firstNote.Position)
+		if outdated && placement == placeLine {
+			placement = placeFile
 		}
-		thread.ThreadContext = &git.CommentThreadContext{
-			FilePath:       gitlab.String("/" + firstNote.Position.NewPath),
-			RightFileStart: &git.CommentPosition{Line: &line},
-			RightFileEnd:   &git.CommentPosition{Line: &line},
+		switch {
+		case ok && anchor.start > 0 && placement == placeLine:
+			thread.ThreadContext = &git.CommentThreadContext{
+				FilePath:       gitlab.String("/" + path),
+				RightFileStart: &git.CommentPosition{Line: gitlab.Int(anchor.start)},
+				RightFileEnd:   &git.CommentPosition{Line: gitlab.Int(anchor.end)},
+			}
+		case ok && placement <= placeFile:
+			thread.ThreadContext = &git.CommentThreadContext{FilePath: gitlab.String("/" + path)}
+			original = prepareOriginalPosition(firstNote.Position)
+			anchor = nil
+		default:
+			original = prepareOriginalPosition(firstNote.Position)
+			anchor = nil
+		}
+		if outdated {
+			original += "\n" + prepareOutdatedPosition(mr, firstNote.Position)
 		}
 	}
 	id := 1
 	for _, note := range discussion.Notes {
 		commentType := &git.CommentTypeValues.Text
 
-		if firstNote.Position != nil && firstNote.Position.NewPath != "" {
+		if anchor != nil {
 			commentType = &git.CommentTypeValues.CodeChange
 		}
-		comment := translateNote(mr, note, id, commentType)
-		if !note.Resolved {
-			status = git.CommentThreadStatusValues.Active
-		}
-		comments = append(comments, comment)
+		comments = append(comments, translateNote(mr, note, id, commentType, anchor))
 		id++
 	}
+	if original != "" {
+		content := *comments[0].Content + "\n\n" + original
+		comments[0].Content = &content
+	}
+	if resolution := prepareResolution(discussion); resolution != "" && status == git.CommentThreadStatusValues.Fixed {
+		closing := *comments[len(comments)-1].Content + "\n\n" + resolution
+		comments[len(comments)-1].Content = &closing
+	}
 	thread.Status = &status
 	if len(comments) == 1 {
 		thread.Comments = &comments
 		return &thread, nil
 	}
 
-	//thread has to exist to add replies, original comment and ThreadContext must be omitted
+	//replies are added by an update when AzDO does not create the whole chain at once, original comment and
+	//ThreadContext must be omitted then
 	threadInit := thread
 	threadInit.Comments = &[]git.Comment{comments[0]}
 	skipFirstComment := comments[1:]
@@ -225,48 +676,144 @@ func translateDiscussion(mr *gitlab.MergeRequest, discussion *gitlab.Discussion)
 	return &threadInit, &thread
 }
 
-func translateNote(mr *gitlab.MergeRequest, note *gitlab.Note, id int, commentType *git.CommentType) git.Comment {
-	content := prepareNoteBody(mr, note, id)
+// threadStatus maps state of gitlab discussion - resolved discussions are fixed, unresolved ones stay active while the
+// merge request is open and were not fixed once it is merged or closed. Plain comments cannot be resolved, they are
+// active until the merge request is closed
+func threadStatus(mr *MergeRequest, discussion *Discussion) git.CommentThreadStatus {
+	resolvable, resolved := false, true
+	for _, note := range discussion.Notes {
+		if note.System || !note.Resolvable {
+			continue
+		}
+		resolvable = true
+		resolved = resolved && note.Resolved
+	}
+	switch {
+	case resolvable && resolved:
+		return git.CommentThreadStatusValues.Fixed
+	case isMigrated(mr):
+		return git.CommentThreadStatusValues.Active
+	case resolvable:
+		return git.CommentThreadStatusValues.WontFix
+	default:
+		return git.CommentThreadStatusValues.Closed
+	}
+}
+
+// prepareResolution returns who resolved the discussion and when, gitlab API client does not expose resolved_at so
+// the time the resolved notes were last updated (resolving updates them) is used
+func prepareResolution(discussion *Discussion) string {
+	var resolver *Note
+	var resolvedAt time.Time
+	for _, note := range discussion.Notes {
+		if !note.Resolved || note.ResolvedBy.Username == "" {
+			continue
+		}
+		resolver = note
+		if note.UpdatedAt != nil && note.UpdatedAt.After(resolvedAt) {
+			resolvedAt = *note.UpdatedAt
+		}
+	}
+	if resolver == nil {
+		return ""
+	}
+	by := resolver.ResolvedBy
+	if resolvedAt.IsZero() {
+		return fmt.Sprintf("*Resolved by %s*", prepareUserLink(by.Username, by.Name, by.WebURL))
+	}
+	return fmt.Sprintf("*Resolved by %s on %s*", prepareUserLink(by.Username, by.Name, by.WebURL), resolvedAt.Format("2006-01-02"))
+}
+
+// threadPlacement is how precisely the thread is attached to the code, a thread AzDO rejects is placed less precisely
+type threadPlacement int
+
+const (
+	placeLine threadPlacement = iota
+	placeFile
+	placeGeneral
+)
+
+func (p threadPlacement) String() string {
+	return [...]string{"line", "file", "general"}[p]
+}
+
+// threadAnchor returns lines the thread should be attached to. Multiline comments span their whole range and
+// a suggestion in the first note widens the anchor to the lines it replaces, so AzDO applies it to the same lines.
+func threadAnchor(note *Note) *lineRange {
+	if note.Position == nil || note.Position.NewPath == "" {
+		return nil
+	}
+	anchor := lineRange{start: note.Position.NewLine, end: note.Position.NewLine}
+	if note.Position.LineRange != nil {
+		anchor = lineRange{start: note.Position.LineRange.StartRange.NewLine, end: note.Position.LineRange.EndRange.NewLine}
+	}
+	for _, line := range strings.Split(note.Body, "\n") {
+		if suggestion, ok := suggestionRange(line, note.Position.NewLine); ok {
+			return &suggestion
+		}
+	}
+	return &anchor
+}
+
+func translateNote(mr *MergeRequest, note *Note, id int, commentType *git.CommentType, anchor *lineRange) git.Comment {
+	content := prepareNoteBody(mr, note, anchor)
 
 	comment := git.Comment{
 		Id:              gitlab.Int(id),
 		Content:         &content,
-		PublishedDate:   &azuredevops.Time{Time: *note.CreatedAt},
-		LastUpdatedDate: &azuredevops.Time{Time: *note.UpdatedAt},
+		PublishedDate:   prepareAzdoTime(note.CreatedAt),
+		LastUpdatedDate: prepareAzdoTime(note.UpdatedAt),
 		CommentType:     commentType,
 	}
 	comment.ParentCommentId = gitlab.Int(id - 1)
 	return comment
 }
 
-func prepareNoteBody(mr *gitlab.MergeRequest, note *gitlab.Note, id int) string {
-	lineRange := ""
-	body := note.Body
-	if id == 1 && note.Position != nil && note.Position.LineRange != nil && note.Position.LineRange.StartRange.NewLine != note.Position.LineRange.EndRange.NewLine {
-		//AzDO does not support multiline comments so we add a note at least
-		lineRange = fmt.Sprintf("| **🚩 Multiline comment %d-%d**", note.Position.LineRange.StartRange.NewLine, note.Position.LineRange.EndRange.NewLine)
-		body = SuggestionReplacer.ReplaceAllString(body, "🚩 **️Multiline suggestions are not supported in AzDO - if suggestion is multiline, commit it manually**\n```suggestion")
+// prepareAzdoTime converts optional date of the source, AzDO dates pull requests, threads and comments without it by
+// their creation
+func prepareAzdoTime(date *time.Time) *azuredevops.Time {
+	if date == nil {
+		return nil
+	}
+	return &azuredevops.Time{Time: *date}
+}
+
+func prepareNoteBody(mr *MergeRequest, note *Note, anchor *lineRange) string {
+	line := 0
+	if note.Position != nil {
+		line = note.Position.NewLine
 	}
-	body = SuggestionReplacer.ReplaceAllString(body, "```suggestion")
+	body := convertMarkdown(note.Body, markdownContext{projectURL: prepareProjectURL(mr), line: line, anchor: anchor})
 	content := fmt.Sprintf(
-		"*Migrated from [Gitlab](%s) | Author: ![%s](%s =24x24) [%s](%s)%s*\n\n%s",
+		"*Migrated from [Gitlab](%s) | Author: %s%s*\n\n%s",
 		prepareNoteLink(note, mr),
-		note.Author.Name,
-		note.Author.AvatarURL,
-		note.Author.Name,
-		note.Author.WebURL,
-		lineRange,
+		prepareAuthor(note.Author.Username, note.Author.Name, note.Author.AvatarURL, note.Author.WebURL),
+		prepareEditedMarker(note),
 		body,
 	)
 	return content
 }
 
-func prepareNoteLink(note *gitlab.Note, mr *gitlab.MergeRequest) string {
+// prepareProjectURL derives gitlab project URL from the merge request one, uploads are relative to it
+func prepareProjectURL(mr *MergeRequest) string {
+	return strings.SplitN(mr.WebURL, "/-/", 2)[0]
+}
+
+// prepareNoteLink links the note in the source, notes which do not know their link are linked as gitlab notes
+func prepareNoteLink(note *Note, mr *MergeRequest) string {
+	if note.WebURL != "" {
+		return note.WebURL
+	}
 	return fmt.Sprintf("%s/diffs#note_%d", mr.WebURL, note.ID)
 }
 
-func translatePullRequest(mr *gitlab.MergeRequest, repository *git.GitRepository) *git.GitPullRequest {
-	if mr.State == "closed" || mr.State == "merged" {
+// isMigrated tells whether the merge request becomes a pull request, merged ones do with --migrate-merged
+func isMigrated(mr *MergeRequest) bool {
+	return mr.State != "closed" && (mr.State != "merged" || *migrateMerged)
+}
+
+func translatePullRequest(mr *MergeRequest, repository *git.GitRepository) *git.GitPullRequest {
+	if !isMigrated(mr) {
 		return nil
 	}
 	azdoRequest := git.GitPullRequest{}
@@ -275,7 +822,11 @@ func translatePullRequest(mr *gitlab.MergeRequest, repository *git.GitRepository
 		DisplayName: &mr.Author.Username,
 		Descriptor:  &mr.Author.Name,
 	}
-	azdoRequest.CreationDate = &azuredevops.Time{Time: *mr.CreatedAt}
+	if *anonymizeAuthors {
+		pseudonym := pseudonyms.pseudonym(mr.Author.Username)
+		azdoRequest.CreatedBy = &webapi.IdentityRef{DisplayName: &pseudonym, Descriptor: &pseudonym}
+	}
+	azdoRequest.CreationDate = prepareAzdoTime(mr.CreatedAt)
 	azdoRequest.IsDraft = &mr.WorkInProgress
 	azdoRequest.Repository = repository
 	if mr.MergeCommitSHA != "" {
@@ -286,78 +837,105 @@ func translatePullRequest(mr *gitlab.MergeRequest, repository *git.GitRepository
 	azdoRequest.Status = &git.PullRequestStatusValues.Active
 
 	description := preparePullRequestDescription(mr)
-	azdoRequest.Title = &mr.Title
+	azdoRequest.Title = gitlab.String(prepareTitle(mr))
 	sourceBranch := fmt.Sprintf("refs/heads/%s", mr.SourceBranch)
 	targetBranch := fmt.Sprintf("refs/heads/%s", mr.TargetBranch)
 	azdoRequest.SourceRefName = &sourceBranch
 	azdoRequest.TargetRefName = &targetBranch
 	azdoRequest.Description = &description
+	azdoRequest.Labels = prepareMigrationLabels()
 	return &azdoRequest
 }
 
-func preparePullRequestDescription(mr *gitlab.MergeRequest) string {
+func preparePullRequestDescription(mr *MergeRequest) string {
+	description := convertMarkdown(mr.Description, markdownContext{projectURL: prepareProjectURL(mr)})
+	if summary := taskSummary(mr); summary != "" {
+		description = summary + "\n\n" + description
+	}
 	return fmt.Sprintf(
-		"*Migrated from [Gitlab](%s) | Author: ![%s](%s =24x24) [%s](%s)*\n\n%s",
+		"*Migrated from [Gitlab](%s) | Author: %s*\n\n%s",
 		mr.WebURL,
-		mr.Author.Name,
-		mr.Author.AvatarURL,
-		mr.Author.Name,
-		mr.Author.WebURL,
-		mr.Description,
+		prepareAuthor(mr.Author.Username, mr.Author.Name, mr.Author.AvatarURL, mr.Author.WebURL),
+		description,
 	)
 }
 
-func importRepository(azdoCtx context.Context, project project, gitlabProject *gitlab.Project, azdoClient git.Client) *git.GitRepository {
+func importRepository(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project, gitlabProject *gitlab.Project, azdoClient git.Client) *git.GitRepository {
+	azdoRepository, pending := startRepositoryTransfer(azdoCtx, azdoConnection, project, gitlabProject, azdoClient)
+	if pending == nil {
+		return azdoRepository
+	}
+	defer pending.removeEndpoint()
+	for {
+		done, err := pending.poll(azdoCtx, azdoClient)
+		if err != nil {
+			project.report.failWith(err)
+			return nil
+		}
+		if done {
+			return azdoRepository
+		}
+		log.Debugf("waiting for import to finish retry in 3 seconds...")
+		time.Sleep(importPollPeriod)
+	}
+}
+
+// startRepositoryTransfer creates AzDO repository and transfers gitlab repository into it, import request is returned
+// as pending as AzDO transfers the repository asynchronously. Nil repository means the transfer failed
+func startRepositoryTransfer(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project, gitlabProject *gitlab.Project, azdoClient git.Client) (*git.GitRepository, *pendingImport) {
+	if !phaseSelected(phaseRepo) {
+		existing, err := findMigratedRepository(azdoCtx, project, azdoClient)
+		if err != nil {
+			project.report.failWith(err)
+		}
+		return existing, nil
+	}
+	if existing := findReusableRepository(azdoCtx, project, azdoClient); existing != nil {
+		log.Infof("repository %s exists already, transfer is skipped", *existing.Name)
+		return existing, nil
+	}
 	azdoRepository, err := reinitAzdoRepository(azdoCtx, project, gitlabProject, azdoClient)
 	if err != nil {
-		log.Error(err)
-		return nil
+		project.report.failWith(err)
+		return nil, nil
 	}
 
-	importRequest, err := createImportRequest(azdoCtx, project, gitlabProject, azdoClient, azdoRepository)
-	if err != nil {
-		log.Error(err)
-		return nil
+	if mirrorsRepository(project) {
+		if err := mirrorRepository(azdoCtx, azdoClient, project, azdoRepository); err != nil {
+			project.report.failWith(fmt.Errorf("cannot mirror %s: %w", gitlabProject.PathWithNamespace, err))
+			return nil, nil
+		}
+		return azdoRepository, nil
+	}
+	if len(project.ExcludeRefs) > 0 || len(project.StripPaths) > 0 {
+		project.report.problem("excludeRefs and stripPaths are honored only by --transfer-mode=mirror or bundle, repository is imported as is")
 	}
 
-	requestStatusArg := git.GetImportRequestArgs{
-		Project:         &project.AzdoProject,
-		RepositoryId:    gitlab.String(azdoRepository.Id.String()),
-		ImportRequestId: importRequest.ImportRequestId,
+	endpointID, removeEndpoint, err := prepareServiceEndpoint(azdoCtx, azdoConnection, project)
+	if err != nil {
+		project.report.failWith(err)
+		return nil, nil
 	}
-	for {
-		currentRequest, err := azdoClient.GetImportRequest(azdoCtx, requestStatusArg)
-		if (currentRequest == nil && err == nil) || *currentRequest.Status == git.GitAsyncOperationStatusValues.Completed {
-			log.Debug("import finished")
-			return azdoRepository
-		}
-		if *currentRequest.Status == git.GitAsyncOperationStatusValues.Abandoned {
-			log.Error("import request abandoned")
-			return nil
-		}
-		if *currentRequest.Status == git.GitAsyncOperationStatusValues.Failed {
-			log.Errorf("import request failed: %s", *currentRequest.DetailedStatus.ErrorMessage)
-			return nil
-		}
 
-		log.Debugf("waiting for import to finish retry in 3 seconds...")
-		time.Sleep(3 * time.Second)
+	importRequest, err := createImportRequest(azdoCtx, project, gitlabProject, azdoClient, azdoRepository, endpointID)
+	if err != nil {
+		removeEndpoint()
+		project.report.failWith(err)
+		return nil, nil
 	}
+	return azdoRepository, &pendingImport{project: project, repository: azdoRepository, request: importRequest, removeEndpoint: removeEndpoint}
 }
 
-func createImportRequest(azdoCtx context.Context, project project, gitlabProject *gitlab.Project, azdoClient git.Client, azdoRepository *git.GitRepository) (*git.GitImportRequest, error) {
+func createImportRequest(azdoCtx context.Context, project project, gitlabProject *gitlab.Project, azdoClient git.Client, azdoRepository *git.GitRepository, endpointID *uuid.UUID) (*git.GitImportRequest, error) {
 	requestArg := git.GitImportRequest{
 		Parameters: &git.GitImportRequestParameters{
 			GitSource: &git.GitImportGitSource{
 				Overwrite: gitlab.Bool(false),
 				Url:       &gitlabProject.HTTPURLToRepo,
 			},
+			ServiceEndpointId: endpointID,
 		},
 	}
-	if *azdoServiceEndpoint != "" {
-		spUUID := uuid.MustParse(*azdoServiceEndpoint)
-		requestArg.Parameters.ServiceEndpointId = &spUUID
-	}
 
 	importRequestArgs := git.CreateImportRequestArgs{
 		ImportRequest: &requestArg,
@@ -365,19 +943,32 @@ func createImportRequest(azdoCtx context.Context, project project, gitlabProject
 		RepositoryId:  gitlab.String(azdoRepository.Id.String()),
 	}
 
-	log.Debugf("create import request to transfer %s into new repo %s", gitlabProject.HTTPURLToRepo, gitlabProject.Path)
+	log.Debugf("create import request to transfer %s into new repo %s", gitlabProject.HTTPURLToRepo, *azdoRepository.Name)
 	importRequest, err := azdoClient.CreateImportRequest(azdoCtx, importRequestArgs)
 	if err != nil {
-		return nil, fmt.Errorf("could not create import request. Either service endpoint is not correct or source repository is empty: %s", err)
+		return nil, fmt.Errorf("could not create import request. Either service endpoint is not correct or source repository is empty: %w", err)
 	}
+	audit.record("importRequest.create", project.AzdoProject, strconv.Itoa(*importRequest.ImportRequestId), map[string]interface{}{
+		"repositoryId": azdoRepository.Id.String(),
+		"sourceUrl":    gitlabProject.HTTPURLToRepo,
+	})
 	return importRequest, nil
 }
 
 func reinitAzdoRepository(azdoCtx context.Context, project project, gitlabProject *gitlab.Project, azdoClient git.Client) (*git.GitRepository, error) {
+	name := project.azdoRepositoryName()
+	if combined := combinedRepository(project); combined != nil {
+		//refreshed as the default branch is set by the project which created it
+		log.Debugf("combining into repository %s", name)
+		return azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{
+			RepositoryId: gitlab.String(combined.Id.String()),
+			Project:      &project.AzdoProject,
+		})
+	}
 	if *recreateRepository {
-		log.Debugf("removing repository %s if exists from %s", gitlabProject.Path, project.AzdoProject)
+		log.Debugf("removing repository %s if exists from %s", name, project.AzdoProject)
 		repo, _ := azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{
-			RepositoryId: &gitlabProject.Path,
+			RepositoryId: &name,
 			Project:      &project.AzdoProject,
 		})
 		if repo != nil {
@@ -386,37 +977,38 @@ func reinitAzdoRepository(azdoCtx context.Context, project project, gitlabProjec
 				Project:      nil,
 			})
 			if err != nil {
-				return nil, fmt.Errorf("could remove previous repository, cannot import to existing repo %s", err.Error())
+				return nil, fmt.Errorf("could remove previous repository, cannot import to existing repo %w", err)
 			}
+			audit.record("repository.delete", project.AzdoProject, repo.Id.String(), map[string]interface{}{
+				"name": *repo.Name,
+			})
 		}
 	}
 
-	log.Debugf("create empty repository %s", gitlabProject.Path)
+	log.Debugf("create empty repository %s", name)
 	azdoRepository, err := azdoClient.CreateRepository(azdoCtx, git.CreateRepositoryArgs{
 		GitRepositoryToCreate: &git.GitRepositoryCreateOptions{
-			Name: &gitlabProject.Path,
+			Name: &name,
 		},
 		Project: &project.AzdoProject,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("could not initiate repository %s: %s", gitlabProject.Path, err)
+		return nil, fmt.Errorf("could not initiate repository %s: %w", name, err)
 	}
+	audit.record("repository.create", project.AzdoProject, azdoRepository.Id.String(), map[string]interface{}{
+		"name":            *azdoRepository.Name,
+		"gitlabProjectId": gitlabProject.ID,
+	})
+	rememberCreatedRepository(azdoRepository)
+	rememberCombinedRepository(project, azdoRepository)
 	return azdoRepository, nil
 }
 
-func readConfig() config {
-	file, _ := ioutil.ReadFile(*configFile)
-
-	configFile := config{}
-
-	err := json.Unmarshal(file, &configFile)
-	if err != nil {
-		log.Fatal(err)
+func initAzdo() (context.Context, *azuredevops.Connection, git.Client) {
+	if *azdoOrganization == "" || *azdoToken == "" {
+		log.Fatal("--azdo-org and --azdo-token are required")
 	}
-	return configFile
-}
-
-func initAzdo() (context.Context, git.Client) {
+	instrumentAzdoTransport()
 	connection := azuredevops.NewPatConnection(*azdoOrganization, *azdoToken)
 
 	ctx := context.Background()
@@ -425,13 +1017,32 @@ func initAzdo() (context.Context, git.Client) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	return ctx, client
+	return ctx, connection, client
 }
 
-func initGitlab() *gitlab.Client {
-	gitlabClient, err := gitlab.NewClient(*gitlabToken)
+func initGitlab() *gitlabInstance {
+	if *gitlabToken == "" {
+		log.Fatal("--gitlab-token is required")
+	}
+	gitlabClient, err := newGitlabClient(*gitlabURL, *gitlabToken)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return gitlabClient
+	instance := &gitlabInstance{URL: *gitlabURL, token: *gitlabToken, client: gitlabClient}
+	if err := initJobToken(instance); err != nil {
+		log.Fatal(err)
+	}
+	return instance
+}
+
+func newGitlabClient(baseURL string, token string) (*gitlab.Client, error) {
+	options := []gitlab.ClientOptionFunc{
+		gitlab.WithBaseURL(baseURL),
+		gitlab.WithHTTPClient(&http.Client{Transport: &instrumentedTransport{base: &pacedTransport{base: traced(timed(baseTransport))}}}),
+		gitlab.WithCustomRetry(countRetries),
+	}
+	if *maxRequestsPerSecond > 0 {
+		options = append(options, gitlab.WithCustomLimiter(newRequestLimiter()))
+	}
+	return gitlab.NewClient(token, options...)
 }
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-test/deep"
 	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
 	"github.com/xanzy/go-gitlab"
+	"strings"
 	"testing"
 	"time"
 )
@@ -22,19 +25,19 @@ func TestTranslateDiscussion(t *testing.T) {
 
 	discussions := []struct {
 		label      string
-		discussion gitlab.Discussion
+		discussion Discussion
 		init       *git.GitPullRequestCommentThread
 		full       *git.GitPullRequestCommentThread
 	}{
 		{
 			"system note - should be skipped",
-			gitlab.Discussion{Notes: []*gitlab.Note{{System: true}}},
+			Discussion{Notes: []*Note{{System: true}}},
 			nil,
 			nil,
 		},
 		{
 			"single generic comment",
-			gitlab.Discussion{Notes: []*gitlab.Note{&singleNote}},
+			Discussion{Notes: []*Note{&singleNote}},
 			&git.GitPullRequestCommentThread{
 				PublishedDate: &azuredevops.Time{Time: createdAt},
 				Comments:      &[]git.Comment{singleComment},
@@ -44,15 +47,15 @@ func TestTranslateDiscussion(t *testing.T) {
 		},
 		{
 			"thread discussion",
-			gitlab.Discussion{Notes: []*gitlab.Note{&suggestionNote, &singleNote}},
+			Discussion{Notes: []*Note{&suggestionNote, &singleNote}},
 			&git.GitPullRequestCommentThread{
 				PublishedDate: &azuredevops.Time{Time: createdAt},
 				Comments:      &[]git.Comment{suggestionComment},
 				Status:        &git.CommentThreadStatusValues.Active,
 				ThreadContext: &git.CommentThreadContext{
 					FilePath:       gitlab.String("/" + suggestionNote.Position.NewPath),
-					RightFileStart: &git.CommentPosition{Line: &suggestionNote.Position.NewLine},
-					RightFileEnd:   &git.CommentPosition{Line: &suggestionNote.Position.NewLine},
+					RightFileStart: &git.CommentPosition{Line: gitlab.Int(1)},
+					RightFileEnd:   &git.CommentPosition{Line: gitlab.Int(2)},
 				},
 			},
 			&git.GitPullRequestCommentThread{
@@ -64,7 +67,7 @@ func TestTranslateDiscussion(t *testing.T) {
 	}
 
 	for _, discussion := range discussions {
-		threadInit, fullThread := translateDiscussion(&mr, &discussion.discussion)
+		threadInit, fullThread := translateDiscussion(&mr, &discussion.discussion, nil, placeLine)
 
 		if diffInit := deep.Equal(threadInit, discussion.init); diffInit != nil {
 			t.Errorf("%s: %+v", discussion.label, diffInit)
@@ -75,11 +78,182 @@ func TestTranslateDiscussion(t *testing.T) {
 	}
 }
 
+func TestTranslateDiscussionPlacement(t *testing.T) {
+	mr := setupSimpleMergeRequest()
+	note := setupSingleNote()
+	note.Position = &NotePosition{OldPath: "main.go", NewPath: "main.go", NewLine: 3}
+	original := "\n\n📄 *Originally on line 3 of `main.go`*"
+
+	placements := []struct {
+		label     string
+		placement threadPlacement
+		context   *git.CommentThreadContext
+		original  bool
+	}{
+		{"line", placeLine, &git.CommentThreadContext{
+			FilePath:       gitlab.String("/main.go"),
+			RightFileStart: &git.CommentPosition{Line: gitlab.Int(3)},
+			RightFileEnd:   &git.CommentPosition{Line: gitlab.Int(3)},
+		}, false},
+		{"file", placeFile, &git.CommentThreadContext{FilePath: gitlab.String("/main.go")}, true},
+		{"general", placeGeneral, nil, true},
+	}
+	for _, placement := range placements {
+		thread, _ := translateDiscussion(&mr, &Discussion{Notes: []*Note{&note}}, nil, placement.placement)
+		content := *(*thread.Comments)[0].Content
+		if diff := deep.Equal([]interface{}{thread.ThreadContext, strings.HasSuffix(content, original)}, []interface{}{placement.context, placement.original}); diff != nil {
+			t.Errorf("%s: %+v", placement.label, diff)
+		}
+	}
+}
+
+// positionClient rejects threads anchored more precisely than allowed, other methods of git.Client are not expected
+type positionClient struct {
+	git.Client
+	allowed threadPlacement
+	created []*git.GitPullRequestCommentThread
+}
+
+func (c *positionClient) CreateThread(ctx context.Context, args git.CreateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	placement := placeGeneral
+	if threadContext := args.CommentThread.ThreadContext; threadContext != nil && threadContext.RightFileStart != nil {
+		placement = placeLine
+	} else if threadContext != nil {
+		placement = placeFile
+	}
+	if placement < c.allowed {
+		return nil, fmt.Errorf("invalid thread position")
+	}
+	c.created = append(c.created, args.CommentThread)
+	args.CommentThread.Id = gitlab.Int(len(c.created))
+	return args.CommentThread, nil
+}
+
+func TestImportCommentThreadFallback(t *testing.T) {
+	mr := setupSimpleMergeRequest()
+	note := setupSingleNote()
+	note.Position = &NotePosition{OldPath: "main.go", NewPath: "main.go", NewLine: 3}
+	pullRequest := &git.GitPullRequest{
+		PullRequestId: gitlab.Int(1),
+		Repository:    &git.GitRepository{Name: gitlab.String("app"), Project: &core.TeamProjectReference{Name: gitlab.String("Apps")}},
+	}
+
+	for _, allowed := range []threadPlacement{placeLine, placeFile, placeGeneral} {
+		client := &positionClient{allowed: allowed}
+		mappings := importCommentThread(context.Background(), client, &mr, pullRequest, &Discussion{Notes: []*Note{&note}}, nil, nil)
+		if diff := deep.Equal(mappings, []noteMapping{{NoteID: note.ID, ThreadID: 1, CommentID: 1}}); diff != nil {
+			t.Errorf("%s: %+v", allowed, diff)
+		}
+	}
+}
+
+// chainClient creates threads with at most accepted comments (all when 0) and counts requests, other methods of
+// git.Client are not expected
+type chainClient struct {
+	git.Client
+	accepted int
+	rejected bool
+	creates  int
+	updates  []int
+}
+
+func (c *chainClient) CreateThread(ctx context.Context, args git.CreateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	c.creates++
+	comments := *args.CommentThread.Comments
+	if c.rejected && len(comments) > 1 {
+		return nil, fmt.Errorf("invalid comments")
+	}
+	if c.accepted > 0 && len(comments) > c.accepted {
+		comments = comments[:c.accepted]
+	}
+	return &git.GitPullRequestCommentThread{Id: gitlab.Int(1), Comments: &comments}, nil
+}
+
+func (c *chainClient) UpdateThread(ctx context.Context, args git.UpdateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	for _, comment := range *args.CommentThread.Comments {
+		c.updates = append(c.updates, *comment.Id)
+	}
+	return args.CommentThread, nil
+}
+
+func TestImportCommentChain(t *testing.T) {
+	mr := setupSimpleMergeRequest()
+	first, second, third := setupSingleNote(), setupSingleNote(), setupSingleNote()
+	first.ID, second.ID, third.ID = 1, 2, 3
+	discussion := &Discussion{Notes: []*Note{&first, &second, &third}}
+	pullRequest := &git.GitPullRequest{
+		PullRequestId: gitlab.Int(1),
+		Repository:    &git.GitRepository{Name: gitlab.String("app"), Project: &core.TeamProjectReference{Name: gitlab.String("Apps")}},
+	}
+	mappings := []noteMapping{{NoteID: 1, ThreadID: 1, CommentID: 1}, {NoteID: 2, ThreadID: 1, CommentID: 2}, {NoteID: 3, ThreadID: 1, CommentID: 3}}
+
+	clients := []struct {
+		label   string
+		client  *chainClient
+		creates int
+		updates []int
+	}{
+		{"whole chain", &chainClient{}, 1, nil},
+		{"partial chain", &chainClient{accepted: 2}, 1, []int{3}},
+		{"rejected chain", &chainClient{rejected: true}, 2, []int{2, 3}},
+	}
+	for _, client := range clients {
+		result := importCommentThread(context.Background(), client.client, &mr, pullRequest, discussion, nil, nil)
+		if diff := deep.Equal([]interface{}{result, client.client.creates, client.client.updates}, []interface{}{mappings, client.creates, client.updates}); diff != nil {
+			t.Errorf("%s: %+v", client.label, diff)
+		}
+	}
+}
+
+func TestThreadStatus(t *testing.T) {
+	resolved := Note{Resolvable: true, Resolved: true}
+	unresolved := Note{Resolvable: true}
+	comment := Note{}
+	open, merged := setupOpenMergeRequest(), setupClosedMergeRequest()
+	merged.State = "merged"
+
+	threads := []struct {
+		label  string
+		mr     *MergeRequest
+		notes  []*Note
+		expect git.CommentThreadStatus
+	}{
+		{"resolved", &open, []*Note{&resolved, &comment}, git.CommentThreadStatusValues.Fixed},
+		{"resolved in merged", &merged, []*Note{&resolved}, git.CommentThreadStatusValues.Fixed},
+		{"unresolved", &open, []*Note{&resolved, &unresolved}, git.CommentThreadStatusValues.Active},
+		{"unresolved in merged", &merged, []*Note{&unresolved}, git.CommentThreadStatusValues.WontFix},
+		{"comment", &open, []*Note{&comment}, git.CommentThreadStatusValues.Active},
+		{"comment in merged", &merged, []*Note{&comment}, git.CommentThreadStatusValues.Closed},
+	}
+	for _, thread := range threads {
+		if diff := deep.Equal(threadStatus(thread.mr, &Discussion{Notes: thread.notes}), thread.expect); diff != nil {
+			t.Errorf("%s: %+v", thread.label, diff)
+		}
+	}
+}
+
+func TestPrepareResolution(t *testing.T) {
+	updatedAt, createdAt := setupDates()
+	author := setupAuthor()
+	first := Note{Resolvable: true, Resolved: true, UpdatedAt: &createdAt}
+	first.ResolvedBy.Username, first.ResolvedBy.Name, first.ResolvedBy.WebURL = author.Username, author.Name, author.WebURL
+	last := first
+	last.UpdatedAt = &updatedAt
+
+	expect := "*Resolved by [John Doe](https://gitlab.com/john-doe) on 2019-11-04*"
+	if diff := deep.Equal(prepareResolution(&Discussion{Notes: []*Note{&first, &last}}), expect); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(prepareResolution(&Discussion{Notes: []*Note{{Resolvable: true}}}), ""); diff != nil {
+		t.Error(diff)
+	}
+}
+
 func TestTranslatePullRequest(t *testing.T) {
 	openPullRequest := setupExpectedOpenPullRequest()
 	pullRequests := []struct {
 		label        string
-		mergeRequest gitlab.MergeRequest
+		mergeRequest MergeRequest
 		pullRequest  *git.GitPullRequest
 	}{
 		{
@@ -104,10 +278,10 @@ func TestTranslatePullRequest(t *testing.T) {
 
 }
 func TestPrepareNoteBody(t *testing.T) {
-	expect := "*Migrated from [Gitlab](https://gitlab.com/gitlab-examples/php/-/merge_requests/1/diffs#note_0) | Author: ![John Doe](https://www.gravatar.com/avatar/0 =24x24) [John Doe](https://gitlab.com/john-doe)| **🚩 Multiline comment 1-2***\n\n🚩 **️Multiline suggestions are not supported in AzDO - if suggestion is multiline, commit it manually**\n```suggestion\nfoo\nbar\n```"
+	expect := "*Migrated from [Gitlab](https://gitlab.com/gitlab-examples/php/-/merge_requests/1/diffs#note_0) | Author: ![John Doe](https://www.gravatar.com/avatar/0 =24x24) [John Doe](https://gitlab.com/john-doe)*\n\n```suggestion\nfoo\nbar\n```"
 	mr := setupOpenMergeRequest()
 	note := setupSuggestionNote()
-	if diff := deep.Equal(expect, prepareNoteBody(&mr, &note, 1)); diff != nil {
+	if diff := deep.Equal(expect, prepareNoteBody(&mr, &note, threadAnchor(&note))); diff != nil {
 		t.Error(diff)
 	}
 }
@@ -134,6 +308,7 @@ func setupExpectedOpenPullRequest() git.GitPullRequest {
 		CreationDate:    &azuredevops.Time{Time: createdAt},
 		Description:     &description,
 		IsDraft:         gitlab.Bool(true),
+		Labels:          &[]core.WebApiTagDefinition{{Name: gitlab.String("migrated-from-gitlab")}},
 		LastMergeCommit: &git.GitCommitRef{CommitId: &mr.MergeCommitSHA},
 		Repository:      &repository,
 		SourceRefName:   gitlab.String(fmt.Sprintf("refs/heads/%s", mr.SourceBranch)),
@@ -149,13 +324,13 @@ func setupExpectedRepository() git.GitRepository {
 	}
 }
 
-func setupOpenMergeRequest() gitlab.MergeRequest {
+func setupOpenMergeRequest() MergeRequest {
 	author := setupAuthor()
 	_, createdAt := setupDates()
-	return gitlab.MergeRequest{
+	return MergeRequest{
 		State:       "open",
 		Description: "open merge request description",
-		Author: &gitlab.BasicUser{
+		Author: &User{
 			Username:  author.Username,
 			Name:      author.Name,
 			AvatarURL: author.AvatarURL,
@@ -171,7 +346,7 @@ func setupOpenMergeRequest() gitlab.MergeRequest {
 	}
 }
 
-func setupClosedMergeRequest() gitlab.MergeRequest {
+func setupClosedMergeRequest() MergeRequest {
 	mr := setupOpenMergeRequest()
 	mr.State = "closed"
 	return mr
@@ -189,7 +364,7 @@ func setupExpectedSingleComment() git.Comment {
 	mr := setupSimpleMergeRequest()
 	updatedAt, createdAt := setupDates()
 	note := setupSingleNote()
-	content := prepareNoteBody(&mr, &note, 1)
+	content := prepareNoteBody(&mr, &note, nil)
 	return git.Comment{
 		Id:              gitlab.Int(1),
 		Content:         &content,
@@ -204,7 +379,7 @@ func setupExpectedSuggestionComment() git.Comment {
 	mr := setupSimpleMergeRequest()
 	updatedAt, createdAt := setupDates()
 	note := setupSuggestionNote()
-	content := prepareNoteBody(&mr, &note, 1)
+	content := prepareNoteBody(&mr, &note, threadAnchor(&note))
 	return git.Comment{
 		Id:              gitlab.Int(1),
 		Content:         &content,
@@ -221,60 +396,43 @@ func setupDates() (time.Time, time.Time) {
 	return updatedAt, createdAt
 }
 
-func setupAuthor() struct {
-	ID        int    `json:"id"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Name      string `json:"name"`
-	State     string `json:"state"`
-	AvatarURL string `json:"avatar_url"`
-	WebURL    string `json:"web_url"`
-} {
-	author := struct {
-		ID        int    `json:"id"`
-		Username  string `json:"username"`
-		Email     string `json:"email"`
-		Name      string `json:"name"`
-		State     string `json:"state"`
-		AvatarURL string `json:"avatar_url"`
-		WebURL    string `json:"web_url"`
-	}{
+func setupAuthor() User {
+	return User{
 		Username:  "john-doe",
 		Name:      "John Doe",
 		AvatarURL: "https://www.gravatar.com/avatar/0",
 		WebURL:    "https://gitlab.com/john-doe",
 	}
-	return author
 }
 
-func setupSimpleMergeRequest() gitlab.MergeRequest {
-	return gitlab.MergeRequest{
+func setupSimpleMergeRequest() MergeRequest {
+	return MergeRequest{
 		WebURL: "https://gitlab.com/gitlab-examples/php/-/merge_requests/1",
 	}
 }
-func setupSuggestionNote() gitlab.Note {
+func setupSuggestionNote() Note {
 	updatedAt, createdAt := setupDates()
-	note := gitlab.Note{
+	note := Note{
 		Body:      "```suggestion:-1+0\nfoo\nbar\n```",
 		Author:    setupAuthor(),
 		CreatedAt: &createdAt,
 		UpdatedAt: &updatedAt,
 		System:    false,
-		Position: &gitlab.NotePosition{
+		Position: &NotePosition{
 			NewPath: "README.md",
-			NewLine: 1,
-			LineRange: &gitlab.LineRange{
-				StartRange: &gitlab.LinePosition{NewLine: 1},
-				EndRange:   &gitlab.LinePosition{NewLine: 2},
+			NewLine: 2,
+			LineRange: &LineRange{
+				StartRange: &LinePosition{NewLine: 1},
+				EndRange:   &LinePosition{NewLine: 2},
 			},
 		},
 	}
 	return note
 }
 
-func setupSingleNote() gitlab.Note {
+func setupSingleNote() Note {
 	updatedAt, createdAt := setupDates()
-	return gitlab.Note{
+	return Note{
 		System:    false,
 		Body:      "single generic comment",
 		Author:    setupAuthor(),
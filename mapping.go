@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+)
+
+var mappingFile = kingpin.Flag("mapping-file", "Write gitlab to AzDO ID mapping into the file at the end of the run").Default("").String()
+
+type migrationMapping struct {
+	Projects []projectMapping `json:"projects"`
+}
+
+type projectMapping struct {
+	GitlabProjectID    int                   `json:"gitlabProjectId"`
+	GitlabPath         string                `json:"gitlabPath"`
+	GitlabURL          string                `json:"gitlabUrl"`
+	AzdoProject        string                `json:"azdoProject"`
+	AzdoRepositoryID   string                `json:"azdoRepositoryId"`
+	AzdoRepositoryName string                `json:"azdoRepositoryName"`
+	AzdoRepositoryURL  string                `json:"azdoRepositoryUrl"`
+	MergeRequests      []mergeRequestMapping `json:"mergeRequests"`
+}
+
+type mergeRequestMapping struct {
+	IID           int           `json:"iid"`
+	GitlabURL     string        `json:"gitlabUrl"`
+	State         string        `json:"state"`
+	PullRequestID int           `json:"pullRequestId"`
+	AzdoURL       string        `json:"azdoUrl"`
+	Notes         []noteMapping `json:"notes"`
+
+	// existing pull requests were created by an earlier run
+	existing bool
+}
+
+type noteMapping struct {
+	NoteID    int `json:"noteId"`
+	ThreadID  int `json:"threadId"`
+	CommentID int `json:"commentId"`
+}
+
+func preparePullRequestURL(repositoryURL string, pullRequestID int) string {
+	return fmt.Sprintf("%s/pullrequest/%d", repositoryURL, pullRequestID)
+}
+
+func writeMapping(path string, mapping migrationMapping) error {
+	content, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
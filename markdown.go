@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	//SuggestionHeader Regex to match gitlab suggestion header including its line offsets (```suggestion:-1+2)
+	SuggestionHeader = regexp.MustCompile("^```suggestion(?::-(\\d+)\\+(\\d+))?\\s*$")
+	fenceMatcher     = regexp.MustCompile("^\\s*(```|~~~)\\s*([\\w+-]*)")
+	taskMatcher      = regexp.MustCompile(`^(\s*)(?:[*+-]|\d+[.)])\s+\[([ xX~])\]\s`)
+	labelMatcher     = regexp.MustCompile(`(^|[\s(])~(?:"([^"]+)"|([\w.:-]*\w))`)
+	uploadMatcher    = regexp.MustCompile(`(!?)\[([^\]]*)\]\((/uploads/[^)\s]+)\)`)
+	inlineMath       = regexp.MustCompile("\\$`([^`]+)`\\$")
+	detailsMatcher   = regexp.MustCompile(`(?i)^\s*</?details[^>]*>\s*$`)
+	summaryMatcher   = regexp.MustCompile(`(?i)^\s*<summary>(.*)</summary>\s*$`)
+	videoExtensions  = []string{".mp4", ".mov", ".webm", ".ogg"}
+)
+
+type lineRange struct {
+	start int
+	end   int
+}
+
+// markdownContext carries everything the conversion needs to know about where the text comes from
+type markdownContext struct {
+	projectURL string
+	line       int
+	anchor     *lineRange
+}
+
+// convertMarkdown translates gitlab flavored markdown into markdown AzDO renders the same way
+func convertMarkdown(body string, ctx markdownContext) string {
+	lines := strings.Split(body, "\n")
+	converted := make([]string, 0, len(lines))
+	fence := ""
+	block := ""
+	for _, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				converted = append(converted, closeBlock(block, line))
+				fence = ""
+				continue
+			}
+			if block == "diff" {
+				line = "+" + line
+			}
+			converted = append(converted, line)
+			continue
+		}
+		if match := fenceMatcher.FindStringSubmatch(line); match != nil {
+			fence = match[1]
+			opened, kind := openBlock(line, match[2], ctx)
+			block = kind
+			converted = append(converted, opened)
+			continue
+		}
+		if detailsMatcher.MatchString(line) {
+			continue
+		}
+		line, ok := convertQuickAction(line)
+		if !ok {
+			continue
+		}
+		converted = append(converted, convertLine(line, ctx))
+	}
+	return strings.Join(converted, "\n")
+}
+
+// openBlock converts fenced block header and returns the kind of the block so that its content and end can be
+// converted as well
+func openBlock(line string, language string, ctx markdownContext) (string, string) {
+	switch {
+	case SuggestionHeader.MatchString(strings.TrimSpace(line)):
+		suggestion, ok := suggestionRange(strings.TrimSpace(line), ctx.line)
+		if ok && ctx.anchor != nil && suggestion == *ctx.anchor {
+			return "```suggestion", "suggestion"
+		}
+		location := "unknown lines"
+		if ok {
+			location = fmt.Sprintf("lines %d-%d", suggestion.start, suggestion.end)
+		}
+		return fmt.Sprintf("🚩 **Suggested change of %s cannot be applied in AzDO - commit it manually**\n```diff", location), "diff"
+	case language == "math":
+		return "$$", "math"
+	case language == "mermaid":
+		return "📊 *Mermaid diagram - not rendered in AzDO pull requests*\n```mermaid", "mermaid"
+	}
+	return line, language
+}
+
+func closeBlock(block string, line string) string {
+	if block == "math" {
+		return "$$"
+	}
+	return line
+}
+
+// suggestionRange parses suggestion header offsets - gitlab counts them relative to the line the note is attached to
+func suggestionRange(header string, line int) (lineRange, bool) {
+	match := SuggestionHeader.FindStringSubmatch(header)
+	if match == nil || line == 0 {
+		return lineRange{}, false
+	}
+	above, below := 0, 0
+	if match[1] != "" {
+		above, _ = strconv.Atoi(match[1])
+		below, _ = strconv.Atoi(match[2])
+	}
+	return lineRange{start: line - above, end: line + below}, true
+}
+
+func convertLine(line string, ctx markdownContext) string {
+	if match := summaryMatcher.FindStringSubmatch(line); match != nil {
+		//AzDO does not render collapsible sections, summary is kept as a heading of the section
+		return fmt.Sprintf("**▸ %s**", strings.TrimSpace(match[1]))
+	}
+	line = convertTask(line)
+	line = labelMatcher.ReplaceAllStringFunc(line, func(reference string) string {
+		match := labelMatcher.FindStringSubmatch(reference)
+		label := match[2]
+		if label == "" {
+			label = match[3]
+		}
+		return fmt.Sprintf("%s`🏷️ %s`", match[1], label)
+	})
+	line = uploadMatcher.ReplaceAllStringFunc(line, func(upload string) string {
+		match := uploadMatcher.FindStringSubmatch(upload)
+		url := ctx.projectURL + match[3]
+		if match[1] == "!" && isVideo(match[3]) {
+			//AzDO cannot embed videos, link is the best we can do
+			return fmt.Sprintf("[🎬 %s](%s)", match[2], url)
+		}
+		return fmt.Sprintf("%s[%s](%s)", match[1], match[2], url)
+	})
+	if *anonymizeAuthors {
+		line = anonymizeMentions(line)
+	}
+	return inlineMath.ReplaceAllString(line, "$$$1$$")
+}
+
+// convertTask normalizes gitlab task list items (any bullet, numbered, inapplicable state) to AzDO checklist
+func convertTask(line string) string {
+	match := taskMatcher.FindStringSubmatch(line)
+	if match == nil {
+		return line
+	}
+	text := line[len(match[0]):]
+	switch match[2] {
+	case "x", "X":
+		return fmt.Sprintf("%s- [x] %s", match[1], text)
+	case "~":
+		return fmt.Sprintf("%s- [ ] ~~%s~~", match[1], text)
+	}
+	return fmt.Sprintf("%s- [ ] %s", match[1], text)
+}
+
+func isVideo(path string) bool {
+	for _, extension := range videoExtensions {
+		if strings.HasSuffix(strings.ToLower(path), extension) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestConvertMarkdown(t *testing.T) {
+	ctx := markdownContext{projectURL: "https://gitlab.com/gitlab-examples/php", line: 5, anchor: &lineRange{start: 4, end: 7}}
+	bodies := []struct {
+		label  string
+		body   string
+		ctx    markdownContext
+		expect string
+	}{
+		{
+			"suggestion matching the anchor",
+			"```suggestion:-1+2\nfoo\n```",
+			ctx,
+			"```suggestion\nfoo\n```",
+		},
+		{
+			"suggestion outside of the anchor",
+			"```suggestion:-0+1\nfoo\nbar\n```\nthanks",
+			ctx,
+			"🚩 **Suggested change of lines 5-6 cannot be applied in AzDO - commit it manually**\n```diff\n+foo\n+bar\n```\nthanks",
+		},
+		{
+			"suggestion without position",
+			"```suggestion:-0+0\nfoo\n```",
+			markdownContext{},
+			"🚩 **Suggested change of unknown lines cannot be applied in AzDO - commit it manually**\n```diff\n+foo\n```",
+		},
+		{
+			"fence of other language starting with suggestion",
+			"```suggestions\nfoo\n```",
+			ctx,
+			"```suggestions\nfoo\n```",
+		},
+		{
+			"task list",
+			"* [X] done\n  1. [ ] todo\n- [~] skipped",
+			ctx,
+			"- [x] done\n  - [ ] todo\n- [ ] ~~skipped~~",
+		},
+		{
+			"collapsible section",
+			"<details>\n<summary>Logs</summary>\nfoo\n</details>",
+			ctx,
+			"**▸ Logs**\nfoo",
+		},
+		{
+			"label references",
+			"see ~bug and ~\"needs review\" but not ~~strike~~",
+			ctx,
+			"see `🏷️ bug` and `🏷️ needs review` but not ~~strike~~",
+		},
+		{
+			"uploads",
+			"![screen](/uploads/abc/screen.png) ![demo](/uploads/abc/demo.mp4) [log](/uploads/abc/log.txt)",
+			ctx,
+			"![screen](https://gitlab.com/gitlab-examples/php/uploads/abc/screen.png) [🎬 demo](https://gitlab.com/gitlab-examples/php/uploads/abc/demo.mp4) [log](https://gitlab.com/gitlab-examples/php/uploads/abc/log.txt)",
+		},
+		{
+			"math",
+			"inline $`a^2`$\n```math\na^2+b^2\n```",
+			ctx,
+			"inline $a^2$\n$$\na^2+b^2\n$$",
+		},
+		{
+			"mermaid",
+			"```mermaid\ngraph TD;\n```",
+			ctx,
+			"📊 *Mermaid diagram - not rendered in AzDO pull requests*\n```mermaid\ngraph TD;\n```",
+		},
+		{
+			"code blocks are left untouched",
+			"```go\n* [x] ~label\n```",
+			ctx,
+			"```go\n* [x] ~label\n```",
+		},
+	}
+
+	for _, body := range bodies {
+		if diff := deep.Equal(body.expect, convertMarkdown(body.body, body.ctx)); diff != nil {
+			t.Errorf("%s: %+v", body.label, diff)
+		}
+	}
+}
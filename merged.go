@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strconv"
+	"strings"
+)
+
+var migrateMerged = kingpin.Flag("migrate-merged", "Migrate merged merge requests as well, their pull requests get a closed thread with merge metadata (merge and squash commit messages, who merged them and when) and are abandoned as AzDO cannot complete pull requests whose changes are merged already. Deleted source branches need --restore-source-branches, merge metadata takes an API call per merged merge request and commit").Default("false").Bool()
+
+// prepareMergeSummary describes how the merge request was merged, commit messages are quoted as they were written
+func prepareMergeSummary(mr *MergeRequest, details *MergeDetails) string {
+	merged := fmt.Sprintf("Merged in [Gitlab](%s)", mr.WebURL)
+	if user := details.MergedBy; user != nil {
+		merged += " by " + prepareUserLink(user.Username, user.Name, user.WebURL)
+	}
+	if details.MergedAt != nil {
+		merged += " on " + details.MergedAt.Format("2006-01-02 15:04")
+	}
+	lines := []string{fmt.Sprintf("🔀 *%s*", merged)}
+	for _, commit := range details.Commits {
+		kind := "Merge commit"
+		if commit.Squash {
+			kind = "Squash commit"
+		}
+		lines = append(lines, "", fmt.Sprintf("%s `%s`:", kind, commit.SHA), "```", strings.TrimSpace(commit.Message), "```")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// closeMergedPullRequest records merge metadata of the merged merge request in a closed thread and abandons its pull
+// request, the changes are in the target branch already so completing it would merge nothing
+func closeMergedPullRequest(azdoCtx context.Context, azdoClient TargetClient, source SourceClient, project project, mr *MergeRequest, pullRequest *git.GitPullRequest) {
+	details, err := source.GetMergeDetails(mr)
+	if err != nil {
+		project.report.problem("merge commits of merge request %d are not described in pull request %d: %s", mr.IID, *pullRequest.PullRequestId, err)
+	}
+	if details != nil {
+		content := prepareMergeSummary(mr, details)
+		thread := &git.GitPullRequestCommentThread{
+			PublishedDate: prepareAzdoTime(details.MergedAt),
+			Status:        &git.CommentThreadStatusValues.Closed,
+			Comments: &[]git.Comment{{
+				Content:         &content,
+				CommentType:     &git.CommentTypeValues.Text,
+				ParentCommentId: gitlab.Int(0),
+			}},
+		}
+		if _, _, err := createThread(azdoCtx, azdoClient, pullRequest, thread, nil); err != nil {
+			log.Errorf("cannot create merge summary of merge request %d: %s", mr.IID, err)
+			recordResult(threadsMetric, "thread", false)
+		} else {
+			recordResult(threadsMetric, "thread", true)
+		}
+	}
+	_, err = azdoClient.UpdatePullRequest(azdoCtx, git.UpdatePullRequestArgs{
+		GitPullRequestToUpdate: &git.GitPullRequest{Status: &git.PullRequestStatusValues.Abandoned},
+		RepositoryId:           pullRequest.Repository.Name,
+		PullRequestId:          pullRequest.PullRequestId,
+		Project:                pullRequest.Repository.Project.Name,
+	})
+	if err != nil {
+		project.report.problem("pull request %d of merged merge request %d stays active, it cannot be abandoned: %s", *pullRequest.PullRequestId, mr.IID, err)
+		return
+	}
+	audit.record("pullRequest.update", project.AzdoProject, strconv.Itoa(*pullRequest.PullRequestId), map[string]interface{}{
+		"repositoryId": *pullRequest.Repository.Name,
+		"field":        "status",
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+	"time"
+)
+
+func TestPrepareMergeSummary(t *testing.T) {
+	mergedAt := time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC)
+	mr := &MergeRequest{WebURL: "https://gitlab.com/group/php/-/merge_requests/7"}
+	details := &MergeDetails{
+		MergedBy: &User{Username: "john", Name: "John", WebURL: "https://gitlab.com/john"},
+		MergedAt: &mergedAt,
+		Commits: []MergeCommit{
+			{SHA: "abc", Message: "Fix login\n", Squash: true},
+			{SHA: "def", Message: "Merge branch 'fix' into 'master'"},
+		},
+	}
+	expected := "🔀 *Merged in [Gitlab](https://gitlab.com/group/php/-/merge_requests/7) by [John](https://gitlab.com/john) on 2021-03-01 10:30*\n\n" +
+		"Squash commit `abc`:\n```\nFix login\n```\n\n" +
+		"Merge commit `def`:\n```\nMerge branch 'fix' into 'master'\n```"
+	if diff := deep.Equal(prepareMergeSummary(mr, details), expected); diff != nil {
+		t.Error(diff)
+	}
+	expected = "🔀 *Merged in [Gitlab](https://gitlab.com/group/php/-/merge_requests/7)*"
+	if diff := deep.Equal(prepareMergeSummary(mr, &MergeDetails{}), expected); diff != nil {
+		t.Errorf("fast-forward merge: %+v", diff)
+	}
+}
+
+func TestCloseMergedPullRequest(t *testing.T) {
+	mr := &MergeRequest{IID: 7, State: "merged", WebURL: "https://gitlab.com/group/php/-/merge_requests/7"}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	source := NewMockSourceClient(ctrl)
+	source.EXPECT().GetMergeDetails(mr).Return(&MergeDetails{Commits: []MergeCommit{{SHA: "def", Message: "Merge"}}}, nil)
+	target := &stubTarget{threads: map[int][]string{}, statuses: map[int]git.PullRequestStatus{}}
+	pullRequest := &git.GitPullRequest{
+		PullRequestId: gitlab.Int(3),
+		Repository:    &git.GitRepository{Name: gitlab.String("php"), Project: &core.TeamProjectReference{Name: gitlab.String("Apps")}},
+	}
+	project := project{AzdoProject: "Apps", report: &projectReport{}}
+
+	closeMergedPullRequest(context.Background(), target, source, project, mr, pullRequest)
+	expected := []interface{}{
+		[]string{"🔀 *Merged in [Gitlab](https://gitlab.com/group/php/-/merge_requests/7)*\n\nMerge commit `def`:\n```\nMerge\n```"},
+		git.PullRequestStatusValues.Abandoned,
+		0,
+	}
+	if diff := deep.Equal([]interface{}{target.threads[3], target.statuses[3], len(project.report.Problems)}, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestIsMigrated(t *testing.T) {
+	merged := &MergeRequest{State: "merged"}
+	if isMigrated(merged) || isMigrated(&MergeRequest{State: "closed"}) || !isMigrated(&MergeRequest{State: "opened"}) {
+		t.Error("only open merge requests are migrated by default")
+	}
+	*migrateMerged = true
+	defer func() { *migrateMerged = false }()
+	if !isMigrated(merged) || isMigrated(&MergeRequest{State: "closed"}) {
+		t.Error("merged merge requests are migrated with --migrate-merged")
+	}
+}
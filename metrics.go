@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	metricsListen      = kingpin.Flag("metrics-listen", "Address to expose prometheus metrics on during the run (e.g. :9090)").Default("").String()
+	metricsPushgateway = kingpin.Flag("metrics-pushgateway", "Prometheus pushgateway URL the metrics are pushed to at the end of the run").Default("").String()
+	metricsJob         = kingpin.Flag("metrics-job", "Job name of metrics pushed to the pushgateway").Default("gitlab-azdo-migration").String()
+
+	// baseTransport is the transport of both clients before it is instrumented, initTransport tunes its pooling
+	baseTransport       = http.DefaultTransport
+	metricsRegistry     = prometheus.NewRegistry()
+	projectsMetric      = newCounterVec("projects_total", "Projects processed by result", "result")
+	mergeRequestsMetric = newCounterVec("merge_requests_total", "Merge requests migrated as pull requests", "result")
+	threadsMetric       = newCounterVec("threads_total", "Discussions migrated as pull request threads", "result")
+	retriesMetric       = newCounterVec("retries_total", "API requests retried", "host")
+	failuresMetric      = newCounterVec("failures_total", "Failed items by kind (project, merge_request, thread)", "kind")
+	requestsMetric      = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "gitlab_azdo_migration",
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of gitlab and AzDO API requests",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"host", "method", "code"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(requestsMetric)
+}
+
+func newCounterVec(name string, help string, label string) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gitlab_azdo_migration",
+		Name:      name,
+		Help:      help,
+	}, []string{label})
+	metricsRegistry.MustRegister(counter)
+	return counter
+}
+
+// recordResult counts migrated item as succeeded or failed, failures are counted by kind of the item as well
+func recordResult(counter *prometheus.CounterVec, kind string, ok bool) {
+	if ok {
+		counter.WithLabelValues("migrated").Inc()
+		return
+	}
+	counter.WithLabelValues("failed").Inc()
+	failuresMetric.WithLabelValues(kind).Inc()
+}
+
+// instrumentedTransport measures latency of every API request, AzDO client cannot be given its own HTTP client
+// so it is measured through http.DefaultTransport
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.base.RoundTrip(request)
+	code := "error"
+	if response != nil {
+		code = strconv.Itoa(response.StatusCode)
+	}
+	requestsMetric.WithLabelValues(request.URL.Host, request.Method, code).Observe(time.Since(start).Seconds())
+	return response, err
+}
+
+// countRetries is the retry policy of gitlab client (rate limit and server errors are retried) which counts retries
+func countRetries(ctx context.Context, response *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		return false, err
+	}
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError {
+		retriesMetric.WithLabelValues(response.Request.URL.Host).Inc()
+		return true, nil
+	}
+	return false, nil
+}
+
+// instrumentAzdoTransport replaces http.DefaultTransport so that AzDO client created afterwards is measured, throttled
+// and backs off when AzDO throttles it
+func instrumentAzdoTransport() {
+	http.DefaultTransport = &instrumentedTransport{base: &throttledTransport{base: &backoffTransport{base: traced(timed(baseTransport))}, limiter: newRequestLimiter()}}
+}
+
+func serveMetrics() {
+	if *metricsListen == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(*metricsListen, mux); err != nil {
+			log.Errorf("cannot expose metrics on %s: %s", *metricsListen, err)
+		}
+	}()
+}
+
+func pushMetrics() {
+	if *metricsPushgateway == "" {
+		return
+	}
+	if err := push.New(*metricsPushgateway, *metricsJob).Gatherer(metricsRegistry).Push(); err != nil {
+		log.Errorf("cannot push metrics to %s: %s", *metricsPushgateway, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentedTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &instrumentedTransport{base: baseTransport}}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	host := response.Request.URL.Host
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	observed := uint64(0)
+	for _, family := range families {
+		if family.GetName() == "gitlab_azdo_migration_api_request_duration_seconds" {
+			observed = family.Metric[0].GetHistogram().GetSampleCount()
+		}
+	}
+	if observed != 1 {
+		t.Errorf("expected one observed request, got %d", observed)
+	}
+
+	retry, _ := countRetries(context.Background(), &http.Response{StatusCode: http.StatusTooManyRequests, Request: &http.Request{URL: &url.URL{Host: host}}}, nil)
+	noRetry, _ := countRetries(context.Background(), &http.Response{StatusCode: http.StatusNotFound, Request: &http.Request{URL: &url.URL{Host: host}}}, nil)
+	if !retry || noRetry {
+		t.Errorf("only rate limited and failed requests should be retried")
+	}
+	if value := testutil.ToFloat64(retriesMetric.WithLabelValues(host)); value != 1 {
+		t.Errorf("expected one retry, got %f", value)
+	}
+}
+
+func TestRecordResult(t *testing.T) {
+	before := testutil.ToFloat64(failuresMetric.WithLabelValues("thread"))
+	recordResult(threadsMetric, "thread", true)
+	recordResult(threadsMetric, "thread", false)
+	if value := testutil.ToFloat64(failuresMetric.WithLabelValues("thread")) - before; value != 1 {
+		t.Errorf("expected one failed thread, got %f", value)
+	}
+	if labels := failuresMetric.WithLabelValues("thread").Desc().String(); !strings.Contains(labels, "kind") {
+		t.Errorf("failures should be labeled by kind: %s", labels)
+	}
+}
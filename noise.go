@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// noiseFilter recognizes notes of bots (Danger, coverage reports, ...) by author username or body, they are skipped
+// or collapsed into a single summary thread so that migrated pull requests stay readable
+type noiseFilter struct {
+	authors  map[string]bool
+	patterns []*regexp.Regexp
+	collapse bool
+}
+
+// newNoiseFilter compiles noise configuration of the project, nil is returned when nothing is configured
+func newNoiseFilter(project project) (*noiseFilter, error) {
+	if len(project.NoiseAuthors) == 0 && len(project.NoisePatterns) == 0 {
+		return nil, nil
+	}
+	filter := &noiseFilter{authors: map[string]bool{}, collapse: project.CollapseNoise}
+	for _, author := range project.NoiseAuthors {
+		filter.authors[strings.ToLower(strings.TrimPrefix(author, "@"))] = true
+	}
+	for _, pattern := range project.NoisePatterns {
+		matcher, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid noisePatterns %s: %s", pattern, err)
+		}
+		filter.patterns = append(filter.patterns, matcher)
+	}
+	return filter, nil
+}
+
+func (f *noiseFilter) matches(note *Note) bool {
+	if f.authors[strings.ToLower(note.Author.Username)] {
+		return true
+	}
+	for _, pattern := range f.patterns {
+		if pattern.MatchString(note.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDiscussion returns copy of the discussion without noise notes (nil when nothing is left) and the noise notes
+func (f *noiseFilter) filterDiscussion(discussion *Discussion) (*Discussion, []*Note) {
+	if f == nil {
+		return discussion, nil
+	}
+	kept := *discussion
+	kept.Notes = nil
+	var noise []*Note
+	for _, note := range discussion.Notes {
+		if note.System || !f.matches(note) {
+			kept.Notes = append(kept.Notes, note)
+			continue
+		}
+		noise = append(noise, note)
+	}
+	if len(kept.Notes) == 0 {
+		return nil, noise
+	}
+	return &kept, noise
+}
+
+// prepareNoiseSummary lists collapsed notes with links to the originals
+func prepareNoiseSummary(mr *MergeRequest, notes []*Note) string {
+	lines := []string{fmt.Sprintf("*Migrated from [Gitlab](%s) | Collapsed comments of bots: %d*", mr.WebURL, len(notes)), ""}
+	for _, note := range notes {
+		summary := strings.TrimSpace(strings.SplitN(strings.TrimSpace(note.Body), "\n", 2)[0])
+		//runes and not bytes are cut so that multibyte characters stay whole
+		if runes := []rune(summary); len(runes) > 100 {
+			summary = string(runes[:100]) + "…"
+		}
+		author := prepareUserLink(note.Author.Username, note.Author.Name, note.Author.WebURL)
+		if note.CreatedAt != nil {
+			author += " on " + note.CreatedAt.Format("2006-01-02")
+		}
+		lines = append(lines, fmt.Sprintf("- %s: [%s](%s)", author, summary, prepareNoteLink(note, mr)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseNoise creates closed thread summarizing skipped notes when collapseNoise is set, all of them are mapped to
+// its comment
+func collapseNoise(azdoCtx context.Context, f *noiseFilter, azdoClient TargetClient, mr *MergeRequest, pullRequest *git.GitPullRequest, notes []*Note) []noteMapping {
+	if f == nil || !f.collapse || len(notes) == 0 {
+		return nil
+	}
+	content := prepareNoiseSummary(mr, notes)
+	thread, err := azdoClient.CreateThread(azdoCtx, git.CreateThreadArgs{
+		CommentThread: &git.GitPullRequestCommentThread{
+			PublishedDate: prepareAzdoTime(notes[0].CreatedAt),
+			Comments: &[]git.Comment{{
+				Content:         &content,
+				CommentType:     &git.CommentTypeValues.Text,
+				ParentCommentId: gitlab.Int(0),
+			}},
+			Status: &git.CommentThreadStatusValues.Closed,
+		},
+		RepositoryId:  pullRequest.Repository.Name,
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       pullRequest.Repository.Project.Name,
+	})
+	if err != nil {
+		log.Errorf("cannot create summary of %d collapsed comments of merge request %d: %s", len(notes), mr.IID, err)
+		recordResult(threadsMetric, "thread", false)
+		return nil
+	}
+	recordResult(threadsMetric, "thread", true)
+	audit.record("thread.create", *pullRequest.Repository.Project.Name, strconv.Itoa(*thread.Id), map[string]interface{}{
+		"pullRequestId": *pullRequest.PullRequestId,
+		"collapsed":     len(notes),
+	})
+	var mappings []noteMapping
+	for _, note := range notes {
+		mappings = append(mappings, noteMapping{NoteID: note.ID, ThreadID: *thread.Id, CommentID: 1})
+	}
+	return mappings
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"strings"
+	"testing"
+)
+
+func TestFilterDiscussion(t *testing.T) {
+	filter, err := newNoiseFilter(project{NoiseAuthors: []string{"@Danger-Bot"}, NoisePatterns: []string{`^Coverage: \d+%`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	danger, coverage, system := Note{ID: 1, Body: "Warnings"}, Note{ID: 2, Body: "Coverage: 85%"}, Note{ID: 3, System: true, Body: "Coverage: 85%"}
+	danger.Author.Username = "danger-bot"
+	review, reply := Note{ID: 4, Body: "Please rename"}, Note{ID: 5, Body: "Done"}
+
+	discussions := []struct {
+		label string
+		notes []*Note
+		kept  []*Note
+		noise []*Note
+	}{
+		{"noise only", []*Note{&danger, &coverage}, nil, []*Note{&danger, &coverage}},
+		{"reply to noise", []*Note{&danger, &reply}, []*Note{&reply}, []*Note{&danger}},
+		{"review", []*Note{&review, &reply}, []*Note{&review, &reply}, nil},
+		{"system", []*Note{&system}, []*Note{&system}, nil},
+	}
+	for _, discussion := range discussions {
+		kept, noise := filter.filterDiscussion(&Discussion{ID: "d", Notes: discussion.notes})
+		var keptNotes []*Note
+		if kept != nil {
+			keptNotes = kept.Notes
+		}
+		if diff := deep.Equal([][]*Note{keptNotes, noise}, [][]*Note{discussion.kept, discussion.noise}); diff != nil {
+			t.Errorf("%s: %+v", discussion.label, diff)
+		}
+	}
+}
+
+func TestNewNoiseFilter(t *testing.T) {
+	if filter, err := newNoiseFilter(project{}); filter != nil || err != nil {
+		t.Errorf("no noise configured: %v %v", filter, err)
+	}
+	if _, err := newNoiseFilter(project{NoisePatterns: []string{"("}}); err == nil {
+		t.Error("invalid pattern was accepted")
+	}
+}
+
+func TestPrepareNoiseSummary(t *testing.T) {
+	_, createdAt := setupDates()
+	mr := setupOpenMergeRequest()
+	note := Note{ID: 7, Body: "\n## Coverage report\n\nTotal: 85%", CreatedAt: &createdAt, Author: setupAuthor()}
+	expected := "*Migrated from [Gitlab](https://gitlab.com/gitlab-examples/php/-/merge_requests/1) | Collapsed comments of bots: 1*\n\n" +
+		"- [John Doe](https://gitlab.com/john-doe) on 2019-11-04: [## Coverage report](https://gitlab.com/gitlab-examples/php/-/merge_requests/1/diffs#note_7)"
+	if diff := deep.Equal(prepareNoiseSummary(&mr, []*Note{&note}), expected); diff != nil {
+		t.Error(diff)
+	}
+	undated := Note{ID: 8, Body: strings.Repeat("ž", 120), Author: setupAuthor()}
+	expected = "*Migrated from [Gitlab](https://gitlab.com/gitlab-examples/php/-/merge_requests/1) | Collapsed comments of bots: 1*\n\n" +
+		"- [John Doe](https://gitlab.com/john-doe): [" + strings.Repeat("ž", 100) + "…](https://gitlab.com/gitlab-examples/php/-/merge_requests/1/diffs#note_8)"
+	if diff := deep.Equal(prepareNoiseSummary(&mr, []*Note{&undated}), expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sort"
+)
+
+// mergeRequestBatch is the number of merge requests whose discussions are prefetched together
+const mergeRequestBatch = 100
+
+func sortMergeRequests(mergeRequests []*MergeRequest) {
+	sort.SliceStable(mergeRequests, func(i, j int) bool {
+		return mergeRequests[i].IID < mergeRequests[j].IID
+	})
+}
+
+// sortDiscussions orders discussions by their first note and notes of every discussion by ID, pages of discussions
+// and GraphQL nodes come in no guaranteed order
+func sortDiscussions(discussions []*Discussion) {
+	for _, discussion := range discussions {
+		notes := discussion.Notes
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].ID < notes[j].ID
+		})
+	}
+	sort.SliceStable(discussions, func(i, j int) bool {
+		return firstNoteID(discussions[i]) < firstNoteID(discussions[j])
+	})
+}
+
+func firstNoteID(discussion *Discussion) int {
+	if len(discussion.Notes) == 0 {
+		return 0
+	}
+	return discussion.Notes[0].ID
+}
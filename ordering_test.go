@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestSortDiscussions(t *testing.T) {
+	discussions := []*Discussion{
+		{ID: "c", Notes: []*Note{{ID: 30}, {ID: 12}}},
+		{ID: "empty"},
+		{ID: "a", Notes: []*Note{{ID: 10}, {ID: 31}}},
+		{ID: "b", Notes: []*Note{{ID: 11}}},
+	}
+	sortDiscussions(discussions)
+	var order []string
+	var notes [][]int
+	for _, discussion := range discussions {
+		order = append(order, discussion.ID)
+		var ids []int
+		for _, note := range discussion.Notes {
+			ids = append(ids, note.ID)
+		}
+		notes = append(notes, ids)
+	}
+	if diff := deep.Equal(order, []string{"empty", "a", "b", "c"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(notes, [][]int{nil, {10, 31}, {11}, {12, 30}}); diff != nil {
+		t.Error(diff)
+	}
+}
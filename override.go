@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var targetProjectOverride = kingpin.Flag("target-project-override", "Migrate every configured project into this AzDO project (e.g. Sandbox) instead of its azdoProject, a rehearsal runs with the config of the real migration").Default("").String()
+
+// overrideTargetProject redirects the project into --target-project-override. Service endpoints of configured
+// projects are scoped to them, --azdo-endpoint is used in the override project instead
+func overrideTargetProject(project *project) {
+	if *targetProjectOverride == "" || project.AzdoProject == *targetProjectOverride {
+		return
+	}
+	log.Debugf("project %s is migrated into %s instead of %s", project.sourceKey(), *targetProjectOverride, project.AzdoProject)
+	project.AzdoProject = *targetProjectOverride
+	project.ServiceEndpointID = ""
+}
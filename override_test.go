@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestOverrideTargetProject(t *testing.T) {
+	configured := project{GitlabProject: "group/app", AzdoProject: "Apps", ServiceEndpointID: "6f0c2f5e-4a52-4d1a-9a47-0a4b1c9f6f3e"}
+
+	kept := configured
+	overrideTargetProject(&kept)
+	if diff := deep.Equal(kept, configured); diff != nil {
+		t.Errorf("project should be kept without the override: %+v", diff)
+	}
+
+	*targetProjectOverride = "Sandbox"
+	defer func() { *targetProjectOverride = "" }()
+	redirected := configured
+	overrideTargetProject(&redirected)
+	if diff := deep.Equal([]string{redirected.AzdoProject, redirected.ServiceEndpointID}, []string{"Sandbox", ""}); diff != nil {
+		t.Errorf("project should be redirected without its service endpoint: %+v", diff)
+	}
+}
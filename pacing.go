@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// paceBelow is the share of the rate limit left at which requests start to be spread until the limit resets
+const paceBelow = 0.1
+
+// pacedTransport slows gitlab requests down once RateLimit headers show the limit is running out, so that the
+// migration does not hit 429 responses and waits for the retry
+type pacedTransport struct {
+	base  http.RoundTripper
+	mutex sync.Mutex
+	next  time.Time
+}
+
+func (t *pacedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	wait := time.Until(t.next)
+	t.mutex.Unlock()
+	if wait > 0 {
+		log.Debugf("gitlab rate limit is running out, pacing request for %s", wait)
+		select {
+		case <-time.After(wait):
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+	response, err := t.base.RoundTrip(request)
+	if response != nil {
+		now := time.Now()
+		delay := paceDelay(response.Header, now)
+		t.mutex.Lock()
+		t.next = now.Add(delay)
+		t.mutex.Unlock()
+	}
+	return response, err
+}
+
+// paceDelay spreads remaining requests evenly until the limit resets once less than paceBelow of the limit is left,
+// responses without RateLimit headers do not slow anything down
+func paceDelay(header http.Header, now time.Time) time.Duration {
+	remaining, err := strconv.Atoi(header.Get("RateLimit-Remaining"))
+	if err != nil {
+		return 0
+	}
+	reset, err := strconv.ParseInt(header.Get("RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	if limit, err := strconv.Atoi(header.Get("RateLimit-Limit")); err == nil && float64(remaining) > float64(limit)*paceBelow {
+		return 0
+	}
+	until := time.Unix(reset, 0).Sub(now)
+	if until <= 0 {
+		return 0
+	}
+	return until / time.Duration(remaining+1)
+}
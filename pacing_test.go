@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPaceDelay(t *testing.T) {
+	now := time.Unix(1000, 0)
+	header := func(limit, remaining, reset string) http.Header {
+		return http.Header{"Ratelimit-Limit": {limit}, "Ratelimit-Remaining": {remaining}, "Ratelimit-Reset": {reset}}
+	}
+	cases := []struct {
+		header http.Header
+		delay  time.Duration
+	}{
+		{http.Header{}, 0},
+		{header("600", "500", "1060"), 0},
+		{header("600", "59", "1060"), time.Second},
+		{header("600", "0", "1030"), 30 * time.Second},
+		{header("600", "5", "990"), 0},
+	}
+	for _, c := range cases {
+		if delay := paceDelay(c.header, now); delay != c.delay {
+			t.Errorf("expected delay %s for %v, got %s", c.delay, c.header, delay)
+		}
+	}
+}
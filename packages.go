@@ -0,0 +1,402 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	migratePackages = kingpin.Flag("migrate-packages", "Republish npm, Maven, NuGet and PyPI packages of gitlab package registry to Azure Artifacts feed (azdoFeed of the project)").Default("false").Bool()
+	// errPackageExists is returned by feeds refusing a version they have already, such package is migrated
+	errPackageExists = errors.New("package version exists in the feed")
+	// packagePublishers republish package files by gitlab package type
+	packagePublishers = map[string]func(*packageFeed, *gitlab.Package, []packageContent) error{
+		"npm":   (*packageFeed).publishNpm,
+		"maven": (*packageFeed).publishMaven,
+		"nuget": (*packageFeed).publishNuget,
+		"pypi":  (*packageFeed).publishPypi,
+	}
+)
+
+// packageFeed is Azure Artifacts feed, requests go through the AzDO transport
+type packageFeed struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// registryFile is package file as returned by gitlab, go-gitlab drops the SHA256 PyPI files are downloaded by
+type registryFile struct {
+	ID         int    `json:"id"`
+	FileName   string `json:"file_name"`
+	FileSHA256 string `json:"file_sha256"`
+}
+
+type packageContent struct {
+	name    string
+	content []byte
+}
+
+// migratePackageRegistry republishes every package version of the project, packages the feed has already are
+// skipped and those which cannot be transferred are reported
+func migratePackageRegistry(project project) {
+	if !*migratePackages || project.gitlab == nil {
+		return
+	}
+	if project.AzdoFeed == "" {
+		project.report.problem("packages are not migrated, azdoFeed is not configured")
+		return
+	}
+	feedURL, err := prepareFeedURL(*azdoOrganization, project.AzdoProject, project.AzdoFeed)
+	if err != nil {
+		project.report.problem("packages are not migrated: %s", err)
+		return
+	}
+	feed := &packageFeed{url: feedURL, token: *azdoToken, client: &http.Client{}}
+	packages, err := listPackages(project.gitlab.client, project.gitlabProject.ID)
+	if err != nil {
+		project.report.problem("cannot list packages: %s", err)
+		return
+	}
+	published := 0
+	for _, pkg := range packages {
+		publish, ok := packagePublishers[pkg.PackageType]
+		if !ok {
+			project.report.problem("%s package %s %s is not migrated, its type is not supported", pkg.PackageType, pkg.Name, pkg.Version)
+			continue
+		}
+		contents, err := downloadPackage(project.gitlab.client, project.gitlabProject.ID, pkg)
+		if err != nil {
+			project.report.problem("cannot download %s package %s %s: %s", pkg.PackageType, pkg.Name, pkg.Version, err)
+			continue
+		}
+		err = publish(feed, pkg, contents)
+		if errors.Is(err, errPackageExists) {
+			log.Debugf("%s package %s %s is in feed %s already", pkg.PackageType, pkg.Name, pkg.Version, project.AzdoFeed)
+			continue
+		}
+		if err != nil {
+			project.report.problem("cannot publish %s package %s %s: %s", pkg.PackageType, pkg.Name, pkg.Version, err)
+			continue
+		}
+		published++
+		audit.record("package.publish", project.AzdoProject, pkg.Name+"@"+pkg.Version, map[string]interface{}{
+			"feed":        project.AzdoFeed,
+			"packageType": pkg.PackageType,
+			"files":       len(contents),
+		})
+	}
+	log.Infof("%d of %d packages published to feed %s", published, len(packages), project.AzdoFeed)
+}
+
+// prepareFeedURL returns packaging URL of project scoped feed of dev.azure.com or visualstudio.com organization
+func prepareFeedURL(organization string, azdoProject string, feed string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(organization, "/"))
+	if err != nil {
+		return "", err
+	}
+	scope := "/" + url.PathEscape(azdoProject) + "/_packaging/" + url.PathEscape(feed)
+	switch {
+	case parsed.Host == "dev.azure.com":
+		return "https://pkgs.dev.azure.com" + parsed.Path + scope, nil
+	case strings.HasSuffix(parsed.Host, ".visualstudio.com"):
+		return "https://" + strings.TrimSuffix(parsed.Host, ".visualstudio.com") + ".pkgs.visualstudio.com" + scope, nil
+	}
+	return "", fmt.Errorf("feed URL of organization %s is not known, only Azure DevOps Services feeds are supported", organization)
+}
+
+// listPackages returns package versions from the oldest one so that the last published is the latest
+func listPackages(gitlabClient *gitlab.Client, projectID int) ([]*gitlab.Package, error) {
+	var packages []*gitlab.Package
+	options := gitlab.ListProjectPackagesOptions{
+		ListOptions: gitlab.ListOptions{Page: 1, PerPage: 100},
+		OrderBy:     gitlab.String("created_at"),
+		Sort:        gitlab.String("asc"),
+	}
+	for {
+		page, response, err := gitlabClient.Packages.ListProjectPackages(projectID, &options)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, page...)
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		return packages, nil
+	}
+}
+
+func downloadPackage(gitlabClient *gitlab.Client, projectID int, pkg *gitlab.Package) ([]packageContent, error) {
+	var files []registryFile
+	options := gitlab.ListOptions{Page: 1, PerPage: 100}
+	for {
+		request, err := gitlabClient.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/packages/%d/package_files", projectID, pkg.ID), &options, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page []registryFile
+		response, err := gitlabClient.Do(request, &page)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page...)
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		break
+	}
+	var contents []packageContent
+	for _, file := range files {
+		if isChecksumFile(file.FileName) {
+			continue
+		}
+		request, err := gitlabClient.NewRequest(http.MethodGet, preparePackageFilePath(projectID, pkg, file), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		content := &bytes.Buffer{}
+		if _, err := gitlabClient.Do(request, content); err != nil {
+			return nil, fmt.Errorf("file %s: %s", file.FileName, err)
+		}
+		contents = append(contents, packageContent{name: file.FileName, content: content.Bytes()})
+	}
+	return contents, nil
+}
+
+// isChecksumFile tells Maven checksums apart, feeds compute them on their own
+func isChecksumFile(name string) bool {
+	switch path.Ext(name) {
+	case ".md5", ".sha1", ".sha256", ".sha512":
+		return true
+	}
+	return false
+}
+
+// preparePackageFilePath is where gitlab serves the file to clients of the package type
+func preparePackageFilePath(projectID int, pkg *gitlab.Package, file registryFile) string {
+	switch pkg.PackageType {
+	case "npm":
+		return fmt.Sprintf("projects/%d/packages/npm/%s/-/%s", projectID, pkg.Name, file.FileName)
+	case "maven":
+		return fmt.Sprintf("projects/%d/packages/maven/%s/%s/%s", projectID, pkg.Name, pkg.Version, file.FileName)
+	case "nuget":
+		return fmt.Sprintf("projects/%d/packages/nuget/download/%s/%s/%s", projectID, strings.ToLower(pkg.Name), strings.ToLower(pkg.Version), file.FileName)
+	default:
+		return fmt.Sprintf("projects/%d/packages/pypi/files/%s/%s", projectID, file.FileSHA256, file.FileName)
+	}
+}
+
+func (f *packageFeed) send(method string, path string, body io.Reader, contentType string) error {
+	request, err := http.NewRequest(method, f.url+path, body)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth("migration", f.token)
+	request.Header.Set("Content-Type", contentType)
+	response, err := f.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusConflict {
+		return errPackageExists
+	}
+	if response.StatusCode >= 300 {
+		content, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("%s %s responded %s: %s", method, path, response.Status, strings.TrimSpace(string(content)))
+	}
+	return nil
+}
+
+// publishNpm publishes the tarball with manifest read from its package.json like npm publish does
+func (f *packageFeed) publishNpm(pkg *gitlab.Package, contents []packageContent) error {
+	for _, tarball := range contents {
+		if !strings.HasSuffix(tarball.name, ".tgz") {
+			continue
+		}
+		document, err := prepareNpmDocument(f.url, pkg, tarball.content)
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(document)
+		if err != nil {
+			return err
+		}
+		return f.send(http.MethodPut, "/npm/registry/"+strings.Replace(pkg.Name, "/", "%2f", 1), bytes.NewReader(body), "application/json")
+	}
+	return fmt.Errorf("package has no tarball")
+}
+
+func prepareNpmDocument(feedURL string, pkg *gitlab.Package, tarball []byte) (map[string]interface{}, error) {
+	manifest, err := readNpmManifest(tarball)
+	if err != nil {
+		return nil, err
+	}
+	sha1sum := sha1.Sum(tarball)
+	sha512sum := sha512.Sum512(tarball)
+	attachment := path.Base(pkg.Name) + "-" + pkg.Version + ".tgz"
+	manifest["_id"] = pkg.Name + "@" + pkg.Version
+	manifest["dist"] = map[string]interface{}{
+		"tarball":   feedURL + "/npm/registry/" + pkg.Name + "/-/" + attachment,
+		"shasum":    hex.EncodeToString(sha1sum[:]),
+		"integrity": "sha512-" + base64.StdEncoding.EncodeToString(sha512sum[:]),
+	}
+	return map[string]interface{}{
+		"_id":       pkg.Name,
+		"name":      pkg.Name,
+		"dist-tags": map[string]string{"latest": pkg.Version},
+		"versions":  map[string]interface{}{pkg.Version: manifest},
+		"_attachments": map[string]interface{}{attachment: map[string]interface{}{
+			"content_type": "application/octet-stream",
+			"data":         base64.StdEncoding.EncodeToString(tarball),
+			"length":       len(tarball),
+		}},
+	}, nil
+}
+
+// readNpmManifest returns package.json of npm tarball
+func readNpmManifest(tarball []byte) (map[string]interface{}, error) {
+	unzipped, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, err
+	}
+	archive := tar.NewReader(unzipped)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("tarball has no package.json")
+		}
+		if err != nil {
+			return nil, err
+		}
+		//npm packs into package/, some tools into the package name
+		if strings.Count(header.Name, "/") != 1 || path.Base(header.Name) != "package.json" {
+			continue
+		}
+		manifest := map[string]interface{}{}
+		if err := json.NewDecoder(archive).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("cannot parse package.json: %s", err)
+		}
+		return manifest, nil
+	}
+}
+
+// publishMaven uploads every file of the version to its Maven repository path, the POM comes last so that the
+// version is complete once it is visible
+func (f *packageFeed) publishMaven(pkg *gitlab.Package, contents []packageContent) error {
+	var poms []packageContent
+	for _, file := range contents {
+		if strings.HasSuffix(file.name, ".pom") {
+			poms = append(poms, file)
+			continue
+		}
+		if err := f.send(http.MethodPut, "/maven/v1/"+pkg.Name+"/"+pkg.Version+"/"+file.name, bytes.NewReader(file.content), "application/octet-stream"); err != nil {
+			return err
+		}
+	}
+	for _, file := range poms {
+		if err := f.send(http.MethodPut, "/maven/v1/"+pkg.Name+"/"+pkg.Version+"/"+file.name, bytes.NewReader(file.content), "application/octet-stream"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishNuget pushes the package like nuget push, symbol packages are not supported by Azure Artifacts feeds
+func (f *packageFeed) publishNuget(pkg *gitlab.Package, contents []packageContent) error {
+	for _, file := range contents {
+		if !strings.HasSuffix(file.name, ".nupkg") {
+			continue
+		}
+		body, contentType, err := prepareMultipart(nil, "package", file)
+		if err != nil {
+			return err
+		}
+		return f.send(http.MethodPut, "/nuget/v2/", body, contentType)
+	}
+	return fmt.Errorf("package has no .nupkg file")
+}
+
+// publishPypi uploads every distribution of the version like twine upload
+func (f *packageFeed) publishPypi(pkg *gitlab.Package, contents []packageContent) error {
+	for _, file := range contents {
+		body, contentType, err := prepareMultipart(preparePypiFields(pkg, file), "content", file)
+		if err != nil {
+			return err
+		}
+		if err := f.send(http.MethodPost, "/pypi/upload", body, contentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func preparePypiFields(pkg *gitlab.Package, file packageContent) [][2]string {
+	digest := sha256Hex(file.content)
+	filetype, pyversion := "sdist", "source"
+	if strings.HasSuffix(file.name, ".whl") {
+		//name-version(-build)?-python-abi-platform.whl
+		parts := strings.Split(strings.TrimSuffix(file.name, ".whl"), "-")
+		filetype = "bdist_wheel"
+		if len(parts) >= 3 {
+			pyversion = parts[len(parts)-3]
+		}
+	}
+	return [][2]string{
+		{":action", "file_upload"},
+		{"protocol_version", "1"},
+		{"metadata_version", "2.1"},
+		{"name", pkg.Name},
+		{"version", pkg.Version},
+		{"filetype", filetype},
+		{"pyversion", pyversion},
+		{"sha256_digest", digest},
+	}
+}
+
+func prepareMultipart(fields [][2]string, fileField string, file packageContent) (io.Reader, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+	part, err := writer.CreateFormFile(fileField, file.name)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(file.content); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
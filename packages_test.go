@@ -0,0 +1,125 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrepareFeedURL(t *testing.T) {
+	tests := []struct {
+		organization string
+		expected     string
+	}{
+		{"https://dev.azure.com/drmax/", "https://pkgs.dev.azure.com/drmax/Web%20Shop/_packaging/shop"},
+		{"https://drmax.visualstudio.com", "https://drmax.pkgs.visualstudio.com/Web%20Shop/_packaging/shop"},
+		{"https://tfs.example.com/DefaultCollection", ""},
+	}
+	for _, test := range tests {
+		actual, _ := prepareFeedURL(test.organization, "Web Shop", "shop")
+		if diff := deep.Equal(actual, test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.organization, diff)
+		}
+	}
+}
+
+func TestPreparePackageFilePath(t *testing.T) {
+	tests := []struct {
+		pkg      gitlab.Package
+		file     registryFile
+		expected string
+	}{
+		{gitlab.Package{Name: "@drmax/ui", Version: "1.2.0", PackageType: "npm"}, registryFile{FileName: "ui-1.2.0.tgz"}, "projects/7/packages/npm/@drmax/ui/-/ui-1.2.0.tgz"},
+		{gitlab.Package{Name: "com/drmax/core", Version: "2.0", PackageType: "maven"}, registryFile{FileName: "core-2.0.jar"}, "projects/7/packages/maven/com/drmax/core/2.0/core-2.0.jar"},
+		{gitlab.Package{Name: "Drmax.Core", Version: "1.0.0-RC", PackageType: "nuget"}, registryFile{FileName: "drmax.core.1.0.0-rc.nupkg"}, "projects/7/packages/nuget/download/drmax.core/1.0.0-rc/drmax.core.1.0.0-rc.nupkg"},
+		{gitlab.Package{Name: "drmax-core", Version: "1.0", PackageType: "pypi"}, registryFile{FileName: "drmax-core-1.0.tar.gz", FileSHA256: "abc"}, "projects/7/packages/pypi/files/abc/drmax-core-1.0.tar.gz"},
+	}
+	for _, test := range tests {
+		actual := preparePackageFilePath(7, &test.pkg, test.file)
+		if diff := deep.Equal(actual, test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.pkg.PackageType, diff)
+		}
+	}
+}
+
+func setupTarball(t *testing.T, name string, content string) []byte {
+	buffer := &bytes.Buffer{}
+	zipped := gzip.NewWriter(buffer)
+	archive := tar.NewWriter(zipped)
+	if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+	zipped.Close()
+	return buffer.Bytes()
+}
+
+func TestPrepareNpmDocument(t *testing.T) {
+	tarball := setupTarball(t, "package/package.json", `{"name": "@drmax/ui", "version": "1.2.0", "main": "index.js"}`)
+	pkg := &gitlab.Package{Name: "@drmax/ui", Version: "1.2.0"}
+	document, err := prepareNpmDocument("https://feed", pkg, tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := document["versions"].(map[string]interface{})["1.2.0"].(map[string]interface{})
+	actual := []interface{}{
+		document["name"],
+		version["_id"],
+		version["main"],
+		version["dist"].(map[string]interface{})["tarball"],
+		len(document["_attachments"].(map[string]interface{})["ui-1.2.0.tgz"].(map[string]interface{})["data"].(string)) > 0,
+	}
+	expected := []interface{}{"@drmax/ui", "@drmax/ui@1.2.0", "index.js", "https://feed/npm/registry/@drmax/ui/-/ui-1.2.0.tgz", true}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+	if _, err := prepareNpmDocument("https://feed", pkg, setupTarball(t, "package/README.md", "")); err == nil {
+		t.Error("tarball without package.json should fail")
+	}
+}
+
+func TestPreparePypiFields(t *testing.T) {
+	pkg := &gitlab.Package{Name: "drmax-core", Version: "1.0"}
+	var actual []string
+	for _, name := range []string{"drmax_core-1.0-py3-none-any.whl", "drmax-core-1.0.tar.gz"} {
+		for _, field := range preparePypiFields(pkg, packageContent{name: name}) {
+			if field[0] == "filetype" || field[0] == "pyversion" {
+				actual = append(actual, field[1])
+			}
+		}
+	}
+	expected := []string{"bdist_wheel", "py3", "sdist", "source"}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPublishMaven(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/maven/v1/com/drmax/core/2.0/core-2.0.pom" {
+			w.WriteHeader(http.StatusConflict)
+		}
+	}))
+	defer server.Close()
+	feed := &packageFeed{url: server.URL, token: "token", client: server.Client()}
+	pkg := &gitlab.Package{Name: "com/drmax/core", Version: "2.0"}
+	err := feed.publishMaven(pkg, []packageContent{{name: "core-2.0.pom"}, {name: "core-2.0.jar"}})
+	if !errors.Is(err, errPackageExists) {
+		t.Errorf("existing version should be reported, got %v", err)
+	}
+	expected := []string{"PUT /maven/v1/com/drmax/core/2.0/core-2.0.jar", "PUT /maven/v1/com/drmax/core/2.0/core-2.0.pom"}
+	if diff := deep.Equal(paths, expected); diff != nil {
+		t.Error(diff)
+	}
+}
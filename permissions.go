@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/graph"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/security"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// git repository permission bits of gitRepositoriesNamespace
+const (
+	gitRead               = 2
+	gitContribute         = 4
+	gitForcePush          = 8
+	gitCreateBranch       = 16
+	gitCreateTag          = 32
+	gitManageNotes        = 64
+	gitRenameRepository   = 1024
+	gitEditPolicies       = 2048
+	gitRemoveOthersLocks  = 4096
+	gitManagePermissions  = 8192
+	gitPullRequestContrib = 16384
+)
+
+var (
+	provisionPermissions = kingpin.Flag("provision-permissions", "Create readers, contributors and admins groups of every migrated repository from gitlab project members (requires --identity-map)").Default("false").Bool()
+	// graphGroupsLocation is the graph groups API, SDK cannot send VSTS group creation context with a display name
+	graphGroupsLocation = uuid.MustParse("ebbe6af8-0b91-4c13-8cf1-777c14858188")
+	repositoryRoles     = []repositoryRole{
+		{"Admins", gitlab.MaintainerPermissions, gitRead | gitContribute | gitForcePush | gitCreateBranch | gitCreateTag | gitManageNotes | gitRenameRepository | gitEditPolicies | gitRemoveOthersLocks | gitManagePermissions | gitPullRequestContrib},
+		{"Contributors", gitlab.DeveloperPermissions, gitRead | gitContribute | gitCreateBranch | gitCreateTag | gitManageNotes | gitPullRequestContrib},
+		{"Readers", gitlab.GuestPermissions, gitRead},
+	}
+)
+
+// repositoryRole is AzDO group of a repository gitlab members of the access level (and above) become members of
+type repositoryRole struct {
+	name        string
+	accessLevel gitlab.AccessLevelValue
+	permissions int
+}
+
+// roleOf returns the highest role the gitlab access level is entitled to, minimal access gets none
+func roleOf(accessLevel gitlab.AccessLevelValue) *repositoryRole {
+	for i := range repositoryRoles {
+		if accessLevel >= repositoryRoles[i].accessLevel {
+			return &repositoryRoles[i]
+		}
+	}
+	return nil
+}
+
+// provisionRepositoryPermissions mirrors gitlab project members into repository groups, members without AzDO
+// identity in the identity map are reported
+func provisionRepositoryPermissions(azdoCtx context.Context, connection *azuredevops.Connection, project project, repository *git.GitRepository) {
+	if !*provisionPermissions {
+		return
+	}
+	if project.gitlab == nil {
+		project.report.problem("permissions are provisioned only from members of gitlab projects")
+		return
+	}
+	members, err := listProjectMembers(project.gitlab.client, project.gitlabProject.ID, true)
+	if err != nil {
+		project.report.problem("cannot provision permissions: %s", err)
+		return
+	}
+	graphClient, err := graph.NewClient(azdoCtx, connection)
+	if err != nil {
+		project.report.problem("cannot provision permissions: %s", err)
+		return
+	}
+	scope, err := graphClient.GetDescriptor(azdoCtx, graph.GetDescriptorArgs{StorageKey: repository.Project.Id})
+	if err != nil {
+		project.report.problem("cannot provision permissions, project descriptor not found: %s", err)
+		return
+	}
+	groups := map[string]*graph.GraphGroup{}
+	for i := range repositoryRoles {
+		role := &repositoryRoles[i]
+		group, err := ensureRepositoryGroup(azdoCtx, connection, graphClient, project, repository, *scope.Value, role)
+		if err != nil {
+			project.report.problem("cannot provision %s group: %s", role.name, err)
+			continue
+		}
+		groups[role.name] = group
+	}
+
+	var unmapped []string
+	for _, member := range members {
+		role := roleOf(member.AccessLevel)
+		if role == nil || groups[role.name] == nil {
+			continue
+		}
+		id, ok := identities.resolve(&User{ID: member.ID, Username: member.Username})
+		if !ok {
+			unmapped = append(unmapped, member.Username)
+			continue
+		}
+		if err := addGroupMember(azdoCtx, graphClient, project, groups[role.name], id); err != nil {
+			project.report.problem("cannot add %s to %s group: %s", member.Username, role.name, err)
+		}
+	}
+	if len(unmapped) > 0 {
+		project.report.problem("members without AzDO identity did not get repository permissions: %s", strings.Join(unmapped, ", "))
+	}
+}
+
+// listProjectMembers returns direct members of the project, with inherited ones members of parent groups as well
+func listProjectMembers(gitlabClient *gitlab.Client, projectID int, inherited bool) ([]*gitlab.ProjectMember, error) {
+	var members []*gitlab.ProjectMember
+	options := gitlab.ListProjectMembersOptions{ListOptions: gitlab.ListOptions{Page: 1, PerPage: 100}}
+	list := gitlabClient.ProjectMembers.ListProjectMembers
+	if inherited {
+		list = gitlabClient.ProjectMembers.ListAllProjectMembers
+	}
+	for {
+		page, response, err := list(projectID, &options)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list gitlab project members: %s", err)
+		}
+		members = append(members, page...)
+		if response.NextPage > response.CurrentPage {
+			options.Page++
+			continue
+		}
+		return members, nil
+	}
+}
+
+// ensureRepositoryGroup finds or creates project group of the repository role and grants it the role permissions on
+// the repository only
+func ensureRepositoryGroup(azdoCtx context.Context, connection *azuredevops.Connection, graphClient graph.Client, project project, repository *git.GitRepository, scope string, role *repositoryRole) (*graph.GraphGroup, error) {
+	name := fmt.Sprintf("%s %s", *repository.Name, role.name)
+	group, err := findGroup(azdoCtx, graphClient, scope, name)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		group, err = createGroup(azdoCtx, connection, scope, name, fmt.Sprintf("%s of %s repository migrated from gitlab", role.name, *repository.Name))
+		if err != nil {
+			return nil, err
+		}
+		audit.record("group.create", project.AzdoProject, *group.Descriptor, map[string]interface{}{
+			"name": name,
+		})
+	}
+
+	descriptor, err := identityDescriptor(*group.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	token := fmt.Sprintf("repoV2/%s/%s", repository.Project.Id, repository.Id)
+	_, err = security.NewClient(azdoCtx, connection).SetAccessControlEntries(azdoCtx, security.SetAccessControlEntriesArgs{
+		Container: map[string]interface{}{
+			"token": token,
+			"merge": true,
+			"accessControlEntries": []security.AccessControlEntry{
+				{Descriptor: &descriptor, Allow: gitlab.Int(role.permissions), Deny: gitlab.Int(0)},
+			},
+		},
+		SecurityNamespaceId: &gitRepositoriesNamespace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot set permissions: %s", err)
+	}
+	audit.record("permission.set", project.AzdoProject, token, map[string]interface{}{
+		"group": name,
+		"allow": role.permissions,
+	})
+	return group, nil
+}
+
+func findGroup(azdoCtx context.Context, graphClient graph.Client, scope string, name string) (*graph.GraphGroup, error) {
+	args := graph.ListGroupsArgs{ScopeDescriptor: &scope}
+	for {
+		page, err := graphClient.ListGroups(azdoCtx, args)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list groups: %s", err)
+		}
+		if page.GraphGroups != nil {
+			for i, group := range *page.GraphGroups {
+				if group.DisplayName != nil && *group.DisplayName == name {
+					return &(*page.GraphGroups)[i], nil
+				}
+			}
+		}
+		if page.ContinuationToken == nil || len(*page.ContinuationToken) == 0 {
+			return nil, nil
+		}
+		args.ContinuationToken = &(*page.ContinuationToken)[0]
+	}
+}
+
+func createGroup(azdoCtx context.Context, connection *azuredevops.Connection, scope string, name string, description string) (*graph.GraphGroup, error) {
+	client, err := connection.GetClientByResourceAreaId(azdoCtx, graph.ResourceAreaId)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(graph.GraphGroupVstsCreationContext{DisplayName: &name, Description: &description})
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Send(azdoCtx, http.MethodPost, graphGroupsLocation, "5.1-preview.1", nil, url.Values{"scopeDescriptor": {scope}}, bytes.NewReader(body), "application/json", "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create group %s: %s", name, err)
+	}
+	var group graph.GraphGroup
+	if err := client.UnmarshalBody(response, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func addGroupMember(azdoCtx context.Context, graphClient graph.Client, project project, group *graph.GraphGroup, identityID string) error {
+	storageKey, err := uuid.Parse(identityID)
+	if err != nil {
+		return err
+	}
+	user, err := graphClient.GetDescriptor(azdoCtx, graph.GetDescriptorArgs{StorageKey: &storageKey})
+	if err != nil {
+		return err
+	}
+	_, err = graphClient.AddMembership(azdoCtx, graph.AddMembershipArgs{SubjectDescriptor: user.Value, ContainerDescriptor: group.Descriptor})
+	if err != nil {
+		return err
+	}
+	audit.record("membership.create", project.AzdoProject, *group.Descriptor, map[string]interface{}{
+		"member": *user.Value,
+	})
+	return nil
+}
+
+// identityDescriptor converts graph group descriptor (vssgp.<base64 SID>) to identity descriptor security API
+// expects in access control entries
+func identityDescriptor(subjectDescriptor string) (string, error) {
+	parts := strings.SplitN(subjectDescriptor, ".", 2)
+	if len(parts) != 2 || parts[0] != "vssgp" {
+		return "", fmt.Errorf("%s is not a group descriptor", subjectDescriptor)
+	}
+	sid, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("cannot decode group descriptor %s: %s", subjectDescriptor, err)
+	}
+	return "Microsoft.TeamFoundation.Identity;" + string(sid), nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestRoleOf(t *testing.T) {
+	levels := []struct {
+		level  gitlab.AccessLevelValue
+		expect string
+	}{
+		{gitlab.OwnerPermissions, "Admins"},
+		{gitlab.MaintainerPermissions, "Admins"},
+		{gitlab.DeveloperPermissions, "Contributors"},
+		{gitlab.ReporterPermissions, "Readers"},
+		{gitlab.GuestPermissions, "Readers"},
+		{gitlab.MinimalAccessPermissions, ""},
+	}
+	for _, level := range levels {
+		name := ""
+		if role := roleOf(level.level); role != nil {
+			name = role.name
+		}
+		if diff := deep.Equal(level.expect, name); diff != nil {
+			t.Errorf("access level %d: %v", level.level, diff)
+		}
+	}
+}
+
+func TestIdentityDescriptor(t *testing.T) {
+	descriptor, err := identityDescriptor("vssgp.Uy0xLTktMTU1MTM3NDI0NS0xMjA0NDAwOTY5LTI0MDI5ODY0MTMtMjE3OTQwODYxNi0zLTIwNjIzNjE3NjY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal("Microsoft.TeamFoundation.Identity;S-1-9-1551374245-1204400969-2402986413-2179408616-3-2062361766", descriptor); diff != nil {
+		t.Error(diff)
+	}
+	if _, err := identityDescriptor("aad.ZmFrZQ"); err == nil {
+		t.Error("user descriptor should not be converted")
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// phases of project migration --phases selects
+const (
+	phaseRepo     = "repo"
+	phaseMRs      = "mrs"
+	phaseComments = "comments"
+	phaseLabels   = "labels"
+	phasePolicies = "policies"
+)
+
+var (
+	allPhases = []string{phaseRepo, phaseMRs, phaseComments, phaseLabels, phasePolicies}
+	phases    = kingpin.Flag("phases", "Comma separated phases of the migration to run: repo (transfer, verification and permissions), mrs (pull requests), comments (threads), labels (pull request labels) and policies (approval rules). Without repo the repository has to exist, without mrs only pull requests migrated already get comments and labels again").Default(strings.Join(allPhases, ",")).String()
+	// selectedPhases are phases of the run, nil selects all of them
+	selectedPhases map[string]bool
+	// migratedNoteLink is the link to gitlab note in migrated comments, system notes and summaries of collapsed noise
+	migratedNoteLink = regexp.MustCompile(`#note_(\d+)\)`)
+	// migratedCommentHeader starts comments translated from gitlab notes, other migrated comments are not updated
+	migratedCommentHeader = regexp.MustCompile(`^\*Migrated from \[Gitlab\]\([^)\s]*#note_(\d+)\) \| Author: `)
+)
+
+// initPhases parses --phases, selecting all of them keeps the migration as it is without the flag
+func initPhases() error {
+	selected := map[string]bool{}
+	for _, phase := range strings.Split(*phases, ",") {
+		phase = strings.TrimSpace(phase)
+		if phase == "" {
+			continue
+		}
+		if !containsPhase(allPhases, phase) {
+			return fmt.Errorf("--phases %s is not one of %s", phase, strings.Join(allPhases, ", "))
+		}
+		selected[phase] = true
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("--phases selects no phase, use some of %s", strings.Join(allPhases, ", "))
+	}
+	if len(selected) < len(allPhases) {
+		selectedPhases = selected
+	}
+	return nil
+}
+
+func containsPhase(phases []string, phase string) bool {
+	for _, candidate := range phases {
+		if candidate == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func phaseSelected(phase string) bool {
+	return selectedPhases == nil || selectedPhases[phase]
+}
+
+// allPhasesSelected tells whether steps outside of the phases run, work items, boards and packages are migrated only
+// by full runs
+func allPhasesSelected() bool {
+	return selectedPhases == nil
+}
+
+// migratesPullRequests tells whether merge requests are read at all
+func migratesPullRequests() bool {
+	return phaseSelected(phaseMRs) || phaseSelected(phaseComments) || phaseSelected(phaseLabels)
+}
+
+// findMigratedRepository returns repository the skipped repo phase created earlier
+func findMigratedRepository(azdoCtx context.Context, project project, azdoClient git.Client) (*git.GitRepository, error) {
+	name := project.azdoRepositoryName()
+	repository, err := azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{
+		RepositoryId: &name,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("cannot find repository %s in %s: %w", name, project.AzdoProject, err)
+	}
+	if repository == nil || err != nil {
+		return nil, classified(failureNotFound, fmt.Errorf("repository %s does not exist in %s, run the %s phase first", name, project.AzdoProject, phaseRepo))
+	}
+	return repository, nil
+}
+
+// revisitPullRequest runs comments and labels phases on pull request migrated by an earlier run when the mrs phase is
+// not selected, threads and labels it has already are not created again
+func revisitPullRequest(azdoCtx context.Context, azdoClient TargetClient, source SourceClient, project project, mr *MergeRequest, pullRequest *git.GitPullRequest, discussions []*Discussion, mapping *mergeRequestMapping) {
+	if phaseSelected(phaseLabels) {
+		refreshLabels(azdoCtx, azdoClient, project, mr, pullRequest)
+	}
+	if phaseSelected(phaseComments) {
+		mapping.Notes = refreshComments(azdoCtx, azdoClient, source, project, mr, pullRequest, discussions)
+	}
+}
+
+// refreshLabels adds labels of the merge request the pull request is missing, labels added in AzDO are kept
+func refreshLabels(azdoCtx context.Context, azdoClient TargetClient, project project, mr *MergeRequest, pullRequest *git.GitPullRequest) {
+	existing, err := azdoClient.GetPullRequestLabels(azdoCtx, git.GetPullRequestLabelsArgs{
+		RepositoryId:  pullRequest.Repository.Name,
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       pullRequest.Repository.Project.Name,
+	})
+	if err != nil {
+		project.report.problem("labels of merge request %d are not migrated again, cannot read labels of pull request %d: %s", mr.IID, *pullRequest.PullRequestId, err)
+		return
+	}
+	for _, label := range *preparePullRequestLabels(project, mr) {
+		if hasLabel(*existing, *label.Name) {
+			continue
+		}
+		_, err := azdoClient.CreatePullRequestLabel(azdoCtx, git.CreatePullRequestLabelArgs{
+			Label:         &core.WebApiCreateTagRequestData{Name: label.Name},
+			RepositoryId:  pullRequest.Repository.Name,
+			PullRequestId: pullRequest.PullRequestId,
+			Project:       pullRequest.Repository.Project.Name,
+		})
+		if err != nil {
+			project.report.problem("cannot add label %s to pull request %d: %s", *label.Name, *pullRequest.PullRequestId, err)
+		}
+	}
+}
+
+func hasLabel(labels []core.WebApiTagDefinition, name string) bool {
+	for _, label := range labels {
+		if label.Name != nil && strings.EqualFold(*label.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// existingComment is a comment an earlier run translated from a gitlab note
+type existingComment struct {
+	thread  git.GitPullRequestCommentThread
+	comment git.Comment
+}
+
+// refreshComments translates notes of discussions migrated already again and updates comments which changed,
+// discussions without any migrated note are imported as new threads. Notes added to migrated discussions since and
+// comments too long for AzDO are left out
+func refreshComments(azdoCtx context.Context, azdoClient TargetClient, source SourceClient, project project, mr *MergeRequest, pullRequest *git.GitPullRequest, discussions []*Discussion) []noteMapping {
+	threads, err := azdoClient.GetThreads(azdoCtx, git.GetThreadsArgs{
+		RepositoryId:  pullRequest.Repository.Name,
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       pullRequest.Repository.Project.Name,
+	})
+	if err != nil {
+		project.report.problem("comments of merge request %d are not migrated again, cannot read threads of pull request %d: %s", mr.IID, *pullRequest.PullRequestId, err)
+		return nil
+	}
+	migratedNotes, comments := indexMigratedComments(*threads)
+	if discussions == nil {
+		if discussions, err = source.ListDiscussions(mr); err != nil {
+			project.report.problem("comments of merge request %d are not migrated again, cannot fetch its discussions: %s", mr.IID, err)
+			return nil
+		}
+		sortDiscussions(discussions)
+	}
+	paths := fetchFilePaths(source, mr)
+	fresh := []*Discussion{}
+	var mappings []noteMapping
+	for _, discussion := range discussions {
+		if !discussionMigrated(discussion, migratedNotes) {
+			fresh = append(fresh, discussion)
+			continue
+		}
+		//comments were numbered without notes of bots
+		discussion, _ = project.noise.filterDiscussion(discussion)
+		if discussion == nil {
+			continue
+		}
+		for _, translated := range retranslateDiscussion(mr, discussion, paths, comments) {
+			note := discussion.Notes[*translated.Id-1]
+			existing, ok := comments[note.ID]
+			if !ok {
+				continue
+			}
+			mappings = append(mappings, noteMapping{NoteID: note.ID, ThreadID: *existing.thread.Id, CommentID: *existing.comment.Id})
+			if *translated.Content == *existing.comment.Content || len(*translated.Content) > azdoCommentLimit {
+				continue
+			}
+			updateComment(azdoCtx, azdoClient, pullRequest, existing, *translated.Content)
+		}
+	}
+	return append(mappings, importComments(azdoCtx, project, mr, pullRequest, source, azdoClient, fresh)...)
+}
+
+// indexMigratedComments returns IDs of notes the threads refer to and comments translated from notes by note ID
+func indexMigratedComments(threads []git.GitPullRequestCommentThread) (map[int]bool, map[int]existingComment) {
+	migratedNotes := map[int]bool{}
+	comments := map[int]existingComment{}
+	for _, thread := range threads {
+		if thread.Comments == nil {
+			continue
+		}
+		for _, comment := range *thread.Comments {
+			if comment.Content == nil || (comment.IsDeleted != nil && *comment.IsDeleted) {
+				continue
+			}
+			for _, match := range migratedNoteLink.FindAllStringSubmatch(*comment.Content, -1) {
+				id, _ := strconv.Atoi(match[1])
+				migratedNotes[id] = true
+			}
+			if match := migratedCommentHeader.FindStringSubmatch(*comment.Content); match != nil {
+				id, _ := strconv.Atoi(match[1])
+				comments[id] = existingComment{thread: thread, comment: comment}
+			}
+		}
+	}
+	return migratedNotes, comments
+}
+
+func discussionMigrated(discussion *Discussion, migratedNotes map[int]bool) bool {
+	for _, note := range discussion.Notes {
+		if migratedNotes[note.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+// retranslateDiscussion translates the discussion placed as its existing thread, comments are numbered by notes
+func retranslateDiscussion(mr *MergeRequest, discussion *Discussion, paths *filePaths, comments map[int]existingComment) []git.Comment {
+	placement := placeGeneral
+	if existing, ok := comments[discussion.Notes[0].ID]; ok && existing.thread.ThreadContext != nil {
+		placement = placeFile
+		if existing.thread.ThreadContext.RightFileStart != nil {
+			placement = placeLine
+		}
+	}
+	threadInit, fullThread := translateDiscussion(mr, discussion, paths, placement)
+	if threadInit == nil {
+		return nil
+	}
+	translated := append([]git.Comment{}, *threadInit.Comments...)
+	if fullThread != nil {
+		translated = append(translated, *fullThread.Comments...)
+	}
+	return translated
+}
+
+func updateComment(azdoCtx context.Context, azdoClient TargetClient, pullRequest *git.GitPullRequest, existing existingComment, content string) {
+	_, err := azdoClient.UpdateComment(azdoCtx, git.UpdateCommentArgs{
+		Comment:       &git.Comment{Content: &content},
+		RepositoryId:  pullRequest.Repository.Name,
+		PullRequestId: pullRequest.PullRequestId,
+		ThreadId:      existing.thread.Id,
+		CommentId:     existing.comment.Id,
+		Project:       pullRequest.Repository.Project.Name,
+	})
+	if err != nil {
+		log.Errorf("cannot update comment %d of thread %d of pull request %d: %s", *existing.comment.Id, *existing.thread.Id, *pullRequest.PullRequestId, err)
+		return
+	}
+	audit.record("comment.update", *pullRequest.Repository.Project.Name, strconv.Itoa(*existing.comment.Id), map[string]interface{}{
+		"pullRequestId": *pullRequest.PullRequestId,
+		"threadId":      *existing.thread.Id,
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestInitPhases(t *testing.T) {
+	defer func() { *phases, selectedPhases = "", nil }()
+	tests := []struct {
+		phases   string
+		expected map[string]bool
+		fails    bool
+	}{
+		{"repo,mrs,comments,labels,policies", nil, false},
+		{"comments, labels", map[string]bool{phaseComments: true, phaseLabels: true}, false},
+		{"repo,issues", nil, true},
+		{",", nil, true},
+	}
+	for _, test := range tests {
+		*phases, selectedPhases = test.phases, nil
+		err := initPhases()
+		if (err != nil) != test.fails {
+			t.Errorf("%s: unexpected error %v", test.phases, err)
+		}
+		if diff := deep.Equal(selectedPhases, test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.phases, diff)
+		}
+	}
+}
+
+// threadsTarget is a pull request with threads migrated by an earlier run, updated comments are recorded by thread
+type threadsTarget struct {
+	*stubTarget
+	existing []git.GitPullRequestCommentThread
+	updated  map[int]string
+}
+
+func (c *threadsTarget) GetThreads(ctx context.Context, args git.GetThreadsArgs) (*[]git.GitPullRequestCommentThread, error) {
+	return &c.existing, nil
+}
+
+func (c *threadsTarget) UpdateComment(ctx context.Context, args git.UpdateCommentArgs) (*git.Comment, error) {
+	c.updated[*args.ThreadId] = *args.Comment.Content
+	return args.Comment, nil
+}
+
+func TestRefreshComments(t *testing.T) {
+	mr := setupOpenMergeRequest()
+	mr.IID = 1
+	discussion := func(id int, body string) *Discussion {
+		note := setupSingleNote()
+		note.ID, note.Body = id, body
+		return &Discussion{Notes: []*Note{&note}}
+	}
+	stale, current, fresh := discussion(11, "fixed conversion"), discussion(12, "unchanged"), discussion(13, "added since")
+	existingThread := func(id int, content string) git.GitPullRequestCommentThread {
+		return git.GitPullRequestCommentThread{Id: gitlab.Int(id), Comments: &[]git.Comment{{Id: gitlab.Int(1), Content: gitlab.String(content)}}}
+	}
+	currentThread, _ := translateDiscussion(&mr, current, nil, placeGeneral)
+	target := &threadsTarget{
+		stubTarget: &stubTarget{threads: map[int][]string{}},
+		existing: []git.GitPullRequestCommentThread{
+			existingThread(4, "*Migrated from [Gitlab]("+mr.WebURL+"/diffs#note_11) | Author: somebody*\n\nbroken conversion"),
+			existingThread(5, *(*currentThread.Comments)[0].Content),
+		},
+		updated: map[int]string{},
+	}
+	pullRequest := &git.GitPullRequest{
+		PullRequestId: gitlab.Int(7),
+		Repository:    &git.GitRepository{Name: gitlab.String("php"), Project: &core.TeamProjectReference{Name: gitlab.String("Apps")}},
+	}
+	project := project{AzdoProject: "Apps"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	source := NewMockSourceClient(ctrl)
+	source.EXPECT().ListChanges(&mr).Return(nil, nil).AnyTimes()
+
+	mappings := refreshComments(context.Background(), target, source, project, &mr, pullRequest, []*Discussion{stale, current, fresh})
+	expected := []noteMapping{{NoteID: 11, ThreadID: 4, CommentID: 1}, {NoteID: 12, ThreadID: 5, CommentID: 1}, {NoteID: 13, ThreadID: 1, CommentID: 1}}
+	if diff := deep.Equal(mappings, expected); diff != nil {
+		t.Error(diff)
+	}
+	staleThread, _ := translateDiscussion(&mr, stale, nil, placeGeneral)
+	if diff := deep.Equal(target.updated, map[int]string{4: *(*staleThread.Comments)[0].Content}); diff != nil {
+		t.Errorf("only the changed comment should be updated: %+v", diff)
+	}
+	if len(target.threads[7]) != 1 {
+		t.Errorf("only the discussion which was not migrated should be created, got %+v", target.threads[7])
+	}
+}
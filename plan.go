@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// importPollPeriod is how often the import request status is checked
+	importPollPeriod = 3 * time.Second
+	// projectGitlabCalls and projectAzdoCalls are requests done for every project regardless of its merge requests -
+	// project, branches, tags and statistics in gitlab, repository, import request and refs verification in AzDO
+	projectGitlabCalls = 4
+	projectAzdoCalls   = 5
+	// mergeRequestGitlabCalls and mergeRequestAzdoCalls are requests done for every migrated merge request besides
+	// its notes - approvals and changed files in gitlab, branch check, pull request, properties and votes in AzDO
+	mergeRequestGitlabCalls = 2
+	mergeRequestAzdoCalls   = 4
+)
+
+var (
+	planCommand              = kingpin.Command("plan", "Estimate size, API calls and duration of the migration of configured projects without migrating anything")
+	planThroughput           = planCommand.Flag("throughput", "Assumed speed repositories are transferred with (per second)").Default("10MB").Bytes()
+	planLatency              = planCommand.Flag("request-latency", "Assumed average duration of an API request").Default("300ms").Duration()
+	planOutput     io.Writer = os.Stdout
+)
+
+// projectEstimate is what the migration of a project is expected to take, calls are approximate as threads, retries
+// and pagination of discussions depend on the content
+type projectEstimate struct {
+	Path           string
+	RepositorySize int64
+	MergeRequests  int
+	Notes          int
+	GitlabCalls    int
+	AzdoCalls      int
+	Duration       time.Duration
+}
+
+// estimateProject counts API calls from merge requests which would be migrated, every user note becomes a thread or
+// a comment in AzDO
+func estimateProject(path string, size int64, migrateMRs bool, mergeRequests []*MergeRequest, listPages int) projectEstimate {
+	estimate := projectEstimate{
+		Path:           path,
+		RepositorySize: size,
+		GitlabCalls:    projectGitlabCalls + listPages,
+		AzdoCalls:      projectAzdoCalls,
+	}
+	transfer := time.Duration(0)
+	if *planThroughput > 0 {
+		transfer = time.Duration(float64(size) / float64(*planThroughput) * float64(time.Second))
+	}
+	estimate.AzdoCalls += int(transfer/importPollPeriod) + 1
+	if migrateMRs {
+		for _, mr := range mergeRequests {
+			if !isMigrated(mr) {
+				continue
+			}
+			estimate.MergeRequests++
+			estimate.Notes += mr.UserNotesCount
+			estimate.GitlabCalls += mergeRequestGitlabCalls + mr.UserNotesCount/100 + 1
+			estimate.AzdoCalls += mergeRequestAzdoCalls + mr.UserNotesCount
+		}
+	}
+	estimate.Duration = transfer + estimateRequests(estimate.GitlabCalls+estimate.AzdoCalls)
+	return estimate
+}
+
+// estimateRequests is time spent by sequential requests, --max-requests-per-second slows them down further
+func estimateRequests(calls int) time.Duration {
+	perCall := *planLatency
+	if *maxRequestsPerSecond > 0 {
+		if limited := time.Duration(float64(time.Second) / *maxRequestsPerSecond); limited > perCall {
+			perCall = limited
+		}
+	}
+	return time.Duration(calls) * perCall
+}
+
+func planMigration(config config) {
+	var estimates []projectEstimate
+	for _, project := range config.Projects {
+		if project.gitlabProject == nil {
+			continue
+		}
+		estimate, err := fetchEstimate(project)
+		if err != nil {
+			log.Errorf("cannot estimate %s: %s", project.gitlabProject.PathWithNamespace, err)
+			continue
+		}
+		estimates = append(estimates, estimate)
+	}
+	writePlan(planOutput, estimates)
+}
+
+func fetchEstimate(project project) (projectEstimate, error) {
+	var size int64
+	if project.gitlabProject.Statistics != nil {
+		size = project.gitlabProject.Statistics.RepositorySize
+	}
+	var mergeRequests []*MergeRequest
+	pages := 0
+	if project.MigrateMRs && project.Prefix == "" {
+		var err error
+		if mergeRequests, err = project.source.ListMergeRequests(); err != nil {
+			return projectEstimate{}, fmt.Errorf("cannot list merge requests: %s", err)
+		}
+		//sources list merge requests in pages of 100
+		pages = len(mergeRequests)/100 + 1
+	}
+	return estimateProject(project.gitlabProject.PathWithNamespace, size, project.MigrateMRs, mergeRequests, pages), nil
+}
+
+// writePlan prints estimates as a table, total duration assumes projects are migrated one after another
+func writePlan(output io.Writer, estimates []projectEstimate) {
+	table := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PROJECT\tSIZE\tMRS\tNOTES\tGITLAB CALLS\tAZDO CALLS\tDURATION")
+	total := projectEstimate{Path: "TOTAL"}
+	for _, estimate := range estimates {
+		writeEstimate(table, estimate)
+		total.RepositorySize += estimate.RepositorySize
+		total.MergeRequests += estimate.MergeRequests
+		total.Notes += estimate.Notes
+		total.GitlabCalls += estimate.GitlabCalls
+		total.AzdoCalls += estimate.AzdoCalls
+		total.Duration += estimate.Duration
+	}
+	writeEstimate(table, total)
+	table.Flush()
+}
+
+func writeEstimate(table io.Writer, estimate projectEstimate) {
+	fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", estimate.Path, formatSize(estimate.RepositorySize), estimate.MergeRequests, estimate.Notes, estimate.GitlabCalls, estimate.AzdoCalls, estimate.Duration.Round(time.Second))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"github.com/go-test/deep"
+	"testing"
+	"time"
+)
+
+func TestEstimateProject(t *testing.T) {
+	*planThroughput = 1024 * 1024
+	*planLatency = time.Second
+	defer func() { *planThroughput, *planLatency = 0, 0 }()
+	mergeRequests := []*MergeRequest{
+		{State: "opened", UserNotesCount: 150},
+		{State: "opened"},
+		{State: "merged", UserNotesCount: 20},
+	}
+
+	expect := projectEstimate{
+		Path:           "group/app",
+		RepositorySize: 30 * 1024 * 1024,
+		MergeRequests:  2,
+		Notes:          150,
+		//project, list page and approvals plus 2 and 1 discussion pages
+		GitlabCalls: projectGitlabCalls + 1 + 2*mergeRequestGitlabCalls + 3,
+		//11 import polls in 30 seconds of transfer
+		AzdoCalls: projectAzdoCalls + 11 + 2*mergeRequestAzdoCalls + 150,
+	}
+	expect.Duration = 30*time.Second + time.Duration(expect.GitlabCalls+expect.AzdoCalls)*time.Second
+	if diff := deep.Equal(estimateProject("group/app", 30*1024*1024, true, mergeRequests, 1), expect); diff != nil {
+		t.Error(diff)
+	}
+
+	withoutMRs := estimateProject("group/app", 0, false, mergeRequests, 0)
+	if diff := deep.Equal([]int{withoutMRs.MergeRequests, withoutMRs.GitlabCalls, withoutMRs.AzdoCalls}, []int{0, projectGitlabCalls, projectAzdoCalls + 1}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	output := &bytes.Buffer{}
+	writePlan(output, []projectEstimate{
+		{Path: "group/app", RepositorySize: 2048, MergeRequests: 1, Notes: 3, GitlabCalls: 10, AzdoCalls: 20, Duration: 90 * time.Second},
+		{Path: "group/lib", RepositorySize: 1024, GitlabCalls: 4, AzdoCalls: 6, Duration: 3 * time.Second},
+	})
+	expect := "PROJECT    SIZE   MRS  NOTES  GITLAB CALLS  AZDO CALLS  DURATION\n" +
+		"group/app  2.0KB  1    3      10            20          1m30s\n" +
+		"group/lib  1.0KB  0    0      4             6           3s\n" +
+		"TOTAL      3.0KB  1    3      14            26          1m33s\n"
+	if diff := deep.Equal(output.String(), expect); diff != nil {
+		t.Error(diff)
+	}
+}
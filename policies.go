@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/policy"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	approvalPolicies = kingpin.Flag("approval-policies", "Translate approval rules and code owners of gitlab projects to minimum reviewers and automatically included reviewers policies of the default branch (approvers are resolved by --identity-map)").Default("false").Bool()
+	// minimumReviewersPolicy and requiredReviewersPolicy are built-in AzDO policy types
+	minimumReviewersPolicy  = uuid.MustParse("fa4e907d-c16b-4a4c-9dfa-4906e5d171dd")
+	requiredReviewersPolicy = uuid.MustParse("fd2167ab-b0be-447a-8ec8-39368250530e")
+	// codeOwnersFiles are locations gitlab reads code owners from, the first one found is used
+	codeOwnersFiles = []string{"CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+)
+
+// codeOwners are owners of files matching the CODEOWNERS pattern
+type codeOwners struct {
+	pattern string
+	owners  []string
+}
+
+// migrateApprovalRules creates branch policies of the default branch, policies the migration created before are
+// updated so that a repeated run does not duplicate them
+func migrateApprovalRules(azdoCtx context.Context, azdoConnection *azuredevops.Connection, project project, repository *git.GitRepository) {
+	if !*approvalPolicies || project.gitlab == nil {
+		return
+	}
+	if project.Prefix != "" {
+		project.report.problem("approval rules of projects combined into shared repository are not migrated")
+		return
+	}
+	branch := project.gitlabProject.DefaultBranch
+	if branch == "" {
+		return
+	}
+	gitlabClient := project.gitlab.client
+	approvals, _, err := gitlabClient.Projects.GetApprovalConfiguration(project.gitlabProject.ID)
+	if err != nil {
+		project.report.problem("cannot fetch approval configuration, approval rules are not migrated: %s", err)
+		return
+	}
+	rules, _, err := gitlabClient.Projects.GetProjectApprovalRules(project.gitlabProject.ID)
+	if err != nil {
+		project.report.problem("cannot fetch approval rules, they are not migrated: %s", err)
+		return
+	}
+	var owners []codeOwners
+	protected, response, err := gitlabClient.ProtectedBranches.GetProtectedBranch(project.gitlabProject.ID, branch)
+	if err != nil && (response == nil || response.StatusCode != http.StatusNotFound) {
+		project.report.problem("cannot fetch protection of branch %s, code owners are not migrated: %s", branch, err)
+	} else if err == nil && protected.CodeOwnerApprovalRequired {
+		owners = fetchCodeOwners(project, branch)
+	}
+	configurations := prepareApprovalPolicies(project, approvals, rules, owners, repository.Id.String(), branch)
+	if len(configurations) == 0 {
+		return
+	}
+	policyClient, err := policy.NewClient(azdoCtx, azdoConnection)
+	if err != nil {
+		project.report.problem("approval rules are not migrated: %s", err)
+		return
+	}
+	existing, err := listPolicyConfigurations(azdoCtx, policyClient, project.AzdoProject)
+	if err != nil {
+		project.report.problem("cannot list branch policies, approval rules are not migrated: %s", err)
+		return
+	}
+	for i := range configurations {
+		configuration := &configurations[i]
+		id := findPolicyConfiguration(*configuration, existing)
+		if id == nil {
+			_, err = policyClient.CreatePolicyConfiguration(azdoCtx, policy.CreatePolicyConfigurationArgs{Configuration: configuration, Project: &project.AzdoProject})
+		} else {
+			_, err = policyClient.UpdatePolicyConfiguration(azdoCtx, policy.UpdatePolicyConfigurationArgs{Configuration: configuration, Project: &project.AzdoProject, ConfigurationId: id})
+		}
+		settings := configuration.Settings.(map[string]interface{})
+		if err != nil {
+			project.report.problem("cannot set branch policy %s: %s", policyMessage(settings), err)
+			continue
+		}
+		audit.record("policy.configure", project.AzdoProject, *repository.Name, map[string]interface{}{
+			"branch":    branch,
+			"policy":    policyMessage(settings),
+			"approvers": settings["minimumApproverCount"],
+			"updated":   id != nil,
+		})
+	}
+	log.Debugf("%d approval policies set on branch %s of %s", len(configurations), branch, *repository.Name)
+}
+
+func fetchCodeOwners(project project, branch string) []codeOwners {
+	for _, file := range codeOwnersFiles {
+		content, response, err := project.gitlab.client.RepositoryFiles.GetRawFile(project.gitlabProject.ID, file, &gitlab.GetRawFileOptions{Ref: &branch})
+		if err != nil && response != nil && response.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			project.report.problem("cannot fetch %s, code owners are not migrated: %s", file, err)
+			return nil
+		}
+		return parseCodeOwners(string(content))
+	}
+	return nil
+}
+
+// parseCodeOwners returns entries of CODEOWNERS, entries without owners get default owners of their section
+func parseCodeOwners(content string) []codeOwners {
+	var entries []codeOwners
+	var defaults []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		//sections are [Name], optional ^[Name] or [Name][approvals], followed by default owners
+		if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			defaults = strings.Fields(line[strings.LastIndex(line, "]")+1:])
+			continue
+		}
+		fields := strings.Fields(line)
+		owners := fields[1:]
+		if len(owners) == 0 {
+			owners = defaults
+		}
+		if len(owners) == 0 {
+			continue
+		}
+		entries = append(entries, codeOwners{pattern: fields[0], owners: owners})
+	}
+	return entries
+}
+
+// prepareApprovalPolicies translates rules applying to the branch. Approvals of anybody become minimum reviewers
+// policy, rules with eligible approvers and code owners become automatically included reviewers. Gitlab lets the
+// last matching CODEOWNERS entry win, every entry is a policy of its own in AzDO
+func prepareApprovalPolicies(project project, approvals *gitlab.ProjectApprovals, rules []*gitlab.ProjectApprovalRule, owners []codeOwners, repositoryID string, branch string) []policy.PolicyConfiguration {
+	scope := []map[string]interface{}{{"repositoryId": repositoryID, "refName": "refs/heads/" + branch, "matchKind": "exact"}}
+	var configurations []policy.PolicyConfiguration
+	add := func(policyType uuid.UUID, blocking bool, settings map[string]interface{}) {
+		settings["creatorVoteCounts"] = approvals.MergeRequestsAuthorApproval
+		settings["scope"] = scope
+		configurations = append(configurations, policy.PolicyConfiguration{
+			Type:       &policy.PolicyTypeRef{Id: &policyType},
+			IsEnabled:  gitlab.Bool(true),
+			IsBlocking: gitlab.Bool(blocking),
+			Settings:   settings,
+		})
+	}
+	minimum := approvals.ApprovalsBeforeMerge
+	for _, rule := range rules {
+		if !ruleApplies(rule, branch) {
+			continue
+		}
+		switch rule.RuleType {
+		case "any_approver":
+			if rule.ApprovalsRequired > minimum {
+				minimum = rule.ApprovalsRequired
+			}
+		case "regular":
+			reviewers := resolveApprovers(project, "approval rule "+rule.Name, rule.EligibleApprovers)
+			if len(reviewers) == 0 {
+				project.report.problem("approval rule %s has no approver in AzDO, it is not migrated", rule.Name)
+				continue
+			}
+			//optional rules only add reviewers
+			count := rule.ApprovalsRequired
+			if count == 0 {
+				count = 1
+			}
+			add(requiredReviewersPolicy, rule.ApprovalsRequired > 0, map[string]interface{}{
+				"requiredReviewerIds":  reviewers,
+				"minimumApproverCount": count,
+				"message":              "gitlab approval rule " + rule.Name,
+			})
+		case "code_owner":
+			//code owner rules are sections of CODEOWNERS, owners are migrated from the file
+		default:
+			project.report.problem("approval rule %s of type %s is not migrated", rule.Name, rule.RuleType)
+		}
+	}
+	if minimum > 0 {
+		//gitlab cannot reject, AzDO rejection should not block either
+		add(minimumReviewersPolicy, true, map[string]interface{}{
+			"minimumApproverCount": minimum,
+			"allowDownvotes":       true,
+			"resetOnSourcePush":    approvals.ResetApprovalsOnPush,
+		})
+	}
+	for _, entry := range owners {
+		var users []*gitlab.BasicUser
+		for _, owner := range entry.owners {
+			users = append(users, &gitlab.BasicUser{Username: strings.TrimPrefix(owner, "@")})
+		}
+		reviewers := resolveApprovers(project, "code owners of "+entry.pattern, users)
+		if len(reviewers) == 0 {
+			project.report.problem("code owners of %s have no AzDO identity, they are not migrated", entry.pattern)
+			continue
+		}
+		add(requiredReviewersPolicy, true, map[string]interface{}{
+			"requiredReviewerIds":  reviewers,
+			"minimumApproverCount": 1,
+			"filenamePatterns":     []string{translateOwnersPattern(entry.pattern)},
+			"message":              "gitlab code owners of " + entry.pattern,
+		})
+	}
+	return configurations
+}
+
+// ruleApplies tells whether the rule protects the branch, rules without protected branches apply to all of them
+func ruleApplies(rule *gitlab.ProjectApprovalRule, branch string) bool {
+	if len(rule.ProtectedBranches) == 0 {
+		return true
+	}
+	for _, protected := range rule.ProtectedBranches {
+		if matched, _ := path.Match(protected.Name, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveApprovers returns AzDO identities of the users, users without identity are reported
+func resolveApprovers(project project, rule string, users []*gitlab.BasicUser) []string {
+	var ids []string
+	for _, user := range users {
+		id, ok := identities.resolve(translateGitlabUser(user))
+		if !ok {
+			project.report.problem("%s: %s has no AzDO identity in the identity map", rule, user.Username)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// translateOwnersPattern converts CODEOWNERS pattern to AzDO path filter, unanchored patterns match in any directory
+// and directories match files below them
+func translateOwnersPattern(pattern string) string {
+	if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "*") {
+		pattern = "*/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "*"
+	}
+	return pattern
+}
+
+func listPolicyConfigurations(azdoCtx context.Context, policyClient policy.Client, azdoProject string) ([]policy.PolicyConfiguration, error) {
+	var configurations []policy.PolicyConfiguration
+	args := policy.GetPolicyConfigurationsArgs{Project: &azdoProject}
+	for {
+		page, err := policyClient.GetPolicyConfigurations(azdoCtx, args)
+		if err != nil {
+			return nil, err
+		}
+		configurations = append(configurations, page.Value...)
+		if page.ContinuationToken == "" {
+			return configurations, nil
+		}
+		args.ContinuationToken = &page.ContinuationToken
+	}
+}
+
+// findPolicyConfiguration returns ID of existing policy of the same type, scope and message
+func findPolicyConfiguration(configuration policy.PolicyConfiguration, existing []policy.PolicyConfiguration) *int {
+	settings := configuration.Settings.(map[string]interface{})
+	scope := settings["scope"].([]map[string]interface{})[0]
+	for _, candidate := range existing {
+		if candidate.Type == nil || candidate.Type.Id == nil || *candidate.Type.Id != *configuration.Type.Id || candidate.IsDeleted != nil && *candidate.IsDeleted {
+			continue
+		}
+		candidateSettings, ok := candidate.Settings.(map[string]interface{})
+		if !ok || policyMessage(candidateSettings) != policyMessage(settings) {
+			continue
+		}
+		candidateScopes, _ := candidateSettings["scope"].([]interface{})
+		for _, candidateScope := range candidateScopes {
+			candidateScope, ok := candidateScope.(map[string]interface{})
+			if ok && fmt.Sprint(candidateScope["repositoryId"]) == scope["repositoryId"] && fmt.Sprint(candidateScope["refName"]) == scope["refName"] {
+				return candidate.Id
+			}
+		}
+	}
+	return nil
+}
+
+// policyMessage names the policy, minimum reviewers policy has no message
+func policyMessage(settings map[string]interface{}) string {
+	if message, ok := settings["message"].(string); ok && message != "" {
+		return message
+	}
+	return "minimum reviewers"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/policy"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestParseCodeOwners(t *testing.T) {
+	content := `# owners
+*.go @alice
+/docs/ @bob @carol
+
+[Frontend][2] @dave
+web/
+^[Optional]
+README.md @erin
+`
+	expected := []codeOwners{
+		{pattern: "*.go", owners: []string{"@alice"}},
+		{pattern: "/docs/", owners: []string{"@bob", "@carol"}},
+		{pattern: "web/", owners: []string{"@dave"}},
+		{pattern: "README.md", owners: []string{"@erin"}},
+	}
+	//entries have unexported fields only which deep does not compare
+	if diff := deep.Equal(fmt.Sprint(parseCodeOwners(content)), fmt.Sprint(expected)); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTranslateOwnersPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"*.go", "*.go"},
+		{"/docs/", "/docs/*"},
+		{"web/", "*/web/*"},
+		{"/Makefile", "/Makefile"},
+	}
+	for _, test := range tests {
+		if diff := deep.Equal(translateOwnersPattern(test.pattern), test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.pattern, diff)
+		}
+	}
+}
+
+func TestPrepareApprovalPolicies(t *testing.T) {
+	identities = &identityMap{resolved: map[string]string{"alice": "alice-id", "bob": "bob-id", "carol": ""}}
+	defer func() { identities = &identityMap{} }()
+	approvals := &gitlab.ProjectApprovals{ApprovalsBeforeMerge: 1, ResetApprovalsOnPush: true}
+	rules := []*gitlab.ProjectApprovalRule{
+		{Name: "All Members", RuleType: "any_approver", ApprovalsRequired: 2},
+		{Name: "Backend", RuleType: "regular", ApprovalsRequired: 1, EligibleApprovers: []*gitlab.BasicUser{{Username: "alice"}, {Username: "carol"}}},
+		{Name: "Release", RuleType: "regular", ApprovalsRequired: 1, EligibleApprovers: []*gitlab.BasicUser{{Username: "bob"}},
+			ProtectedBranches: []*gitlab.ProtectedBranch{{Name: "release/*"}}},
+		{Name: "Security", RuleType: "report_approver", ApprovalsRequired: 1},
+	}
+	owners := []codeOwners{{pattern: "/docs/", owners: []string{"@bob"}}, {pattern: "*.go", owners: []string{"@carol"}}}
+	report := &projectReport{}
+	configurations := prepareApprovalPolicies(project{report: report}, approvals, rules, owners, "repo-id", "main")
+	var actual []interface{}
+	for _, configuration := range configurations {
+		settings := configuration.Settings.(map[string]interface{})
+		actual = append(actual, policyMessage(settings), settings["minimumApproverCount"], settings["requiredReviewerIds"], settings["filenamePatterns"])
+	}
+	expected := []interface{}{
+		"gitlab approval rule Backend", 1, []string{"alice-id"}, nil,
+		"minimum reviewers", 2, nil, nil,
+		"gitlab code owners of /docs/", 1, []string{"bob-id"}, []string{"/docs/*"},
+	}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(len(report.Problems), 4); diff != nil {
+		t.Errorf("problems %v: %+v", report.Problems, diff)
+	}
+}
+
+func TestFindPolicyConfiguration(t *testing.T) {
+	minimumID, otherID := 7, 8
+	existing := []policy.PolicyConfiguration{
+		{Id: &otherID, Type: &policy.PolicyTypeRef{Id: &minimumReviewersPolicy}, Settings: map[string]interface{}{
+			"scope": []interface{}{map[string]interface{}{"repositoryId": "other-repo", "refName": "refs/heads/main"}},
+		}},
+		{Id: &minimumID, Type: &policy.PolicyTypeRef{Id: &minimumReviewersPolicy}, Settings: map[string]interface{}{
+			"minimumApproverCount": float64(3),
+			"scope":                []interface{}{map[string]interface{}{"repositoryId": "repo-id", "refName": "refs/heads/main"}},
+		}},
+	}
+	configurations := prepareApprovalPolicies(project{}, &gitlab.ProjectApprovals{ApprovalsBeforeMerge: 1}, nil, nil, "repo-id", "main")
+	if diff := deep.Equal(findPolicyConfiguration(configurations[0], existing), &minimumID); diff != nil {
+		t.Error(diff)
+	}
+	configurations = prepareApprovalPolicies(project{}, &gitlab.ProjectApprovals{ApprovalsBeforeMerge: 1}, nil, nil, "repo-id", "develop")
+	if findPolicyConfiguration(configurations[0], existing) != nil {
+		t.Error("policy of another branch should not be found")
+	}
+}
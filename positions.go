@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"strings"
+)
+
+// headFiles are files at the source commit of the pull request threads are anchored to, every file is read once
+type headFiles struct {
+	azdoCtx      context.Context
+	client       TargetClient
+	pullRequest  *git.GitPullRequest
+	commitID     string
+	lines        map[string]int
+	unverifiable map[string]bool
+}
+
+// newHeadFiles returns files of the pull request head, nil (positions are trusted) when AzDO did not tell the commit
+func newHeadFiles(azdoCtx context.Context, azdoClient TargetClient, pullRequest *git.GitPullRequest) *headFiles {
+	if pullRequest.LastMergeSourceCommit == nil || pullRequest.LastMergeSourceCommit.CommitId == nil || pullRequest.Repository == nil {
+		return nil
+	}
+	return &headFiles{
+		azdoCtx:      azdoCtx,
+		client:       azdoClient,
+		pullRequest:  pullRequest,
+		commitID:     *pullRequest.LastMergeSourceCommit.CommitId,
+		lines:        map[string]int{},
+		unverifiable: map[string]bool{},
+	}
+}
+
+// placement returns how precisely the thread of the note can be anchored, threads on missing files are general and
+// threads beyond the end of the file are on the file, AzDO rejects them otherwise. Positions which cannot be verified
+// are left to AzDO
+func (h *headFiles) placement(note *Note, paths *filePaths) threadPlacement {
+	anchor := threadAnchor(note)
+	if h == nil || anchor == nil || anchor.start <= 0 {
+		return placeLine
+	}
+	path, ok := paths.resolve(note.Position)
+	if !ok {
+		return placeLine
+	}
+	lines, found := h.countLines(path)
+	switch {
+	case h.unverifiable[path]:
+		return placeLine
+	case !found:
+		log.Debugf("file %s is not in commit %s of pull request %d, thread is not anchored", path, h.commitID, *h.pullRequest.PullRequestId)
+		return placeGeneral
+	case anchor.end > lines:
+		log.Debugf("line %d is beyond the end of %s in pull request %d, thread is anchored to the file", anchor.end, path, *h.pullRequest.PullRequestId)
+		return placeFile
+	}
+	return placeLine
+}
+
+// countLines returns number of lines of the file at the head commit, files which cannot be read are unverifiable
+func (h *headFiles) countLines(path string) (int, bool) {
+	if lines, ok := h.lines[path]; ok {
+		return lines, lines >= 0
+	}
+	if h.unverifiable[path] {
+		return 0, false
+	}
+	repositoryID := h.pullRequest.Repository.Id.String()
+	item, err := h.client.GetItem(h.azdoCtx, git.GetItemArgs{
+		RepositoryId:      &repositoryID,
+		Path:              gitlab.String("/" + path),
+		Project:           h.pullRequest.Repository.Project.Name,
+		IncludeContent:    gitlab.Bool(true),
+		VersionDescriptor: &git.GitVersionDescriptor{Version: &h.commitID, VersionType: &git.GitVersionTypeValues.Commit},
+	})
+	switch {
+	case isNotFound(err):
+		h.lines[path] = -1
+		return 0, false
+	case err != nil || item.Content == nil:
+		log.Debugf("cannot read %s of pull request %d, its threads are not verified: %v", path, *h.pullRequest.PullRequestId, err)
+		h.unverifiable[path] = true
+		return 0, false
+	}
+	h.lines[path] = countContentLines(*item.Content)
+	return h.lines[path], true
+}
+
+func countContentLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// positionOutdated tells whether the note was written on a diff older than the last one of the merge request, lines
+// of the position may hold different code since and the thread is not anchored to them. Positions without commits
+// are trusted
+func positionOutdated(mr *MergeRequest, position *NotePosition) bool {
+	if position == nil || position.HeadSHA == "" || mr.DiffHeadSHA == "" {
+		return false
+	}
+	return position.HeadSHA != mr.DiffHeadSHA
+}
+
+// prepareOutdatedPosition links the commit the outdated note was written on so that the code it discusses can be found
+func prepareOutdatedPosition(mr *MergeRequest, position *NotePosition) string {
+	commit := position.HeadSHA
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	return fmt.Sprintf("🕰️ *Outdated - written on commit [`%s`](%s/-/commit/%s), the code changed since*", commit, prepareProjectURL(mr), position.HeadSHA)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// stubItems serves file contents of the head commit, reads are counted
+type stubItems struct {
+	git.Client
+	files map[string]string
+	reads int
+}
+
+func (c *stubItems) GetItem(ctx context.Context, args git.GetItemArgs) (*git.GitItem, error) {
+	c.reads++
+	if *args.Path == "/broken.go" {
+		return nil, fmt.Errorf("server error")
+	}
+	content, ok := c.files[*args.Path]
+	if !ok {
+		return nil, azuredevops.WrappedError{StatusCode: gitlab.Int(http.StatusNotFound)}
+	}
+	return &git.GitItem{Path: args.Path, Content: &content}, nil
+}
+
+func TestHeadFilesPlacement(t *testing.T) {
+	client := &stubItems{files: map[string]string{"/main.go": "package main\n\nfunc main() {\n}\n"}}
+	repositoryID := uuid.New()
+	pullRequest := &git.GitPullRequest{
+		PullRequestId:         gitlab.Int(1),
+		Repository:            &git.GitRepository{Id: &repositoryID, Project: &core.TeamProjectReference{Name: gitlab.String("Project")}},
+		LastMergeSourceCommit: &git.GitCommitRef{CommitId: gitlab.String("abc")},
+	}
+	head := newHeadFiles(context.Background(), client, pullRequest)
+	note := func(path string, line int) *Note {
+		return &Note{Position: &NotePosition{NewPath: path, NewLine: line}}
+	}
+	tests := []struct {
+		label    string
+		note     *Note
+		expected threadPlacement
+	}{
+		{"line", note("main.go", 3), placeLine},
+		{"last line", note("main.go", 4), placeLine},
+		{"beyond the end", note("main.go", 5), placeFile},
+		{"missing file", note("deleted.go", 1), placeGeneral},
+		{"unreadable file", note("broken.go", 1), placeLine},
+		{"removed line", &Note{Position: &NotePosition{OldPath: "main.go", OldLine: 3}}, placeLine},
+		{"general", &Note{}, placeLine},
+	}
+	for _, test := range tests {
+		if diff := deep.Equal(head.placement(test.note, nil), test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+	if diff := deep.Equal(client.reads, 3); diff != nil {
+		t.Errorf("files should be read once: %+v", diff)
+	}
+	var unknown *headFiles
+	if diff := deep.Equal(unknown.placement(note("main.go", 100), nil), placeLine); diff != nil {
+		t.Errorf("unknown head: %+v", diff)
+	}
+	if newHeadFiles(context.Background(), client, &git.GitPullRequest{}) != nil {
+		t.Error("head without commit should not be verified")
+	}
+}
+
+func TestCountContentLines(t *testing.T) {
+	for content, expected := range map[string]int{"": 0, "one": 1, "one\n": 1, "one\ntwo": 2, "one\ntwo\n\n": 3} {
+		if diff := deep.Equal(countContentLines(content), expected); diff != nil {
+			t.Errorf("%q: %+v", content, diff)
+		}
+	}
+}
+
+func TestTranslateOutdatedDiscussion(t *testing.T) {
+	mr := setupSimpleMergeRequest()
+	mr.DiffHeadSHA = "4f2a9c7e1b"
+	note := setupSingleNote()
+	note.Position = &NotePosition{HeadSHA: "0b1d3e5f7a", OldPath: "main.go", NewPath: "main.go", NewLine: 3}
+	outdated := "\n\n📄 *Originally on line 3 of `main.go`*\n🕰️ *Outdated - written on commit [`0b1d3e5f`](https://gitlab.com/gitlab-examples/php/-/commit/0b1d3e5f7a), the code changed since*"
+
+	thread, _ := translateDiscussion(&mr, &Discussion{Notes: []*Note{&note}}, nil, placeLine)
+	content := *(*thread.Comments)[0].Content
+	if diff := deep.Equal([]interface{}{thread.ThreadContext, strings.HasSuffix(content, outdated)}, []interface{}{&git.CommentThreadContext{FilePath: gitlab.String("/main.go")}, true}); diff != nil {
+		t.Errorf("outdated position should be anchored to the file with its commit: %+v\n%s", diff, content)
+	}
+
+	note.Position.HeadSHA = mr.DiffHeadSHA
+	thread, _ = translateDiscussion(&mr, &Discussion{Notes: []*Note{&note}}, nil, placeLine)
+	if thread.ThreadContext == nil || thread.ThreadContext.RightFileStart == nil {
+		t.Errorf("position on the last diff should be anchored to its line: %+v", thread.ThreadContext)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// post-migration actions done with gitlab project once it is migrated
+const (
+	postActionNone    = "none"
+	postActionArchive = "archive"
+	postActionLock    = "lock"
+)
+
+var accessLevelNames = map[gitlab.AccessLevelValue]string{
+	gitlab.MinimalAccessPermissions: "minimal access",
+	gitlab.GuestPermissions:         "guest",
+	gitlab.ReporterPermissions:      "reporter",
+	gitlab.DeveloperPermissions:     "developer",
+	gitlab.MaintainerPermissions:    "maintainer",
+	gitlab.OwnerPermissions:         "owner",
+}
+
+func validatePostAction(project project) error {
+	switch project.PostAction {
+	case "", postActionNone, postActionArchive, postActionLock:
+		return nil
+	}
+	return fmt.Errorf("postAction %s is not one of %s, %s, %s", project.PostAction, postActionLock, postActionArchive, postActionNone)
+}
+
+// runPostAction keeps people from working in gitlab project once it is migrated, failures are reported only as the
+// migration itself succeeded
+func runPostAction(project project) {
+	var err error
+	switch project.PostAction {
+	case postActionArchive:
+		err = archiveProject(project)
+	case postActionLock:
+		err = lockProject(project)
+	default:
+		return
+	}
+	if err != nil {
+		project.report.problem("cannot %s gitlab project: %s", project.PostAction, err)
+	}
+}
+
+func archiveProject(project project) error {
+	log.Infof("archiving gitlab project %s", project.gitlabProject.PathWithNamespace)
+	if _, _, err := project.gitlab.client.Projects.ArchiveProject(project.GitlabID); err != nil {
+		return err
+	}
+	audit.record("gitlabProject.archive", project.AzdoProject, strconv.Itoa(project.GitlabID), map[string]interface{}{
+		"gitlabPath": project.gitlabProject.PathWithNamespace,
+	})
+	return nil
+}
+
+// lockProject disables merge requests and demotes direct members who can push to reporters, the project stays
+// visible and searchable unlike an archived one. Original access levels are in the audit log.
+func lockProject(project project) error {
+	client := project.gitlab.client
+	log.Infof("locking gitlab project %s", project.gitlabProject.PathWithNamespace)
+	_, _, err := client.Projects.EditProject(project.GitlabID, &gitlab.EditProjectOptions{
+		MergeRequestsAccessLevel: gitlab.AccessControl(gitlab.DisabledAccessControl),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot disable merge requests: %s", err)
+	}
+	audit.record("gitlabProject.lock", project.AzdoProject, strconv.Itoa(project.GitlabID), map[string]interface{}{
+		"gitlabPath":    project.gitlabProject.PathWithNamespace,
+		"mergeRequests": gitlab.DisabledAccessControl,
+	})
+
+	//the token owner keeps its access, otherwise it could not undo the lock
+	owner, _, err := client.Users.CurrentUser()
+	if err != nil {
+		return err
+	}
+	direct, err := listProjectMembers(client, project.GitlabID, false)
+	if err != nil {
+		return err
+	}
+	handled := map[int]bool{owner.ID: true}
+	for _, member := range direct {
+		if handled[member.ID] || member.AccessLevel <= gitlab.ReporterPermissions || member.AccessLevel >= gitlab.OwnerPermissions {
+			continue
+		}
+		_, _, err := client.ProjectMembers.EditProjectMember(project.GitlabID, member.ID, &gitlab.EditProjectMemberOptions{
+			AccessLevel: gitlab.AccessLevel(gitlab.ReporterPermissions),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot demote %s: %s", member.Username, err)
+		}
+		handled[member.ID] = true
+		audit.record("gitlabMember.update", project.AzdoProject, strconv.Itoa(project.GitlabID), map[string]interface{}{
+			"username":            member.Username,
+			"accessLevel":         accessLevelNames[gitlab.ReporterPermissions],
+			"originalAccessLevel": accessLevelNames[member.AccessLevel],
+		})
+	}
+
+	all, err := listProjectMembers(client, project.GitlabID, true)
+	if err != nil {
+		return err
+	}
+	if unlocked := listUnlockedMembers(all, handled); len(unlocked) > 0 {
+		project.report.problem("gitlab project is locked but these members can still push (owners or inherited from groups): %s", strings.Join(unlocked, ", "))
+	}
+	return nil
+}
+
+// listUnlockedMembers returns members above reporter who were not handled (demoted or the token owner)
+func listUnlockedMembers(members []*gitlab.ProjectMember, handled map[int]bool) []string {
+	var unlocked []string
+	for _, member := range members {
+		if handled[member.ID] || member.AccessLevel <= gitlab.ReporterPermissions {
+			continue
+		}
+		unlocked = append(unlocked, fmt.Sprintf("%s (%s)", member.Username, accessLevelNames[member.AccessLevel]))
+	}
+	sort.Strings(unlocked)
+	return unlocked
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPostAction(t *testing.T) {
+	if err := validatePostAction(project{PostAction: "delete"}); err == nil {
+		t.Error("unknown post action should be refused")
+	}
+	members := []*gitlab.ProjectMember{
+		{ID: 1, Username: "token.owner", AccessLevel: gitlab.MaintainerPermissions},
+		{ID: 2, Username: "dev", AccessLevel: gitlab.DeveloperPermissions},
+		{ID: 3, Username: "reader", AccessLevel: gitlab.ReporterPermissions},
+		{ID: 4, Username: "group.maintainer", AccessLevel: gitlab.MaintainerPermissions},
+		{ID: 5, Username: "owner", AccessLevel: gitlab.OwnerPermissions},
+	}
+	expected := []string{"group.maintainer (maintainer)", "owner (owner)"}
+	if diff := deep.Equal(listUnlockedMembers(members, map[int]bool{1: true, 2: true}), expected); diff != nil {
+		t.Error(diff)
+	}
+}
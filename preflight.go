@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/security"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/serviceendpoint"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+)
+
+var (
+	// gitRepositoriesNamespace is the AzDO security namespace guarding git repositories
+	gitRepositoriesNamespace = uuid.MustParse("2e9eb7ed-3c0a-47d4-87c1-0ffdd275fd87")
+	gitPermissions           = []gitPermission{
+		{"Read", 2},
+		{"Contribute", 4},
+		{"Create repository", 256},
+		{"Contribute to pull requests", 16384},
+	}
+	gitDeletePermission = gitPermission{"Delete repository", 512}
+)
+
+type gitPermission struct {
+	name string
+	bit  int
+}
+
+// preflight verifies access to everything the migration touches so that it fails fast instead of in the middle of
+// the run, it returns number of failed checks
+func preflight(azdoCtx context.Context, connection *azuredevops.Connection, config config) int {
+	failures := 0
+	check := func(err error, format string, args ...interface{}) {
+		subject := fmt.Sprintf(format, args...)
+		if err != nil {
+			failures++
+			log.Errorf("✘ %s: %s", subject, err)
+			return
+		}
+		log.Infof("✔ %s", subject)
+	}
+
+	for _, project := range config.Projects {
+		if project.gitlabProject == nil {
+			continue
+		}
+		check(preflightGitlabProject(project), "gitlab project %s", project.gitlabProject.PathWithNamespace)
+		project.report = report.project(project.gitlabProject.PathWithNamespace, project.AzdoProject)
+		check(checkRepositoryLimits(project), "gitlab project %s fits AzDO limits", project.gitlabProject.PathWithNamespace)
+	}
+
+	coreClient, err := core.NewClient(azdoCtx, connection)
+	if err != nil {
+		log.Fatal(err)
+	}
+	securityClient := security.NewClient(azdoCtx, connection)
+	endpointClient, err := serviceendpoint.NewClient(azdoCtx, connection)
+	if err != nil {
+		log.Fatal(err)
+	}
+	checked := map[string]bool{}
+	accessible := map[string]bool{}
+	for _, project := range config.Projects {
+		if !checked[project.AzdoProject] {
+			checked[project.AzdoProject] = true
+			azdoProject, err := coreClient.GetProject(azdoCtx, core.GetProjectArgs{ProjectId: gitlab.String(project.AzdoProject)})
+			if err == nil && azdoProject == nil {
+				err = fmt.Errorf("project does not exist")
+			}
+			check(err, "AzDO project %s is accessible", project.AzdoProject)
+			if err != nil {
+				continue
+			}
+			accessible[project.AzdoProject] = true
+			check(preflightGitPermissions(azdoCtx, securityClient, azdoProject.Id.String()), "AzDO token permissions in project %s", project.AzdoProject)
+		}
+		//projects of one AzDO project may use different endpoints
+		endpoint := project.serviceEndpoint()
+		if !accessible[project.AzdoProject] || endpoint == "" || *createServiceEndpoint || checked[project.AzdoProject+"/"+endpoint] {
+			continue
+		}
+		checked[project.AzdoProject+"/"+endpoint] = true
+		check(preflightServiceEndpoint(azdoCtx, endpointClient, project.AzdoProject, endpoint), "service endpoint %s in project %s", endpoint, project.AzdoProject)
+	}
+	return failures
+}
+
+func preflightGitlabProject(project project) error {
+	//repositories of other sources were read by their token already
+	if !project.MigrateMRs || project.gitlab == nil {
+		return nil
+	}
+	_, _, err := project.gitlab.client.MergeRequests.ListProjectMergeRequests(project.GitlabID, &gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot list merge requests, are merge requests enabled and visible to the token owner? %s", err)
+	}
+	return nil
+}
+
+func preflightGitPermissions(azdoCtx context.Context, securityClient security.Client, projectID string) error {
+	permissions := gitPermissions
+	if *recreateRepository {
+		permissions = append(permissions, gitDeletePermission)
+	}
+	var missing []string
+	for _, permission := range permissions {
+		allowed, err := securityClient.HasPermissions(azdoCtx, security.HasPermissionsArgs{
+			SecurityNamespaceId: &gitRepositoriesNamespace,
+			Permissions:         gitlab.Int(permission.bit),
+			Tokens:              gitlab.String("repoV2/" + projectID),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot evaluate permissions, does the PAT have Code (Read, write, & manage) scope? %s", err)
+		}
+		if allowed == nil || len(*allowed) == 0 || !(*allowed)[0] {
+			missing = append(missing, permission.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("token owner is missing git permissions %v in the project", missing)
+	}
+	return nil
+}
+
+func preflightServiceEndpoint(azdoCtx context.Context, endpointClient serviceendpoint.Client, azdoProject string, endpoint string) error {
+	endpointID, err := uuid.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("endpoint is not a valid UUID, copy resourceId from the service connection URL: %s", err)
+	}
+	details, err := endpointClient.GetServiceEndpointDetails(azdoCtx, serviceendpoint.GetServiceEndpointDetailsArgs{
+		Project:    &azdoProject,
+		EndpointId: &endpointID,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read service endpoint, does the PAT have Service Connections (Read) scope? %s", err)
+	}
+	if details == nil {
+		return fmt.Errorf("service endpoint does not exist in the project, service connections are project scoped")
+	}
+	return nil
+}
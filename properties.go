@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// migratedLabel marks every migrated pull request so that they can be found in AzDO
+const migratedLabel = "migrated-from-gitlab"
+
+// pull request properties identifying the original merge request, downstream automation reads them
+const (
+	projectIDProperty       = "gitlab_project_id"
+	mergeRequestIIDProperty = "mr_iid"
+	mergeRequestURLProperty = "mr_url"
+	// legacyMergeRequestURLProperty is the merge request URL written by earlier runs
+	legacyMergeRequestURLProperty = "gitlab.mergeRequestUrl"
+)
+
+// pullRequestPropertiesLocation is the pull request properties API, SDK GetPullRequestProperties drops the response
+var pullRequestPropertiesLocation = uuid.MustParse("48a52185-5b9e-4736-9dc1-bb1e2feac80b")
+
+// pullRequestProperties is properties collection of a pull request, values are typed by $type next to them
+type pullRequestProperties struct {
+	Value map[string]struct {
+		Value interface{} `json:"$value"`
+	} `json:"value"`
+}
+
+func prepareMigrationLabels() *[]core.WebApiTagDefinition {
+	return &[]core.WebApiTagDefinition{{Name: gitlab.String(migratedLabel)}}
+}
+
+func prepareMigrationProperties(mr *MergeRequest) *[]webapi.JsonPatchOperation {
+	properties := []struct {
+		name  string
+		value interface{}
+	}{
+		{projectIDProperty, mr.ProjectID},
+		{mergeRequestIIDProperty, mr.IID},
+		{mergeRequestURLProperty, mr.WebURL},
+	}
+	var patch []webapi.JsonPatchOperation
+	for _, property := range properties {
+		patch = append(patch, webapi.JsonPatchOperation{
+			Op:    &webapi.OperationValues.Add,
+			Path:  gitlab.String("/" + property.name),
+			Value: property.value,
+		})
+	}
+	return &patch
+}
+
+// setMigrationProperties stores the original merge request in pull request properties, unlike the description
+// they cannot be edited by users and are safe to find the pull request by
+func setMigrationProperties(azdoCtx context.Context, azdoClient TargetClient, project project, pullRequest *git.GitPullRequest, mr *MergeRequest) {
+	_, err := azdoClient.UpdatePullRequestProperties(azdoCtx, git.UpdatePullRequestPropertiesArgs{
+		PatchDocument: prepareMigrationProperties(mr),
+		RepositoryId:  gitlab.String(pullRequest.Repository.Id.String()),
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       &project.AzdoProject,
+	})
+	if err != nil {
+		project.report.problem("cannot set properties of pull request %d: %s", *pullRequest.PullRequestId, err)
+		return
+	}
+	audit.record("pullRequest.properties", project.AzdoProject, strconv.Itoa(*pullRequest.PullRequestId), map[string]interface{}{
+		"mergeRequestIid": mr.IID,
+	})
+}
+
+// readMigrationProperties returns properties of the pull request, targets other than AzDO (simulation) have none
+func readMigrationProperties(azdoCtx context.Context, azdoClient TargetClient, repository *git.GitRepository, pullRequestID int) (map[string]interface{}, error) {
+	client, ok := azdoClient.(*git.ClientImpl)
+	if !ok {
+		return nil, nil
+	}
+	routeValues := map[string]string{
+		"project":       *repository.Project.Name,
+		"repositoryId":  repository.Id.String(),
+		"pullRequestId": strconv.Itoa(pullRequestID),
+	}
+	response, err := client.Client.Send(azdoCtx, http.MethodGet, pullRequestPropertiesLocation, "5.1-preview.1", routeValues, nil, nil, "", "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	collection := pullRequestProperties{}
+	if err := client.Client.UnmarshalBody(response, &collection); err != nil {
+		return nil, err
+	}
+	properties := map[string]interface{}{}
+	for name, property := range collection.Value {
+		properties[name] = property.Value
+	}
+	return properties, nil
+}
+
+// carriesMigrationMark tells whether the pull request has the label or description marker of migrated pull requests,
+// properties are read only for those as every read is a request
+func carriesMigrationMark(pullRequest git.GitPullRequest) bool {
+	if pullRequest.Labels != nil {
+		for _, label := range *pullRequest.Labels {
+			if label.Name != nil && strings.EqualFold(*label.Name, migratedLabel) {
+				return true
+			}
+		}
+	}
+	return pullRequest.Description != nil && migratedMarker.MatchString(*pullRequest.Description)
+}
+
+// migratedMergeRequestURL returns URL of the merge request the pull request was migrated from, the property is
+// preferred as the description can be edited and only pull requests of old runs have the marker alone
+func migratedMergeRequestURL(pullRequest git.GitPullRequest, properties map[string]interface{}) string {
+	for _, property := range []string{mergeRequestURLProperty, legacyMergeRequestURLProperty} {
+		if mergeRequestURL, ok := properties[property].(string); ok && mergeRequestURL != "" {
+			return mergeRequestURL
+		}
+	}
+	if pullRequest.Description == nil {
+		return ""
+	}
+	if match := migratedMarker.FindStringSubmatch(*pullRequest.Description); match != nil {
+		return match[1]
+	}
+	return ""
+}
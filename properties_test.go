@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestMigratedMergeRequestURL(t *testing.T) {
+	mr := setupOpenMergeRequest()
+	described := git.GitPullRequest{Description: gitlab.String(preparePullRequestDescription(&mr))}
+	edited := git.GitPullRequest{Description: gitlab.String("rewritten by the author")}
+	tests := []struct {
+		label       string
+		pullRequest git.GitPullRequest
+		properties  map[string]interface{}
+		expected    string
+	}{
+		{"property", edited, map[string]interface{}{mergeRequestURLProperty: "https://gitlab.com/group/php/-/merge_requests/7"}, "https://gitlab.com/group/php/-/merge_requests/7"},
+		{"property over description", described, map[string]interface{}{mergeRequestURLProperty: "https://gitlab.com/group/php/-/merge_requests/7"}, "https://gitlab.com/group/php/-/merge_requests/7"},
+		{"property of earlier run", edited, map[string]interface{}{legacyMergeRequestURLProperty: "https://gitlab.com/group/php/-/merge_requests/7"}, "https://gitlab.com/group/php/-/merge_requests/7"},
+		{"description of old run", described, map[string]interface{}{}, mr.WebURL},
+		{"description without properties", described, nil, mr.WebURL},
+		{"not migrated", edited, nil, ""},
+		{"no description", git.GitPullRequest{}, nil, ""},
+	}
+	for _, test := range tests {
+		if diff := deep.Equal(migratedMergeRequestURL(test.pullRequest, test.properties), test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+}
+
+func TestCarriesMigrationMark(t *testing.T) {
+	mr := setupOpenMergeRequest()
+	tests := []struct {
+		label       string
+		pullRequest git.GitPullRequest
+		expected    bool
+	}{
+		{"label", git.GitPullRequest{Labels: prepareMigrationLabels(), Description: gitlab.String("rewritten by the author")}, true},
+		{"description", git.GitPullRequest{Description: gitlab.String(preparePullRequestDescription(&mr))}, true},
+		{"other labels", git.GitPullRequest{Labels: &[]core.WebApiTagDefinition{{Name: gitlab.String("security")}}}, false},
+		{"created in AzDO", git.GitPullRequest{Description: gitlab.String("Adds login")}, false},
+	}
+	for _, test := range tests {
+		if actual := carriesMigrationMark(test.pullRequest); actual != test.expected {
+			t.Errorf("%s: expected %t", test.label, test.expected)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/graph"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/security"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strings"
+	"unicode/utf16"
+)
+
+var protectTags = kingpin.Flag("protect-tags", "Restrict who may create, move and delete tags matching gitlab protected tags of the project in tag security of the AzDO repository").Default("false").Bool()
+
+// tagPermission is access control entry of a group on a tag folder, unset permissions are removed from what the group
+// inherits without denying them
+type tagPermission struct {
+	group string
+	allow int
+	deny  int
+	unset int
+}
+
+// migrateProtectedTags sets tag security of protected tag patterns. Tag folders stop inheriting permissions of the
+// repository and get the inherited entries with Contributors losing what gitlab restricts, repository admins group of
+// --provision-permissions gets what maintainers may do. Creating tags is not set instead of denied for Contributors,
+// deny wins in AzDO and maintainers are members of Contributors as well
+func migrateProtectedTags(azdoCtx context.Context, connection *azuredevops.Connection, project project, repository *git.GitRepository) {
+	if !*protectTags || project.gitlab == nil {
+		return
+	}
+	tags, err := listProtectedTags(project)
+	if err != nil {
+		project.report.problem("cannot list protected tags, they are not migrated: %s", err)
+		return
+	}
+	if len(tags) == 0 {
+		return
+	}
+	graphClient, err := graph.NewClient(azdoCtx, connection)
+	if err != nil {
+		project.report.problem("protected tags are not migrated: %s", err)
+		return
+	}
+	scope, err := graphClient.GetDescriptor(azdoCtx, graph.GetDescriptorArgs{StorageKey: repository.Project.Id})
+	if err != nil {
+		project.report.problem("protected tags are not migrated, project descriptor not found: %s", err)
+		return
+	}
+	descriptors := map[string]string{}
+	for _, name := range []string{"Contributors", *repository.Name + " Contributors", *repository.Name + " Admins"} {
+		group, err := findGroup(azdoCtx, graphClient, *scope.Value, name)
+		if err != nil || group == nil {
+			log.Debugf("group %s is not found, tag security is not set for it: %v", name, err)
+			continue
+		}
+		if descriptors[name], err = identityDescriptor(*group.Descriptor); err != nil {
+			project.report.problem("tag security is not set for group %s: %s", name, err)
+			delete(descriptors, name)
+		}
+	}
+	securityClient := security.NewClient(azdoCtx, connection)
+	for _, tag := range tags {
+		pattern := tag.Name
+		if project.Prefix != "" {
+			pattern = project.Prefix + "/" + pattern
+		}
+		token, err := prepareTagToken(repository.Project.Id.String(), repository.Id.String(), pattern)
+		if err != nil {
+			project.report.problem("protected tag %s is not migrated: %s", tag.Name, err)
+			continue
+		}
+		inherited, err := readInheritedEntries(azdoCtx, securityClient, token)
+		if err != nil {
+			project.report.problem("protected tag %s is not migrated, cannot read inherited tag security: %s", tag.Name, err)
+			continue
+		}
+		entries := prepareTagEntries(inherited, descriptors, prepareTagPermissions(*repository.Name, lowestAccessLevel(tag)))
+		err = securityClient.SetAccessControlLists(azdoCtx, security.SetAccessControlListsArgs{
+			AccessControlLists: &azuredevops.VssJsonCollectionWrapper{
+				Count: gitlab.Int(1),
+				Value: &[]interface{}{security.AccessControlList{
+					Token:              &token,
+					InheritPermissions: gitlab.Bool(false),
+					AcesDictionary:     &entries,
+				}},
+			},
+			SecurityNamespaceId: &gitRepositoriesNamespace,
+		})
+		if err != nil {
+			project.report.problem("cannot set security of protected tag %s: %s", tag.Name, err)
+			continue
+		}
+		audit.record("permission.set", project.AzdoProject, token, map[string]interface{}{
+			"protectedTag": tag.Name,
+			"groups":       len(entries),
+		})
+	}
+}
+
+// listProtectedTags returns all pages of protected tags of the project
+func listProtectedTags(project project) ([]*gitlab.ProtectedTag, error) {
+	options := &gitlab.ListProtectedTagsOptions{Page: 1, PerPage: 100}
+	var tags []*gitlab.ProtectedTag
+	for {
+		page, response, err := project.gitlab.client.ProtectedTags.ListProtectedTags(project.gitlabProject.ID, options)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page...)
+		if response.NextPage == 0 {
+			return tags, nil
+		}
+		options.Page = response.NextPage
+	}
+}
+
+// readInheritedEntries returns entries the token gets from the tokens above it and has explicitly, by descriptor
+func readInheritedEntries(azdoCtx context.Context, securityClient security.Client, token string) (map[string]security.AccessControlEntry, error) {
+	var levels [][]security.AccessControlEntry
+	for _, ancestor := range ancestorTokens(token) {
+		lists, err := securityClient.QueryAccessControlLists(azdoCtx, security.QueryAccessControlListsArgs{
+			SecurityNamespaceId: &gitRepositoriesNamespace,
+			Token:               gitlab.String(ancestor),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var level []security.AccessControlEntry
+		for _, list := range *lists {
+			if list.AcesDictionary == nil {
+				continue
+			}
+			for _, entry := range *list.AcesDictionary {
+				level = append(level, entry)
+			}
+		}
+		levels = append(levels, level)
+	}
+	return mergeInheritedEntries(levels), nil
+}
+
+// ancestorTokens returns the token and tokens it inherits from starting by the top one, e.g. repoV2, repoV2/project,
+// repoV2/project/repo and ref folders below it which end by slash
+func ancestorTokens(token string) []string {
+	segments := strings.Split(strings.TrimSuffix(token, "/"), "/")
+	var tokens []string
+	for i := 1; i <= len(segments); i++ {
+		ancestor := strings.Join(segments[:i], "/")
+		//repoV2/project/repo/refs alone secures nothing
+		if i == 4 {
+			continue
+		}
+		if i > 4 {
+			ancestor += "/"
+		}
+		tokens = append(tokens, ancestor)
+	}
+	return tokens
+}
+
+// mergeInheritedEntries applies entries from the top token down, permissions set below override those set above
+func mergeInheritedEntries(levels [][]security.AccessControlEntry) map[string]security.AccessControlEntry {
+	merged := map[string]security.AccessControlEntry{}
+	for _, level := range levels {
+		for _, entry := range level {
+			if entry.Descriptor == nil {
+				continue
+			}
+			allow, deny := 0, 0
+			if existing, ok := merged[*entry.Descriptor]; ok {
+				allow, deny = *existing.Allow, *existing.Deny
+			}
+			if entry.Allow != nil {
+				allow, deny = allow|*entry.Allow, deny&^*entry.Allow
+			}
+			if entry.Deny != nil {
+				allow, deny = allow&^*entry.Deny, deny|*entry.Deny
+			}
+			merged[*entry.Descriptor] = security.AccessControlEntry{Descriptor: entry.Descriptor, Allow: gitlab.Int(allow), Deny: gitlab.Int(deny)}
+		}
+	}
+	return merged
+}
+
+// prepareTagEntries applies tag permissions of the groups found to the inherited entries
+func prepareTagEntries(inherited map[string]security.AccessControlEntry, descriptors map[string]string, permissions []tagPermission) map[string]security.AccessControlEntry {
+	entries := map[string]security.AccessControlEntry{}
+	for descriptor, entry := range inherited {
+		entries[descriptor] = entry
+	}
+	for _, permission := range permissions {
+		descriptor, ok := descriptors[permission.group]
+		if !ok {
+			continue
+		}
+		allow, deny := 0, 0
+		if entry, ok := entries[descriptor]; ok {
+			allow, deny = *entry.Allow, *entry.Deny
+		}
+		allow = allow&^(permission.unset|permission.deny) | permission.allow
+		deny = deny&^permission.allow | permission.deny
+		entries[descriptor] = security.AccessControlEntry{Descriptor: gitlab.String(descriptor), Allow: gitlab.Int(allow), Deny: gitlab.Int(deny)}
+	}
+	return entries
+}
+
+// lowestAccessLevel is the lowest access level allowed to create the tag, no one is allowed without any
+func lowestAccessLevel(tag *gitlab.ProtectedTag) gitlab.AccessLevelValue {
+	lowest := gitlab.NoPermissions
+	for _, level := range tag.CreateAccessLevels {
+		if lowest == gitlab.NoPermissions || level.AccessLevel != gitlab.NoPermissions && level.AccessLevel < lowest {
+			lowest = level.AccessLevel
+		}
+	}
+	return lowest
+}
+
+// prepareTagPermissions restricts groups below the access level, nobody may move or delete protected tags. Tags
+// nobody may create are denied to all groups
+func prepareTagPermissions(repositoryName string, allowed gitlab.AccessLevelValue) []tagPermission {
+	contributors := []string{"Contributors", repositoryName + " Contributors"}
+	admins := repositoryName + " Admins"
+	var permissions []tagPermission
+	switch {
+	case allowed == gitlab.NoPermissions:
+		for _, group := range append(contributors, admins) {
+			permissions = append(permissions, tagPermission{group: group, deny: gitCreateTag | gitForcePush})
+		}
+	case allowed >= gitlab.MaintainerPermissions:
+		for _, group := range contributors {
+			permissions = append(permissions, tagPermission{group: group, deny: gitForcePush, unset: gitCreateTag})
+		}
+		permissions = append(permissions, tagPermission{group: admins, allow: gitCreateTag})
+	default:
+		for _, group := range contributors {
+			permissions = append(permissions, tagPermission{group: group, deny: gitForcePush})
+		}
+	}
+	return permissions
+}
+
+// prepareTagToken returns security token of the tag or tag folder of wildcard pattern, every ref segment is
+// hex encoded UTF-16LE. AzDO secures folders, wildcards are supported only as the whole last segment
+func prepareTagToken(projectID string, repositoryID string, pattern string) (string, error) {
+	token := fmt.Sprintf("repoV2/%s/%s/refs/tags/", projectID, repositoryID)
+	segments := strings.Split(pattern, "/")
+	if segments[len(segments)-1] == "*" {
+		segments = segments[:len(segments)-1]
+	}
+	for _, segment := range segments {
+		if strings.ContainsAny(segment, "*?[") {
+			return "", fmt.Errorf("pattern %s cannot be expressed as AzDO tag folder", pattern)
+		}
+		var encoded []byte
+		for _, unit := range utf16.Encode([]rune(segment)) {
+			encoded = append(encoded, byte(unit), byte(unit>>8))
+		}
+		token += hex.EncodeToString(encoded) + "/"
+	}
+	return token, nil
+}
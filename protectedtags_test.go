@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/security"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPrepareTagToken(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"v1.0", "repoV2/project/repo/refs/tags/760031002e003000/"},
+		{"release/*", "repoV2/project/repo/refs/tags/720065006c006500610073006500/"},
+		{"*", "repoV2/project/repo/refs/tags/"},
+		{"v*", ""},
+	}
+	for _, test := range tests {
+		actual, _ := prepareTagToken("project", "repo", test.pattern)
+		if diff := deep.Equal(actual, test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.pattern, diff)
+		}
+	}
+}
+
+func TestAncestorTokens(t *testing.T) {
+	expected := []string{"repoV2", "repoV2/project", "repoV2/project/repo", "repoV2/project/repo/refs/tags/", "repoV2/project/repo/refs/tags/7600/"}
+	if diff := deep.Equal(ancestorTokens("repoV2/project/repo/refs/tags/7600/"), expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrepareTagEntries(t *testing.T) {
+	contributors, admins, readers := "contributors", "admins", "readers"
+	levels := [][]security.AccessControlEntry{
+		{{Descriptor: &contributors, Allow: gitlab.Int(gitRead | gitCreateTag), Deny: gitlab.Int(0)}, {Descriptor: &readers, Allow: gitlab.Int(gitRead), Deny: gitlab.Int(0)}},
+		{{Descriptor: &contributors, Allow: gitlab.Int(gitContribute), Deny: gitlab.Int(gitRead)}},
+	}
+	permissions := prepareTagPermissions("shop", gitlab.MaintainerPermissions)
+	entries := prepareTagEntries(mergeInheritedEntries(levels), map[string]string{"Contributors": contributors, "shop Admins": admins}, permissions)
+	actual := map[string][]int{}
+	for descriptor, entry := range entries {
+		actual[descriptor] = []int{*entry.Allow, *entry.Deny}
+	}
+	//creating tags is not set for contributors so that maintainers among them get it from admins
+	expected := map[string][]int{
+		contributors: {gitContribute, gitRead | gitForcePush},
+		admins:       {gitCreateTag, 0},
+		readers:      {gitRead, 0},
+	}
+	if diff := deep.Equal(actual, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrepareTagPermissions(t *testing.T) {
+	tests := []struct {
+		label    string
+		levels   []gitlab.AccessLevelValue
+		expected []tagPermission
+	}{
+		{"maintainers", []gitlab.AccessLevelValue{gitlab.MaintainerPermissions}, []tagPermission{
+			{group: "Contributors", deny: gitForcePush, unset: gitCreateTag},
+			{group: "shop Contributors", deny: gitForcePush, unset: gitCreateTag},
+			{group: "shop Admins", allow: gitCreateTag},
+		}},
+		{"developers", []gitlab.AccessLevelValue{gitlab.MaintainerPermissions, gitlab.DeveloperPermissions}, []tagPermission{
+			{group: "Contributors", deny: gitForcePush},
+			{group: "shop Contributors", deny: gitForcePush},
+		}},
+		{"no one", []gitlab.AccessLevelValue{gitlab.NoPermissions}, []tagPermission{
+			{group: "Contributors", deny: gitCreateTag | gitForcePush},
+			{group: "shop Contributors", deny: gitCreateTag | gitForcePush},
+			{group: "shop Admins", deny: gitCreateTag | gitForcePush},
+		}},
+	}
+	for _, test := range tests {
+		tag := &gitlab.ProtectedTag{Name: "v*"}
+		for _, level := range test.levels {
+			tag.CreateAccessLevels = append(tag.CreateAccessLevels, &gitlab.TagAccessDescription{AccessLevel: level})
+		}
+		actual := prepareTagPermissions("shop", lowestAccessLevel(tag))
+		//permissions have unexported fields only which deep does not compare
+		if diff := deep.Equal(fmt.Sprint(actual), fmt.Sprint(test.expected)); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	queuedStatus   = "queued"
+	runningStatus  = "running"
+	migratedStatus = "migrated"
+	failedStatus   = "failed"
+	// retryStatus jobs failed and their half-migrated repository was deleted by --cleanup-failed
+	retryStatus = "retry"
+)
+
+var (
+	enqueueCommand   = kingpin.Command("enqueue", "Push every configured project as a job into the queue workers take them from")
+	workerCommand    = kingpin.Command("worker", "Migrate projects taken from the queue, run as many workers as the APIs allow")
+	collectCommand   = kingpin.Command("collect", "Write report and mapping of projects migrated by workers (--report-file, --mapping-file)")
+	redisURL         = kingpin.Flag("redis-url", "Redis the job queue and results of enqueue, worker and collect commands are kept in (redis://host:6379/0)").Default("redis://localhost:6379/0").String()
+	queueName        = kingpin.Flag("queue", "Prefix of redis keys, separates queues of independent migrations").Default("gitlab-azdo-migration").String()
+	workerExitIdle   = workerCommand.Flag("exit-when-empty", "Stop the worker once the queue is empty instead of waiting for more jobs").Default("false").Bool()
+	workerJobLease   = workerCommand.Flag("job-lease", "Jobs whose worker did not renew their lease for this long are taken as crashed and pushed back to the queue").Default("30m").Duration()
+	workerPollPeriod = 5 * time.Second
+)
+
+// queuedJob is a single project in the queue, gitlab instance of the project is referred by its name and workers
+// resolve it from their own config so that neither URLs nor token variables are taken from redis
+type queuedJob struct {
+	ID      string  `json:"id"`
+	Project project `json:"project"`
+	// Index is position of the project in the config, collected results keep its order
+	Index int `json:"index"`
+}
+
+// jobState is kept in <queue>:state hash by job ID so that progress of all workers can be watched in one place
+type jobState struct {
+	Status  string    `json:"status"`
+	Project string    `json:"project"`
+	Index   int       `json:"index"`
+	Worker  string    `json:"worker,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+func queueKey(name string) string {
+	return *queueName + ":" + name
+}
+
+func dialQueue() redis.Conn {
+	conn, err := redis.DialURL(*redisURL)
+	if err != nil {
+		log.Fatalf("cannot connect to redis %s: %s", *redisURL, err)
+	}
+	return conn
+}
+
+// prepareQueuedJobs turns configured projects into jobs, projects are resolved by workers
+func prepareQueuedJobs(config config) ([]queuedJob, error) {
+	var jobs []queuedJob
+	for i, project := range config.Projects {
+		if _, ok := config.GitlabInstances[project.GitlabInstance]; project.GitlabInstance != "" && !ok {
+			return nil, fmt.Errorf("project #%d: gitlab instance %s is not defined in gitlabInstances", i+1, project.GitlabInstance)
+		}
+		jobs = append(jobs, queuedJob{ID: uuid.New().String(), Project: project, Index: i})
+	}
+	return jobs, nil
+}
+
+func enqueueProjects(config config) {
+	jobs, err := prepareQueuedJobs(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn := dialQueue()
+	defer conn.Close()
+	for _, job := range jobs {
+		payload, err := json.Marshal(job)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := saveJobState(conn, job, queuedStatus); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := conn.Do("LPUSH", queueKey("jobs"), payload); err != nil {
+			log.Fatalf("cannot enqueue project %v: %s", job.Project.gitlabKey(), err)
+		}
+	}
+	log.Infof("%d projects enqueued into %s", len(jobs), queueKey("jobs"))
+}
+
+func saveJobState(conn redis.Conn, job queuedJob, status string) error {
+	hostname, _ := os.Hostname()
+	state, err := json.Marshal(jobState{Status: status, Project: fmt.Sprint(job.Project.gitlabKey()), Index: job.Index, Worker: hostname, Updated: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Do("HSET", queueKey("state"), job.ID, state); err != nil {
+		return fmt.Errorf("cannot save state of job %s: %s", job.ID, err)
+	}
+	return nil
+}
+
+// runWorker migrates jobs one by one, a job stays in <queue>:processing list until its result is saved so that jobs of
+// crashed workers can be found. Gitlab instances are those of the worker config
+func runWorker(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoClient git.Client, defaultGitlab *gitlabInstance, instances map[string]*gitlabInstance) {
+	checkTransferMode()
+	conn := dialQueue()
+	defer conn.Close()
+	for {
+		if err := requeueExpiredJobs(conn, time.Now()); err != nil {
+			log.Fatal(err)
+		}
+		payload, err := redis.Bytes(conn.Do("BRPOPLPUSH", queueKey("jobs"), queueKey("processing"), int(workerPollPeriod.Seconds())))
+		if err == redis.ErrNil {
+			if *workerExitIdle {
+				log.Info("queue is empty, worker stops")
+				return
+			}
+			continue
+		}
+		if err != nil {
+			log.Fatalf("cannot take job from %s: %s", queueKey("jobs"), err)
+		}
+		if err := runQueuedJob(azdoCtx, azdoConnection, azdoClient, defaultGitlab, instances, conn, payload); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := conn.Do("LREM", queueKey("processing"), 1, payload); err != nil {
+			log.Fatalf("cannot remove finished job from %s: %s", queueKey("processing"), err)
+		}
+		if sample.isDeclined() {
+			log.Warn(errSampleDeclined)
+			return
+		}
+	}
+}
+
+func runQueuedJob(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoClient git.Client, defaultGitlab *gitlabInstance, instances map[string]*gitlabInstance, conn redis.Conn, payload []byte) error {
+	job := queuedJob{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		log.Errorf("dropping invalid job %s: %s", payload, err)
+		return nil
+	}
+	if err := saveJobState(conn, job, runningStatus); err != nil {
+		return err
+	}
+	stopRenewal := renewLease(job)
+	defer stopRenewal()
+
+	report = &runReport{}
+	var mapping migrationMapping
+	if err := resolveProject(defaultGitlab, instances, &job.Project); err != nil {
+		projectReport := report.project(fmt.Sprint(job.Project.gitlabKey()), job.Project.AzdoProject)
+		projectReport.problem("%s", err)
+		projectReport.fail()
+	} else {
+		//declined sample stops the worker once the result of the job is saved
+		mapping, _ = migrateProjects(azdoCtx, azdoConnection, azdoClient, []project{job.Project})
+	}
+
+	projectReport, err := json.Marshal(report.Projects[0])
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Do("HSET", queueKey("reports"), job.ID, projectReport); err != nil {
+		return fmt.Errorf("cannot save report of job %s: %s", job.ID, err)
+	}
+	status := failedStatus
+	if report.Projects[0].RetryFromScratch {
+		status = retryStatus
+	}
+	if len(mapping.Projects) > 0 {
+		status = migratedStatus
+		projectMapping, err := json.Marshal(mapping.Projects[0])
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Do("HSET", queueKey("mappings"), job.ID, projectMapping); err != nil {
+			return fmt.Errorf("cannot save mapping of job %s: %s", job.ID, err)
+		}
+	}
+	return saveJobState(conn, job, status)
+}
+
+// renewLease saves running state of the job periodically until the returned function is called, workers take jobs
+// whose state is older than --job-lease as crashed
+func renewLease(job queuedJob) func() {
+	done := make(chan struct{})
+	go func() {
+		conn := dialQueue()
+		defer conn.Close()
+		ticker := time.NewTicker(*workerJobLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := saveJobState(conn, job, runningStatus); err != nil {
+					log.Warnf("cannot renew lease of job %s: %s", job.ID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// leaseExpired tells whether the job in <queue>:processing was left there by a crashed worker
+func leaseExpired(state jobState, now time.Time) bool {
+	return state.Status == runningStatus && now.Sub(state.Updated) > *workerJobLease
+}
+
+// requeueExpiredJobs pushes jobs of crashed workers back to the queue to be taken next, the job is pushed only by the
+// worker which removed it from <queue>:processing
+func requeueExpiredJobs(conn redis.Conn, now time.Time) error {
+	payloads, err := redis.ByteSlices(conn.Do("LRANGE", queueKey("processing"), 0, -1))
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %s", queueKey("processing"), err)
+	}
+	for _, payload := range payloads {
+		job := queuedJob{}
+		if err := json.Unmarshal(payload, &job); err != nil {
+			continue
+		}
+		content, err := redis.Bytes(conn.Do("HGET", queueKey("state"), job.ID))
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read state of job %s: %s", job.ID, err)
+		}
+		state := jobState{}
+		if err := json.Unmarshal(content, &state); err != nil || !leaseExpired(state, now) {
+			continue
+		}
+		removed, err := redis.Int(conn.Do("LREM", queueKey("processing"), 1, payload))
+		if err != nil {
+			return fmt.Errorf("cannot remove expired job from %s: %s", queueKey("processing"), err)
+		}
+		if removed == 0 {
+			continue
+		}
+		log.Warnf("lease of job %s (project %s) on %s expired, the job is queued again", job.ID, state.Project, state.Worker)
+		if err := saveJobState(conn, job, queuedStatus); err != nil {
+			return err
+		}
+		if _, err := conn.Do("RPUSH", queueKey("jobs"), payload); err != nil {
+			return fmt.Errorf("cannot queue expired job %s again: %s", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// collectResults assembles report and mapping of the whole migration from results saved by workers, projects are in
+// the order of the config jobs were enqueued from
+func collectResults(reports map[string]string, mappings map[string]string, states map[string]string) (*runReport, migrationMapping, error) {
+	indexes := map[string]int{}
+	for id, content := range states {
+		state := jobState{}
+		if err := json.Unmarshal([]byte(content), &state); err != nil {
+			return nil, migrationMapping{}, fmt.Errorf("invalid state of job %s: %s", id, err)
+		}
+		indexes[id] = state.Index
+	}
+	var reportIDs []string
+	for id := range reports {
+		reportIDs = append(reportIDs, id)
+	}
+	sortJobIDs(reportIDs, indexes)
+	collected := &runReport{}
+	for _, id := range reportIDs {
+		projectReport := &projectReport{}
+		if err := json.Unmarshal([]byte(reports[id]), projectReport); err != nil {
+			return nil, migrationMapping{}, fmt.Errorf("invalid report of job %s: %s", id, err)
+		}
+		collected.Projects = append(collected.Projects, projectReport)
+	}
+	var mappingIDs []string
+	for id := range mappings {
+		mappingIDs = append(mappingIDs, id)
+	}
+	sortJobIDs(mappingIDs, indexes)
+	mapping := migrationMapping{}
+	for _, id := range mappingIDs {
+		projectMapping := projectMapping{}
+		if err := json.Unmarshal([]byte(mappings[id]), &projectMapping); err != nil {
+			return nil, migrationMapping{}, fmt.Errorf("invalid mapping of job %s: %s", id, err)
+		}
+		mapping.Projects = append(mapping.Projects, projectMapping)
+	}
+	return collected, mapping, nil
+}
+
+// sortJobIDs orders jobs by index of their project in the config, jobs without state come last
+func sortJobIDs(ids []string, indexes map[string]int) {
+	sort.Slice(ids, func(i, j int) bool {
+		index, ok := indexes[ids[i]]
+		other, otherOK := indexes[ids[j]]
+		if ok != otherOK {
+			return ok
+		}
+		if index != other {
+			return index < other
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+func collectQueue() {
+	conn := dialQueue()
+	defer conn.Close()
+	reports, err := redis.StringMap(conn.Do("HGETALL", queueKey("reports")))
+	if err != nil {
+		log.Fatalf("cannot read reports: %s", err)
+	}
+	mappings, err := redis.StringMap(conn.Do("HGETALL", queueKey("mappings")))
+	if err != nil {
+		log.Fatalf("cannot read mappings: %s", err)
+	}
+	states, err := redis.StringMap(conn.Do("HGETALL", queueKey("state")))
+	if err != nil {
+		log.Fatalf("cannot read job states: %s", err)
+	}
+	collected, mapping, err := collectResults(reports, mappings, states)
+	if err != nil {
+		log.Fatal(err)
+	}
+	queued, err := redis.Int(conn.Do("LLEN", queueKey("jobs")))
+	if err != nil {
+		log.Fatalf("cannot read queue length: %s", err)
+	}
+	processing, err := redis.Int(conn.Do("LLEN", queueKey("processing")))
+	if err != nil {
+		log.Fatalf("cannot read queue length: %s", err)
+	}
+	if queued+processing > 0 {
+		log.Warnf("%d projects are still queued and %d being migrated, results are incomplete", queued, processing)
+	}
+
+	report = collected
+	report.summarize()
+	if *mappingFile != "" {
+		if err := writeMapping(*mappingFile, mapping); err != nil {
+			log.Errorf("cannot write mapping file %s: %s", *mappingFile, err)
+		}
+	}
+	if *reportFile != "" {
+		if err := report.write(*reportFile); err != nil {
+			log.Errorf("cannot write report file %s: %s", *reportFile, err)
+		}
+	}
+}
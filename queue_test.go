@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-test/deep"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrepareQueuedJobs(t *testing.T) {
+	selfhosted := &gitlabInstance{URL: "https://gitlab.example.com", TokenEnv: "SELFHOSTED_TOKEN"}
+	jobs, err := prepareQueuedJobs(config{
+		GitlabInstances: map[string]*gitlabInstance{"selfhosted": selfhosted},
+		Projects: []project{
+			{GitlabProject: "group/app", AzdoProject: "Apps"},
+			{GitlabID: 7, AzdoProject: "Apps", GitlabInstance: "selfhosted"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID == "" || jobs[0].ID == jobs[1].ID {
+		t.Fatalf("every job needs unique ID: %+v", jobs)
+	}
+	if diff := deep.Equal([]int{jobs[0].Index, jobs[1].Index}, []int{0, 1}); diff != nil {
+		t.Error(diff)
+	}
+	//instance is resolved by workers from their config, its URL and token variable are not queued
+	payload, err := json.Marshal(jobs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(payload), selfhosted.URL) || strings.Contains(string(payload), selfhosted.TokenEnv) {
+		t.Errorf("job carries gitlab instance: %s", payload)
+	}
+
+	if _, err := prepareQueuedJobs(config{Projects: []project{{GitlabID: 7, GitlabInstance: "unknown"}}}); err == nil {
+		t.Error("expected error for undefined gitlab instance")
+	}
+}
+
+func TestLeaseExpired(t *testing.T) {
+	*workerJobLease = 30 * time.Minute
+	defer func() { *workerJobLease = 0 }()
+	now := time.Now()
+	cases := []struct {
+		label    string
+		state    jobState
+		expected bool
+	}{
+		{"renewed lease", jobState{Status: runningStatus, Updated: now.Add(-time.Minute)}, false},
+		{"crashed worker", jobState{Status: runningStatus, Updated: now.Add(-time.Hour)}, true},
+		{"finished job", jobState{Status: failedStatus, Updated: now.Add(-time.Hour)}, false},
+	}
+	for _, c := range cases {
+		if actual := leaseExpired(c.state, now); actual != c.expected {
+			t.Errorf("%s: expected %t", c.label, c.expected)
+		}
+	}
+}
+
+func TestCollectResults(t *testing.T) {
+	reports := map[string]string{
+		"a": `{"gitlabPath": "group/lib", "azdoProject": "Libs", "failed": true, "problems": ["cannot import"]}`,
+		"b": `{"gitlabPath": "group/app", "azdoProject": "Apps", "failed": false}`,
+		"c": `{"gitlabPath": "group/api", "azdoProject": "Apps", "failed": false}`,
+	}
+	mappings := map[string]string{
+		"b": `{"gitlabProjectId": 1, "gitlabPath": "group/app", "azdoProject": "Apps"}`,
+	}
+	//group/app is configured first, state of group/api is missing
+	states := map[string]string{
+		"a": `{"status": "failed", "index": 1}`,
+		"b": `{"status": "migrated", "index": 0}`,
+	}
+	collected, mapping, err := collectResults(reports, mappings, states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"group/app Apps false []", "group/lib Libs true [cannot import]", "group/api Apps false []"}
+	var projects []string
+	for _, project := range collected.Projects {
+		projects = append(projects, fmt.Sprint(project.GitlabPath, " ", project.AzdoProject, " ", project.Failed, " ", project.Problems))
+	}
+	if diff := deep.Equal(projects, expect); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(mapping, migrationMapping{Projects: []projectMapping{{GitlabProjectID: 1, GitlabPath: "group/app", AzdoProject: "Apps"}}}); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, _, err := collectResults(map[string]string{"1": "{"}, nil, nil); err == nil {
+		t.Error("expected error for invalid report")
+	}
+}
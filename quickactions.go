@@ -0,0 +1,82 @@
+package main
+
+import (
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strings"
+)
+
+const (
+	quickActionsTranslate = "translate"
+	quickActionsSkip      = "skip"
+	quickActionsKeep      = "keep"
+)
+
+var (
+	quickActions       = kingpin.Flag("quick-actions", "What happens with gitlab quick actions (/approve, /assign @x) in migrated comments - translate them into readable annotations, skip or keep the slash commands").Default(quickActionsTranslate).Enum(quickActionsTranslate, quickActionsSkip, quickActionsKeep)
+	quickActionMatcher = regexp.MustCompile(`^\s*/(\w+)(?:\s+(.*?))?\s*$`)
+	milestoneMatcher   = regexp.MustCompile(`(^|\s)%(?:"([^"]+)"|(\S+))`)
+
+	// quickActionAnnotations are the annotations of quick actions usable in merge requests, arguments (users,
+	// labels, milestones) follow the annotation
+	quickActionAnnotations = map[string]string{
+		"approve":           "✅ approved",
+		"unapprove":         "↩️ approval revoked",
+		"assign":            "👤 assigned to",
+		"unassign":          "👤 unassigned",
+		"reassign":          "👤 reassigned to",
+		"assign_reviewer":   "👀 review requested from",
+		"reviewer":          "👀 review requested from",
+		"request_review":    "👀 review requested from",
+		"unassign_reviewer": "👀 review request removed",
+		"remove_reviewer":   "👀 review request removed",
+		"milestone":         "🎯 milestone set to",
+		"remove_milestone":  "🎯 milestone removed",
+		"label":             "🏷️ labeled",
+		"unlabel":           "🏷️ unlabeled",
+		"relabel":           "🏷️ relabeled",
+		"close":             "🚫 closed",
+		"reopen":            "🔄 reopened",
+		"merge":             "🔀 merge requested",
+		"draft":             "📝 marked as draft",
+		"wip":               "📝 marked as draft",
+		"ready":             "📝 marked as ready",
+		"title":             "✏️ title changed to",
+		"target_branch":     "🎯 target branch changed to",
+		"rebase":            "🔀 rebase requested",
+		"estimate":          "⏱️ estimated",
+		"remove_estimate":   "⏱️ estimate removed",
+		"spend":             "⏱️ spent",
+		"remove_time_spent": "⏱️ time spent removed",
+		"due":               "📅 due",
+		"remove_due_date":   "📅 due date removed",
+		"lock":              "🔒 discussion locked",
+		"unlock":            "🔓 discussion unlocked",
+		"subscribe":         "🔔 subscribed",
+		"unsubscribe":       "🔕 unsubscribed",
+		"todo":              "📌 added to do",
+		"done":              "📌 marked to do as done",
+		"award":             "👍 reacted with",
+		"cc":                "cc",
+	}
+)
+
+// convertQuickAction rewrites the line with a known quick action into readable annotation, false is returned when
+// the line is no quick action or it has to be skipped
+func convertQuickAction(line string) (string, bool) {
+	match := quickActionMatcher.FindStringSubmatch(line)
+	if match == nil || *quickActions == quickActionsKeep {
+		return line, true
+	}
+	annotation, ok := quickActionAnnotations[strings.ToLower(match[1])]
+	if !ok {
+		return line, true
+	}
+	if *quickActions == quickActionsSkip {
+		return "", false
+	}
+	if match[2] == "" {
+		return annotation, true
+	}
+	return annotation + " " + milestoneMatcher.ReplaceAllString(match[2], "$1$2$3"), true
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestConvertQuickActions(t *testing.T) {
+	ctx := markdownContext{projectURL: "https://gitlab.com/gitlab-examples/php"}
+	bodies := []struct {
+		label  string
+		mode   string
+		body   string
+		expect string
+	}{
+		{"approve", quickActionsTranslate, "LGTM\n/approve", "LGTM\n✅ approved"},
+		{"assign", quickActionsTranslate, "/assign @john-doe @jane", "👤 assigned to @john-doe @jane"},
+		{"milestone", quickActionsTranslate, `/milestone %"Release 1.0"`, "🎯 milestone set to Release 1.0"},
+		{"label", quickActionsTranslate, "/label ~bug", "🏷️ labeled `🏷️ bug`"},
+		{"unknown command", quickActionsTranslate, "/usr/bin is missing", "/usr/bin is missing"},
+		{"code block", quickActionsTranslate, "```\n/approve\n```", "```\n/approve\n```"},
+		{"skip", quickActionsSkip, "LGTM\n/approve\n/assign @jane", "LGTM"},
+		{"keep", quickActionsKeep, "/approve", "/approve"},
+	}
+	defer func(mode string) { *quickActions = mode }(*quickActions)
+	for _, body := range bodies {
+		*quickActions = body.mode
+		if diff := deep.Equal(convertMarkdown(body.body, ctx), body.expect); diff != nil {
+			t.Errorf("%s: %+v", body.label, diff)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	redactor = &redactingHook{}
+	// redactPatterns match credentials of any kind, also those which are not configured (e.g. echoed by a server)
+	redactPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(://[^/\s:@]+:)[^@\s/]+(@)`),
+		regexp.MustCompile(`(?i)(authorization:\s*(?:basic|bearer)\s+)\S+()`),
+		regexp.MustCompile(`(?i)(private-token:\s*)\S+()`),
+		regexp.MustCompile(`(?i)([?&](?:private_token|access_token|job_token)=)[^&\s]+()`),
+		regexp.MustCompile(`()\bglpat-[0-9a-zA-Z_-]{20}\b()`),
+		regexp.MustCompile(`()\bgh[pousr]_[0-9a-zA-Z]{36}\b()`),
+	}
+)
+
+// redactingHook scrubs tokens from log messages before they are written, error messages of both clients and git can
+// contain URLs with credentials
+type redactingHook struct {
+	mutex   sync.RWMutex
+	secrets []string
+}
+
+// add registers secret which must never be logged, its URL encoded form as well as it is embedded in remote URLs
+func (h *redactingHook) add(secret string) {
+	if len(secret) < 4 {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.secrets = append(h.secrets, secret)
+	if escaped := url.QueryEscape(secret); escaped != secret {
+		h.secrets = append(h.secrets, escaped)
+	}
+}
+
+func (h *redactingHook) redact(text string) string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for _, secret := range h.secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	for _, pattern := range redactPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+redacted+"${2}")
+	}
+	return text
+}
+
+func (h *redactingHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *redactingHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.redact(entry.Message)
+	for key, value := range entry.Data {
+		if text, ok := value.(string); ok {
+			entry.Data[key] = h.redact(text)
+		}
+	}
+	return nil
+}
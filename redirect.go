@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	redirectNginx  = "nginx"
+	redirectApache = "apache"
+	redirectCaddy  = "caddy"
+)
+
+var (
+	redirectMap    = kingpin.Flag("redirect-map", "Write redirects of old gitlab repository and merge request URLs to AzDO into the file at the end of the run").Default("").String()
+	redirectFormat = kingpin.Flag("redirect-format", "Web server the redirect map is written for").Default(redirectNginx).Enum(redirectNginx, redirectApache, redirectCaddy)
+)
+
+type redirect struct {
+	path   string
+	target string
+}
+
+// prepareRedirects returns path of every migrated gitlab repository and merge request with the AzDO URL it moved to
+func prepareRedirects(mapping migrationMapping) ([]redirect, error) {
+	var redirects []redirect
+	add := func(gitlabURL string, target string) error {
+		parsed, err := url.Parse(gitlabURL)
+		if err != nil {
+			return fmt.Errorf("invalid gitlab URL %s: %s", gitlabURL, err)
+		}
+		redirects = append(redirects, redirect{path: strings.TrimSuffix(parsed.Path, "/"), target: target})
+		return nil
+	}
+	for _, project := range mapping.Projects {
+		if err := add(project.GitlabURL, project.AzdoRepositoryURL); err != nil {
+			return nil, err
+		}
+		for _, mr := range project.MergeRequests {
+			if err := add(mr.GitlabURL, mr.AzdoURL); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(redirects, func(i, j int) bool {
+		return redirects[i].path < redirects[j].path
+	})
+	return redirects, nil
+}
+
+// formatRedirects renders the redirects as configuration snippet of the web server, only the exact paths are redirected
+// so that unmigrated pages of the same project are left alone
+func formatRedirects(redirects []redirect, format string) string {
+	var lines []string
+	switch format {
+	case redirectNginx:
+		lines = append(lines,
+			"# include into http block and redirect in server block by: if ($gitlab_redirect) { return 301 $gitlab_redirect; }",
+			"map $uri $gitlab_redirect {",
+			"    default \"\";",
+		)
+		for _, r := range redirects {
+			lines = append(lines, fmt.Sprintf("    %q %q;", r.path, r.target))
+		}
+		lines = append(lines, "}")
+	case redirectApache:
+		for _, r := range redirects {
+			lines = append(lines, fmt.Sprintf("RedirectMatch permanent \"^%s/?$\" \"%s\"", regexp.QuoteMeta(r.path), r.target))
+		}
+	case redirectCaddy:
+		for _, r := range redirects {
+			lines = append(lines, fmt.Sprintf("redir %s %s permanent", r.path, r.target))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func writeRedirectMap(path string, format string, mapping migrationMapping) error {
+	redirects, err := prepareRedirects(mapping)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(formatRedirects(redirects, format)), 0644)
+}
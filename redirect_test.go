@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestFormatRedirects(t *testing.T) {
+	mapping := migrationMapping{Projects: []projectMapping{{
+		GitlabURL:         "https://gitlab.com/group/app",
+		AzdoRepositoryURL: "https://dev.azure.com/org/Apps/_git/app",
+		MergeRequests: []mergeRequestMapping{{
+			GitlabURL: "https://gitlab.com/group/app/-/merge_requests/1",
+			AzdoURL:   "https://dev.azure.com/org/Apps/_git/app/pullrequest/7",
+		}},
+	}}}
+	redirects, err := prepareRedirects(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formats := []struct {
+		format string
+		expect string
+	}{
+		{
+			redirectNginx,
+			"# include into http block and redirect in server block by: if ($gitlab_redirect) { return 301 $gitlab_redirect; }\n" +
+				"map $uri $gitlab_redirect {\n" +
+				"    default \"\";\n" +
+				"    \"/group/app\" \"https://dev.azure.com/org/Apps/_git/app\";\n" +
+				"    \"/group/app/-/merge_requests/1\" \"https://dev.azure.com/org/Apps/_git/app/pullrequest/7\";\n" +
+				"}\n",
+		},
+		{
+			redirectApache,
+			"RedirectMatch permanent \"^/group/app/?$\" \"https://dev.azure.com/org/Apps/_git/app\"\n" +
+				"RedirectMatch permanent \"^/group/app/-/merge_requests/1/?$\" \"https://dev.azure.com/org/Apps/_git/app/pullrequest/7\"\n",
+		},
+		{
+			redirectCaddy,
+			"redir /group/app https://dev.azure.com/org/Apps/_git/app permanent\n" +
+				"redir /group/app/-/merge_requests/1 https://dev.azure.com/org/Apps/_git/app/pullrequest/7 permanent\n",
+		},
+	}
+	for _, format := range formats {
+		if diff := deep.Equal(formatRedirects(redirects, format.format), format.expect); diff != nil {
+			t.Errorf("%s: %+v", format.format, diff)
+		}
+	}
+}
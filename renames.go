@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+)
+
+// filePaths are files changed by the merge request as they are in the pull request, comments written on an earlier
+// version may point to a path which was renamed or deleted since
+type filePaths struct {
+	current map[string]bool
+	renamed map[string]string
+}
+
+// fetchFilePaths returns changed files of the merge request, nil (positions are trusted) is returned when they are
+// unknown or gitlab truncated them
+func fetchFilePaths(source SourceClient, mr *MergeRequest) *filePaths {
+	//pull requests of GitHub projects are migrated without gitlab source
+	if source == nil {
+		return nil
+	}
+	changes, err := source.ListChanges(mr)
+	if err != nil {
+		log.Warnf("cannot fetch changes of merge request %d, comments are anchored to their original paths: %s", mr.IID, err)
+		return nil
+	}
+	if changes == nil {
+		return nil
+	}
+	paths := &filePaths{current: map[string]bool{}, renamed: map[string]string{}}
+	for _, change := range changes {
+		if change.Deleted {
+			continue
+		}
+		paths.current[change.NewPath] = true
+		if change.Renamed {
+			paths.renamed[change.OldPath] = change.NewPath
+		}
+	}
+	return paths
+}
+
+// resolve returns path of the commented file in the pull request, a file renamed by the merge request after the
+// comment was written is found by its original path
+func (p *filePaths) resolve(position *NotePosition) (string, bool) {
+	if p == nil {
+		return position.NewPath, true
+	}
+	if p.current[position.NewPath] {
+		return position.NewPath, true
+	}
+	if renamed, ok := p.renamed[position.OldPath]; ok {
+		return renamed, true
+	}
+	return "", false
+}
+
+// prepareOriginalPosition notes where the comment was written when the thread cannot be anchored to the file
+func prepareOriginalPosition(position *NotePosition) string {
+	if position.NewLine == 0 && position.OldLine > 0 {
+		return fmt.Sprintf("📄 *Originally on removed line %d of `%s`*", position.OldLine, position.OldPath)
+	}
+	return fmt.Sprintf("📄 *Originally on line %d of `%s`*", position.NewLine, position.NewPath)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"strings"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	paths := &filePaths{current: map[string]bool{"docs/README.md": true, "main.go": true}, renamed: map[string]string{"README.md": "docs/README.md"}}
+	positions := []struct {
+		label    string
+		paths    *filePaths
+		position NotePosition
+		path     string
+		ok       bool
+	}{
+		{"unchanged path", paths, NotePosition{OldPath: "main.go", NewPath: "main.go"}, "main.go", true},
+		{"renamed after the comment", paths, NotePosition{OldPath: "README.md", NewPath: "README.md"}, "docs/README.md", true},
+		{"renamed in the commented version", paths, NotePosition{OldPath: "README.md", NewPath: "docs/README.md"}, "docs/README.md", true},
+		{"deleted", paths, NotePosition{OldPath: "old.go", NewPath: "old.go"}, "", false},
+		{"unknown changes", nil, NotePosition{OldPath: "old.go", NewPath: "old.go"}, "old.go", true},
+	}
+	for _, position := range positions {
+		path, ok := position.paths.resolve(&position.position)
+		if diff := deep.Equal([]interface{}{path, ok}, []interface{}{position.path, position.ok}); diff != nil {
+			t.Errorf("%s: %+v", position.label, diff)
+		}
+	}
+}
+
+func TestTranslateDiscussionOnRenamedFile(t *testing.T) {
+	mr := setupSimpleMergeRequest()
+	note := setupSingleNote()
+	note.Position = &NotePosition{OldPath: "README.md", NewPath: "README.md", NewLine: 3}
+	paths := &filePaths{current: map[string]bool{"docs/README.md": true}, renamed: map[string]string{"README.md": "docs/README.md"}}
+
+	thread, _ := translateDiscussion(&mr, &Discussion{Notes: []*Note{&note}}, paths, placeLine)
+	if diff := deep.Equal(*thread.ThreadContext.FilePath, "/docs/README.md"); diff != nil {
+		t.Errorf("renamed: %+v", diff)
+	}
+
+	thread, _ = translateDiscussion(&mr, &Discussion{Notes: []*Note{&note}}, &filePaths{}, placeLine)
+	content := *(*thread.Comments)[0].Content
+	if thread.ThreadContext != nil || !strings.HasSuffix(content, "\n\n📄 *Originally on line 3 of `README.md`*") {
+		t.Errorf("deleted: thread anchored to %+v with %s", thread.ThreadContext, content)
+	}
+
+	note.Position = &NotePosition{OldPath: "README.md", NewPath: "README.md", OldLine: 7}
+	thread, _ = translateDiscussion(&mr, &Discussion{Notes: []*Note{&note}}, nil, placeLine)
+	content = *(*thread.Comments)[0].Content
+	if diff := deep.Equal(thread.ThreadContext, &git.CommentThreadContext{FilePath: gitlab.String("/README.md")}); diff != nil || !strings.HasSuffix(content, "\n\n📄 *Originally on removed line 7 of `README.md`*") {
+		t.Errorf("removed line: thread anchored to %+v with %s", thread.ThreadContext, content)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strings"
+)
+
+// invalidRepositoryCharacters are not allowed in AzDO repository names
+const invalidRepositoryCharacters = `/\:*?"<>|;#$,{}+=[]%&@'` + "`"
+
+var repoPrefix = kingpin.Flag("repo-prefix", "Prefix of names of all AzDO repositories the run creates or continues (e.g. gl-), a trial migration into the same AzDO project does not collide with repositories of the real one").Default("").String()
+
+// initRepoPrefix rejects prefixes AzDO would refuse for every repository
+func initRepoPrefix() error {
+	if strings.ContainsAny(*repoPrefix, invalidRepositoryCharacters) || strings.HasPrefix(*repoPrefix, ".") || strings.HasPrefix(*repoPrefix, "_") {
+		return fmt.Errorf("invalid --repo-prefix %q, AzDO repository names cannot start with . or _ nor contain any of %s", *repoPrefix, invalidRepositoryCharacters)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestRepoPrefix(t *testing.T) {
+	defer func() { *repoPrefix = "" }()
+	*repoPrefix = "gl-"
+	if err := initRepoPrefix(); err != nil {
+		t.Error(err)
+	}
+	gitlabProject := &gitlab.Project{Path: "monorepo"}
+	cases := map[string]project{
+		"gl-monorepo": {gitlabProject: gitlabProject},
+		"gl-foo":      {gitlabProject: gitlabProject, Subdirectory: "services/foo"},
+		"gl-foo-api":  {gitlabProject: gitlabProject, AzdoRepository: "foo-api"},
+	}
+	for expected, project := range cases {
+		if name := project.azdoRepositoryName(); name != expected {
+			t.Errorf("expected repository %s, got %s", expected, name)
+		}
+	}
+	for _, invalid := range []string{"trial/", "_trial-", ".trial-", "trial run?"} {
+		*repoPrefix = invalid
+		if initRepoPrefix() == nil {
+			t.Errorf("prefix %q should be rejected", invalid)
+		}
+	}
+}
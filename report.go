@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+var (
+	reportFile = kingpin.Flag("report-file", "Write JSON report of failed projects and problems found during the run into the file").Default("").String()
+	report     = &runReport{}
+)
+
+// runReport collects problems which do not stop the migration but somebody has to look at them afterwards
+type runReport struct {
+	mutex          sync.Mutex
+	Projects       []*projectReport `json:"projects"`
+	AzdoThrottling throttlingStats  `json:"azdoThrottling"`
+	HTTPTimeouts   int              `json:"httpTimeouts"`
+	// FailureClasses counts failed projects by class of their failure
+	FailureClasses map[string]int `json:"failureClasses,omitempty"`
+}
+
+type projectReport struct {
+	mutex         sync.Mutex
+	GitlabPath    string                  `json:"gitlabPath"`
+	AzdoProject   string                  `json:"azdoProject"`
+	Failed        bool                    `json:"failed"`
+	Failure       string                  `json:"failure,omitempty"`
+	FailureClass  string                  `json:"failureClass,omitempty"`
+	Problems      []string                `json:"problems,omitempty"`
+	Authors       map[string]*authorStats `json:"authors,omitempty"`
+	UnmappedUsers []string                `json:"unmappedUsers,omitempty"`
+	// RetryFromScratch projects had their half-migrated repository deleted by --cleanup-failed
+	RetryFromScratch  bool        `json:"retryFromScratch,omitempty"`
+	AzdoRepositoryURL string      `json:"azdoRepositoryUrl,omitempty"`
+	LFS               *lfsSummary `json:"lfs,omitempty"`
+	// FailedComments are merge requests migrated without their comments, --phases comments migrates them again
+	FailedComments []int `json:"failedComments,omitempty"`
+}
+
+func (r *runReport) project(gitlabPath string, azdoProject string) *projectReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	project := &projectReport{GitlabPath: gitlabPath, AzdoProject: azdoProject}
+	r.Projects = append(r.Projects, project)
+	return project
+}
+
+// problem logs the problem and keeps it for the report, projects outside of the migration run are only logged
+func (p *projectReport) problem(format string, args ...interface{}) {
+	message := redactor.redact(fmt.Sprintf(format, args...))
+	if p == nil {
+		log.Warn(message)
+		return
+	}
+	log.Warnf("%s: %s", p.GitlabPath, message)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.Problems = append(p.Problems, message)
+}
+
+// migrated remembers the repository of migrated project, the report email links it
+func (p *projectReport) migrated(repositoryURL string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.AzdoRepositoryURL = repositoryURL
+}
+
+// failWith logs the error which stopped the project and fails it with the class of the error, projects outside of the
+// migration run are only logged
+func (p *projectReport) failWith(err error) {
+	message := redactor.redact(err.Error())
+	if p == nil {
+		log.Error(message)
+		return
+	}
+	log.Errorf("%s: %s", p.GitlabPath, message)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.Failed = true
+	p.Failure = message
+	p.FailureClass = failureClass(err)
+}
+
+// commentsFailed remembers the merge request whose discussions could not be fetched, its pull request is migrated
+// without comments
+func (p *projectReport) commentsFailed(iid int, err error) {
+	p.problem("comments of merge request %d are not migrated, migrate them again with --phases %s: %s", iid, phaseComments, err)
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.FailedComments = append(p.FailedComments, iid)
+}
+
+func (p *projectReport) fail() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.Failed = true
+}
+
+// throttle counts AzDO responses which were rejected (throttled) or slowed down and time requests were paused
+func (r *runReport) throttle(throttled bool, paused time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if throttled {
+		r.AzdoThrottling.Throttled++
+	} else if paused == 0 {
+		r.AzdoThrottling.Delayed++
+	}
+	r.AzdoThrottling.paused += paused
+	r.AzdoThrottling.Paused = r.AzdoThrottling.paused.String()
+}
+
+// counts are numbers of migrated projects, failed ones and migrated projects with problems
+func (r *runReport) counts() (int, int, int) {
+	failed, problems := 0, 0
+	for _, project := range r.Projects {
+		if project.Failed {
+			failed++
+		} else if len(project.Problems) > 0 {
+			problems++
+		}
+	}
+	return len(r.Projects) - failed, failed, problems
+}
+
+func (r *runReport) summarize() {
+	retries, failedComments := 0, 0
+	r.FailureClasses = nil
+	for _, project := range r.Projects {
+		if project.RetryFromScratch {
+			retries++
+		}
+		failedComments += len(project.FailedComments)
+		if project.Failed {
+			r.countFailure(project.FailureClass)
+		}
+	}
+	migrated, failed, problems := r.counts()
+	log.Infof("migrated %d projects, %d failed, %d with problems", migrated, failed, problems)
+	if len(r.FailureClasses) > 0 {
+		log.Warnf("failed projects by class: %s", formatFailureClasses(r.FailureClasses))
+	}
+	if retries > 0 {
+		log.Warnf("%d projects have to be migrated again from scratch", retries)
+	}
+	if failedComments > 0 {
+		log.Warnf("comments of %d merge requests are not migrated, run again with --phases %s", failedComments, phaseComments)
+	}
+	if throttling := r.AzdoThrottling; throttling.Throttled > 0 || throttling.Delayed > 0 || throttling.paused > 0 {
+		log.Warnf("AzDO throttled %d requests and delayed %d, requests were paused for %s", throttling.Throttled, throttling.Delayed, throttling.paused)
+	}
+	r.summarizeAuthors()
+	if r.HTTPTimeouts > 0 {
+		log.Warnf("%d API requests timed out after %s", r.HTTPTimeouts, *httpTimeout)
+	}
+}
+
+// finishReport summarizes the run, pushes its metrics, writes the report file and emails it
+func finishReport() {
+	report.summarize()
+	pushMetrics()
+	if *reportFile != "" {
+		if err := report.write(*reportFile); err != nil {
+			log.Errorf("cannot write report file %s: %s", *reportFile, err)
+		}
+	}
+	emailReport()
+}
+
+func (r *runReport) write(path string) error {
+	content, err := r.marshal()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// marshal locks the report and all its projects, the report of API job is read while the job is running
+func (r *runReport) marshal() ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, project := range r.Projects {
+		project.mutex.Lock()
+		defer project.mutex.Unlock()
+	}
+	return json.MarshalIndent(r, "", "  ")
+}
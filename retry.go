@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	retryFile    = kingpin.Flag("retry-file", "Write projects which failed transiently (transient and rate-limit failures) or are to be retried from scratch (--cleanup-failed) into the file, the retry command migrates them again").Default("").String()
+	retryCommand = kingpin.Command("retry", "Migrate again projects listed in --retry-file with their configuration from --config, the file is rewritten with projects which still fail transiently")
+	retries      = &retryQueue{}
+)
+
+// retryQueue lists projects by their key in the config, the config is read again by the retry so that no credentials
+// are written into the queue
+type retryQueue struct {
+	mutex    sync.Mutex
+	Config   string       `json:"config"`
+	Projects []retryEntry `json:"projects"`
+	// attempts are attempts of projects taken from the retry file by their key
+	attempts map[string]int
+}
+
+type retryEntry struct {
+	Key          string `json:"key"`
+	GitlabPath   string `json:"gitlabPath"`
+	AzdoProject  string `json:"azdoProject"`
+	FailureClass string `json:"failureClass"`
+	Failure      string `json:"failure"`
+	Attempts     int    `json:"attempts"`
+	// RetryFromScratch projects had their half-migrated repository deleted, they fail for rejected credentials
+	RetryFromScratch bool `json:"retryFromScratch,omitempty"`
+}
+
+// add queues the failed project when its failure is transient or its repository was deleted to be migrated again
+func (q *retryQueue) add(project project) {
+	if project.report == nil || !retryableFailure(project.report.FailureClass) && !project.report.RetryFromScratch {
+		return
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	key := project.sourceKey()
+	q.Projects = append(q.Projects, retryEntry{
+		Key:              key,
+		GitlabPath:       project.report.GitlabPath,
+		AzdoProject:      project.AzdoProject,
+		FailureClass:     project.report.FailureClass,
+		Failure:          project.report.Failure,
+		Attempts:         q.attempts[key] + 1,
+		RetryFromScratch: project.report.RetryFromScratch,
+	})
+}
+
+func (q *retryQueue) write(path string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.Config = *configFile
+	if q.Projects == nil {
+		q.Projects = []retryEntry{}
+	}
+	content, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+	if len(q.Projects) > 0 {
+		log.Warnf("%d projects failed transiently, migrate them again by the retry command with --retry-file %s", len(q.Projects), path)
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// selectRetriedProjects keeps configured projects the retry file lists, projects which are not configured anymore are
+// dropped from the queue
+func selectRetriedProjects(path string, projects []project) ([]project, error) {
+	if path == "" {
+		return nil, fmt.Errorf("retry needs --retry-file written by an earlier run")
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read retry file: %s", err)
+	}
+	queued := retryQueue{}
+	if err := json.Unmarshal(content, &queued); err != nil {
+		return nil, fmt.Errorf("invalid retry file %s: %s", path, err)
+	}
+	if queued.Config != "" && queued.Config != *configFile {
+		log.Warnf("retry file %s was written with config %s, projects are looked up in %s", path, queued.Config, *configFile)
+	}
+	retries.attempts = map[string]int{}
+	for _, entry := range queued.Projects {
+		retries.attempts[entry.Key] = entry.Attempts
+	}
+	var selected []project
+	configured := map[string]bool{}
+	for _, project := range projects {
+		if _, ok := retries.attempts[project.sourceKey()]; ok {
+			selected = append(selected, project)
+			configured[project.sourceKey()] = true
+		}
+	}
+	for _, entry := range queued.Projects {
+		if !configured[entry.Key] {
+			log.Warnf("%s is not configured in %s anymore, it is dropped from the retry queue", entry.GitlabPath, *configFile)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetryQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "retry.json")
+	defer func(previous *runReport, queue *retryQueue) { report, retries = previous, queue }(report, retries)
+	report, retries = &runReport{}, &retryQueue{}
+
+	configured := []project{
+		{GitlabProject: "group/app", AzdoProject: "Apps"},
+		{GitlabProject: "group/api", AzdoProject: "Apps"},
+		{GitlabProject: "group/lib", AzdoProject: "Apps"},
+	}
+	transient, invalid, abandoned := configured[0], configured[1], configured[2]
+	transient.report = report.project("group/app", "Apps")
+	transient.report.failWith(fmt.Errorf("cannot check import request: %w", errHTTPTimeout))
+	invalid.report = report.project("group/api", "Apps")
+	invalid.report.failWith(classified(failureValidation, fmt.Errorf("repository exceeds AzDO limits")))
+	abandoned.report = report.project("group/lib", "Apps")
+	abandoned.report.retryFromScratch()
+	abandoned.report.fail()
+	retries.add(transient)
+	retries.add(invalid)
+	retries.add(abandoned)
+	if err := retries.write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	retries = &retryQueue{}
+	selected, err := selectRetriedProjects(path, configured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(selected, []project{configured[0], configured[2]}); diff != nil {
+		t.Errorf("transient failure and abandoned repository should be retried: %+v", diff)
+	}
+	//failing again counts the attempt
+	transient.report = report.project("group/app", "Apps")
+	transient.report.failWith(fmt.Errorf("cannot check import request: %w", errHTTPTimeout))
+	retries.add(transient)
+	if diff := deep.Equal([]interface{}{retries.Projects[0].Key, retries.Projects[0].Attempts}, []interface{}{":group/app:", 2}); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := selectRetriedProjects("", configured); err == nil {
+		t.Error("retry without --retry-file should fail")
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	reverseCommand = kingpin.Command("reverse", "Move configured AzDO repositories and their active pull requests back into gitlab - gitlabProject is created, azdoRepository is the source")
+
+	tagMatcher         = regexp.MustCompile("`🏷️ ([^`]+)`")
+	skippedTaskMatcher = regexp.MustCompile(`^(\s*)- \[ \] ~~(.*)~~$`)
+)
+
+// reverseProjects is the inverse of the migration for teams moving back from AzDO, every configured project is
+// created in gitlab from its AzDO repository and active pull requests become merge requests
+func reverseProjects(azdoCtx context.Context, azdoClient git.Client, defaultInstance *gitlabInstance, config config) {
+	for _, project := range config.Projects {
+		project.report = report.project(project.GitlabProject, project.AzdoProject)
+		instance := defaultInstance
+		if project.GitlabInstance != "" {
+			var err error
+			if instance, err = initGitlabInstance(config.GitlabInstances, project.GitlabInstance); err != nil {
+				project.report.problem("%s", err)
+				project.report.fail()
+				recordResult(projectsMetric, "project", false)
+				continue
+			}
+		}
+		if err := reverseProject(azdoCtx, azdoClient, instance, project); err != nil {
+			project.report.problem("cannot move AzDO repository back to gitlab: %s", err)
+			project.report.fail()
+			recordResult(projectsMetric, "project", false)
+			continue
+		}
+		recordResult(projectsMetric, "project", true)
+	}
+}
+
+func reverseProject(azdoCtx context.Context, azdoClient git.Client, instance *gitlabInstance, project project) error {
+	gitlabClient := instance.client
+	if project.GitlabProject == "" {
+		return fmt.Errorf("gitlabProject is required, it is the path of the created gitlab project")
+	}
+	name := project.AzdoRepository
+	if name == "" {
+		name = path.Base(project.GitlabProject)
+	}
+	repository, err := azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{RepositoryId: &name, Project: &project.AzdoProject})
+	if err != nil {
+		return fmt.Errorf("cannot find AzDO repository %s: %s", name, err)
+	}
+	gitlabProject, err := createReverseProject(gitlabClient, project, repository)
+	if err != nil {
+		return err
+	}
+	if err := pushReverseRepository(gitlabClient, instance, project, gitlabProject, repository); err != nil {
+		return err
+	}
+	if !project.MigrateMRs {
+		return nil
+	}
+	pullRequests, err := listActivePullRequests(azdoCtx, azdoClient, repository)
+	if err != nil {
+		return fmt.Errorf("cannot list pull requests of %s: %s", name, err)
+	}
+	for _, pullRequest := range pullRequests {
+		reversePullRequest(azdoCtx, azdoClient, gitlabClient, project, gitlabProject, repository, pullRequest)
+	}
+	return nil
+}
+
+// createReverseProject creates empty gitlab project, the repository is pushed into it from a local clone as gitlab
+// import would need the organization-wide AzDO token
+func createReverseProject(gitlabClient *gitlab.Client, project project, repository *git.GitRepository) (*gitlab.Project, error) {
+	namespacePath, projectPath := path.Split(project.GitlabProject)
+	namespace, _, err := gitlabClient.Namespaces.GetNamespace(strings.Trim(namespacePath, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot find gitlab namespace %s: %s", namespacePath, err)
+	}
+	gitlabProject, _, err := gitlabClient.Projects.CreateProject(&gitlab.CreateProjectOptions{
+		Name:        &projectPath,
+		Path:        &projectPath,
+		NamespaceID: &namespace.ID,
+		Description: gitlab.String(fmt.Sprintf("Migrated from AzDO %s", *repository.WebUrl)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gitlab project %s: %s", project.GitlabProject, err)
+	}
+	audit.record("gitlabProject.create", project.AzdoProject, strconv.Itoa(gitlabProject.ID), map[string]interface{}{
+		"gitlabProject":  gitlabProject.PathWithNamespace,
+		"azdoRepository": *repository.Name,
+	})
+	return gitlabProject, nil
+}
+
+// pushReverseRepository copies branches and tags of AzDO repository into the gitlab project through a local clone so
+// that the AzDO token never leaves the machine, gitlab makes the first pushed branch default so it is set afterwards
+func pushReverseRepository(gitlabClient *gitlab.Client, instance *gitlabInstance, project project, gitlabProject *gitlab.Project, repository *git.GitRepository) error {
+	source, err := azdoRemote(*repository.RemoteUrl)
+	if err != nil {
+		return err
+	}
+	target, err := gitlabRemote(gitlabProject.HTTPURLToRepo, instance)
+	if err != nil {
+		return err
+	}
+	copied, err := copyRefs(source, target)
+	if err != nil {
+		return fmt.Errorf("cannot push AzDO repository %s into %s: %s", *repository.Name, gitlabProject.PathWithNamespace, err)
+	}
+	if !copied {
+		log.Infof("AzDO repository %s is empty, %s has no branches", *repository.Name, gitlabProject.PathWithNamespace)
+		return nil
+	}
+	audit.record("gitlabProject.push", project.AzdoProject, strconv.Itoa(gitlabProject.ID), map[string]interface{}{
+		"sourceUrl": *repository.WebUrl,
+	})
+	log.Infof("AzDO repository pushed into %s", gitlabProject.PathWithNamespace)
+	if repository.DefaultBranch == nil {
+		return nil
+	}
+	defaultBranch := strings.TrimPrefix(*repository.DefaultBranch, "refs/heads/")
+	if _, _, err := gitlabClient.Projects.EditProject(gitlabProject.ID, &gitlab.EditProjectOptions{DefaultBranch: &defaultBranch}); err != nil {
+		return fmt.Errorf("cannot set default branch %s of %s: %s", defaultBranch, gitlabProject.PathWithNamespace, err)
+	}
+	return nil
+}
+
+// copyRefs fetches branches and tags of the source remote into a local repository and pushes them to the target,
+// nothing is pushed from an empty source
+func copyRefs(source string, target string) (bool, error) {
+	repository, err := newLocalRepository()
+	if err != nil {
+		return false, err
+	}
+	defer repository.remove()
+
+	if _, err := repository.run("fetch", "--quiet", source, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+		return false, err
+	}
+	refs, err := repository.run("for-each-ref", "--format=%(refname)")
+	if err != nil || refs == "" {
+		return false, err
+	}
+	return true, repository.push(target, "refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*")
+}
+
+func listActivePullRequests(azdoCtx context.Context, azdoClient git.Client, repository *git.GitRepository) ([]git.GitPullRequest, error) {
+	var pullRequests []git.GitPullRequest
+	args := git.GetPullRequestsArgs{
+		RepositoryId:   gitlab.String(repository.Id.String()),
+		Project:        repository.Project.Name,
+		SearchCriteria: &git.GitPullRequestSearchCriteria{Status: &git.PullRequestStatusValues.Active},
+		Skip:           gitlab.Int(0),
+		Top:            gitlab.Int(100),
+	}
+	for {
+		page, err := azdoClient.GetPullRequests(azdoCtx, args)
+		if err != nil {
+			return nil, err
+		}
+		pullRequests = append(pullRequests, *page...)
+		if len(*page) < *args.Top {
+			return pullRequests, nil
+		}
+		*args.Skip += *args.Top
+	}
+}
+
+func reversePullRequest(azdoCtx context.Context, azdoClient git.Client, gitlabClient *gitlab.Client, project project, gitlabProject *gitlab.Project, repository *git.GitRepository, pullRequest git.GitPullRequest) {
+	pullRequestURL := preparePullRequestURL(*repository.WebUrl, *pullRequest.PullRequestId)
+	mr, _, err := gitlabClient.MergeRequests.CreateMergeRequest(gitlabProject.ID, translateReversePullRequest(pullRequest, pullRequestURL))
+	if err != nil {
+		project.report.problem("cannot create merge request of pull request %d: %s", *pullRequest.PullRequestId, err)
+		recordResult(mergeRequestsMetric, "merge_request", false)
+		return
+	}
+	recordResult(mergeRequestsMetric, "merge_request", true)
+	audit.record("gitlabMergeRequest.create", project.AzdoProject, strconv.Itoa(mr.IID), map[string]interface{}{
+		"gitlabProject": gitlabProject.PathWithNamespace,
+		"pullRequestId": *pullRequest.PullRequestId,
+	})
+	threads, err := azdoClient.GetThreads(azdoCtx, git.GetThreadsArgs{
+		RepositoryId:  gitlab.String(repository.Id.String()),
+		PullRequestId: pullRequest.PullRequestId,
+		Project:       repository.Project.Name,
+	})
+	if err != nil {
+		project.report.problem("cannot fetch threads of pull request %d: %s", *pullRequest.PullRequestId, err)
+		return
+	}
+	for _, thread := range *threads {
+		if err := reverseThread(gitlabClient, gitlabProject, mr, pullRequestURL, thread); err != nil {
+			project.report.problem("cannot migrate thread %d of pull request %d: %s", *thread.Id, *pullRequest.PullRequestId, err)
+			recordResult(threadsMetric, "thread", false)
+		}
+	}
+}
+
+// translateReversePullRequest prepares merge request of the pull request, its description says where it comes from
+func translateReversePullRequest(pullRequest git.GitPullRequest, pullRequestURL string) *gitlab.CreateMergeRequestOptions {
+	title := *pullRequest.Title
+	if pullRequest.IsDraft != nil && *pullRequest.IsDraft {
+		title = "Draft: " + title
+	}
+	description := ""
+	if pullRequest.Description != nil {
+		description = *pullRequest.Description
+	}
+	var labels gitlab.Labels
+	if pullRequest.Labels != nil {
+		for _, label := range *pullRequest.Labels {
+			labels = append(labels, *label.Name)
+		}
+	}
+	return &gitlab.CreateMergeRequestOptions{
+		Title:        &title,
+		Description:  gitlab.String(prepareReverseBody(pullRequestURL, pullRequest.CreatedBy, description)),
+		SourceBranch: gitlab.String(strings.TrimPrefix(*pullRequest.SourceRefName, "refs/heads/")),
+		TargetBranch: gitlab.String(strings.TrimPrefix(*pullRequest.TargetRefName, "refs/heads/")),
+		Labels:       &labels,
+	}
+}
+
+// reverseThread creates discussion of the thread, system comments (votes, pushes) are left behind and the position
+// is noted in the body as gitlab anchors discussions to diff versions AzDO does not have
+func reverseThread(gitlabClient *gitlab.Client, gitlabProject *gitlab.Project, mr *gitlab.MergeRequest, pullRequestURL string, thread git.GitPullRequestCommentThread) error {
+	bodies := prepareReverseNotes(pullRequestURL, thread)
+	if len(bodies) == 0 {
+		return nil
+	}
+	discussion, _, err := gitlabClient.Discussions.CreateMergeRequestDiscussion(gitlabProject.ID, mr.IID, &gitlab.CreateMergeRequestDiscussionOptions{Body: &bodies[0]})
+	if err != nil {
+		return err
+	}
+	for _, body := range bodies[1:] {
+		body := body
+		if _, _, err := gitlabClient.Discussions.AddMergeRequestDiscussionNote(gitlabProject.ID, mr.IID, discussion.ID, &gitlab.AddMergeRequestDiscussionNoteOptions{Body: &body}); err != nil {
+			return err
+		}
+	}
+	if isThreadResolved(thread) {
+		if _, _, err := gitlabClient.Discussions.ResolveMergeRequestDiscussion(gitlabProject.ID, mr.IID, discussion.ID, &gitlab.ResolveMergeRequestDiscussionOptions{Resolved: gitlab.Bool(true)}); err != nil {
+			return err
+		}
+	}
+	recordResult(threadsMetric, "thread", true)
+	return nil
+}
+
+// prepareReverseNotes returns note bodies of the thread comments which are not deleted and were written by people
+func prepareReverseNotes(pullRequestURL string, thread git.GitPullRequestCommentThread) []string {
+	if thread.IsDeleted != nil && *thread.IsDeleted || thread.Comments == nil {
+		return nil
+	}
+	var bodies []string
+	for _, comment := range *thread.Comments {
+		if comment.IsDeleted != nil && *comment.IsDeleted || comment.CommentType != nil && *comment.CommentType == git.CommentTypeValues.System || comment.Content == nil {
+			continue
+		}
+		bodies = append(bodies, prepareReverseBody(pullRequestURL, comment.Author, *comment.Content))
+	}
+	if len(bodies) > 0 && thread.ThreadContext != nil && thread.ThreadContext.FilePath != nil {
+		bodies[0] += "\n\n" + prepareOriginalPosition(reversePosition(thread.ThreadContext))
+	}
+	return bodies
+}
+
+func reversePosition(threadContext *git.CommentThreadContext) *NotePosition {
+	filePath := strings.TrimPrefix(*threadContext.FilePath, "/")
+	position := &NotePosition{NewPath: filePath, OldPath: filePath}
+	if threadContext.RightFileStart != nil {
+		position.NewLine = *threadContext.RightFileStart.Line
+	} else if threadContext.LeftFileStart != nil {
+		position.OldLine = *threadContext.LeftFileStart.Line
+	}
+	return position
+}
+
+func isThreadResolved(thread git.GitPullRequestCommentThread) bool {
+	if thread.Status == nil {
+		return false
+	}
+	switch *thread.Status {
+	case git.CommentThreadStatusValues.Active, git.CommentThreadStatusValues.Pending, git.CommentThreadStatusValues.Unknown:
+		return false
+	}
+	return true
+}
+
+func prepareReverseBody(pullRequestURL string, author *webapi.IdentityRef, content string) string {
+	name := "unknown"
+	if author != nil && author.DisplayName != nil {
+		name = *author.DisplayName
+		if *anonymizeAuthors && author.UniqueName != nil {
+			name = pseudonyms.pseudonym(*author.UniqueName)
+		}
+	}
+	return fmt.Sprintf("*Migrated from [AzDO](%s) | Author: %s*\n\n%s", pullRequestURL, name, convertAzdoMarkdown(content))
+}
+
+// convertAzdoMarkdown reverts what convertMarkdown translates - math blocks, label references and inapplicable tasks
+func convertAzdoMarkdown(body string) string {
+	lines := strings.Split(body, "\n")
+	math := false
+	for i, line := range lines {
+		switch {
+		case strings.TrimSpace(line) == "$$" && !math:
+			lines[i], math = "```math", true
+		case strings.TrimSpace(line) == "$$":
+			lines[i], math = "```", false
+		case !math:
+			line = skippedTaskMatcher.ReplaceAllString(line, "$1- [~] $2")
+			lines[i] = tagMatcher.ReplaceAllStringFunc(line, func(tag string) string {
+				label := tagMatcher.FindStringSubmatch(tag)[1]
+				if strings.ContainsAny(label, " \t") {
+					return fmt.Sprintf("~%q", label)
+				}
+				return "~" + label
+			})
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestTranslateReversePullRequest(t *testing.T) {
+	pullRequest := git.GitPullRequest{
+		Title:         gitlab.String("Add login"),
+		Description:   gitlab.String("Adds `🏷️ auth` login"),
+		IsDraft:       gitlab.Bool(true),
+		SourceRefName: gitlab.String("refs/heads/feature/login"),
+		TargetRefName: gitlab.String("refs/heads/main"),
+		CreatedBy:     &webapi.IdentityRef{DisplayName: gitlab.String("John Doe")},
+		Labels:        &[]core.WebApiTagDefinition{{Name: gitlab.String("security")}},
+	}
+	expected := &gitlab.CreateMergeRequestOptions{
+		Title:        gitlab.String("Draft: Add login"),
+		Description:  gitlab.String("*Migrated from [AzDO](https://dev.azure.com/org/app/_git/app/pullrequest/7) | Author: John Doe*\n\nAdds ~auth login"),
+		SourceBranch: gitlab.String("feature/login"),
+		TargetBranch: gitlab.String("main"),
+		Labels:       &gitlab.Labels{"security"},
+	}
+	if diff := deep.Equal(translateReversePullRequest(pullRequest, "https://dev.azure.com/org/app/_git/app/pullrequest/7"), expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPrepareReverseNotes(t *testing.T) {
+	url := "https://dev.azure.com/org/app/_git/app/pullrequest/7"
+	author := &webapi.IdentityRef{DisplayName: gitlab.String("John Doe")}
+	comment := git.Comment{Author: author, Content: gitlab.String("Rename it"), CommentType: &git.CommentTypeValues.Text}
+	reply := git.Comment{Author: author, Content: gitlab.String("Done"), CommentType: &git.CommentTypeValues.Text}
+	deleted := git.Comment{Author: author, Content: gitlab.String("Oops"), IsDeleted: gitlab.Bool(true)}
+	vote := git.Comment{Author: author, Content: gitlab.String("John Doe voted 10"), CommentType: &git.CommentTypeValues.System}
+
+	threads := []struct {
+		label  string
+		thread git.GitPullRequestCommentThread
+		expect []string
+	}{
+		{"system", git.GitPullRequestCommentThread{Comments: &[]git.Comment{vote}}, nil},
+		{"deleted thread", git.GitPullRequestCommentThread{IsDeleted: gitlab.Bool(true), Comments: &[]git.Comment{comment}}, nil},
+		{
+			"code thread",
+			git.GitPullRequestCommentThread{
+				Comments:      &[]git.Comment{comment, deleted, reply},
+				ThreadContext: &git.CommentThreadContext{FilePath: gitlab.String("/main.go"), RightFileStart: &git.CommentPosition{Line: gitlab.Int(3)}},
+			},
+			[]string{
+				"*Migrated from [AzDO](" + url + ") | Author: John Doe*\n\nRename it\n\n📄 *Originally on line 3 of `main.go`*",
+				"*Migrated from [AzDO](" + url + ") | Author: John Doe*\n\nDone",
+			},
+		},
+	}
+	for _, thread := range threads {
+		if diff := deep.Equal(prepareReverseNotes(url, thread.thread), thread.expect); diff != nil {
+			t.Errorf("%s: %+v", thread.label, diff)
+		}
+	}
+}
+
+func TestConvertAzdoMarkdown(t *testing.T) {
+	bodies := []struct {
+		label  string
+		body   string
+		expect string
+	}{
+		{"math", "$$\na^2\n$$", "```math\na^2\n```"},
+		{"labels", "`🏷️ bug` and `🏷️ needs review`", `~bug and ~"needs review"`},
+		{"skipped task", "- [ ] ~~obsolete~~\n- [x] done", "- [~] obsolete\n- [x] done"},
+	}
+	for _, body := range bodies {
+		if diff := deep.Equal(convertAzdoMarkdown(body.body), body.expect); diff != nil {
+			t.Errorf("%s: %+v", body.label, diff)
+		}
+	}
+}
+
+func TestIsThreadResolved(t *testing.T) {
+	for status, expect := range map[git.CommentThreadStatus]bool{
+		git.CommentThreadStatusValues.Active:  false,
+		git.CommentThreadStatusValues.Pending: false,
+		git.CommentThreadStatusValues.Fixed:   true,
+		git.CommentThreadStatusValues.WontFix: true,
+		git.CommentThreadStatusValues.Closed:  true,
+	} {
+		status := status
+		if resolved := isThreadResolved(git.GitPullRequestCommentThread{Status: &status}); resolved != expect {
+			t.Errorf("%s: resolved %t", status, resolved)
+		}
+	}
+}
+
+func TestCopyRefs(t *testing.T) {
+	source, err := newLocalRepository()
+	if err != nil {
+		t.Skip(err)
+	}
+	defer source.remove()
+	target, err := newLocalRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.remove()
+	if copied, err := copyRefs(source.dir, target.dir); copied || err != nil {
+		t.Errorf("empty repository should not be pushed: %v", err)
+	}
+	tree, _ := source.run("mktree")
+	commit, err := source.runWith([]string{"GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@b", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@b"}, "commit-tree", tree, "-m", "initial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.run("update-ref", "refs/heads/main", commit)
+	source.run("update-ref", "refs/tags/v1", commit)
+	if copied, err := copyRefs(source.dir, target.dir); !copied || err != nil {
+		t.Fatalf("repository should be pushed: %v", err)
+	}
+	refs, _ := target.run("for-each-ref", "--format=%(refname)")
+	if diff := deep.Equal(refs, "refs/heads/main\nrefs/tags/v1"); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"strconv"
+	"strings"
+)
+
+var reviewStates = kingpin.Flag("review-state", "Migrate approvals and requested changes of merge requests into the reviewers summary and votes of the token owner, it takes an API call per merge request for approvals and another one per merge request with reviewers for requested changes, --no-review-state skips both").Default("true").Bool()
+
+const (
+	// approvedVote is the AzDO vote of approving reviewer
+	approvedVote = 10
+	// waitingForAuthorVote is the AzDO vote of reviewer who requested changes
+	waitingForAuthorVote = -5
+)
+
+type mergeRequestReviewer struct {
+	user             *User
+	approved         bool
+	changesRequested bool
+}
+
+// vote is the AzDO vote matching review state of the reviewer, 0 when the reviewer did not decide
+func (r mergeRequestReviewer) vote() int {
+	if r.changesRequested {
+		return waitingForAuthorVote
+	}
+	if r.approved {
+		return approvedVote
+	}
+	return 0
+}
+
+// fetchReviewStates returns approvers and reviewers requesting changes of the merge request, nobody without
+// --review-state
+func fetchReviewStates(source SourceClient, project project, mr *MergeRequest) ([]*User, []*User) {
+	if !*reviewStates {
+		return nil, nil
+	}
+	approvers, err := source.ListApprovers(mr)
+	if err != nil {
+		project.report.problem("cannot fetch approvals of merge request %d, review state is not migrated: %s", mr.IID, err)
+		return nil, nil
+	}
+	requesters, err := source.ListChangesRequesters(mr)
+	if err != nil {
+		project.report.problem("cannot fetch reviewers of merge request %d, requested changes are not migrated: %s", mr.IID, err)
+	}
+	return approvers, requesters
+}
+
+// prepareReviewers merges reviewers with approvers and reviewers requesting changes of the merge request, approval
+// can come from somebody who was not asked for review
+func prepareReviewers(mr *MergeRequest, approvedBy []*User, changesRequestedBy []*User) []mergeRequestReviewer {
+	var reviewers []mergeRequestReviewer
+	approved := map[string]bool{}
+	for _, approver := range approvedBy {
+		approved[approver.Username] = true
+	}
+	requested := map[string]bool{}
+	for _, requester := range changesRequestedBy {
+		requested[requester.Username] = true
+	}
+	listed := map[string]bool{}
+	users := append(append(append([]*User{}, mr.Reviewers...), approvedBy...), changesRequestedBy...)
+	for _, user := range users {
+		if listed[user.Username] {
+			continue
+		}
+		listed[user.Username] = true
+		reviewers = append(reviewers, mergeRequestReviewer{user: user, approved: approved[user.Username], changesRequested: requested[user.Username]})
+	}
+	return reviewers
+}
+
+// prepareReviewSummary keeps review state in the description as votes of other users cannot be set in AzDO
+func prepareReviewSummary(reviewers []mergeRequestReviewer) string {
+	if len(reviewers) == 0 {
+		return ""
+	}
+	var summary []string
+	for _, reviewer := range reviewers {
+		entry := prepareUserLink(reviewer.user.Username, reviewer.user.Name, reviewer.user.WebURL)
+		switch reviewer.vote() {
+		case waitingForAuthorVote:
+			entry += " ⏳ requested changes (waiting for author)"
+		case approvedVote:
+			entry += " ✔️ approved"
+		}
+		summary = append(summary, entry)
+	}
+	return "**Reviewers:** " + strings.Join(summary, ", ")
+}
+
+// translateReviewers adds reviewers known to the identity map as optional reviewers
+func translateReviewers(reviewers []mergeRequestReviewer) *[]git.IdentityRefWithVote {
+	var azdoReviewers []git.IdentityRefWithVote
+	for _, reviewer := range reviewers {
+		id, ok := identities.resolve(reviewer.user)
+		if !ok {
+			continue
+		}
+		azdoReviewers = append(azdoReviewers, git.IdentityRefWithVote{Id: gitlab.String(id), IsRequired: gitlab.Bool(false)})
+	}
+	if len(azdoReviewers) == 0 {
+		return nil
+	}
+	return &azdoReviewers
+}
+
+// voteReviewers approves the pull request or waits for its author on behalf of reviewer who decided, AzDO allows it
+// only for the token owner
+func voteReviewers(azdoCtx context.Context, azdoClient TargetClient, project project, pullRequest *git.GitPullRequest, reviewers []mergeRequestReviewer) {
+	for _, reviewer := range reviewers {
+		vote := reviewer.vote()
+		if vote == 0 {
+			continue
+		}
+		id, ok := identities.resolve(reviewer.user)
+		if !ok || !identities.isOwner(id) {
+			continue
+		}
+		_, err := azdoClient.CreatePullRequestReviewer(azdoCtx, git.CreatePullRequestReviewerArgs{
+			Reviewer:      &git.IdentityRefWithVote{Vote: gitlab.Int(vote)},
+			RepositoryId:  gitlab.String(pullRequest.Repository.Id.String()),
+			PullRequestId: pullRequest.PullRequestId,
+			ReviewerId:    &id,
+			Project:       &project.AzdoProject,
+		})
+		if err != nil {
+			project.report.problem("cannot vote %d on pull request %d as %s: %s", vote, *pullRequest.PullRequestId, reviewer.user.Username, err)
+			continue
+		}
+		audit.record("pullRequest.vote", project.AzdoProject, strconv.Itoa(*pullRequest.PullRequestId), map[string]interface{}{
+			"reviewerId": id,
+			"vote":       vote,
+		})
+	}
+}
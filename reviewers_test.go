@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestPrepareReviewers(t *testing.T) {
+	alice := &User{ID: 1, Username: "alice", Name: "Alice", WebURL: "https://gitlab.com/alice"}
+	bob := &User{ID: 2, Username: "bob", Name: "Bob", WebURL: "https://gitlab.com/bob"}
+	carol := &User{ID: 3, Username: "carol", Name: "Carol", WebURL: "https://gitlab.com/carol"}
+	dave := &User{ID: 4, Username: "dave", Name: "Dave", WebURL: "https://gitlab.com/dave"}
+	mr := &MergeRequest{Reviewers: []*User{alice, bob, dave}}
+	approvedBy := []*User{bob, carol}
+
+	reviewers := prepareReviewers(mr, approvedBy, []*User{dave})
+	expect := "**Reviewers:** [Alice](https://gitlab.com/alice), [Bob](https://gitlab.com/bob) ✔️ approved, [Dave](https://gitlab.com/dave) ⏳ requested changes (waiting for author), [Carol](https://gitlab.com/carol) ✔️ approved"
+	if diff := deep.Equal(expect, prepareReviewSummary(reviewers)); diff != nil {
+		t.Error(diff)
+	}
+	if votes := []int{reviewers[0].vote(), reviewers[1].vote(), reviewers[2].vote()}; deep.Equal([]int{0, approvedVote, waitingForAuthorVote}, votes) != nil {
+		t.Errorf("unexpected votes %v", votes)
+	}
+	if summary := prepareReviewSummary(prepareReviewers(&MergeRequest{}, nil, nil)); summary != "" {
+		t.Errorf("summary of merge request without reviewers should be empty, got %s", summary)
+	}
+
+	identities = &identityMap{resolved: map[string]string{"alice": "alice-id", "carol": ""}}
+	defer func() { identities = &identityMap{} }()
+	expectReviewers := &[]git.IdentityRefWithVote{{Id: gitlab.String("alice-id"), IsRequired: gitlab.Bool(false)}}
+	if diff := deep.Equal(expectReviewers, translateReviewers(reviewers)); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	sampleSize = kingpin.Flag("sample", "Migrate first N merge requests, print created pull requests and wait for confirmation before the rest is migrated").Default("0").Int()
+	// sampleInput and sampleOutput are the terminal the confirmation is asked on
+	sampleInput  io.Reader = os.Stdin
+	sampleOutput io.Writer = os.Stdout
+	sample                 = &sampleDecision{}
+	// errSampleDeclined stops the run once the sample is declined, what was migrated until then is still reported
+	errSampleDeclined = errors.New("migration stopped after the sample")
+)
+
+// sampleDecision is the answer to the sample, it is asked only once while projects of bulk imports and workers finish
+// one after another
+type sampleDecision struct {
+	mutex     sync.Mutex
+	confirmed bool
+	declined  bool
+}
+
+func (s *sampleDecision) decided() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.confirmed || s.declined
+}
+
+func (s *sampleDecision) isDeclined() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.declined
+}
+
+// sampleReady tells whether migrated merge requests should be shown for confirmation, the whole project counts
+// as a sample when it has less merge requests than requested
+func sampleReady(migrated int, finished bool) bool {
+	if *sampleSize <= 0 || sample.decided() || migrated == 0 {
+		return false
+	}
+	return migrated >= *sampleSize || finished
+}
+
+// confirmSample prints pull requests of the sample and returns errSampleDeclined unless the user confirms the
+// formatting
+func confirmSample(mappings []mergeRequestMapping) error {
+	sample.mutex.Lock()
+	defer sample.mutex.Unlock()
+	if sample.declined {
+		return errSampleDeclined
+	}
+	if sample.confirmed {
+		return nil
+	}
+	fmt.Fprintf(sampleOutput, "Sample of %d pull requests was migrated, check them before the rest is migrated:\n", len(mappings))
+	for _, mapping := range mappings {
+		fmt.Fprintf(sampleOutput, "  %s (%s)\n", mapping.AzdoURL, mapping.GitlabURL)
+	}
+	fmt.Fprint(sampleOutput, "Continue? [y/N] ")
+	answer, _ := bufio.NewReader(sampleInput).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		sample.declined = true
+		return errSampleDeclined
+	}
+	sample.confirmed = true
+	return nil
+}
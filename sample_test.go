@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	*sampleSize = 2
+	defer func() {
+		*sampleSize = 0
+		sample = &sampleDecision{}
+		sampleInput, sampleOutput = os.Stdin, os.Stdout
+	}()
+	if sampleReady(1, false) || !sampleReady(2, false) || !sampleReady(1, true) || sampleReady(0, true) {
+		t.Error("sample should be ready once enough merge requests are migrated or the project is finished")
+	}
+
+	output := &bytes.Buffer{}
+	sampleInput, sampleOutput = strings.NewReader("yes\n"), output
+	mappings := []mergeRequestMapping{{GitlabURL: "https://gitlab.com/group/php/-/merge_requests/1", AzdoURL: "https://dev.azure.com/org/project/_git/php/pullrequest/7"}}
+	if err := confirmSample(mappings); err != nil {
+		t.Errorf("confirmed sample should continue: %s", err)
+	}
+	if !strings.Contains(output.String(), "https://dev.azure.com/org/project/_git/php/pullrequest/7 (https://gitlab.com/group/php/-/merge_requests/1)") {
+		t.Errorf("pull request is not listed in %s", output.String())
+	}
+	if sampleReady(2, false) {
+		t.Error("sample should be confirmed only once")
+	}
+
+	sample = &sampleDecision{}
+	sampleInput = strings.NewReader("\n")
+	if err := confirmSample(mappings); err != errSampleDeclined || !sample.isDeclined() || sampleReady(2, false) {
+		t.Errorf("declined sample should stop the run, got %v", err)
+	}
+}
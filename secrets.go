@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	secretScanOff    = "off"
+	secretScanReport = "report"
+	secretScanBlock  = "block"
+)
+
+var (
+	secretScan  = kingpin.Flag("secret-scan", "Scan history of mirrored repositories for credentials before the push - report findings and push anyway or block the project").Default(secretScanOff).Enum(secretScanOff, secretScanReport, secretScanBlock)
+	secretRules = []secretRule{
+		{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"AWS secret key", regexp.MustCompile(`(?i)aws.{0,20}['"][0-9a-zA-Z/+]{40}['"]`)},
+		{"Azure storage key", regexp.MustCompile(`AccountKey=[0-9a-zA-Z+/=]{88}`)},
+		{"gitlab token", regexp.MustCompile(`\bglpat-[0-9a-zA-Z_-]{20}\b`)},
+		{"github token", regexp.MustCompile(`\bgh[pousr]_[0-9a-zA-Z]{36}\b`)},
+		{"slack token", regexp.MustCompile(`\bxox[abposr]-[0-9a-zA-Z-]{10,}`)},
+		{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+		{"password assignment", regexp.MustCompile(`(?i)(password|passwd|secret|api_?key|token)["']?\s*[:=]\s*["'][^"'\s]{8,}["']`)},
+	}
+	hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+)
+
+type secretRule struct {
+	name    string
+	matcher *regexp.Regexp
+}
+
+// secretFinding locates a credential without the credential itself so that the report does not leak it again
+type secretFinding struct {
+	rule   string
+	commit string
+	path   string
+	line   int
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s in %s:%d (commit %s)", f.rule, f.path, f.line, f.commit)
+}
+
+// secretScanner consumes git log patch output and looks for credentials in added lines, every credential is reported
+// in the commit which introduced it
+type secretScanner struct {
+	commit   string
+	path     string
+	line     int
+	findings []secretFinding
+}
+
+func (s *secretScanner) feed(line string) {
+	switch {
+	case strings.HasPrefix(line, "commit "):
+		s.commit = strings.TrimPrefix(line, "commit ")
+	case strings.HasPrefix(line, "+++ "):
+		s.path = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+	case strings.HasPrefix(line, "@@"):
+		if match := hunkHeader.FindStringSubmatch(line); match != nil {
+			s.line, _ = strconv.Atoi(match[1])
+		}
+	case strings.HasPrefix(line, "+"):
+		for _, rule := range secretRules {
+			if rule.matcher.MatchString(line) {
+				s.findings = append(s.findings, secretFinding{rule: rule.name, commit: s.commit, path: s.path, line: s.line})
+				break
+			}
+		}
+		s.line++
+	case strings.HasPrefix(line, " "):
+		s.line++
+	}
+}
+
+// scanSecrets checks every commit of the local repository, findings are reported and with block mode the project
+// is not pushed at all
+func scanSecrets(repository *localRepository, project project) error {
+	if *secretScan == secretScanOff {
+		return nil
+	}
+	scanner := &secretScanner{}
+	err := repository.stream(scanner.feed, "log", "--all", "--no-color", "--no-renames", "--format=commit %H", "-p", "-U0")
+	if err != nil {
+		return err
+	}
+	for _, finding := range scanner.findings {
+		project.report.problem("possible secret: %s", finding)
+	}
+	if len(scanner.findings) > 0 && *secretScan == secretScanBlock {
+		return classified(failureValidation, fmt.Errorf("%d possible secrets found in the history, push blocked", len(scanner.findings)))
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jobQueued   = "queued"
+	jobRunning  = "running"
+	jobFinished = "finished"
+)
+
+var (
+	serveCommand        = kingpin.Command("serve", "Expose REST API to submit migrations and fetch their reports")
+	serveListen         = serveCommand.Flag("listen", "Address the API listens on, only local clients reach it by default").Default("127.0.0.1:8080").String()
+	serveToken          = serveCommand.Flag("api-token", "Bearer token API clients have to send, jobs run with gitlab and AzDO tokens of the server so it cannot be empty").Default("").String()
+	serveAllowOverrides = serveCommand.Flag("allow-job-overrides", "Accept postAction and serviceEndpointId of submitted projects, clients can archive gitlab projects or pick AzDO credentials of imports with them").Default("false").Bool()
+)
+
+// migrationJob is a project list submitted through the API, jobs run one at a time as the migration keeps global state
+type migrationJob struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Projects  int        `json:"projects"`
+	Processed int        `json:"processed"`
+	Failed    int        `json:"failed"`
+
+	projects []project
+	report   *runReport
+	mapping  migrationMapping
+}
+
+// jobRequest is the body of job submission, gitlab instances are not accepted as their tokenEnv would let clients
+// send environment of the server anywhere
+type jobRequest struct {
+	Projects []project `json:"projects"`
+}
+
+type jobServer struct {
+	mutex sync.Mutex
+	jobs  map[string]*migrationJob
+	order []*migrationJob
+	queue chan *migrationJob
+
+	azdoCtx        context.Context
+	azdoConnection *azuredevops.Connection
+	azdoClient     git.Client
+	defaultGitlab  *gitlabInstance
+}
+
+func serveAPI(azdoCtx context.Context, azdoConnection *azuredevops.Connection, azdoClient git.Client, defaultGitlab *gitlabInstance) {
+	if *sampleSize > 0 {
+		log.Fatal("--sample asks for confirmation and cannot be used with serve")
+	}
+	if *serveToken == "" {
+		log.Fatal("--api-token is required, anybody who can reach the API could start migrations without it")
+	}
+	redactor.add(*serveToken)
+	checkTransferMode()
+	server := &jobServer{
+		jobs:           map[string]*migrationJob{},
+		queue:          make(chan *migrationJob, 1000),
+		azdoCtx:        azdoCtx,
+		azdoConnection: azdoConnection,
+		azdoClient:     azdoClient,
+		defaultGitlab:  defaultGitlab,
+	}
+	go server.work()
+	log.Infof("serving API on %s", *serveListen)
+	log.Fatal(http.ListenAndServe(*serveListen, server.handler()))
+}
+
+func (s *jobServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", s.authorized(s.handleJobs))
+	mux.HandleFunc("/jobs/", s.authorized(s.handleJob))
+	return mux
+}
+
+func (s *jobServer) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if *serveToken == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+*serveToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// handleJobs lists jobs (GET) or submits a new one (POST)
+func (s *jobServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mutex.Lock()
+		jobs := make([]migrationJob, 0, len(s.order))
+		for _, job := range s.order {
+			jobs = append(jobs, job.progress())
+		}
+		s.mutex.Unlock()
+		writeJSON(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		job, errors := s.prepareJob(r)
+		if len(errors) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string][]string{"errors": errors})
+			return
+		}
+		s.mutex.Lock()
+		s.jobs[job.ID] = job
+		s.order = append(s.order, job)
+		progress := job.progress()
+		s.mutex.Unlock()
+		s.queue <- job
+		log.Infof("job %s with %d projects submitted", job.ID, job.Projects)
+		writeJSON(w, http.StatusAccepted, progress)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET or POST"})
+	}
+}
+
+// handleJob returns progress of the job, its report (/jobs/<id>/report) or mapping (/jobs/<id>/mapping)
+func (s *jobServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET"})
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	s.mutex.Lock()
+	job, ok := s.jobs[parts[0]]
+	var progress migrationJob
+	if ok {
+		progress = job.progress()
+	}
+	s.mutex.Unlock()
+	if !ok || len(parts) > 2 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if len(parts) == 1 {
+		writeJSON(w, http.StatusOK, progress)
+		return
+	}
+	switch parts[1] {
+	case "report":
+		content, err := job.report.marshal()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(content)
+	case "mapping":
+		if progress.Status != jobFinished {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "mapping is available once the job is finished"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job.mapping)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+	}
+}
+
+// prepareJob resolves submitted projects in gitlab, the whole job is rejected when any project cannot be migrated
+func (s *jobServer) prepareJob(r *http.Request) (*migrationJob, []string) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	request := jobRequest{}
+	if err := decoder.Decode(&request); err != nil {
+		return nil, []string{fmt.Sprintf("invalid job: %s", err)}
+	}
+	if len(request.Projects) == 0 {
+		return nil, []string{"job has no projects"}
+	}
+	var errors []string
+	for i := range request.Projects {
+		project := &request.Projects[i]
+		if project.GitlabInstance != "" {
+			errors = append(errors, fmt.Sprintf("project #%d: gitlabInstance is not supported by the API", i+1))
+			continue
+		}
+		if !*serveAllowOverrides && (project.PostAction != "" || project.ServiceEndpointID != "") {
+			errors = append(errors, fmt.Sprintf("project #%d: postAction and serviceEndpointId are accepted only when the server allows job overrides", i+1))
+			continue
+		}
+		if err := resolveProject(s.defaultGitlab, nil, project); err != nil {
+			errors = append(errors, fmt.Sprintf("project #%d: %s", i+1, redactor.redact(err.Error())))
+		}
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return &migrationJob{
+		ID:        uuid.New().String(),
+		Status:    jobQueued,
+		Submitted: time.Now(),
+		Projects:  len(request.Projects),
+		projects:  request.Projects,
+		report:    &runReport{},
+	}, nil
+}
+
+// work runs queued jobs one by one, every job collects problems into its own report
+func (s *jobServer) work() {
+	for job := range s.queue {
+		s.mutex.Lock()
+		started := time.Now()
+		job.Status, job.Started = jobRunning, &started
+		s.mutex.Unlock()
+
+		log.Infof("job %s started", job.ID)
+		report = job.report
+		//serve runs without --sample, nothing stops the job
+		mapping, _ := migrateProjects(s.azdoCtx, s.azdoConnection, s.azdoClient, job.projects)
+		report.summarize()
+
+		s.mutex.Lock()
+		finished := time.Now()
+		job.Status, job.Finished, job.mapping = jobFinished, &finished, mapping
+		s.mutex.Unlock()
+		log.Infof("job %s finished", job.ID)
+	}
+}
+
+// progress copies public fields of the job with counts taken from its report, the caller holds the server lock
+func (j *migrationJob) progress() migrationJob {
+	progress := migrationJob{
+		ID:        j.ID,
+		Status:    j.Status,
+		Submitted: j.Submitted,
+		Started:   j.Started,
+		Finished:  j.Finished,
+		Projects:  j.Projects,
+	}
+	j.report.mutex.Lock()
+	defer j.report.mutex.Unlock()
+	for _, project := range j.report.Projects {
+		progress.Processed++
+		project.mutex.Lock()
+		if project.Failed {
+			progress.Failed++
+		}
+		project.mutex.Unlock()
+	}
+	return progress
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Warnf("cannot write API response: %s", err)
+	}
+}
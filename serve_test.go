@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJobServer(t *testing.T) {
+	*serveToken = "api-secret"
+	defer func() { *serveToken = "" }()
+	job := &migrationJob{ID: "1", Status: jobRunning, Projects: 2, report: &runReport{}}
+	job.report.project("group/app", "Apps").fail()
+	server := &jobServer{jobs: map[string]*migrationJob{"1": job}, order: []*migrationJob{job}}
+	api := httptest.NewServer(server.handler())
+	defer api.Close()
+
+	requests := []struct {
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		expect string
+	}{
+		{http.MethodGet, "/jobs", "", "", http.StatusUnauthorized, `{"error":"missing or invalid bearer token"}`},
+		{http.MethodGet, "/jobs", "api-secret", "", http.StatusOK, `[{"id":"1","status":"running","submitted":"0001-01-01T00:00:00Z","projects":2,"processed":1,"failed":1}]`},
+		{http.MethodGet, "/jobs/1/mapping", "api-secret", "", http.StatusConflict, `{"error":"mapping is available once the job is finished"}`},
+		{http.MethodGet, "/jobs/2", "api-secret", "", http.StatusNotFound, `{"error":"job not found"}`},
+		{http.MethodPost, "/jobs", "api-secret", `{"include": ["secrets.json"]}`, http.StatusBadRequest, `{"errors":["invalid job: json: unknown field \"include\""]}`},
+		{http.MethodPost, "/jobs", "api-secret", `{"projects": []}`, http.StatusBadRequest, `{"errors":["job has no projects"]}`},
+		{http.MethodPost, "/jobs", "api-secret", `{"projects": [{"gitlabProject": "group/app", "gitlabInstance": "other"}]}`, http.StatusBadRequest, `{"errors":["project #1: gitlabInstance is not supported by the API"]}`},
+		{http.MethodPost, "/jobs", "api-secret", `{"projects": [{"gitlabProject": "group/app", "postAction": "archive"}]}`, http.StatusBadRequest, `{"errors":["project #1: postAction and serviceEndpointId are accepted only when the server allows job overrides"]}`},
+	}
+	for _, r := range requests {
+		request, err := http.NewRequest(r.method, api.URL+r.path, strings.NewReader(r.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.token != "" {
+			request.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if diff := deep.Equal([]interface{}{response.StatusCode, strings.TrimSpace(string(body))}, []interface{}{r.status, r.expect}); diff != nil {
+			t.Errorf("%s %s: %+v", r.method, r.path, diff)
+		}
+	}
+}
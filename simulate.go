@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// simulationProject is the AzDO project simulated pull requests are created in
+const simulationProject = "simulation"
+
+var (
+	simulateRecordings           = kingpin.Flag("simulate", "Run the pull request pipeline with the flags of the run - merge requests with their comments and the second pass linking merge request references (--fixup-links) - over merge requests recorded in the directory (gitlab-merge-request-<iid>.json and gitlab-discussions-<iid>.json as attached by --attach-original, one project per directory) into memory and print pull requests and threads which would be created, gitlab and AzDO are not contacted so no tokens are needed").String()
+	simulateOutput     io.Writer = os.Stdout
+	recordingName                = regexp.MustCompile(`^gitlab-(merge-request|discussions)-(\d+)\.json$`)
+)
+
+// recordedSource is gitlab API of recorded merge requests, everything not recorded is missing
+type recordedSource struct {
+	mergeRequests []*gitlab.MergeRequest
+	discussions   map[int][]*gitlab.Discussion
+}
+
+func (s *recordedSource) ListProjectMergeRequests(projectID int, options *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, *gitlab.Response, error) {
+	return s.mergeRequests, &gitlab.Response{CurrentPage: 1}, nil
+}
+
+func (s *recordedSource) ListMergeRequestDiscussions(projectID int, iid int, options *gitlab.ListMergeRequestDiscussionsOptions) ([]*gitlab.Discussion, *gitlab.Response, error) {
+	return s.discussions[iid], &gitlab.Response{CurrentPage: 1}, nil
+}
+
+func (s *recordedSource) GetApprovalConfiguration(projectID int, iid int) (*gitlab.MergeRequestApprovals, error) {
+	return &gitlab.MergeRequestApprovals{}, nil
+}
+
+func (s *recordedSource) GetMergeRequestChanges(projectID int, iid int) (*gitlab.MergeRequest, error) {
+	return nil, fmt.Errorf("changes are not recorded")
+}
+
+func (s *recordedSource) GetMergeRequestDiffVersions(projectID int, iid int) ([]*gitlab.MergeRequestDiffVersion, error) {
+	return nil, nil
+}
+
+func (s *recordedSource) QueryGraphQL(query graphqlRequest) (*discussionsResponse, error) {
+	return nil, fmt.Errorf("GraphQL is not recorded")
+}
+
+func (s *recordedSource) ListMergeRequestReviewers(projectID int, iid int) ([]*gitlabReviewerState, error) {
+	return nil, nil
+}
+
+func (s *recordedSource) GetCommit(projectID int, sha string) (*gitlab.Commit, error) {
+	return nil, fmt.Errorf("commits are not recorded")
+}
+
+var _ TargetClient = (*memoryTarget)(nil)
+
+// memoryTarget is AzDO repository kept in memory, every branch exists in it and no file can be read from it
+type memoryTarget struct {
+	pullRequests []*git.GitPullRequest
+	threads      map[int][]*git.GitPullRequestCommentThread
+	attachments  map[int][]string
+}
+
+func newMemoryTarget() *memoryTarget {
+	return &memoryTarget{threads: map[int][]*git.GitPullRequestCommentThread{}, attachments: map[int][]string{}}
+}
+
+func (t *memoryTarget) GetPullRequests(ctx context.Context, args git.GetPullRequestsArgs) (*[]git.GitPullRequest, error) {
+	return &[]git.GitPullRequest{}, nil
+}
+
+func (t *memoryTarget) GetPullRequestById(ctx context.Context, args git.GetPullRequestByIdArgs) (*git.GitPullRequest, error) {
+	return t.pullRequest(*args.PullRequestId)
+}
+
+func (t *memoryTarget) GetRefs(ctx context.Context, args git.GetRefsArgs) (*git.GetRefsResponseValue, error) {
+	return &git.GetRefsResponseValue{Value: []git.GitRef{{Name: gitlab.String("refs/" + *args.Filter)}}}, nil
+}
+
+// GetItem reads no files, threads anchored to lines are not verified against the head like in AzDO
+func (t *memoryTarget) GetItem(ctx context.Context, args git.GetItemArgs) (*git.GitItem, error) {
+	return nil, fmt.Errorf("files are not simulated")
+}
+
+func (t *memoryTarget) CreatePullRequest(ctx context.Context, args git.CreatePullRequestArgs) (*git.GitPullRequest, error) {
+	pullRequest := *args.GitPullRequestToCreate
+	pullRequest.PullRequestId = gitlab.Int(len(t.pullRequests) + 1)
+	t.pullRequests = append(t.pullRequests, &pullRequest)
+	return &pullRequest, nil
+}
+
+func (t *memoryTarget) UpdatePullRequest(ctx context.Context, args git.UpdatePullRequestArgs) (*git.GitPullRequest, error) {
+	pullRequest, err := t.pullRequest(*args.PullRequestId)
+	if err != nil {
+		return nil, err
+	}
+	if status := args.GitPullRequestToUpdate.Status; status != nil {
+		pullRequest.Status = status
+	}
+	if description := args.GitPullRequestToUpdate.Description; description != nil {
+		pullRequest.Description = description
+	}
+	return pullRequest, nil
+}
+
+func (t *memoryTarget) GetPullRequestProperties(ctx context.Context, args git.GetPullRequestPropertiesArgs) (interface{}, error) {
+	return nil, nil
+}
+
+func (t *memoryTarget) UpdatePullRequestProperties(ctx context.Context, args git.UpdatePullRequestPropertiesArgs) (interface{}, error) {
+	return nil, nil
+}
+
+func (t *memoryTarget) pullRequest(id int) (*git.GitPullRequest, error) {
+	if id < 1 || id > len(t.pullRequests) {
+		return nil, fmt.Errorf("pull request %d does not exist", id)
+	}
+	return t.pullRequests[id-1], nil
+}
+
+func (t *memoryTarget) GetPullRequestLabels(ctx context.Context, args git.GetPullRequestLabelsArgs) (*[]core.WebApiTagDefinition, error) {
+	pullRequest, err := t.pullRequest(*args.PullRequestId)
+	if err != nil || pullRequest.Labels == nil {
+		return &[]core.WebApiTagDefinition{}, err
+	}
+	return pullRequest.Labels, nil
+}
+
+func (t *memoryTarget) CreatePullRequestLabel(ctx context.Context, args git.CreatePullRequestLabelArgs) (*core.WebApiTagDefinition, error) {
+	pullRequest, err := t.pullRequest(*args.PullRequestId)
+	if err != nil {
+		return nil, err
+	}
+	label := core.WebApiTagDefinition{Name: args.Label.Name}
+	labels := []core.WebApiTagDefinition{label}
+	if pullRequest.Labels != nil {
+		labels = append(*pullRequest.Labels, label)
+	}
+	pullRequest.Labels = &labels
+	return &label, nil
+}
+
+func (t *memoryTarget) CreatePullRequestReviewer(ctx context.Context, args git.CreatePullRequestReviewerArgs) (*git.IdentityRefWithVote, error) {
+	return args.Reviewer, nil
+}
+
+func (t *memoryTarget) CreateAttachment(ctx context.Context, args git.CreateAttachmentArgs) (*git.Attachment, error) {
+	t.attachments[*args.PullRequestId] = append(t.attachments[*args.PullRequestId], *args.FileName)
+	return &git.Attachment{Id: gitlab.Int(len(t.attachments[*args.PullRequestId]))}, nil
+}
+
+func (t *memoryTarget) GetThreads(ctx context.Context, args git.GetThreadsArgs) (*[]git.GitPullRequestCommentThread, error) {
+	threads := []git.GitPullRequestCommentThread{}
+	for _, thread := range t.threads[*args.PullRequestId] {
+		threads = append(threads, *thread)
+	}
+	return &threads, nil
+}
+
+func (t *memoryTarget) CreateThread(ctx context.Context, args git.CreateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	thread := *args.CommentThread
+	thread.Id = gitlab.Int(len(t.threads[*args.PullRequestId]) + 1)
+	comments := append([]git.Comment{}, *thread.Comments...)
+	for i := range comments {
+		comments[i].Id = gitlab.Int(i + 1)
+	}
+	thread.Comments = &comments
+	t.threads[*args.PullRequestId] = append(t.threads[*args.PullRequestId], &thread)
+	return &thread, nil
+}
+
+func (t *memoryTarget) UpdateThread(ctx context.Context, args git.UpdateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	for _, thread := range t.threads[*args.PullRequestId] {
+		if *thread.Id == *args.ThreadId {
+			comments := append(*thread.Comments, *args.CommentThread.Comments...)
+			thread.Comments = &comments
+			return thread, nil
+		}
+	}
+	return nil, fmt.Errorf("thread %d does not exist", *args.ThreadId)
+}
+
+func (t *memoryTarget) UpdateComment(ctx context.Context, args git.UpdateCommentArgs) (*git.Comment, error) {
+	for _, thread := range t.threads[*args.PullRequestId] {
+		if *thread.Id != *args.ThreadId {
+			continue
+		}
+		for i, comment := range *thread.Comments {
+			if *comment.Id == *args.CommentId {
+				(*thread.Comments)[i].Content = args.Comment.Content
+				return &(*thread.Comments)[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("comment %d of thread %d does not exist", *args.CommentId, *args.ThreadId)
+}
+
+// simulateMigration migrates merge requests of every directory with recordings as a project into one AzDO
+// organization in memory and prints the result once references between them are fixed up
+func simulateMigration(root string) {
+	directories, err := findRecordings(root)
+	if err != nil {
+		log.Fatalf("cannot read recordings: %s", err)
+	}
+	if len(directories) == 0 {
+		log.Fatalf("there are no recorded merge requests in %s", root)
+	}
+	target := newMemoryTarget()
+	mapping := migrationMapping{}
+	for _, directory := range directories {
+		source, err := loadRecordings(directory)
+		if err != nil {
+			log.Fatalf("cannot read recordings: %s", err)
+		}
+		name, _ := filepath.Rel(root, directory)
+		if name == "." {
+			name = filepath.Base(directory)
+		}
+		mapping.Projects = append(mapping.Projects, simulateProject(name, source, target))
+	}
+	if *fixupLinks {
+		fixupMergeRequestReferences(context.Background(), target, mapping)
+	}
+	for _, project := range mapping.Projects {
+		writeSimulation(simulateOutput, project, target)
+	}
+}
+
+// findRecordings returns directories with recorded merge requests
+func findRecordings(root string) ([]string, error) {
+	found := map[string]bool{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && recordingName.MatchString(info.Name()) {
+			found[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	var directories []string
+	for directory := range found {
+		directories = append(directories, directory)
+	}
+	sort.Strings(directories)
+	return directories, err
+}
+
+// loadRecordings reads merge requests of the directory, discussions are optional
+func loadRecordings(directory string) (*recordedSource, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	source := &recordedSource{discussions: map[int][]*gitlab.Discussion{}}
+	for _, file := range files {
+		match := recordingName.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if match[1] == "discussions" {
+			iid, _ := strconv.Atoi(match[2])
+			var discussions []*gitlab.Discussion
+			if err := json.Unmarshal(content, &discussions); err != nil {
+				return nil, fmt.Errorf("cannot parse %s: %s", file.Name(), err)
+			}
+			source.discussions[iid] = discussions
+			continue
+		}
+		mr := &gitlab.MergeRequest{}
+		if err := json.Unmarshal(content, mr); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %s", file.Name(), err)
+		}
+		source.mergeRequests = append(source.mergeRequests, mr)
+	}
+	sort.Slice(source.mergeRequests, func(i, j int) bool {
+		return source.mergeRequests[i].IID < source.mergeRequests[j].IID
+	})
+	return source, nil
+}
+
+// simulateProject runs the merge request migration of the project against memory
+func simulateProject(name string, source *recordedSource, target *memoryTarget) projectMapping {
+	gitlabProject := &gitlab.Project{PathWithNamespace: name}
+	if len(source.mergeRequests) > 0 {
+		gitlabProject.ID = source.mergeRequests[0].ProjectID
+	}
+	id := uuid.New()
+	repositoryName := filepath.Base(name)
+	repository := &git.GitRepository{
+		Id:      &id,
+		Name:    &repositoryName,
+		WebUrl:  gitlab.String(fmt.Sprintf("https://dev.azure.com/%s/_git/%s", simulationProject, repositoryName)),
+		Project: &core.TeamProjectReference{Name: gitlab.String(simulationProject)},
+	}
+	project := project{AzdoProject: simulationProject, MigrateMRs: true, gitlabProject: gitlabProject}
+	mappings, _ := importMergeRequests(context.Background(), project, &gitlabSource{api: source, project: gitlabProject}, target, gitlabProject, repository)
+	return projectMapping{
+		GitlabProjectID:    gitlabProject.ID,
+		GitlabPath:         name,
+		AzdoProject:        simulationProject,
+		AzdoRepositoryID:   id.String(),
+		AzdoRepositoryName: repositoryName,
+		AzdoRepositoryURL:  *repository.WebUrl,
+		MergeRequests:      mappings,
+	}
+}
+
+// writeSimulation prints pull requests of the project with their threads as they would be created in AzDO
+func writeSimulation(output io.Writer, project projectMapping, target *memoryTarget) {
+	fmt.Fprintf(output, "# %s: %d pull requests\n", project.GitlabPath, len(project.MergeRequests))
+	for _, mr := range project.MergeRequests {
+		pullRequest, err := target.pullRequest(mr.PullRequestID)
+		if err != nil {
+			continue
+		}
+		id := *pullRequest.PullRequestId
+		fmt.Fprintf(output, "\n## Pull request %d: %s\n", id, *pullRequest.Title)
+		fmt.Fprintf(output, "%s -> %s, draft: %t\n", *pullRequest.SourceRefName, *pullRequest.TargetRefName, *pullRequest.IsDraft)
+		if pullRequest.Status != nil {
+			fmt.Fprintf(output, "status: %s\n", *pullRequest.Status)
+		}
+		if pullRequest.Labels != nil {
+			var labels []string
+			for _, label := range *pullRequest.Labels {
+				labels = append(labels, *label.Name)
+			}
+			fmt.Fprintf(output, "labels: %s\n", strings.Join(labels, ", "))
+		}
+		if attachments := target.attachments[id]; len(attachments) > 0 {
+			fmt.Fprintf(output, "attachments: %s\n", strings.Join(attachments, ", "))
+		}
+		fmt.Fprintf(output, "\n%s\n", *pullRequest.Description)
+		for _, thread := range target.threads[id] {
+			fmt.Fprintf(output, "\n### Thread %d (%s)%s\n", *thread.Id, describeThreadStatus(thread), describeThreadContext(thread.ThreadContext))
+			for _, comment := range *thread.Comments {
+				fmt.Fprintf(output, "\n%s\n", *comment.Content)
+			}
+		}
+	}
+	fmt.Fprintln(output)
+}
+
+func describeThreadStatus(thread *git.GitPullRequestCommentThread) string {
+	if thread.Status == nil {
+		return "active"
+	}
+	return string(*thread.Status)
+}
+
+// describeThreadContext is the file and lines the thread is anchored to
+func describeThreadContext(threadContext *git.CommentThreadContext) string {
+	switch {
+	case threadContext == nil:
+		return ""
+	case threadContext.RightFileStart != nil:
+		return fmt.Sprintf(" on %s:%d-%d", *threadContext.FilePath, *threadContext.RightFileStart.Line, *threadContext.RightFileEnd.Line)
+	case threadContext.LeftFileStart != nil:
+		return fmt.Sprintf(" on removed %s:%d", *threadContext.FilePath, *threadContext.LeftFileStart.Line)
+	default:
+		return " on " + *threadContext.FilePath
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-test/deep"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSimulateMigration(t *testing.T) {
+	root := t.TempDir()
+	mr, note := setupOpenMergeRequest(), setupSingleNote()
+	mr.IID, mr.ProjectID = 1, 5
+	note.Body = "Same as !1"
+	*fixupLinks = true
+	defer func() { *fixupLinks = false }()
+	recordings := map[string]interface{}{
+		"gitlab-merge-request-1.json": mr,
+		"gitlab-discussions-1.json":   []*Discussion{{Notes: []*Note{&note}}},
+		"notes.txt":                   "not a recording",
+	}
+	for name, recording := range recordings {
+		content, _ := json.Marshal(recording)
+		if err := ioutil.WriteFile(filepath.Join(root, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := &bytes.Buffer{}
+	simulateOutput = output
+	simulateMigration(root)
+
+	var headings []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if strings.HasPrefix(line, "#") {
+			headings = append(headings, line)
+		}
+	}
+	expected := []string{
+		"# " + filepath.Base(root) + ": 1 pull requests",
+		"## Pull request 1: Foo",
+		"### Thread 1 (active)",
+	}
+	if diff := deep.Equal(headings, expected); diff != nil {
+		t.Error(diff)
+	}
+	if !strings.Contains(output.String(), "refs/heads/develop -> refs/heads/master, draft: true") {
+		t.Errorf("branches are not printed: %s", output.String())
+	}
+	link := "Same as [!1](https://dev.azure.com/simulation/_git/" + filepath.Base(root) + "/pullrequest/1)"
+	if !strings.Contains(output.String(), link) {
+		t.Errorf("references are not fixed up: %s", output.String())
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"time"
+)
+
+//go:generate mockgen -source=source.go -destination=source_mock_test.go -package=main -self_package=github.com/drmaxgit/drmax-gitlab-azdo-migration
+
+// SourceClient is the forge a project's merge requests are migrated from, it is scoped to the project and speaks the
+// model below so that translation and import of merge requests do not depend on gitlab or GitHub API. Features
+// a forge does not have are empty rather than errors, e.g. GitHub lists no approvers as reviews are comments there
+type SourceClient interface {
+	// ListMergeRequests returns all merge requests of the project by IID
+	ListMergeRequests() ([]*MergeRequest, error)
+	// ListDiscussions returns all discussions of the merge request, none are returned unless all were fetched
+	ListDiscussions(mr *MergeRequest) ([]*Discussion, error)
+	// PrefetchDiscussions returns discussions of the batch of merge requests by IID, those left out are listed one by
+	// one
+	PrefetchDiscussions(mergeRequests []*MergeRequest) map[int][]*Discussion
+	ListApprovers(mr *MergeRequest) ([]*User, error)
+	// ListChangesRequesters returns reviewers whose latest review of the merge request requests changes
+	ListChangesRequesters(mr *MergeRequest) ([]*User, error)
+	// ListChanges returns files changed by the merge request, nil when the source does not know all of them
+	ListChanges(mr *MergeRequest) ([]FileChange, error)
+	// ListVersionHeads returns distinct head commits of the merge request diff versions from the oldest one
+	ListVersionHeads(mr *MergeRequest) ([]string, error)
+	// HeadRef is the ref the source keeps head of the merge request in, even when its source branch is gone
+	HeadRef(mr *MergeRequest) string
+	// IssuePath is the path of issues under web URL of a project, merge request descriptions close them
+	IssuePath() string
+	// GitCredentials are user and password git fetches the repository with
+	GitCredentials() (string, string)
+	// GetMergeDetails returns how the merged merge request was merged, details known without the commits are returned
+	// along with the error
+	GetMergeDetails(mr *MergeRequest) (*MergeDetails, error)
+}
+
+// TargetClient is the part of AzDO git API pull requests are created and fixed up by, AzDO git.Client implements it
+// and tests or the simulation provide their own
+type TargetClient interface {
+	GetRefs(context.Context, git.GetRefsArgs) (*git.GetRefsResponseValue, error)
+	GetItem(context.Context, git.GetItemArgs) (*git.GitItem, error)
+	GetPullRequests(context.Context, git.GetPullRequestsArgs) (*[]git.GitPullRequest, error)
+	GetPullRequestById(context.Context, git.GetPullRequestByIdArgs) (*git.GitPullRequest, error)
+	CreatePullRequest(context.Context, git.CreatePullRequestArgs) (*git.GitPullRequest, error)
+	GetPullRequestProperties(context.Context, git.GetPullRequestPropertiesArgs) (interface{}, error)
+	UpdatePullRequest(context.Context, git.UpdatePullRequestArgs) (*git.GitPullRequest, error)
+	UpdatePullRequestProperties(context.Context, git.UpdatePullRequestPropertiesArgs) (interface{}, error)
+	GetPullRequestLabels(context.Context, git.GetPullRequestLabelsArgs) (*[]core.WebApiTagDefinition, error)
+	CreatePullRequestLabel(context.Context, git.CreatePullRequestLabelArgs) (*core.WebApiTagDefinition, error)
+	CreatePullRequestReviewer(context.Context, git.CreatePullRequestReviewerArgs) (*git.IdentityRefWithVote, error)
+	CreateAttachment(context.Context, git.CreateAttachmentArgs) (*git.Attachment, error)
+	GetThreads(context.Context, git.GetThreadsArgs) (*[]git.GitPullRequestCommentThread, error)
+	CreateThread(context.Context, git.CreateThreadArgs) (*git.GitPullRequestCommentThread, error)
+	UpdateThread(context.Context, git.UpdateThreadArgs) (*git.GitPullRequestCommentThread, error)
+	UpdateComment(context.Context, git.UpdateCommentArgs) (*git.Comment, error)
+}
+
+var (
+	_ SourceClient = (*gitlabSource)(nil)
+	_ SourceClient = (*githubSource)(nil)
+	_ TargetClient = git.Client(nil)
+)
+
+// User is an author, reviewer or resolver in the source
+type User struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	WebURL    string `json:"web_url"`
+}
+
+// MergeRequest is gitlab merge request or GitHub pull request
+type MergeRequest struct {
+	IID             int    `json:"iid"`
+	ProjectID       int    `json:"project_id"`
+	SourceProjectID int    `json:"source_project_id"`
+	TargetProjectID int    `json:"target_project_id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	State           string `json:"state"`
+	WorkInProgress  bool   `json:"work_in_progress"`
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	SHA             string `json:"sha"`
+	MergeCommitSHA  string `json:"merge_commit_sha"`
+	SquashCommitSHA string `json:"squash_commit_sha"`
+	// DiffHeadSHA is head of the latest diff, notes written on another head are outdated
+	DiffHeadSHA          string                `json:"diff_head_sha"`
+	WebURL               string                `json:"web_url"`
+	Labels               []string              `json:"labels"`
+	CreatedAt            *time.Time            `json:"created_at"`
+	UpdatedAt            *time.Time            `json:"updated_at"`
+	MergedAt             *time.Time            `json:"merged_at"`
+	MergedBy             *User                 `json:"merged_by"`
+	Author               *User                 `json:"author"`
+	Assignees            []*User               `json:"assignees"`
+	Reviewers            []*User               `json:"reviewers"`
+	UserNotesCount       int                   `json:"user_notes_count"`
+	TaskCompletionStatus *TaskCompletionStatus `json:"task_completion_status"`
+	// Original is the merge request as the source API returned it, --attach-original keeps it
+	Original interface{} `json:"-"`
+}
+
+// TaskCompletionStatus counts tasks of the description as the source does, unknown status is nil
+type TaskCompletionStatus struct {
+	Count          int `json:"count"`
+	CompletedCount int `json:"completed_count"`
+}
+
+// Discussion is a thread of notes, a plain comment is a discussion of a single note
+type Discussion struct {
+	ID    string  `json:"id"`
+	Notes []*Note `json:"notes"`
+	// Original is the discussion as the source API returned it
+	Original interface{} `json:"-"`
+}
+
+// Note is a comment or a system note of a discussion
+type Note struct {
+	ID         int           `json:"id"`
+	Body       string        `json:"body"`
+	Author     User          `json:"author"`
+	System     bool          `json:"system"`
+	CreatedAt  *time.Time    `json:"created_at"`
+	UpdatedAt  *time.Time    `json:"updated_at"`
+	Position   *NotePosition `json:"position"`
+	Resolvable bool          `json:"resolvable"`
+	Resolved   bool          `json:"resolved"`
+	ResolvedBy User          `json:"resolved_by"`
+	// WebURL links the note in the source, notes without it are linked as gitlab notes of the merge request
+	WebURL string `json:"web_url"`
+}
+
+// NotePosition is where in the diff a note was written
+type NotePosition struct {
+	HeadSHA   string     `json:"head_sha"`
+	NewPath   string     `json:"new_path"`
+	NewLine   int        `json:"new_line"`
+	OldPath   string     `json:"old_path"`
+	OldLine   int        `json:"old_line"`
+	LineRange *LineRange `json:"line_range"`
+}
+
+// LineRange are lines a multiline comment spans
+type LineRange struct {
+	StartRange *LinePosition `json:"start"`
+	EndRange   *LinePosition `json:"end"`
+}
+
+// LinePosition is a line of the diff, removed lines have only the old line
+type LinePosition struct {
+	OldLine int `json:"old_line"`
+	NewLine int `json:"new_line"`
+}
+
+// MergeDetails is who merged the merge request, when and by which commits
+type MergeDetails struct {
+	MergedBy *User
+	MergedAt *time.Time
+	// Commits are merge and squash commits of the merge request, fast-forward merges have none
+	Commits []MergeCommit
+}
+
+// MergeCommit is a commit the merge request was merged by
+type MergeCommit struct {
+	SHA     string
+	Message string
+	Squash  bool
+}
+
+// FileChange is a file changed by the merge request
+type FileChange struct {
+	OldPath string
+	NewPath string
+	Renamed bool
+	Deleted bool
+}
@@ -0,0 +1,462 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: source.go
+
+// Package main is a generated GoMock package.
+package main
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	core "github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	git "github.com/microsoft/azure-devops-go-api/azuredevops/git"
+)
+
+// MockSourceClient is a mock of SourceClient interface.
+type MockSourceClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockSourceClientMockRecorder
+}
+
+// MockSourceClientMockRecorder is the mock recorder for MockSourceClient.
+type MockSourceClientMockRecorder struct {
+	mock *MockSourceClient
+}
+
+// NewMockSourceClient creates a new mock instance.
+func NewMockSourceClient(ctrl *gomock.Controller) *MockSourceClient {
+	mock := &MockSourceClient{ctrl: ctrl}
+	mock.recorder = &MockSourceClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSourceClient) EXPECT() *MockSourceClientMockRecorder {
+	return m.recorder
+}
+
+// GetMergeDetails mocks base method.
+func (m *MockSourceClient) GetMergeDetails(mr *MergeRequest) (*MergeDetails, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMergeDetails", mr)
+	ret0, _ := ret[0].(*MergeDetails)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMergeDetails indicates an expected call of GetMergeDetails.
+func (mr_2 *MockSourceClientMockRecorder) GetMergeDetails(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "GetMergeDetails", reflect.TypeOf((*MockSourceClient)(nil).GetMergeDetails), mr)
+}
+
+// GitCredentials mocks base method.
+func (m *MockSourceClient) GitCredentials() (string, string) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GitCredentials")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	return ret0, ret1
+}
+
+// GitCredentials indicates an expected call of GitCredentials.
+func (mr *MockSourceClientMockRecorder) GitCredentials() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GitCredentials", reflect.TypeOf((*MockSourceClient)(nil).GitCredentials))
+}
+
+// HeadRef mocks base method.
+func (m *MockSourceClient) HeadRef(mr *MergeRequest) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HeadRef", mr)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// HeadRef indicates an expected call of HeadRef.
+func (mr_2 *MockSourceClientMockRecorder) HeadRef(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "HeadRef", reflect.TypeOf((*MockSourceClient)(nil).HeadRef), mr)
+}
+
+// IssuePath mocks base method.
+func (m *MockSourceClient) IssuePath() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssuePath")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// IssuePath indicates an expected call of IssuePath.
+func (mr *MockSourceClientMockRecorder) IssuePath() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssuePath", reflect.TypeOf((*MockSourceClient)(nil).IssuePath))
+}
+
+// ListApprovers mocks base method.
+func (m *MockSourceClient) ListApprovers(mr *MergeRequest) ([]*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListApprovers", mr)
+	ret0, _ := ret[0].([]*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListApprovers indicates an expected call of ListApprovers.
+func (mr_2 *MockSourceClientMockRecorder) ListApprovers(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "ListApprovers", reflect.TypeOf((*MockSourceClient)(nil).ListApprovers), mr)
+}
+
+// ListChanges mocks base method.
+func (m *MockSourceClient) ListChanges(mr *MergeRequest) ([]FileChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListChanges", mr)
+	ret0, _ := ret[0].([]FileChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListChanges indicates an expected call of ListChanges.
+func (mr_2 *MockSourceClientMockRecorder) ListChanges(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "ListChanges", reflect.TypeOf((*MockSourceClient)(nil).ListChanges), mr)
+}
+
+// ListChangesRequesters mocks base method.
+func (m *MockSourceClient) ListChangesRequesters(mr *MergeRequest) ([]*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListChangesRequesters", mr)
+	ret0, _ := ret[0].([]*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListChangesRequesters indicates an expected call of ListChangesRequesters.
+func (mr_2 *MockSourceClientMockRecorder) ListChangesRequesters(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "ListChangesRequesters", reflect.TypeOf((*MockSourceClient)(nil).ListChangesRequesters), mr)
+}
+
+// ListDiscussions mocks base method.
+func (m *MockSourceClient) ListDiscussions(mr *MergeRequest) ([]*Discussion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDiscussions", mr)
+	ret0, _ := ret[0].([]*Discussion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDiscussions indicates an expected call of ListDiscussions.
+func (mr_2 *MockSourceClientMockRecorder) ListDiscussions(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "ListDiscussions", reflect.TypeOf((*MockSourceClient)(nil).ListDiscussions), mr)
+}
+
+// ListMergeRequests mocks base method.
+func (m *MockSourceClient) ListMergeRequests() ([]*MergeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListMergeRequests")
+	ret0, _ := ret[0].([]*MergeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListMergeRequests indicates an expected call of ListMergeRequests.
+func (mr *MockSourceClientMockRecorder) ListMergeRequests() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMergeRequests", reflect.TypeOf((*MockSourceClient)(nil).ListMergeRequests))
+}
+
+// ListVersionHeads mocks base method.
+func (m *MockSourceClient) ListVersionHeads(mr *MergeRequest) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVersionHeads", mr)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVersionHeads indicates an expected call of ListVersionHeads.
+func (mr_2 *MockSourceClientMockRecorder) ListVersionHeads(mr interface{}) *gomock.Call {
+	mr_2.mock.ctrl.T.Helper()
+	return mr_2.mock.ctrl.RecordCallWithMethodType(mr_2.mock, "ListVersionHeads", reflect.TypeOf((*MockSourceClient)(nil).ListVersionHeads), mr)
+}
+
+// PrefetchDiscussions mocks base method.
+func (m *MockSourceClient) PrefetchDiscussions(mergeRequests []*MergeRequest) map[int][]*Discussion {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrefetchDiscussions", mergeRequests)
+	ret0, _ := ret[0].(map[int][]*Discussion)
+	return ret0
+}
+
+// PrefetchDiscussions indicates an expected call of PrefetchDiscussions.
+func (mr *MockSourceClientMockRecorder) PrefetchDiscussions(mergeRequests interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrefetchDiscussions", reflect.TypeOf((*MockSourceClient)(nil).PrefetchDiscussions), mergeRequests)
+}
+
+// MockTargetClient is a mock of TargetClient interface.
+type MockTargetClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockTargetClientMockRecorder
+}
+
+// MockTargetClientMockRecorder is the mock recorder for MockTargetClient.
+type MockTargetClientMockRecorder struct {
+	mock *MockTargetClient
+}
+
+// NewMockTargetClient creates a new mock instance.
+func NewMockTargetClient(ctrl *gomock.Controller) *MockTargetClient {
+	mock := &MockTargetClient{ctrl: ctrl}
+	mock.recorder = &MockTargetClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTargetClient) EXPECT() *MockTargetClientMockRecorder {
+	return m.recorder
+}
+
+// CreateAttachment mocks base method.
+func (m *MockTargetClient) CreateAttachment(arg0 context.Context, arg1 git.CreateAttachmentArgs) (*git.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAttachment", arg0, arg1)
+	ret0, _ := ret[0].(*git.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAttachment indicates an expected call of CreateAttachment.
+func (mr *MockTargetClientMockRecorder) CreateAttachment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAttachment", reflect.TypeOf((*MockTargetClient)(nil).CreateAttachment), arg0, arg1)
+}
+
+// CreatePullRequest mocks base method.
+func (m *MockTargetClient) CreatePullRequest(arg0 context.Context, arg1 git.CreatePullRequestArgs) (*git.GitPullRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePullRequest", arg0, arg1)
+	ret0, _ := ret[0].(*git.GitPullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePullRequest indicates an expected call of CreatePullRequest.
+func (mr *MockTargetClientMockRecorder) CreatePullRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePullRequest", reflect.TypeOf((*MockTargetClient)(nil).CreatePullRequest), arg0, arg1)
+}
+
+// CreatePullRequestLabel mocks base method.
+func (m *MockTargetClient) CreatePullRequestLabel(arg0 context.Context, arg1 git.CreatePullRequestLabelArgs) (*core.WebApiTagDefinition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePullRequestLabel", arg0, arg1)
+	ret0, _ := ret[0].(*core.WebApiTagDefinition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePullRequestLabel indicates an expected call of CreatePullRequestLabel.
+func (mr *MockTargetClientMockRecorder) CreatePullRequestLabel(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePullRequestLabel", reflect.TypeOf((*MockTargetClient)(nil).CreatePullRequestLabel), arg0, arg1)
+}
+
+// CreatePullRequestReviewer mocks base method.
+func (m *MockTargetClient) CreatePullRequestReviewer(arg0 context.Context, arg1 git.CreatePullRequestReviewerArgs) (*git.IdentityRefWithVote, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePullRequestReviewer", arg0, arg1)
+	ret0, _ := ret[0].(*git.IdentityRefWithVote)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePullRequestReviewer indicates an expected call of CreatePullRequestReviewer.
+func (mr *MockTargetClientMockRecorder) CreatePullRequestReviewer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePullRequestReviewer", reflect.TypeOf((*MockTargetClient)(nil).CreatePullRequestReviewer), arg0, arg1)
+}
+
+// CreateThread mocks base method.
+func (m *MockTargetClient) CreateThread(arg0 context.Context, arg1 git.CreateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateThread", arg0, arg1)
+	ret0, _ := ret[0].(*git.GitPullRequestCommentThread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateThread indicates an expected call of CreateThread.
+func (mr *MockTargetClientMockRecorder) CreateThread(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateThread", reflect.TypeOf((*MockTargetClient)(nil).CreateThread), arg0, arg1)
+}
+
+// GetItem mocks base method.
+func (m *MockTargetClient) GetItem(arg0 context.Context, arg1 git.GetItemArgs) (*git.GitItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItem", arg0, arg1)
+	ret0, _ := ret[0].(*git.GitItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItem indicates an expected call of GetItem.
+func (mr *MockTargetClientMockRecorder) GetItem(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItem", reflect.TypeOf((*MockTargetClient)(nil).GetItem), arg0, arg1)
+}
+
+// GetPullRequestById mocks base method.
+func (m *MockTargetClient) GetPullRequestById(arg0 context.Context, arg1 git.GetPullRequestByIdArgs) (*git.GitPullRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPullRequestById", arg0, arg1)
+	ret0, _ := ret[0].(*git.GitPullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPullRequestById indicates an expected call of GetPullRequestById.
+func (mr *MockTargetClientMockRecorder) GetPullRequestById(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequestById", reflect.TypeOf((*MockTargetClient)(nil).GetPullRequestById), arg0, arg1)
+}
+
+// GetPullRequestLabels mocks base method.
+func (m *MockTargetClient) GetPullRequestLabels(arg0 context.Context, arg1 git.GetPullRequestLabelsArgs) (*[]core.WebApiTagDefinition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPullRequestLabels", arg0, arg1)
+	ret0, _ := ret[0].(*[]core.WebApiTagDefinition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPullRequestLabels indicates an expected call of GetPullRequestLabels.
+func (mr *MockTargetClientMockRecorder) GetPullRequestLabels(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequestLabels", reflect.TypeOf((*MockTargetClient)(nil).GetPullRequestLabels), arg0, arg1)
+}
+
+// GetPullRequestProperties mocks base method.
+func (m *MockTargetClient) GetPullRequestProperties(arg0 context.Context, arg1 git.GetPullRequestPropertiesArgs) (interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPullRequestProperties", arg0, arg1)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPullRequestProperties indicates an expected call of GetPullRequestProperties.
+func (mr *MockTargetClientMockRecorder) GetPullRequestProperties(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequestProperties", reflect.TypeOf((*MockTargetClient)(nil).GetPullRequestProperties), arg0, arg1)
+}
+
+// GetPullRequests mocks base method.
+func (m *MockTargetClient) GetPullRequests(arg0 context.Context, arg1 git.GetPullRequestsArgs) (*[]git.GitPullRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPullRequests", arg0, arg1)
+	ret0, _ := ret[0].(*[]git.GitPullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPullRequests indicates an expected call of GetPullRequests.
+func (mr *MockTargetClientMockRecorder) GetPullRequests(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequests", reflect.TypeOf((*MockTargetClient)(nil).GetPullRequests), arg0, arg1)
+}
+
+// GetRefs mocks base method.
+func (m *MockTargetClient) GetRefs(arg0 context.Context, arg1 git.GetRefsArgs) (*git.GetRefsResponseValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefs", arg0, arg1)
+	ret0, _ := ret[0].(*git.GetRefsResponseValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefs indicates an expected call of GetRefs.
+func (mr *MockTargetClientMockRecorder) GetRefs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefs", reflect.TypeOf((*MockTargetClient)(nil).GetRefs), arg0, arg1)
+}
+
+// GetThreads mocks base method.
+func (m *MockTargetClient) GetThreads(arg0 context.Context, arg1 git.GetThreadsArgs) (*[]git.GitPullRequestCommentThread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreads", arg0, arg1)
+	ret0, _ := ret[0].(*[]git.GitPullRequestCommentThread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThreads indicates an expected call of GetThreads.
+func (mr *MockTargetClientMockRecorder) GetThreads(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreads", reflect.TypeOf((*MockTargetClient)(nil).GetThreads), arg0, arg1)
+}
+
+// UpdateComment mocks base method.
+func (m *MockTargetClient) UpdateComment(arg0 context.Context, arg1 git.UpdateCommentArgs) (*git.Comment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateComment", arg0, arg1)
+	ret0, _ := ret[0].(*git.Comment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateComment indicates an expected call of UpdateComment.
+func (mr *MockTargetClientMockRecorder) UpdateComment(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateComment", reflect.TypeOf((*MockTargetClient)(nil).UpdateComment), arg0, arg1)
+}
+
+// UpdatePullRequest mocks base method.
+func (m *MockTargetClient) UpdatePullRequest(arg0 context.Context, arg1 git.UpdatePullRequestArgs) (*git.GitPullRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePullRequest", arg0, arg1)
+	ret0, _ := ret[0].(*git.GitPullRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePullRequest indicates an expected call of UpdatePullRequest.
+func (mr *MockTargetClientMockRecorder) UpdatePullRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePullRequest", reflect.TypeOf((*MockTargetClient)(nil).UpdatePullRequest), arg0, arg1)
+}
+
+// UpdatePullRequestProperties mocks base method.
+func (m *MockTargetClient) UpdatePullRequestProperties(arg0 context.Context, arg1 git.UpdatePullRequestPropertiesArgs) (interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePullRequestProperties", arg0, arg1)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePullRequestProperties indicates an expected call of UpdatePullRequestProperties.
+func (mr *MockTargetClientMockRecorder) UpdatePullRequestProperties(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePullRequestProperties", reflect.TypeOf((*MockTargetClient)(nil).UpdatePullRequestProperties), arg0, arg1)
+}
+
+// UpdateThread mocks base method.
+func (m *MockTargetClient) UpdateThread(arg0 context.Context, arg1 git.UpdateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateThread", arg0, arg1)
+	ret0, _ := ret[0].(*git.GitPullRequestCommentThread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateThread indicates an expected call of UpdateThread.
+func (mr *MockTargetClientMockRecorder) UpdateThread(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateThread", reflect.TypeOf((*MockTargetClient)(nil).UpdateThread), arg0, arg1)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"github.com/go-test/deep"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+// stubTarget is a repository with the branches and pull requests migrated already, created pull requests and
+// threads are recorded
+type stubTarget struct {
+	TargetClient
+	branches     []string
+	pullRequests []git.GitPullRequest
+	created      []string
+	threads      map[int][]string
+	statuses     map[int]git.PullRequestStatus
+}
+
+func (c *stubTarget) GetPullRequests(ctx context.Context, args git.GetPullRequestsArgs) (*[]git.GitPullRequest, error) {
+	return &c.pullRequests, nil
+}
+
+func (c *stubTarget) GetRefs(ctx context.Context, args git.GetRefsArgs) (*git.GetRefsResponseValue, error) {
+	var refs []git.GitRef
+	for _, branch := range c.branches {
+		refs = append(refs, git.GitRef{Name: gitlab.String("refs/heads/" + branch)})
+	}
+	return &git.GetRefsResponseValue{Value: refs}, nil
+}
+
+func (c *stubTarget) CreatePullRequest(ctx context.Context, args git.CreatePullRequestArgs) (*git.GitPullRequest, error) {
+	c.created = append(c.created, *args.GitPullRequestToCreate.Title)
+	pullRequest := *args.GitPullRequestToCreate
+	pullRequest.PullRequestId = gitlab.Int(len(c.created))
+	return &pullRequest, nil
+}
+
+func (c *stubTarget) UpdatePullRequestProperties(ctx context.Context, args git.UpdatePullRequestPropertiesArgs) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *stubTarget) UpdatePullRequest(ctx context.Context, args git.UpdatePullRequestArgs) (*git.GitPullRequest, error) {
+	c.statuses[*args.PullRequestId] = *args.GitPullRequestToUpdate.Status
+	return args.GitPullRequestToUpdate, nil
+}
+
+func (c *stubTarget) CreateThread(ctx context.Context, args git.CreateThreadArgs) (*git.GitPullRequestCommentThread, error) {
+	var contents []string
+	for _, comment := range *args.CommentThread.Comments {
+		contents = append(contents, *comment.Content)
+	}
+	c.threads[*args.PullRequestId] = append(c.threads[*args.PullRequestId], contents...)
+	id := len(c.threads[*args.PullRequestId])
+	comments := *args.CommentThread.Comments
+	for i := range comments {
+		comments[i].Id = gitlab.Int(i + 1)
+	}
+	return &git.GitPullRequestCommentThread{Id: &id, Comments: &comments}, nil
+}
+
+func TestImportMergeRequests(t *testing.T) {
+	migrated, open, closed, note := setupOpenMergeRequest(), setupOpenMergeRequest(), setupClosedMergeRequest(), setupSingleNote()
+	migrated.IID, open.IID, closed.IID = 1, 2, 3
+	open.Title = "Bar"
+	open.WebURL = "https://gitlab.com/gitlab-examples/php/-/merge_requests/2"
+	closed.WebURL = "https://gitlab.com/gitlab-examples/php/-/merge_requests/3"
+	*reviewStates = true
+	defer func() { *reviewStates = false }()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	source := NewMockSourceClient(ctrl)
+	source.EXPECT().ListMergeRequests().Return([]*MergeRequest{&migrated, &open, &closed}, nil)
+	source.EXPECT().PrefetchDiscussions(gomock.Any()).Return(nil)
+	source.EXPECT().ListApprovers(&open).Return(nil, nil)
+	source.EXPECT().ListChangesRequesters(&open).Return(nil, nil)
+	source.EXPECT().ListChanges(&open).Return(nil, nil)
+	source.EXPECT().ListDiscussions(&open).Return([]*Discussion{{Notes: []*Note{&note}}}, nil)
+	target := &stubTarget{
+		branches:     []string{"develop", "master"},
+		pullRequests: []git.GitPullRequest{{PullRequestId: gitlab.Int(7), Description: gitlab.String(preparePullRequestDescription(&migrated))}},
+		threads:      map[int][]string{},
+	}
+	id := uuid.New()
+	repository := &git.GitRepository{
+		Id:      &id,
+		Name:    gitlab.String("php"),
+		WebUrl:  gitlab.String("https://dev.azure.com/org/Apps/_git/php"),
+		Project: &core.TeamProjectReference{Name: gitlab.String("Apps")},
+	}
+	project := project{AzdoProject: "Apps", gitlabProject: &gitlab.Project{ID: 1}}
+
+	mappings, err := importMergeRequests(context.Background(), project, source, target, project.gitlabProject, repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []interface{}
+	for _, mapping := range mappings {
+		actual = append(actual, mapping.IID, mapping.PullRequestID, len(mapping.Notes))
+	}
+	expected := []interface{}{1, 7, 0, 2, 1, 1}
+	if diff := deep.Equal([]interface{}{actual, target.created, len(target.threads[1])}, []interface{}{expected, []string{"Bar"}, 1}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+	"strings"
+)
+
+// extractSubdirectory rewrites history of the local repository so that the configured subdirectory becomes its root,
+// commits not touching the subdirectory are dropped the way git filter-repo --subdirectory-filter does it
+func extractSubdirectory(repository *localRepository, project project) error {
+	if project.Subdirectory == "" {
+		return nil
+	}
+	subdirectory := strings.Trim(project.Subdirectory, "/")
+	log.Infof("extracting %s of %s into repository %s", subdirectory, project.gitlabProject.PathWithNamespace, project.azdoRepositoryName())
+	return repository.rewriteHistory("--prune-empty", "--subdirectory-filter", subdirectory)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestAzdoRepositoryName(t *testing.T) {
+	gitlabProject := &gitlab.Project{Path: "monorepo"}
+	cases := map[string]project{
+		"monorepo": {gitlabProject: gitlabProject},
+		"foo":      {gitlabProject: gitlabProject, Subdirectory: "services/foo/"},
+		"foo-api":  {gitlabProject: gitlabProject, Subdirectory: "services/foo", AzdoRepository: "foo-api"},
+	}
+	for expected, project := range cases {
+		if name := project.azdoRepositoryName(); name != expected {
+			t.Errorf("expected repository %s, got %s", expected, name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stripIndexFilter removes index entries of listed blobs from every commit, blob IDs are kept in a file inside of the
+// rewritten repository
+const stripIndexFilter = `git ls-files -s -z | grep -z -F -f "$GIT_DIR/strip-blobs" | cut -z -f2- | git update-index -z --force-remove --stdin`
+
+var stripBlobsLargerThan = kingpin.Flag("strip-blobs-larger-than", "Rewrite history of mirrored repositories to drop files larger than the size (e.g. 100MB), 0 keeps everything").Default("0").Bytes()
+
+type strippedBlob struct {
+	id   string
+	path string
+	size int64
+}
+
+// rewritesHistory tells whether commits pushed to AzDO differ from gitlab ones
+func rewritesHistory(project project) bool {
+	return stripsBlobs(project) || project.Subdirectory != "" || project.Prefix != "" || truncatesHistory() || migratesLFS(project)
+}
+
+// stripsBlobs tells whether any files are dropped from history of the project
+func stripsBlobs(project project) bool {
+	return mirrorsRepository(project) && (*stripBlobsLargerThan > 0 || len(project.StripPaths) > 0)
+}
+
+// stripBlobs drops large files and files matching the project patterns from the whole history of the local
+// repository - every commit is rewritten including branch heads, so stripped files are gone from the latest commit too
+// and have to be added back (e.g. to LFS) after the migration
+func stripBlobs(repository *localRepository, project project) error {
+	if !stripsBlobs(project) {
+		return nil
+	}
+	blobs, err := findStrippedBlobs(repository, int64(*stripBlobsLargerThan), project.StripPaths)
+	if err != nil || len(blobs) == 0 {
+		return err
+	}
+	ids := make([]string, 0, len(blobs))
+	var paths []string
+	for i, blob := range blobs {
+		ids = append(ids, blob.id)
+		//blobs are sorted by path, every version of a file is stripped but the file is reported once
+		if i == 0 || blobs[i-1].path != blob.path {
+			paths = append(paths, blob.path)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(repository.dir, "strip-blobs"), []byte(strings.Join(ids, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+
+	log.Infof("rewriting history of %s to strip %d files", project.gitlabProject.PathWithNamespace, len(blobs))
+	if err := repository.rewriteHistory("--index-filter", stripIndexFilter); err != nil {
+		return err
+	}
+	project.report.problem("history rewritten, stripped %d versions of files: %s", len(blobs), strings.Join(paths, ", "))
+	return nil
+}
+
+// findStrippedBlobs lists reachable blobs larger than the limit or with path matching any of the patterns
+func findStrippedBlobs(repository *localRepository, limit int64, patterns []string) ([]strippedBlob, error) {
+	matchers, err := compileRefPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := repository.run("cat-file", "--batch-all-objects", "--batch-check=%(objecttype) %(objectname) %(objectsize)")
+	if err != nil {
+		return nil, err
+	}
+	sizes := map[string]int64{}
+	for _, line := range strings.Split(objects, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "blob" {
+			continue
+		}
+		sizes[fields[1]], _ = strconv.ParseInt(fields[2], 10, 64)
+	}
+	reachable, err := repository.run("rev-list", "--objects", "--all")
+	if err != nil {
+		return nil, err
+	}
+	return selectStrippedBlobs(reachable, sizes, limit, matchers), nil
+}
+
+// selectStrippedBlobs picks blobs to strip from rev-list --objects output, lines of blobs are "<id> <path>"
+func selectStrippedBlobs(reachable string, sizes map[string]int64, limit int64, matchers []*regexp.Regexp) []strippedBlob {
+	var blobs []strippedBlob
+	for _, line := range strings.Split(reachable, "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		size, blob := sizes[parts[0]]
+		if !blob || len(parts) != 2 {
+			continue
+		}
+		if (limit > 0 && size > limit) || matchRef(matchers, parts[1]) {
+			blobs = append(blobs, strippedBlob{id: parts[0], path: parts[1], size: size})
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].path < blobs[j].path
+	})
+	return blobs
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestSelectStrippedBlobs(t *testing.T) {
+	reachable := "c1\nt1 \nb1 assets/video.mp4\nb2 README.md\nb3 dist/app.iso\nt2 dist"
+	sizes := map[string]int64{"b1": 200, "b2": 10, "b3": 50}
+	matchers, err := compileRefPatterns([]string{"*.iso"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []strippedBlob{
+		{id: "b1", path: "assets/video.mp4", size: 200},
+		{id: "b3", path: "dist/app.iso", size: 50},
+	}
+	//blobs have unexported fields only which deep does not compare
+	if diff := deep.Equal(fmt.Sprint(expect), fmt.Sprint(selectStrippedBlobs(reachable, sizes, 100, matchers))); diff != nil {
+		t.Error(diff)
+	}
+	if blobs := selectStrippedBlobs(reachable, sizes, 0, nil); blobs != nil {
+		t.Errorf("nothing should be stripped without limit and patterns, got %v", blobs)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	fixupSubmodules     = kingpin.Flag("fixup-submodules", "After all projects are migrated, rewrite .gitmodules URLs pointing to migrated gitlab projects to AzDO repositories").Default("false").Bool()
+	submoduleMappings   = kingpin.Flag("submodule-mapping", "Mapping files of earlier runs, submodules pointing to projects migrated by them are rewritten as well").Strings()
+	submoduleURLMatcher = regexp.MustCompile(`^(\s*url\s*=\s*)(\S+)\s*$`)
+	scpURLMatcher       = regexp.MustCompile(`^[\w.-]+@([\w.-]+):/?(.+)$`)
+)
+
+// repositoryURLs resolves normalized gitlab repository URL to the migrated AzDO repository URL
+type repositoryURLs map[string]string
+
+func prepareRepositoryURLs(mappings ...migrationMapping) repositoryURLs {
+	urls := repositoryURLs{}
+	for _, mapping := range mappings {
+		for _, project := range mapping.Projects {
+			urls[normalizeRepositoryURL(project.GitlabURL)] = project.AzdoRepositoryURL
+		}
+	}
+	return urls
+}
+
+// normalizeRepositoryURL reduces https, ssh and scp-like git URLs to host and path so that all of them match the web
+// URL of the project, relative URLs are returned as they are
+func normalizeRepositoryURL(raw string) string {
+	host, path := "", ""
+	if match := scpURLMatcher.FindStringSubmatch(raw); match != nil && !strings.Contains(raw, "://") {
+		host, path = match[1], match[2]
+	} else if parsed, err := url.Parse(raw); err == nil && parsed.Host != "" {
+		host, path = parsed.Hostname(), parsed.Path
+	} else {
+		return raw
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host + "/" + path)
+}
+
+// rewriteSubmoduleURLs replaces URLs of migrated projects in .gitmodules content and returns number of rewritten ones
+func rewriteSubmoduleURLs(content string, urls repositoryURLs) (string, int) {
+	lines := strings.Split(content, "\n")
+	rewritten := 0
+	for i, line := range lines {
+		match := submoduleURLMatcher.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		azdoURL, ok := urls[normalizeRepositoryURL(match[2])]
+		if !ok {
+			continue
+		}
+		lines[i] = match[1] + azdoURL
+		rewritten++
+	}
+	return strings.Join(lines, "\n"), rewritten
+}
+
+func readMapping(path string) (migrationMapping, error) {
+	mapping := migrationMapping{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return mapping, err
+	}
+	return mapping, json.Unmarshal(content, &mapping)
+}
+
+// fixupSubmoduleURLs is a second pass over migrated repositories, submodules may point to projects migrated later
+func fixupSubmoduleURLs(azdoCtx context.Context, azdoClient git.Client, mapping migrationMapping) {
+	mappings := []migrationMapping{mapping}
+	for _, path := range *submoduleMappings {
+		previous, err := readMapping(path)
+		if err != nil {
+			log.Errorf("cannot read submodule mapping %s: %s", path, err)
+			continue
+		}
+		mappings = append(mappings, previous)
+	}
+	urls := prepareRepositoryURLs(mappings...)
+	fixed := map[string]bool{}
+	for _, project := range mapping.Projects {
+		//projects combined into one repository share it
+		if fixed[project.AzdoRepositoryID] {
+			continue
+		}
+		fixed[project.AzdoRepositoryID] = true
+		if err := fixupRepositorySubmodules(azdoCtx, azdoClient, project, urls); err != nil {
+			log.Errorf("cannot fix submodules of repository %s: %s", project.AzdoRepositoryName, err)
+		}
+	}
+}
+
+func fixupRepositorySubmodules(azdoCtx context.Context, azdoClient git.Client, project projectMapping, urls repositoryURLs) error {
+	repository, err := azdoClient.GetRepository(azdoCtx, git.GetRepositoryArgs{
+		RepositoryId: &project.AzdoRepositoryID,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		return err
+	}
+	if repository.DefaultBranch == nil || *repository.DefaultBranch == "" {
+		return nil
+	}
+	branch := strings.TrimPrefix(*repository.DefaultBranch, "refs/heads/")
+	item, err := azdoClient.GetItem(azdoCtx, git.GetItemArgs{
+		RepositoryId:      &project.AzdoRepositoryID,
+		Path:              gitlab.String("/.gitmodules"),
+		Project:           &project.AzdoProject,
+		IncludeContent:    gitlab.Bool(true),
+		VersionDescriptor: &git.GitVersionDescriptor{Version: &branch, VersionType: &git.GitVersionTypeValues.Branch},
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read .gitmodules: %s", err)
+	}
+	if item.Content == nil {
+		return nil
+	}
+	content, rewritten := rewriteSubmoduleURLs(*item.Content, urls)
+	if rewritten == 0 {
+		return nil
+	}
+	head, err := azdoClient.GetBranch(azdoCtx, git.GetBranchArgs{
+		RepositoryId: &project.AzdoRepositoryID,
+		Name:         &branch,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read head of %s: %s", branch, err)
+	}
+	log.Infof("rewriting %d submodule URLs of repository %s", rewritten, project.AzdoRepositoryName)
+	push, err := azdoClient.CreatePush(azdoCtx, git.CreatePushArgs{
+		Push: &git.GitPush{
+			RefUpdates: &[]git.GitRefUpdate{{Name: repository.DefaultBranch, OldObjectId: head.Commit.CommitId}},
+			Commits: &[]git.GitCommitRef{{
+				Comment: gitlab.String("Point submodules to migrated AzDO repositories"),
+				Changes: &[]interface{}{git.GitChange{
+					ChangeType: &git.VersionControlChangeTypeValues.Edit,
+					Item:       git.GitItem{Path: gitlab.String("/.gitmodules")},
+					NewContent: &git.ItemContent{Content: &content, ContentType: &git.ItemContentTypeValues.RawText},
+				}},
+			}},
+		},
+		RepositoryId: &project.AzdoRepositoryID,
+		Project:      &project.AzdoProject,
+	})
+	if err != nil {
+		return err
+	}
+	audit.record("repository.push", project.AzdoProject, project.AzdoRepositoryID, map[string]interface{}{
+		"pushId":     *push.PushId,
+		"branch":     branch,
+		"submodules": rewritten,
+	})
+	return nil
+}
+
+func isNotFound(err error) bool {
+	switch wrapped := err.(type) {
+	case azuredevops.WrappedError:
+		return wrapped.StatusCode != nil && *wrapped.StatusCode == http.StatusNotFound
+	case *azuredevops.WrappedError:
+		return wrapped.StatusCode != nil && *wrapped.StatusCode == http.StatusNotFound
+	}
+	return false
+}
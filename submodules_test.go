@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestRewriteSubmoduleURLs(t *testing.T) {
+	urls := prepareRepositoryURLs(migrationMapping{Projects: []projectMapping{
+		{GitlabURL: "https://gitlab.com/group/lib", AzdoRepositoryURL: "https://dev.azure.com/org/project/_git/lib"},
+		{GitlabURL: "https://gitlab.example.com/tools/cli", AzdoRepositoryURL: "https://dev.azure.com/org/project/_git/cli"},
+	}})
+	content := `[submodule "lib"]
+	path = lib
+	url = https://gitlab.com/group/lib.git
+[submodule "cli"]
+	path = cli
+	url = git@gitlab.example.com:tools/cli.git
+[submodule "ssh"]
+	path = ssh
+	url = ssh://git@gitlab.com:2222/group/lib
+[submodule "other"]
+	path = other
+	url = https://github.com/other/repo.git
+[submodule "relative"]
+	path = relative
+	url = ../lib.git`
+	expected := `[submodule "lib"]
+	path = lib
+	url = https://dev.azure.com/org/project/_git/lib
+[submodule "cli"]
+	path = cli
+	url = https://dev.azure.com/org/project/_git/cli
+[submodule "ssh"]
+	path = ssh
+	url = https://dev.azure.com/org/project/_git/lib
+[submodule "other"]
+	path = other
+	url = https://github.com/other/repo.git
+[submodule "relative"]
+	path = relative
+	url = ../lib.git`
+	rewritten, count := rewriteSubmoduleURLs(content, urls)
+	if diff := deep.Equal(rewritten, expected); diff != nil {
+		t.Error(diff)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 rewritten URLs, got %d", count)
+	}
+}
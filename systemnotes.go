@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// systemNoteCategories are system notes projects can keep (systemNotes) by the first line gitlab writes them with,
+// other system notes are not migrated
+var systemNoteCategories = map[string]*regexp.Regexp{
+	"approvals": regexp.MustCompile(`^(approved|unapproved) this merge request`),
+	"merges":    regexp.MustCompile(`^(merged|enabled an automatic merge|canceled the automatic merge|aborted the automatic merge)`),
+	"labels":    regexp.MustCompile(`^(added|removed) ~.* labels?$`),
+	"commits":   regexp.MustCompile(`^added \d+ (new )?commits?`),
+}
+
+func validateSystemNotes(project project) error {
+	for _, category := range project.SystemNotes {
+		if _, ok := systemNoteCategories[category]; !ok {
+			var categories []string
+			for known := range systemNoteCategories {
+				categories = append(categories, known)
+			}
+			sort.Strings(categories)
+			return fmt.Errorf("systemNotes %s is not one of %s", category, strings.Join(categories, ", "))
+		}
+	}
+	return nil
+}
+
+// keepSystemNote tells whether the system note belongs to a category the project keeps
+func keepSystemNote(project project, note *Note) bool {
+	summary := systemNoteSummary(note)
+	for _, category := range project.SystemNotes {
+		if systemNoteCategories[category].MatchString(summary) {
+			return true
+		}
+	}
+	return false
+}
+
+// systemNoteSummary is the first line of the note, commits notes list the commits below it
+func systemNoteSummary(note *Note) string {
+	return strings.SplitN(strings.TrimSpace(note.Body), "\n", 2)[0]
+}
+
+// translateSystemNote compacts the system note into a one line closed thread, it is history rather than discussion
+func translateSystemNote(mr *MergeRequest, note *Note) *git.GitPullRequestCommentThread {
+	date := ""
+	if note.CreatedAt != nil {
+		date = " on " + note.CreatedAt.Format("2006-01-02 15:04")
+	}
+	content := fmt.Sprintf("⚙️ *%s %s%s* ([Gitlab](%s))",
+		prepareUserLink(note.Author.Username, note.Author.Name, note.Author.WebURL),
+		convertMarkdown(systemNoteSummary(note), markdownContext{projectURL: prepareProjectURL(mr)}),
+		date,
+		prepareNoteLink(note, mr),
+	)
+	return &git.GitPullRequestCommentThread{
+		PublishedDate: prepareAzdoTime(note.CreatedAt),
+		Status:        &git.CommentThreadStatusValues.Closed,
+		Comments: &[]git.Comment{{
+			Id:              gitlab.Int(1),
+			Content:         &content,
+			PublishedDate:   prepareAzdoTime(note.CreatedAt),
+			LastUpdatedDate: prepareAzdoTime(note.CreatedAt),
+			CommentType:     &git.CommentTypeValues.Text,
+		}},
+	}
+}
+
+func importSystemNote(azdoCtx context.Context, azdoClient TargetClient, mr *MergeRequest, pullRequest *git.GitPullRequest, note *Note) []noteMapping {
+	createdThread, _, err := createThread(azdoCtx, azdoClient, pullRequest, translateSystemNote(mr, note), nil)
+	if err != nil {
+		log.Errorf("cannot create thread of system note (%s): %s", prepareNoteLink(note, mr), err)
+		recordResult(threadsMetric, "thread", false)
+		return nil
+	}
+	recordResult(threadsMetric, "thread", true)
+	audit.record("thread.create", *pullRequest.Repository.Project.Name, strconv.Itoa(*createdThread.Id), map[string]interface{}{
+		"pullRequestId": *pullRequest.PullRequestId,
+		"noteId":        note.ID,
+		"comments":      1,
+	})
+	return prepareNoteMappings([]*Note{note}, *createdThread.Id)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"testing"
+	"time"
+)
+
+func TestKeepSystemNote(t *testing.T) {
+	keeping := project{SystemNotes: []string{"approvals", "labels", "commits"}}
+	tests := []struct {
+		body     string
+		expected bool
+	}{
+		{"approved this merge request", true},
+		{"unapproved this merge request", true},
+		{"added ~12 label", true},
+		{"added ~12 ~13 labels and removed ~14 label", true},
+		{"added 3 commits\n\n<ul><li>abc - Fix</li></ul>", true},
+		{"added 1 commit\n\n<ul><li>abc - Fix</li></ul>", true},
+		{"merged", false},
+		{"changed title from **a** to **b**", false},
+		{"assigned to @john", false},
+	}
+	for _, test := range tests {
+		if diff := deep.Equal(keepSystemNote(keeping, &Note{System: true, Body: test.body}), test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.body, diff)
+		}
+	}
+	if keepSystemNote(project{}, &Note{System: true, Body: "merged"}) {
+		t.Error("system notes should not be kept by default")
+	}
+}
+
+func TestValidateSystemNotes(t *testing.T) {
+	if err := validateSystemNotes(project{SystemNotes: []string{"merges", "commits"}}); err != nil {
+		t.Error(err)
+	}
+	err := validateSystemNotes(project{SystemNotes: []string{"milestones"}})
+	if diff := deep.Equal(err.Error(), "systemNotes milestones is not one of approvals, commits, labels, merges"); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTranslateSystemNote(t *testing.T) {
+	created := time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC)
+	mr := &MergeRequest{IID: 7, WebURL: "https://gitlab.com/group/php/-/merge_requests/7"}
+	note := &Note{ID: 42, System: true, Body: "added 2 commits\n\n<ul><li>abc - Fix</li></ul>", CreatedAt: &created}
+	note.Author.Username = "john"
+	note.Author.Name = "John"
+	note.Author.WebURL = "https://gitlab.com/john"
+	thread := translateSystemNote(mr, note)
+	expected := "⚙️ *[John](https://gitlab.com/john) added 2 commits on 2021-03-01 10:30* ([Gitlab](https://gitlab.com/group/php/-/merge_requests/7/diffs#note_42))"
+	if diff := deep.Equal(*(*thread.Comments)[0].Content, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(*thread.Status, git.CommentThreadStatusValues.Closed); diff != nil {
+		t.Error(diff)
+	}
+	note.CreatedAt = nil
+	thread = translateSystemNote(mr, note)
+	expected = "⚙️ *[John](https://gitlab.com/john) added 2 commits* ([Gitlab](https://gitlab.com/group/php/-/merge_requests/7/diffs#note_42))"
+	if diff := deep.Equal([]interface{}{*(*thread.Comments)[0].Content, thread.PublishedDate}, []interface{}{expected, (*azuredevops.Time)(nil)}); diff != nil {
+		t.Errorf("undated note: %+v", diff)
+	}
+}
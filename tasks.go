@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// taskSummary is the completion line of the merge request task list, AzDO renders checklists without the progress
+// gitlab shows next to the title. It is empty without tasks
+func taskSummary(mr *MergeRequest) string {
+	completed, count := countTasks(mr.Description)
+	if mr.TaskCompletionStatus != nil && mr.TaskCompletionStatus.Count > 0 {
+		//gitlab counts the rendered description, it knows task lists the line matching does not
+		completed, count = mr.TaskCompletionStatus.CompletedCount, mr.TaskCompletionStatus.Count
+	}
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("**☑️ %d/%d tasks complete**", completed, count)
+}
+
+// countTasks counts task list items outside of code blocks, inapplicable items are not counted as gitlab does
+func countTasks(description string) (int, int) {
+	completed, count := 0, 0
+	fence := ""
+	for _, line := range strings.Split(description, "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if match := fenceMatcher.FindStringSubmatch(line); match != nil {
+			fence = match[1]
+			continue
+		}
+		match := taskMatcher.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		switch match[2] {
+		case "x", "X":
+			completed++
+			count++
+		case " ":
+			count++
+		}
+	}
+	return completed, count
+}
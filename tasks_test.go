@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestTaskSummary(t *testing.T) {
+	tests := []struct {
+		description string
+		status      *TaskCompletionStatus
+		expected    string
+	}{
+		{"no tasks", nil, ""},
+		{"- [x] build\n* [ ] deploy\n1. [X] test\n- [~] docs", nil, "**☑️ 2/3 tasks complete**"},
+		{"```\n- [ ] not a task\n```\n- [ ] review", nil, "**☑️ 0/1 tasks complete**"},
+		{"- [x] build", &TaskCompletionStatus{Count: 7, CompletedCount: 3}, "**☑️ 3/7 tasks complete**"},
+		{"- [x] build", &TaskCompletionStatus{}, "**☑️ 1/1 tasks complete**"},
+	}
+	for _, test := range tests {
+		mr := &MergeRequest{Description: test.description, TaskCompletionStatus: test.status}
+		if diff := deep.Equal(taskSummary(mr), test.expected); diff != nil {
+			t.Errorf("%q: %+v", test.description, diff)
+		}
+	}
+}
+
+func TestPreparePullRequestDescriptionWithTasks(t *testing.T) {
+	mr := setupOpenMergeRequest()
+	mr.Description = "checklist\n- [x] build\n- [ ] deploy"
+	expected := "*Migrated from [Gitlab](https://gitlab.com/gitlab-examples/php/-/merge_requests/1) | Author: ![John Doe](https://www.gravatar.com/avatar/0 =24x24) [John Doe](https://gitlab.com/john-doe)*\n\n**☑️ 1/2 tasks complete**\n\nchecklist\n- [x] build\n- [ ] deploy"
+	if diff := deep.Equal(preparePullRequestDescription(&mr), expected); diff != nil {
+		t.Error(diff)
+	}
+}
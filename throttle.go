@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"golang.org/x/time/rate"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// throttleChunk is the largest amount of bytes transferred at once, it is the burst of the bandwidth limiter as well
+const throttleChunk = 32 * 1024
+
+var (
+	maxRequestsPerSecond = kingpin.Flag("max-requests-per-second", "Limit of requests per second sent to each API (gitlab, AzDO), 0 is unlimited").Default("0").Float64()
+	pushBandwidthLimit   = kingpin.Flag("push-bandwidth-limit", "Limit of bandwidth per second used by git push in local transfers (e.g. 10MB), 0 is unlimited").Default("0").Bytes()
+	bandwidthProxyOnce   sync.Once
+	bandwidthProxyURL    string
+)
+
+// newRequestLimiter returns limiter of API requests, every API gets its own one
+func newRequestLimiter() *rate.Limiter {
+	if *maxRequestsPerSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+	return rate.NewLimiter(rate.Limit(*maxRequestsPerSecond), 1)
+}
+
+// throttledTransport waits for the limiter before every request
+type throttledTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+func (t *throttledTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(request.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(request)
+}
+
+// throttledReader reads at most as fast as the limiter lets it
+type throttledReader struct {
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *throttledReader) Read(buffer []byte) (int, error) {
+	if len(buffer) > throttleChunk {
+		buffer = buffer[:throttleChunk]
+	}
+	n, err := r.reader.Read(buffer)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(context.Background(), n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// bandwidthProxy is a local HTTP proxy git pushes through, git itself cannot limit its bandwidth. All pushes share
+// one limiter so the limit holds for the whole run.
+type bandwidthProxy struct {
+	limiter *rate.Limiter
+}
+
+func (p *bandwidthProxy) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodConnect {
+		p.forward(w, request)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", request.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "connection cannot be tunneled", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	go func() {
+		io.Copy(upstream, &throttledReader{reader: client, limiter: p.limiter})
+		upstream.Close()
+	}()
+	io.Copy(client, &throttledReader{reader: upstream, limiter: p.limiter})
+	client.Close()
+}
+
+// forward passes plain HTTP requests (e.g. AzDO server without TLS) to the target
+func (p *bandwidthProxy) forward(w http.ResponseWriter, request *http.Request) {
+	outgoing := request.Clone(request.Context())
+	outgoing.RequestURI = ""
+	outgoing.Body = ioutil.NopCloser(&throttledReader{reader: request.Body, limiter: p.limiter})
+	response, err := baseTransport.RoundTrip(outgoing)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, &throttledReader{reader: response.Body, limiter: p.limiter})
+}
+
+// pushEnvironment returns environment routing git through the bandwidth limiting proxy, the proxy is started on
+// the first push
+func pushEnvironment() ([]string, error) {
+	if *pushBandwidthLimit <= 0 {
+		return nil, nil
+	}
+	var err error
+	bandwidthProxyOnce.Do(func() {
+		var listener net.Listener
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			err = fmt.Errorf("cannot start bandwidth limiting proxy: %s", err)
+			return
+		}
+		proxy := &bandwidthProxy{limiter: rate.NewLimiter(rate.Limit(*pushBandwidthLimit), throttleChunk)}
+		go func() {
+			if err := http.Serve(listener, proxy); err != nil {
+				log.Errorf("bandwidth limiting proxy stopped: %s", err)
+			}
+		}()
+		bandwidthProxyURL = "http://" + listener.Addr().String()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bandwidthProxyURL == "" {
+		return nil, fmt.Errorf("bandwidth limiting proxy is not running")
+	}
+	return []string{"http_proxy=" + bandwidthProxyURL, "https_proxy=" + bandwidthProxyURL, "no_proxy="}, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThrottle(t *testing.T) {
+	*pushBandwidthLimit = 1024 * 1024
+	defer func() { *pushBandwidthLimit = 0 }()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		w.Write(append([]byte("pushed "), body...))
+	}))
+	defer server.Close()
+
+	env, err := pushEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 3 {
+		t.Fatalf("unexpected environment %v", env)
+	}
+	proxy, _ := url.Parse(bandwidthProxyURL)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxy),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	response, err := client.Post(server.URL, "text/plain", strings.NewReader("refs/heads/main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if string(body) != "pushed refs/heads/main" {
+		t.Errorf("unexpected response through the proxy %q", body)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var httpTimeout = kingpin.Flag("http-timeout", "Deadline of every gitlab, GitHub and AzDO API request including its response body, git transfers are aborted when they stall for that long (0 disables)").Default("5m").Duration()
+
+// errHTTPTimeout tells timed out requests apart from other network errors in the report
+var errHTTPTimeout = errors.New("request timed out")
+
+// timeoutTransport gives every request its own deadline, clients of the SDKs cannot be given one per operation
+type timeoutTransport struct {
+	base http.RoundTripper
+}
+
+// timed wraps the transport of an API client, requests of the run share --http-timeout
+func timed(base http.RoundTripper) http.RoundTripper {
+	return &timeoutTransport{base: base}
+}
+
+func (t *timeoutTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	timeout := *httpTimeout
+	if timeout <= 0 {
+		return t.base.RoundTrip(request)
+	}
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
+	response, err := t.base.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, timeoutError(ctx, request, timeout, err)
+	}
+	response.Body = &deadlineBody{ReadCloser: response.Body, ctx: ctx, cancel: cancel, request: request, timeout: timeout}
+	return response, nil
+}
+
+// deadlineBody keeps the deadline until the body is read and closed
+type deadlineBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelFunc
+	request *http.Request
+	timeout time.Duration
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = timeoutError(b.ctx, b.request, b.timeout, err)
+	}
+	return n, err
+}
+
+func (b *deadlineBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// timeoutError replaces error of the request which ran out of its deadline and counts it for the report, errors of
+// requests canceled otherwise are kept
+func timeoutError(ctx context.Context, request *http.Request, timeout time.Duration, err error) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) || request.Context().Err() != nil {
+		return err
+	}
+	report.timeout()
+	return fmt.Errorf("%w: %s %s%s did not finish in %s (--http-timeout)", errHTTPTimeout, request.Method, request.URL.Host, request.URL.Path, timeout)
+}
+
+// gitTimeoutEnvironment aborts git transfers slower than 1 kB/s for the whole timeout, big repositories take longer
+// than any deadline of the whole transfer would allow
+func gitTimeoutEnvironment() []string {
+	if *httpTimeout <= 0 {
+		return nil
+	}
+	return []string{"GIT_HTTP_LOW_SPEED_LIMIT=1000", "GIT_HTTP_LOW_SPEED_TIME=" + strconv.Itoa(int(httpTimeout.Seconds()))}
+}
+
+// timeout counts requests which ran out of --http-timeout
+func (r *runReport) timeout() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.HTTPTimeouts++
+	if r.HTTPTimeouts == 1 {
+		log.Warnf("API request timed out after %s, raise --http-timeout if the endpoint is just slow", *httpTimeout)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutTransport(t *testing.T) {
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	*httpTimeout = 50 * time.Millisecond
+	defer func() { *httpTimeout, report = 5*time.Minute, &runReport{} }()
+	report = &runReport{}
+	client := &http.Client{Transport: timed(http.DefaultTransport)}
+
+	if _, err := client.Get(server.URL + "/headers"); !errors.Is(err, errHTTPTimeout) {
+		t.Errorf("hung request should time out, got %v", err)
+	}
+	response, err := client.Get(server.URL + "/body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if _, err := ioutil.ReadAll(response.Body); !errors.Is(err, errHTTPTimeout) {
+		t.Errorf("hung body should time out, got %v", err)
+	}
+	if report.HTTPTimeouts != 2 {
+		t.Errorf("timeouts should be counted, got %d", report.HTTPTimeouts)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// assumedThroughput and assumedLatency are defaults of plan the run duration is estimated with
+	assumedThroughput = 10 << 20
+	assumedLatency    = 300 * time.Millisecond
+	tokenDateLayout   = "2006-01-02"
+)
+
+var azdoTokenExpiry = kingpin.Flag("azdo-token-expiry", "Expiry date (YYYY-MM-DD) of --azdo-token as AzDO showed it when the token was created, AzDO does not tell it to the token itself. The run warns when it expires before the run is estimated to finish").Default("").String()
+
+// tokenDetails are what the token tells about itself, expiry is nil for tokens which do not expire
+type tokenDetails struct {
+	subject string
+	name    string
+	scopes  []string
+	expires *time.Time
+}
+
+// checkTokenExpiry reports scopes and expiry of tokens of the run and warns about tokens expiring before the run is
+// estimated to finish, projects are estimated only when some token expires
+func checkTokenExpiry(projects []project) {
+	tokens := introspectTokens(projects)
+	var expiring []tokenDetails
+	for _, token := range tokens {
+		log.Info(token)
+		if token.expires != nil {
+			expiring = append(expiring, token)
+		}
+	}
+	if len(expiring) == 0 {
+		return
+	}
+	estimate := estimateRun(projects)
+	finish := time.Now().Add(estimate)
+	for _, token := range expiring {
+		if token.expires.Before(finish) {
+			log.Warnf("%s expires on %s, before the run estimated to take at least %s finishes - renew it before the migration", token.subject, token.expires.Format(tokenDateLayout), estimate.Round(time.Minute))
+		}
+	}
+}
+
+// introspectTokens returns details of gitlab tokens of the projects and of the AzDO token, GitHub tokens are not
+// introspected
+func introspectTokens(projects []project) []tokenDetails {
+	var tokens []tokenDetails
+	seen := map[*gitlabInstance]bool{}
+	for _, project := range projects {
+		if project.gitlab == nil || seen[project.gitlab] {
+			continue
+		}
+		seen[project.gitlab] = true
+		token, err := gitlabTokenDetails(project.gitlab)
+		if err != nil {
+			log.Warnf("cannot introspect gitlab token of %s, its expiry is not checked: %s", project.gitlab.client.BaseURL(), err)
+			continue
+		}
+		if token != nil {
+			tokens = append(tokens, *token)
+		}
+	}
+	if token := azdoTokenDetails(); token != nil {
+		tokens = append(tokens, *token)
+	}
+	return tokens
+}
+
+// gitlabTokenDetails introspects personal, group and project access tokens, gitlab before 15.5 and job tokens do not
+// tell about themselves and return nil
+func gitlabTokenDetails(instance *gitlabInstance) (*tokenDetails, error) {
+	request, err := instance.client.NewRequest(http.MethodGet, "personal_access_tokens/self", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var self struct {
+		Name      string          `json:"name"`
+		Scopes    []string        `json:"scopes"`
+		ExpiresAt *gitlab.ISOTime `json:"expires_at"`
+	}
+	response, err := instance.client.Do(request, &self)
+	if response != nil && (response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden) {
+		log.Debugf("gitlab %s does not introspect its token: %s", instance.client.BaseURL(), err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := &tokenDetails{subject: "gitlab token of " + instance.client.BaseURL().Host, name: self.Name, scopes: self.Scopes}
+	sort.Strings(token.scopes)
+	if self.ExpiresAt != nil {
+		expires := time.Time(*self.ExpiresAt)
+		token.expires = &expires
+	}
+	if !hasScope(token.scopes, "api", "read_api") || !hasScope(token.scopes, "api", "read_repository") {
+		log.Warnf("%s has scopes %s, the migration needs api or read_api and read_repository", token.subject, strings.Join(token.scopes, ", "))
+	}
+	return token, nil
+}
+
+// azdoTokenDetails returns expiry of AzDO token from --azdo-token-expiry, PAT API does not accept PATs
+func azdoTokenDetails() *tokenDetails {
+	if *azdoTokenExpiry == "" {
+		return nil
+	}
+	//validated by initTokenExpiry
+	expires, _ := time.Parse(tokenDateLayout, *azdoTokenExpiry)
+	return &tokenDetails{subject: "AzDO token of " + *azdoOrganization, expires: &expires}
+}
+
+func hasScope(scopes []string, accepted ...string) bool {
+	for _, scope := range scopes {
+		for _, candidate := range accepted {
+			if scope == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t tokenDetails) String() string {
+	description := t.subject
+	if t.name != "" {
+		description += " " + t.name
+	}
+	if len(t.scopes) > 0 {
+		description += " with scopes " + strings.Join(t.scopes, ", ")
+	}
+	if t.expires == nil {
+		return description + " does not expire"
+	}
+	return description + " expires on " + t.expires.Format(tokenDateLayout)
+}
+
+// estimateRun is the least the run takes with assumptions of plan, merge requests are counted without their notes
+func estimateRun(projects []project) time.Duration {
+	pace := assumedLatency
+	if *maxRequestsPerSecond > 0 {
+		if limited := time.Duration(float64(time.Second) / *maxRequestsPerSecond); limited > pace {
+			pace = limited
+		}
+	}
+	var estimate time.Duration
+	for _, project := range projects {
+		calls := projectGitlabCalls + projectAzdoCalls
+		if project.gitlabProject != nil && project.gitlabProject.Statistics != nil {
+			estimate += time.Duration(float64(project.gitlabProject.Statistics.RepositorySize) / assumedThroughput * float64(time.Second))
+		}
+		calls += countMergeRequests(project) * (mergeRequestGitlabCalls + mergeRequestAzdoCalls)
+		estimate += time.Duration(calls) * pace
+	}
+	return estimate
+}
+
+// countMergeRequests counts merge requests of gitlab project by one request, they are not counted when it fails
+func countMergeRequests(project project) int {
+	if !project.MigrateMRs || project.Prefix != "" || project.gitlab == nil || project.gitlabProject == nil {
+		return 0
+	}
+	_, response, err := project.gitlab.client.MergeRequests.ListProjectMergeRequests(project.gitlabProject.ID, &gitlab.ListProjectMergeRequestsOptions{ListOptions: gitlab.ListOptions{PerPage: 1}})
+	if err != nil {
+		log.Debugf("cannot count merge requests of %v for the estimate: %s", project.gitlabKey(), err)
+		return 0
+	}
+	return response.TotalItems
+}
+
+// initTokenExpiry rejects --azdo-token-expiry which is not a date
+func initTokenExpiry() error {
+	if *azdoTokenExpiry == "" {
+		return nil
+	}
+	if _, err := time.Parse(tokenDateLayout, *azdoTokenExpiry); err != nil {
+		return fmt.Errorf("invalid --azdo-token-expiry %s, use YYYY-MM-DD: %s", *azdoTokenExpiry, err)
+	}
+	return nil
+}
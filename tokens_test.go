@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGitlabTokenDetails(t *testing.T) {
+	introspects := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v4/personal_access_tokens/self" && introspects:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"migration","scopes":["read_repository","api"],"expires_at":"2026-11-01"}`))
+		case r.URL.Path == "/api/v4/projects/7/merge_requests":
+			w.Header().Set("X-Total", "40")
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client, err := gitlab.NewClient("token", gitlab.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance := &gitlabInstance{client: client}
+
+	token, err := gitlabTokenDetails(instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	expected := &tokenDetails{subject: "gitlab token of " + client.BaseURL().Host, name: "migration", scopes: []string{"api", "read_repository"}, expires: &expires}
+	if diff := deep.Equal(token, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	introspects = false
+	if token, err := gitlabTokenDetails(instance); token != nil || err != nil {
+		t.Errorf("gitlab which does not introspect tokens should be skipped, got %+v, %v", token, err)
+	}
+
+	projects := []project{
+		{MigrateMRs: true, gitlab: instance, gitlabProject: &gitlab.Project{ID: 7, Statistics: &gitlab.ProjectStatistics{StorageStatistics: gitlab.StorageStatistics{RepositorySize: 100 << 20}}}},
+		{MigrateMRs: true, Prefix: "lib", gitlab: instance, gitlabProject: &gitlab.Project{ID: 8}},
+	}
+	calls := 2*(projectGitlabCalls+projectAzdoCalls) + 40*(mergeRequestGitlabCalls+mergeRequestAzdoCalls)
+	if diff := deep.Equal(estimateRun(projects), 10*time.Second+time.Duration(calls)*assumedLatency); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestAzdoTokenDetails(t *testing.T) {
+	defer func() { *azdoTokenExpiry = "" }()
+	*azdoTokenExpiry = "1.11.2026"
+	if initTokenExpiry() == nil {
+		t.Error("expiry which is not a date should be rejected")
+	}
+	*azdoTokenExpiry = "2026-11-01"
+	if err := initTokenExpiry(); err != nil {
+		t.Fatal(err)
+	}
+	token := azdoTokenDetails()
+	if token == nil || token.expires.Format(tokenDateLayout) != "2026-11-01" {
+		t.Errorf("unexpected AzDO token %+v", token)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// traceBodyLimit is how much of an error response body is logged
+const traceBodyLimit = 2048
+
+var (
+	debugHTTP = kingpin.Flag("debug-http", "Log every gitlab and AzDO request - method, URL, status, duration, correlation IDs and body of error responses, credentials are redacted").Default("false").Bool()
+	// correlationHeaders identify the request in gitlab and AzDO logs, support asks for them
+	correlationHeaders = []string{"X-Request-Id", "ActivityId", "X-TFS-Session", "X-VSS-E2EID", "X-MSEdge-Ref"}
+)
+
+// tracingTransport logs requests with --debug-http, the log is redacted by the redacting hook
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+// traced wraps the transport in tracing one when --debug-http is set
+func traced(base http.RoundTripper) http.RoundTripper {
+	if !*debugHTTP {
+		return base
+	}
+	return &tracingTransport{base: base}
+}
+
+func (t *tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.base.RoundTrip(request)
+	duration := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		log.Infof("http %s %s failed after %s: %s", request.Method, request.URL, duration, err)
+		return response, err
+	}
+	message := fmt.Sprintf("http %s %s %d in %s%s", request.Method, request.URL, response.StatusCode, duration, prepareCorrelation(response.Header))
+	if response.StatusCode >= http.StatusBadRequest {
+		body, readErr := ioutil.ReadAll(io.LimitReader(response.Body, traceBodyLimit))
+		//the client reads the whole body, the logged part is put back in front of the rest
+		response.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+		if readErr == nil && len(body) > 0 {
+			message += ": " + strings.TrimSpace(string(body))
+		}
+	}
+	log.Info(message)
+	return response, nil
+}
+
+func prepareCorrelation(header http.Header) string {
+	var ids []string
+	for _, name := range correlationHeaders {
+		if value := header.Get(name); value != "" {
+			ids = append(ids, name+"="+value)
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(ids, ", ") + ")"
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracingTransport(t *testing.T) {
+	message := strings.Repeat("x", traceBodyLimit+10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		w.Header().Set("X-Request-Id", "01FX")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(message))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &tracingTransport{base: http.DefaultTransport}}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if string(body) != message {
+		t.Errorf("traced response body should be kept whole, got %d bytes", len(body))
+	}
+	if correlation := prepareCorrelation(response.Header); correlation != " (X-Request-Id=01FX)" {
+		t.Errorf("unexpected correlation %q", correlation)
+	}
+}
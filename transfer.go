@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"strings"
+)
+
+const (
+	transferImport = "import"
+	transferMirror = "mirror"
+	transferBundle = "bundle"
+)
+
+var transferMode = kingpin.Flag("transfer-mode", "How repositories get into AzDO - AzDO import request, local mirror pushed by git (requires git) or local mirror of gitlab project export pushed by git when neither AzDO nor git can reach gitlab (requires git and maintainer role)").Default(transferImport).Enum(transferImport, transferMirror, transferBundle)
+
+// mirrorsRepository tells whether the project is transferred through a local repository, extracting a subdirectory
+// or combining projects cannot be done by import request
+func mirrorsRepository(project project) bool {
+	return *transferMode != transferImport || project.Subdirectory != "" || project.Prefix != ""
+}
+
+// checkTransferMode warns about options the import request cannot honor
+func checkTransferMode() {
+	if *historyDepth > 0 && *transferMode != transferMirror {
+		log.Warn("--history-depth is honored only by --transfer-mode=mirror, full history is transferred")
+	}
+	if *transferMode != transferImport {
+		return
+	}
+	if *stripBlobsLargerThan > 0 || *secretScan != secretScanOff || (*lfsMode != lfsOff && *lfsMode != "") {
+		log.Warn("--strip-blobs-larger-than, --secret-scan and --lfs are honored only by --transfer-mode=mirror or bundle")
+	}
+}
+
+// mirrorRepository fetches branches and tags of gitlab project into a local repository and pushes them to AzDO,
+// unlike import request it lets us decide what gets transferred
+func mirrorRepository(azdoCtx context.Context, azdoClient git.Client, project project, azdoRepository *git.GitRepository) error {
+	target, err := azdoRemote(*azdoRepository.RemoteUrl)
+	if err != nil {
+		return err
+	}
+	repository, err := newLocalRepository()
+	if err != nil {
+		return err
+	}
+	defer repository.remove()
+
+	if err := fetchSource(project, repository); err != nil {
+		return err
+	}
+	if err := excludeRefs(repository, project.ExcludeRefs); err != nil {
+		return err
+	}
+	if err := extractSubdirectory(repository, project); err != nil {
+		return err
+	}
+	if err := checkLargeFiles(repository, project); err != nil {
+		return err
+	}
+	if err := stripBlobs(repository, project); err != nil {
+		return err
+	}
+	if err := adviseLFS(repository, project); err != nil {
+		return err
+	}
+	if err := moveUnderPrefix(repository, project); err != nil {
+		return err
+	}
+	if err := scanSecrets(repository, project); err != nil {
+		return err
+	}
+	refspecs, defaultBranch, err := prepareRefspecs(repository, project, azdoRepository, target)
+	if err != nil {
+		return err
+	}
+
+	if err := pushLFSObjects(repository, project, target); err != nil {
+		return err
+	}
+	log.Debugf("pushing local mirror into %s", *azdoRepository.Name)
+	if err := repository.push(target, refspecs...); err != nil {
+		return err
+	}
+	audit.record("repository.push", project.AzdoProject, azdoRepository.Id.String(), map[string]interface{}{
+		"sourceUrl": project.gitlabProject.HTTPURLToRepo,
+	})
+
+	//AzDO makes the first pushed branch default one
+	if defaultBranch == "" {
+		return nil
+	}
+	_, err = azdoClient.UpdateRepository(azdoCtx, git.UpdateRepositoryArgs{
+		NewRepositoryInfo: &git.GitRepository{DefaultBranch: gitlab.String("refs/heads/" + defaultBranch)},
+		RepositoryId:      azdoRepository.Id,
+		Project:           &project.AzdoProject,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot set default branch %s: %s", defaultBranch, err)
+	}
+	return nil
+}
+
+// fetchSource fetches branches and tags of the project into the local repository, GitHub repositories have no export
+// and are fetched by git in bundle mode as well
+func fetchSource(project project, repository *localRepository) error {
+	if *transferMode == transferBundle && project.gitlab != nil {
+		log.Debugf("fetching export of %s into local mirror", project.gitlabProject.PathWithNamespace)
+		return fetchBundle(project, repository)
+	}
+	source, err := sourceRemote(project.gitlabProject.HTTPURLToRepo, project.source)
+	if err != nil {
+		return err
+	}
+	log.Debugf("fetching %s into local mirror", project.gitlabProject.HTTPURLToRepo)
+	args := append(append([]string{"fetch", "--quiet"}, fetchDepth()...), source, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	if _, err := repository.run(args...); err != nil {
+		return fmt.Errorf("%w%s", err, jobTokenHint(project.gitlab))
+	}
+	return truncateHistory(repository, project)
+}
+
+// excludeRefs deletes refs matching any of the patterns from the local repository before it is pushed
+func excludeRefs(repository *localRepository, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	matchers, err := compileRefPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	refs, err := repository.run("for-each-ref", "--format=%(refname)")
+	if err != nil {
+		return err
+	}
+	for _, ref := range strings.Split(refs, "\n") {
+		if ref == "" || !matchRef(matchers, ref) {
+			continue
+		}
+		log.Debugf("excluding %s", ref)
+		if _, err := repository.run("update-ref", "-d", ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileRefPatterns turns refspec like patterns into regular expressions, * matches any characters including /
+// the same way git does it in refspecs
+func compileRefPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var matchers []*regexp.Regexp
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		matcher, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid ref pattern %s: %s", pattern, err)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+func matchRef(matchers []*regexp.Regexp, ref string) bool {
+	for _, matcher := range matchers {
+		if matcher.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
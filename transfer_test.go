@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMatchRef(t *testing.T) {
+	matchers, err := compileRefPatterns([]string{"refs/heads/tmp/*", "refs/tags/v0.*", "refs/heads/old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := []struct {
+		ref    string
+		expect bool
+	}{
+		{"refs/heads/tmp/a", true},
+		{"refs/heads/tmp/a/b", true},
+		{"refs/heads/tmpx", false},
+		{"refs/tags/v0.1.0", true},
+		{"refs/tags/v10.0", false},
+		{"refs/heads/old", true},
+		{"refs/heads/older", false},
+		{"refs/heads/main", false},
+	}
+	for _, ref := range refs {
+		if matched := matchRef(matchers, ref.ref); matched != ref.expect {
+			t.Errorf("%s matched %t, expected %t", ref.ref, matched, ref.expect)
+		}
+	}
+}
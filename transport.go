@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"net/http"
+)
+
+var (
+	httpMaxIdleConns        = kingpin.Flag("http-max-idle-conns", "Idle connections kept open for reuse across all gitlab, GitHub and AzDO hosts").Default("100").Int()
+	httpMaxIdleConnsPerHost = kingpin.Flag("http-max-idle-conns-per-host", "Idle connections kept open for reuse per host, connections over it are closed after their request so concurrent migrations keep reconnecting when it is low").Default("32").Int()
+	httpMaxConnsPerHost     = kingpin.Flag("http-max-conns-per-host", "Connections open to a single host at once, requests over it wait for a free connection (0 is unlimited)").Default("0").Int()
+	httpHTTP2               = kingpin.Flag("http2", "Use HTTP/2 with hosts which support it, requests to a host share one connection then. --no-http2 keeps every request on its own HTTP/1.1 connection").Default("true").Bool()
+)
+
+// initTransport tunes connection pooling of baseTransport, which all API clients of the run and its workers share
+// so that connections opened by one project are reused by the next
+func initTransport() error {
+	if *httpMaxIdleConns < 0 || *httpMaxIdleConnsPerHost < 0 || *httpMaxConnsPerHost < 0 {
+		return fmt.Errorf("--http-max-idle-conns, --http-max-idle-conns-per-host and --http-max-conns-per-host cannot be negative")
+	}
+	baseTransport = newBaseTransport(*httpMaxIdleConns, *httpMaxIdleConnsPerHost, *httpMaxConnsPerHost, *httpHTTP2)
+	return nil
+}
+
+// newBaseTransport derives the transport from the default one, proxy, dial and TLS timeouts of the environment stay
+func newBaseTransport(maxIdle int, maxIdlePerHost int, maxPerHost int, http2 bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdle
+	transport.MaxIdleConnsPerHost = maxIdlePerHost
+	transport.MaxConnsPerHost = maxPerHost
+	transport.ForceAttemptHTTP2 = http2
+	if !http2 {
+		//non-nil empty map keeps the transport from upgrading TLS connections to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
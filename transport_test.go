@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNewBaseTransport(t *testing.T) {
+	transport := newBaseTransport(50, 10, 20, true)
+	if transport.MaxIdleConns != 50 || transport.MaxIdleConnsPerHost != 10 || transport.MaxConnsPerHost != 20 {
+		t.Errorf("pooling limits are not applied: %d %d %d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if !transport.ForceAttemptHTTP2 || transport.TLSNextProto != nil {
+		t.Error("HTTP/2 should be negotiated")
+	}
+	if transport.Proxy == nil {
+		t.Error("proxy of the environment should be kept")
+	}
+	http1 := newBaseTransport(50, 10, 0, false)
+	if http1.ForceAttemptHTTP2 || http1.TLSNextProto == nil || len(http1.TLSNextProto) != 0 {
+		t.Error("HTTP/2 should be disabled")
+	}
+}
+
+func TestInitTransport(t *testing.T) {
+	*httpMaxIdleConnsPerHost = -1
+	defer func() { *httpMaxIdleConnsPerHost = 0 }()
+	if err := initTransport(); err == nil {
+		t.Error("negative limit should be refused")
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/common/version"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// projectName prefixes release assets and binaries in them, see tarball target of the makefile
+const projectName = "drmax-gitlab-azdo-migration"
+
+// release binaries pin the key SHA256SUMS of releases are signed with, the makefile sets them from GPG_SIGNING_KEY
+var (
+	// releaseSigningKey is base64 of the armored public key
+	releaseSigningKey = ""
+	// releaseSigningFingerprint is the fingerprint of the key or of its signing subkey
+	releaseSigningFingerprint = ""
+)
+
+var (
+	checkUpdate       = kingpin.Flag("check-update", "Warn at start when a newer release of the migration exists").Default("false").Bool()
+	updateURL         = kingpin.Flag("update-url", "GitHub API URL of the latest release the update is checked against").Default("https://api.github.com/repos/drmaxgit/drmax-gitlab-azdo-migration/releases/latest").String()
+	selfUpdateCommand = kingpin.Command("self-update", "Replace the binary by the latest release when it is newer")
+)
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// warnAboutUpdate tells about newer release, migration goes on when the check fails
+func warnAboutUpdate() {
+	release, err := fetchNewerRelease()
+	if err != nil {
+		log.Warnf("cannot check for update: %s", err)
+		return
+	}
+	if release != nil {
+		log.Warnf("release %s is newer than %s, it may fix migration problems: %s (self-update installs it)", release.TagName, version.Version, release.HTMLURL)
+	}
+}
+
+// fetchNewerRelease returns the latest release when it is newer than the running binary, nil otherwise
+func fetchNewerRelease() (*githubRelease, error) {
+	if version.Version == "" {
+		return nil, fmt.Errorf("the binary is built without release version, update it from sources")
+	}
+	release := &githubRelease{}
+	if err := downloadUpdate(*updateURL, "application/vnd.github.v3+json", release); err != nil {
+		return nil, err
+	}
+	if compareVersions(release.TagName, version.Version) <= 0 {
+		return nil, nil
+	}
+	return release, nil
+}
+
+// selfUpdate replaces the running binary by the one of the latest release for this platform
+func selfUpdate() error {
+	release, err := fetchNewerRelease()
+	if err != nil {
+		return err
+	}
+	if release == nil {
+		log.Infof("%s is the latest release", version.Version)
+		return nil
+	}
+	asset := fmt.Sprintf("%s-%s-%s-%s.tar.gz", projectName, runtime.GOOS, runtime.GOARCH, release.TagName)
+	downloaded := map[string]*bytes.Buffer{}
+	for _, name := range []string{asset, "SHA256SUMS", "SHA256SUMS.asc"} {
+		downloadURL := ""
+		for _, candidate := range release.Assets {
+			if candidate.Name == name {
+				downloadURL = candidate.BrowserDownloadURL
+			}
+		}
+		if downloadURL == "" {
+			return fmt.Errorf("release %s has no %s, download it from %s", release.TagName, name, release.HTMLURL)
+		}
+		downloaded[name] = &bytes.Buffer{}
+		if err := downloadUpdate(downloadURL, "application/octet-stream", downloaded[name]); err != nil {
+			return err
+		}
+	}
+	sums := downloaded["SHA256SUMS"].Bytes()
+	if err := verifySignature(sums, downloaded["SHA256SUMS.asc"].Bytes()); err != nil {
+		return fmt.Errorf("release %s is not updated, SHA256SUMS cannot be verified: %s", release.TagName, err)
+	}
+	tarball := downloaded[asset]
+	if err := verifyChecksum(sums, asset, tarball.Bytes()); err != nil {
+		return fmt.Errorf("release %s is not updated: %s", release.TagName, err)
+	}
+	binary, err := extractReleaseBinary(tarball.Bytes(), runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return fmt.Errorf("%s: %s", asset, err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+	if err := replaceExecutable(executable, binary); err != nil {
+		return fmt.Errorf("cannot replace %s, is it writable? %s", executable, err)
+	}
+	log.Infof("%s updated from %s to %s", executable, version.Version, release.TagName)
+	return nil
+}
+
+// downloadUpdate decodes JSON into result or copies the body when the result is a writer
+func downloadUpdate(url string, accept string, result interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", accept)
+	client := &http.Client{Transport: traced(baseTransport), Timeout: 5 * time.Minute}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("GET %s responded %s: %s", url, response.Status, strings.TrimSpace(string(body)))
+	}
+	if writer, ok := result.(io.Writer); ok {
+		_, err = io.Copy(writer, response.Body)
+		return err
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// verifySignature checks by gpg that SHA256SUMS are signed by the pinned release key. The key is imported into
+// temporary keyring so that keys the user trusts cannot sign an update
+func verifySignature(sums []byte, signature []byte) error {
+	if releaseSigningKey == "" || releaseSigningFingerprint == "" {
+		return fmt.Errorf("the binary is built without release signing key, download the release manually")
+	}
+	key, err := base64.StdEncoding.DecodeString(releaseSigningKey)
+	if err != nil {
+		return fmt.Errorf("release signing key of the binary is not base64: %s", err)
+	}
+	home, err := ioutil.TempDir("", "migration-gnupg")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	files := map[string][]byte{"release.asc": key, "SHA256SUMS": sums, "SHA256SUMS.asc": signature}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(home, name), content, 0600); err != nil {
+			return err
+		}
+	}
+	gpg := func(args ...string) ([]byte, error) {
+		command := exec.Command("gpg", append([]string{"--batch", "--no-tty", "--homedir", home}, args...)...)
+		command.Dir = home
+		output, err := command.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("gpg %s failed, is gpg installed? %s: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+		return output, nil
+	}
+	if _, err := gpg("--import", "release.asc"); err != nil {
+		return err
+	}
+	status, err := gpg("--status-fd", "1", "--verify", "SHA256SUMS.asc", "SHA256SUMS")
+	if err != nil {
+		return err
+	}
+	return checkValidSignature(status, releaseSigningFingerprint)
+}
+
+// checkValidSignature looks for VALIDSIG status line of gpg made by the key of fingerprint, the line carries
+// fingerprints of the signing key and its primary key
+func checkValidSignature(status []byte, fingerprint string) error {
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "[GNUPG:]" || fields[1] != "VALIDSIG" {
+			continue
+		}
+		if strings.ToUpper(fields[2]) == fingerprint || strings.ToUpper(fields[len(fields)-1]) == fingerprint {
+			return nil
+		}
+		return fmt.Errorf("signed by %s, not by release key %s", fields[2], fingerprint)
+	}
+	return fmt.Errorf("no valid signature of release key %s", fingerprint)
+}
+
+// verifyChecksum compares SHA-256 of the tarball with its line in SHA256SUMS
+func verifyChecksum(sums []byte, asset string, tarball []byte) error {
+	expected := ""
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		//sha256sum marks files read in binary mode by *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == asset {
+			expected = strings.ToLower(fields[0])
+		}
+	}
+	if expected == "" {
+		return fmt.Errorf("SHA256SUMS have no checksum of %s", asset)
+	}
+	actual := sha256.Sum256(tarball)
+	if hex.EncodeToString(actual[:]) != expected {
+		return fmt.Errorf("checksum of %s does not match SHA256SUMS", asset)
+	}
+	return nil
+}
+
+// extractReleaseBinary returns the binary of the platform from release tarball
+func extractReleaseBinary(tarball []byte, goos string, goarch string) ([]byte, error) {
+	name := fmt.Sprintf("%s-%s-%s", projectName, goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	unzipped, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, err
+	}
+	archive := tar.NewReader(unzipped)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("tarball has no %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(header.Name) == name {
+			return ioutil.ReadAll(archive)
+		}
+	}
+}
+
+// replaceExecutable writes the new binary next to the old one and renames it over, Windows cannot replace running
+// executable so it is moved aside first
+func replaceExecutable(executable string, binary []byte) error {
+	replacement := executable + ".new"
+	if err := ioutil.WriteFile(replacement, binary, 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		previous := executable + ".old"
+		os.Remove(previous)
+		if err := os.Rename(executable, previous); err != nil {
+			os.Remove(replacement)
+			return err
+		}
+	}
+	if err := os.Rename(replacement, executable); err != nil {
+		os.Remove(replacement)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/go-test/deep"
+	"github.com/prometheus/common/version"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchNewerRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.10.0", "html_url": "https://github.com/drmaxgit/drmax-gitlab-azdo-migration/releases/tag/v1.10.0"}`))
+	}))
+	defer server.Close()
+	defaultURL := *updateURL
+	*updateURL = server.URL
+	defer func() { *updateURL, version.Version = defaultURL, "" }()
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{"v1.9.3", "v1.10.0"},
+		{"v1.10.0", ""},
+		{"v2.0.0", ""},
+	}
+	for _, test := range tests {
+		version.Version = test.version
+		release, err := fetchNewerRelease()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := ""
+		if release != nil {
+			actual = release.TagName
+		}
+		if diff := deep.Equal(actual, test.expected); diff != nil {
+			t.Errorf("%s: %+v", test.version, diff)
+		}
+	}
+	version.Version = ""
+	if _, err := fetchNewerRelease(); err == nil {
+		t.Error("binary without version should not be updated")
+	}
+}
+
+func TestExtractReleaseBinary(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	zipped := gzip.NewWriter(buffer)
+	archive := tar.NewWriter(zipped)
+	for name, content := range map[string]string{"LICENSE.md": "license", "drmax-gitlab-azdo-migration-windows-amd64.exe": "binary"} {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		archive.Write([]byte(content))
+	}
+	archive.Close()
+	zipped.Close()
+	binary, err := extractReleaseBinary(buffer.Bytes(), "windows", "amd64")
+	if diff := deep.Equal([]interface{}{string(binary), err}, []interface{}{"binary", nil}); diff != nil {
+		t.Error(diff)
+	}
+	if _, err := extractReleaseBinary(buffer.Bytes(), "linux", "amd64"); err == nil {
+		t.Error("tarball of another platform should fail")
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	executable := filepath.Join(t.TempDir(), "migration")
+	if err := ioutil.WriteFile(executable, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceExecutable(executable, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(executable)
+	if diff := deep.Equal([]interface{}{string(content), err}, []interface{}{"new", nil}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tarball := []byte("tarball")
+	sum := sha256.Sum256(tarball)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  migration-linux-amd64-v1.0.0.tar.gz\n" +
+		hex.EncodeToString(sum[:]) + " *migration-windows-amd64-v1.0.0.tar.gz\n")
+	for _, asset := range []string{"migration-linux-amd64-v1.0.0.tar.gz", "migration-windows-amd64-v1.0.0.tar.gz"} {
+		if err := verifyChecksum(sums, asset, tarball); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := verifyChecksum(sums, "migration-linux-amd64-v1.0.0.tar.gz", []byte("tampered")); err == nil {
+		t.Error("tampered tarball should fail")
+	}
+	if err := verifyChecksum(sums, "migration-darwin-arm64-v1.0.0.tar.gz", tarball); err == nil {
+		t.Error("tarball missing in SHA256SUMS should fail")
+	}
+}
+
+func TestCheckValidSignature(t *testing.T) {
+	status := []byte("[GNUPG:] NEWSIG\n[GNUPG:] GOODSIG 89AB Release\n" +
+		"[GNUPG:] VALIDSIG 0123SUBKEY 2021-10-01 1633046400 0 4 0 22 10 00 4567PRIMARY\n")
+	for _, fingerprint := range []string{"0123subkey", "4567PRIMARY"} {
+		if err := checkValidSignature(status, fingerprint); err != nil {
+			t.Errorf("%s: %s", fingerprint, err)
+		}
+	}
+	if err := checkValidSignature(status, "89ABOTHER"); err == nil {
+		t.Error("signature of another key should fail")
+	}
+	if err := checkValidSignature([]byte("[GNUPG:] BADSIG 89AB Release\n"), "0123SUBKEY"); err == nil {
+		t.Error("bad signature should fail")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	home := t.TempDir()
+	gpg := func(stdin string, args ...string) string {
+		command := exec.Command("gpg", append([]string{"--batch", "--homedir", home, "--passphrase", ""}, args...)...)
+		command.Stdin = strings.NewReader(stdin)
+		output, err := command.Output()
+		if err != nil {
+			t.Fatalf("gpg %s: %s", args, err)
+		}
+		return string(output)
+	}
+	sign := func(user string, sums string) []byte {
+		return []byte(gpg(sums, "--pinentry-mode", "loopback", "--local-user", user, "--armor", "--detach-sign"))
+	}
+	gpg("", "--quick-gen-key", "release@example.com", "ed25519", "sign", "never")
+	gpg("", "--quick-gen-key", "other@example.com", "ed25519", "sign", "never")
+	fingerprint := ""
+	for _, line := range strings.Split(gpg("", "--with-colons", "--list-keys", "release@example.com"), "\n") {
+		if fields := strings.Split(line, ":"); fields[0] == "fpr" && fingerprint == "" {
+			fingerprint = fields[9]
+		}
+	}
+	defer func() { releaseSigningKey, releaseSigningFingerprint = "", "" }()
+	sums := "checksums\n"
+	if err := verifySignature([]byte(sums), sign("release@example.com", sums)); err == nil {
+		t.Error("binary without pinned key should not verify")
+	}
+	releaseSigningKey = base64.StdEncoding.EncodeToString([]byte(gpg("", "--armor", "--export", "release@example.com")))
+	releaseSigningFingerprint = fingerprint
+	if err := verifySignature([]byte(sums), sign("release@example.com", sums)); err != nil {
+		t.Error(err)
+	}
+	if err := verifySignature([]byte("tampered\n"), sign("release@example.com", sums)); err == nil {
+		t.Error("tampered SHA256SUMS should fail")
+	}
+	if err := verifySignature([]byte(sums), sign("other@example.com", sums)); err == nil {
+		t.Error("signature of another key should fail")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/graph"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// user roles in merge requests, identity map resolves reviewers and approvers of migrated pull requests
+const (
+	roleAuthor   = "author"
+	roleAssignee = "assignee"
+	roleReviewer = "reviewer"
+	roleApprover = "approver"
+)
+
+// matches of suggested AzDO users, users of --identity-map keep their mapping
+const (
+	matchIdentityMap = "identity map"
+	matchEmail       = "email"
+	matchName        = "name"
+)
+
+var (
+	usersCommand                     = kingpin.Command("users", "List authors, assignees, reviewers and approvers of merge requests of configured projects with AzDO users matching them by email and write a starter identity map")
+	usersIdentityMapOutput           = usersCommand.Flag("output", "File the starter identity map is written to, users without AzDO match are mapped to empty string to be filled in").Default("identity-map.json").String()
+	usersOutput            io.Writer = os.Stdout
+)
+
+// gitlabUser is a user of merge requests of configured projects
+type gitlabUser struct {
+	id         int
+	username   string
+	name       string
+	email      string
+	roles      map[string]bool
+	projects   map[string]bool
+	suggestion string
+	match      string
+	// lookedUp users are not read from gitlab again even when they have no visible email
+	lookedUp bool
+}
+
+// listUsers scans merge requests which would be migrated, users are looked up in gitlab for email once
+func listUsers(azdoCtx context.Context, connection *azuredevops.Connection, config config) {
+	users := map[string]*gitlabUser{}
+	for _, project := range config.Projects {
+		if project.gitlabProject == nil || !project.MigrateMRs {
+			continue
+		}
+		if err := collectProjectUsers(project, users); err != nil {
+			log.Errorf("cannot list users of %s: %s", project.gitlabProject.PathWithNamespace, err)
+		}
+	}
+	azdoUsers, err := listAzdoUsers(azdoCtx, connection)
+	if err != nil {
+		log.Errorf("AzDO users are not matched, cannot list them: %s", err)
+	}
+	for _, user := range users {
+		user.suggestion, user.match = suggestIdentity(user, identities.users, azdoUsers)
+	}
+	writeUsers(usersOutput, users)
+	if err := writeStarterIdentityMap(*usersIdentityMapOutput, users, identities.users); err != nil {
+		log.Errorf("cannot write identity map %s: %s", *usersIdentityMapOutput, err)
+	}
+}
+
+func collectProjectUsers(project project, users map[string]*gitlabUser) error {
+	mergeRequests, err := project.source.ListMergeRequests()
+	if err != nil {
+		return err
+	}
+	path := project.gitlabProject.PathWithNamespace
+	for _, mr := range mergeRequests {
+		if !isMigrated(mr) {
+			continue
+		}
+		approvers, err := project.source.ListApprovers(mr)
+		if err != nil {
+			project.report.problem("cannot fetch approvals of merge request %d, approvers are not listed: %s", mr.IID, err)
+		}
+		addMergeRequestUsers(users, path, mr, approvers)
+	}
+	//emails are looked up in gitlab only, GitHub users are listed without them
+	if project.gitlab == nil {
+		return nil
+	}
+	for _, user := range users {
+		if user.lookedUp {
+			continue
+		}
+		user.lookedUp = true
+		found, _, err := project.gitlab.client.Users.GetUser(user.id, gitlab.GetUsersOptions{})
+		if err != nil {
+			log.Warnf("cannot read email of gitlab user %s: %s", user.username, err)
+			continue
+		}
+		//email is visible to administrators only, others see the public one
+		user.email = found.Email
+		if user.email == "" {
+			user.email = found.PublicEmail
+		}
+	}
+	return nil
+}
+
+// addMergeRequestUsers adds users of the merge request with their roles
+func addMergeRequestUsers(users map[string]*gitlabUser, project string, mr *MergeRequest, approvedBy []*User) {
+	add := func(user *User, role string) {
+		if user == nil || user.Username == "" {
+			return
+		}
+		found, ok := users[user.Username]
+		if !ok {
+			found = &gitlabUser{id: user.ID, username: user.Username, name: user.Name, roles: map[string]bool{}, projects: map[string]bool{}}
+			users[user.Username] = found
+		}
+		found.roles[role] = true
+		found.projects[project] = true
+	}
+	add(mr.Author, roleAuthor)
+	for _, user := range mr.Assignees {
+		add(user, roleAssignee)
+	}
+	for _, user := range mr.Reviewers {
+		add(user, roleReviewer)
+	}
+	for _, approver := range approvedBy {
+		add(approver, roleApprover)
+	}
+}
+
+// listAzdoUsers returns all users of the organization, graph API pages them by continuation token
+func listAzdoUsers(azdoCtx context.Context, connection *azuredevops.Connection) ([]graph.GraphUser, error) {
+	graphClient, err := graph.NewClient(azdoCtx, connection)
+	if err != nil {
+		return nil, err
+	}
+	var users []graph.GraphUser
+	args := graph.ListUsersArgs{}
+	for {
+		page, err := graphClient.ListUsers(azdoCtx, args)
+		if err != nil {
+			return nil, err
+		}
+		if page.GraphUsers != nil {
+			users = append(users, *page.GraphUsers...)
+		}
+		if page.ContinuationToken == nil || len(*page.ContinuationToken) == 0 || (*page.ContinuationToken)[0] == "" {
+			return users, nil
+		}
+		args.ContinuationToken = &(*page.ContinuationToken)[0]
+	}
+}
+
+// suggestIdentity returns principal name of the AzDO user matching email of the gitlab user, display name matches
+// are weaker and only suggested when the email does not match anybody
+func suggestIdentity(user *gitlabUser, mapped map[string]string, azdoUsers []graph.GraphUser) (string, string) {
+	if account, ok := mapped[user.username]; ok && account != "" {
+		return account, matchIdentityMap
+	}
+	byName := ""
+	for _, azdoUser := range azdoUsers {
+		if azdoUser.PrincipalName == nil {
+			continue
+		}
+		if user.email != "" && (equalFoldPointer(azdoUser.MailAddress, user.email) || equalFoldPointer(azdoUser.PrincipalName, user.email)) {
+			return *azdoUser.PrincipalName, matchEmail
+		}
+		if byName == "" && user.name != "" && equalFoldPointer(azdoUser.DisplayName, user.name) {
+			byName = *azdoUser.PrincipalName
+		}
+	}
+	if byName != "" {
+		return byName, matchName
+	}
+	return "", ""
+}
+
+func equalFoldPointer(value *string, expected string) bool {
+	return value != nil && strings.EqualFold(*value, expected)
+}
+
+// writeUsers prints users as a table sorted by username
+func writeUsers(output io.Writer, users map[string]*gitlabUser) {
+	table := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "USERNAME\tNAME\tEMAIL\tROLES\tPROJECTS\tAZDO USER\tMATCH")
+	unmatched := 0
+	for _, username := range sortedUsernames(users) {
+		user := users[username]
+		suggestion, match := user.suggestion, user.match
+		if suggestion == "" {
+			unmatched++
+			suggestion, match = "-", "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", user.username, user.name, orDash(user.email), strings.Join(sortedKeys(user.roles), ","), len(user.projects), suggestion, match)
+	}
+	table.Flush()
+	if unmatched > 0 {
+		log.Warnf("%d of %d gitlab users have no AzDO match, fill them in the identity map", unmatched, len(users))
+	}
+}
+
+// writeStarterIdentityMap writes the identity map --identity-map reads, empty accounts are left unresolved. Accounts
+// of the file written already and of the loaded identity map are kept, users of other projects stay in the map
+func writeStarterIdentityMap(path string, users map[string]*gitlabUser, mapped map[string]string) error {
+	starter := map[string]string{}
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(content, &starter); err != nil {
+			return fmt.Errorf("existing file is not an identity map and is not overwritten: %s", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for username, account := range mapped {
+		if account != "" {
+			starter[username] = account
+		}
+	}
+	for username, user := range users {
+		if starter[username] == "" {
+			starter[username] = user.suggestion
+		}
+	}
+	content, err = json.MarshalIndent(starter, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+func sortedUsernames(users map[string]*gitlabUser) []string {
+	var usernames []string
+	for username := range users {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	return usernames
+}
+
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-test/deep"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/graph"
+	"github.com/xanzy/go-gitlab"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddMergeRequestUsers(t *testing.T) {
+	alice := &User{ID: 1, Username: "alice", Name: "Alice"}
+	bob := &User{ID: 2, Username: "bob", Name: "Bob"}
+	users := map[string]*gitlabUser{}
+	addMergeRequestUsers(users, "group/php", &MergeRequest{Author: alice, Assignees: []*User{alice}, Reviewers: []*User{bob}}, nil)
+	addMergeRequestUsers(users, "group/go", &MergeRequest{Author: bob}, []*User{alice})
+
+	//gitlabUser has unexported fields only which deep does not compare
+	expected := map[string]*gitlabUser{
+		"alice": {id: 1, username: "alice", name: "Alice", roles: map[string]bool{roleAuthor: true, roleAssignee: true, roleApprover: true}, projects: map[string]bool{"group/php": true, "group/go": true}},
+		"bob":   {id: 2, username: "bob", name: "Bob", roles: map[string]bool{roleReviewer: true, roleAuthor: true}, projects: map[string]bool{"group/php": true, "group/go": true}},
+	}
+	if diff := deep.Equal(fmt.Sprint(*users["alice"], *users["bob"]), fmt.Sprint(*expected["alice"], *expected["bob"])); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSuggestIdentity(t *testing.T) {
+	azdoUsers := []graph.GraphUser{
+		{PrincipalName: gitlab.String("alice@drmax.eu"), MailAddress: gitlab.String("Alice.Smith@drmax.eu"), DisplayName: gitlab.String("Alice Smith")},
+		{PrincipalName: gitlab.String("bob@drmax.eu"), MailAddress: gitlab.String("bob@drmax.eu"), DisplayName: gitlab.String("Bob Builder")},
+		{MailAddress: gitlab.String("service@drmax.eu")},
+	}
+	tests := []struct {
+		label      string
+		user       gitlabUser
+		mapped     map[string]string
+		suggestion string
+		match      string
+	}{
+		{"email", gitlabUser{username: "alice", email: "alice.smith@drmax.eu"}, nil, "alice@drmax.eu", matchEmail},
+		{"principal name", gitlabUser{username: "bob", email: "BOB@drmax.eu"}, nil, "bob@drmax.eu", matchEmail},
+		{"email over name", gitlabUser{username: "bob", name: "Alice Smith", email: "bob@drmax.eu"}, nil, "bob@drmax.eu", matchEmail},
+		{"name", gitlabUser{username: "bob", name: "bob builder", email: "bob@home.example"}, nil, "bob@drmax.eu", matchName},
+		{"identity map", gitlabUser{username: "alice", email: "alice.smith@drmax.eu"}, map[string]string{"alice": "asmith@drmax.eu"}, "asmith@drmax.eu", matchIdentityMap},
+		{"empty mapping", gitlabUser{username: "alice", email: "alice.smith@drmax.eu"}, map[string]string{"alice": ""}, "alice@drmax.eu", matchEmail},
+		{"service without principal", gitlabUser{username: "ci", email: "service@drmax.eu"}, nil, "", ""},
+		{"no match", gitlabUser{username: "carol", name: "Carol"}, nil, "", ""},
+	}
+	for _, test := range tests {
+		suggestion, match := suggestIdentity(&test.user, test.mapped, azdoUsers)
+		if diff := deep.Equal([]string{suggestion, match}, []string{test.suggestion, test.match}); diff != nil {
+			t.Errorf("%s: %+v", test.label, diff)
+		}
+	}
+}
+
+func TestWriteUsers(t *testing.T) {
+	users := map[string]*gitlabUser{
+		"bob":   {username: "bob", name: "Bob", roles: map[string]bool{roleReviewer: true}, projects: map[string]bool{"group/php": true}},
+		"alice": {username: "alice", name: "Alice", email: "alice@drmax.eu", roles: map[string]bool{roleAuthor: true, roleApprover: true}, projects: map[string]bool{"group/php": true, "group/go": true}, suggestion: "alice@drmax.eu", match: matchEmail},
+	}
+	output := &bytes.Buffer{}
+	writeUsers(output, users)
+	expected := []string{
+		"USERNAME  NAME   EMAIL           ROLES            PROJECTS  AZDO USER       MATCH",
+		"alice     Alice  alice@drmax.eu  approver,author  2         alice@drmax.eu  email",
+		"bob       Bob    -               reviewer         1         -               -",
+	}
+	if diff := deep.Equal(strings.Split(strings.TrimSpace(output.String()), "\n"), expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWriteStarterIdentityMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identity-map.json")
+	//carol was filled in by hand after an earlier run, dave is mapped by --identity-map only
+	if err := ioutil.WriteFile(path, []byte(`{"bob": "", "carol": "carol@drmax.eu"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	users := map[string]*gitlabUser{
+		"alice": {username: "alice", suggestion: "alice@drmax.eu"},
+		"bob":   {username: "bob"},
+		"carol": {username: "carol", suggestion: "carol.smith@drmax.eu"},
+	}
+	if err := writeStarterIdentityMap(path, users, map[string]string{"dave": "dave@drmax.eu"}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	starter := map[string]string{}
+	if err := json.Unmarshal(content, &starter); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"alice": "alice@drmax.eu", "bob": "", "carol": "carol@drmax.eu", "dave": "dave@drmax.eu"}
+	if diff := deep.Equal(starter, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not a map"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStarterIdentityMap(path, users, nil); err == nil {
+		t.Error("file which is not an identity map should not be overwritten")
+	}
+}
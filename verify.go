@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// verifyRepository compares branch and tag heads of imported repository with gitlab, import request reports success
+// even when it transferred only part of the refs
+func verifyRepository(azdoCtx context.Context, azdoClient git.Client, project project, repository *git.GitRepository) {
+	if project.gitlab == nil {
+		log.Debugf("refs of %s are verified only against gitlab projects", project.gitlabProject.PathWithNamespace)
+		return
+	}
+	gitlabBranches, gitlabTags, err := listGitlabRefs(project.gitlab.client, project.gitlabProject.ID)
+	if err != nil {
+		project.report.problem("cannot verify imported refs: %s", err)
+		return
+	}
+	matchers, err := compileRefPatterns(project.ExcludeRefs)
+	if err != nil {
+		project.report.problem("cannot verify imported refs: %s", err)
+		return
+	}
+	if mirrorsRepository(project) {
+		//excluded refs are not expected in AzDO
+		omitRefs(gitlabBranches, "refs/heads/", matchers)
+		omitRefs(gitlabTags, "refs/tags/", matchers)
+	}
+	if project.Prefix != "" {
+		gitlabBranches = prefixRefs(gitlabBranches, project.Prefix)
+		gitlabTags = prefixRefs(gitlabTags, project.Prefix)
+	}
+	azdoBranches, err := listAzdoRefs(azdoCtx, azdoClient, repository, "heads/")
+	if err != nil {
+		project.report.problem("cannot verify imported refs: %s", err)
+		return
+	}
+	azdoTags, err := listAzdoRefs(azdoCtx, azdoClient, repository, "tags/")
+	if err != nil {
+		project.report.problem("cannot verify imported refs: %s", err)
+		return
+	}
+	//rewritten history cannot match, only presence of refs is verified
+	matchSHA := !rewritesHistory(project)
+	for _, mismatch := range append(compareRefs("branch", gitlabBranches, azdoBranches, matchSHA), compareRefs("tag", gitlabTags, azdoTags, matchSHA)...) {
+		project.report.problem("%s", mismatch)
+	}
+}
+
+// listGitlabRefs returns commit SHA of every branch and tag
+func listGitlabRefs(gitlabClient *gitlab.Client, projectID int) (map[string]string, map[string]string, error) {
+	branches := map[string]string{}
+	branchOptions := gitlab.ListBranchesOptions{ListOptions: gitlab.ListOptions{Page: 1, PerPage: 100}}
+	for {
+		page, response, err := gitlabClient.Branches.ListBranches(projectID, &branchOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot list gitlab branches: %s", err)
+		}
+		for _, branch := range page {
+			branches[branch.Name] = branch.Commit.ID
+		}
+		if response.NextPage > response.CurrentPage {
+			branchOptions.Page++
+			continue
+		}
+		break
+	}
+
+	tags := map[string]string{}
+	tagOptions := gitlab.ListTagsOptions{ListOptions: gitlab.ListOptions{Page: 1, PerPage: 100}}
+	for {
+		page, response, err := gitlabClient.Tags.ListTags(projectID, &tagOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot list gitlab tags: %s", err)
+		}
+		for _, tag := range page {
+			tags[tag.Name] = tag.Commit.ID
+		}
+		if response.NextPage > response.CurrentPage {
+			tagOptions.Page++
+			continue
+		}
+		break
+	}
+	return branches, tags, nil
+}
+
+// listAzdoRefs returns commit SHA of every ref with the prefix, annotated tags are peeled to the commit
+func listAzdoRefs(azdoCtx context.Context, azdoClient git.Client, repository *git.GitRepository, prefix string) (map[string]string, error) {
+	refs := map[string]string{}
+	args := git.GetRefsArgs{
+		RepositoryId: gitlab.String(repository.Id.String()),
+		Project:      repository.Project.Name,
+		Filter:       &prefix,
+		PeelTags:     gitlab.Bool(true),
+	}
+	for {
+		page, err := azdoClient.GetRefs(azdoCtx, args)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list AzDO refs: %s", err)
+		}
+		for _, ref := range page.Value {
+			sha := *ref.ObjectId
+			if ref.PeeledObjectId != nil && *ref.PeeledObjectId != "" {
+				sha = *ref.PeeledObjectId
+			}
+			refs[strings.TrimPrefix(*ref.Name, "refs/"+prefix)] = sha
+		}
+		if page.ContinuationToken == "" {
+			return refs, nil
+		}
+		args.ContinuationToken = &page.ContinuationToken
+	}
+}
+
+// prefixRefs renames refs of a project combined into shared repository to the names they are pushed as
+func prefixRefs(refs map[string]string, prefix string) map[string]string {
+	prefixed := map[string]string{}
+	for name, sha := range refs {
+		prefixed[prefix+"/"+name] = sha
+	}
+	return prefixed
+}
+
+func omitRefs(refs map[string]string, prefix string, matchers []*regexp.Regexp) {
+	for name := range refs {
+		if matchRef(matchers, prefix+name) {
+			delete(refs, name)
+		}
+	}
+}
+
+func compareRefs(kind string, gitlabRefs map[string]string, azdoRefs map[string]string, matchSHA bool) []string {
+	var mismatches []string
+	for name, sha := range gitlabRefs {
+		azdoSHA, ok := azdoRefs[name]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("%s %s is missing in AzDO", kind, name))
+		case matchSHA && azdoSHA != sha:
+			mismatches = append(mismatches, fmt.Sprintf("%s %s points to %s in AzDO but to %s in gitlab", kind, name, azdoSHA, sha))
+		}
+	}
+	sort.Strings(mismatches)
+	return mismatches
+}
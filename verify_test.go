@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+)
+
+func TestCompareRefs(t *testing.T) {
+	gitlabRefs := map[string]string{
+		"main":      "aaa",
+		"feature/a": "bbb",
+		"feature/b": "ccc",
+	}
+	azdoRefs := map[string]string{
+		"main":      "aaa",
+		"feature/a": "ddd",
+		"extra":     "eee",
+	}
+	expect := []string{
+		"branch feature/a points to ddd in AzDO but to bbb in gitlab",
+		"branch feature/b is missing in AzDO",
+	}
+	if diff := deep.Equal(expect, compareRefs("branch", gitlabRefs, azdoRefs, true)); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(expect[1:], compareRefs("branch", gitlabRefs, azdoRefs, false)); diff != nil {
+		t.Error(diff)
+	}
+	if mismatches := compareRefs("tag", gitlabRefs, gitlabRefs, true); mismatches != nil {
+		t.Errorf("identical refs reported %v", mismatches)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/common/version"
+	"runtime/debug"
+	"strings"
+)
+
+// sdkModules are the API clients whose versions --version reports by their names, behavior of the migration depends
+// on them
+var sdkModules = []struct{ name, path string }{
+	{"go-gitlab", "github.com/xanzy/go-gitlab"},
+	{"azure-devops sdk", "github.com/microsoft/azure-devops-go-api/azuredevops"},
+}
+
+// versionInfo is printed by --version, version, commit and build date are set by the makefile and binaries built
+// from sources without it leave them empty
+func versionInfo() string {
+	info := version.Print(projectName)
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, sdk := range sdkVersions(build) {
+		info += "\n  " + sdk
+	}
+	return info
+}
+
+// sdkVersions lists versions of SDK modules the binary is built with, replaced modules report their replacement
+func sdkVersions(build *debug.BuildInfo) []string {
+	var versions []string
+	for _, sdk := range sdkModules {
+		for _, module := range build.Deps {
+			if module.Path != sdk.path {
+				continue
+			}
+			used := module.Version
+			if module.Replace != nil {
+				used = strings.TrimSpace(module.Replace.Path+" "+module.Replace.Version) + " (replaced)"
+			}
+			versions = append(versions, fmt.Sprintf("%-18s%s", sdk.name+":", used))
+		}
+	}
+	return versions
+}
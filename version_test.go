@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"runtime/debug"
+	"testing"
+)
+
+func TestSDKVersions(t *testing.T) {
+	build := &debug.BuildInfo{Deps: []*debug.Module{
+		{Path: "github.com/microsoft/azure-devops-go-api/azuredevops", Version: "v1.0.0-b5"},
+		{Path: "github.com/google/uuid", Version: "v1.3.0"},
+		{Path: "github.com/xanzy/go-gitlab", Version: "v0.54.4", Replace: &debug.Module{Path: "../go-gitlab"}},
+	}}
+	expected := []string{
+		"go-gitlab:        ../go-gitlab (replaced)",
+		"azure-devops sdk: v1.0.0-b5",
+	}
+	if diff := deep.Equal(sdkVersions(build), expected); diff != nil {
+		t.Error(diff)
+	}
+}
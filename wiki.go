@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/wiki"
+	"github.com/prometheus/common/log"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const wikiHeader = "# Migrated gitlab repositories\n\n| GitLab | AzDO | Migrated | Merge requests |\n| --- | --- | --- | --- |\n"
+
+var (
+	wikiPage = kingpin.Flag("wiki-page", "Path of the project wiki page listing migrated repositories (e.g. /Gitlab migration), created or updated at the end of the run").Default("").String()
+	// wikiRowMatcher finds gitlab URL in the first column of rows written by earlier runs
+	wikiRowMatcher = regexp.MustCompile(`^\| \[[^\]]*\]\(([^)\s]+)\) \|`)
+)
+
+// prepareWikiRows returns table rows of migrated repositories by AzDO project
+func prepareWikiRows(mapping migrationMapping, date time.Time) map[string][]string {
+	rows := map[string][]string{}
+	for _, project := range mapping.Projects {
+		row := fmt.Sprintf("| [%s](%s) | [%s](%s) | %s | %d |", project.GitlabPath, project.GitlabURL, project.AzdoRepositoryName, project.AzdoRepositoryURL, date.Format("2006-01-02"), len(project.MergeRequests))
+		rows[project.AzdoProject] = append(rows[project.AzdoProject], row)
+	}
+	return rows
+}
+
+// mergeWikiPage keeps rows of repositories migrated by earlier runs, repositories migrated again get the new row
+func mergeWikiPage(existing string, rows []string) string {
+	updated := map[string]bool{}
+	for _, row := range rows {
+		updated[wikiRowMatcher.FindStringSubmatch(row)[1]] = true
+	}
+	for _, line := range strings.Split(existing, "\n") {
+		if match := wikiRowMatcher.FindStringSubmatch(line); match != nil && !updated[match[1]] {
+			rows = append(rows, line)
+		}
+	}
+	sort.Strings(rows)
+	return wikiHeader + strings.Join(rows, "\n") + "\n"
+}
+
+// publishWikiIndex writes human readable companion of the mapping file into wiki of every AzDO project migrated into
+func publishWikiIndex(azdoCtx context.Context, connection *azuredevops.Connection, mapping migrationMapping) {
+	if *wikiPage == "" {
+		return
+	}
+	client, err := wiki.NewClient(azdoCtx, connection)
+	if err != nil {
+		log.Errorf("cannot publish wiki index: %s", err)
+		return
+	}
+	for azdoProject, rows := range prepareWikiRows(mapping, time.Now()) {
+		if err := publishProjectWikiIndex(azdoCtx, client, azdoProject, rows); err != nil {
+			log.Errorf("cannot publish wiki index of project %s: %s", azdoProject, err)
+		}
+	}
+}
+
+func publishProjectWikiIndex(azdoCtx context.Context, client wiki.Client, azdoProject string, rows []string) error {
+	wikis, err := client.GetAllWikis(azdoCtx, wiki.GetAllWikisArgs{Project: &azdoProject})
+	if err != nil {
+		return err
+	}
+	var projectWiki *wiki.WikiV2
+	for i := range *wikis {
+		if (*wikis)[i].Type != nil && *(*wikis)[i].Type == wiki.WikiTypeValues.ProjectWiki {
+			projectWiki = &(*wikis)[i]
+		}
+	}
+	if projectWiki == nil {
+		return fmt.Errorf("project has no wiki, create it in AzDO first")
+	}
+
+	existing, version := "", (*string)(nil)
+	page, err := client.GetPage(azdoCtx, wiki.GetPageArgs{
+		Project:        &azdoProject,
+		WikiIdentifier: projectWiki.Name,
+		Path:           wikiPage,
+		IncludeContent: gitlab.Bool(true),
+	})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if err == nil && page.Page != nil {
+		if page.Page.Content != nil {
+			existing = *page.Page.Content
+		}
+		if page.ETag != nil && len(*page.ETag) > 0 {
+			version = &(*page.ETag)[0]
+		}
+	}
+	content := mergeWikiPage(existing, rows)
+	updated, err := client.CreateOrUpdatePage(azdoCtx, wiki.CreateOrUpdatePageArgs{
+		Parameters:     &wiki.WikiPageCreateOrUpdateParameters{Content: &content},
+		Project:        &azdoProject,
+		WikiIdentifier: projectWiki.Name,
+		Path:           wikiPage,
+		Version:        version,
+		Comment:        gitlab.String("Update index of repositories migrated from gitlab"),
+	})
+	if err != nil {
+		return err
+	}
+	id := *wikiPage
+	if updated.Page != nil && updated.Page.Id != nil {
+		id = fmt.Sprint(*updated.Page.Id)
+	}
+	audit.record("wikiPage.update", azdoProject, id, map[string]interface{}{
+		"path":         *wikiPage,
+		"repositories": len(rows),
+	})
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"testing"
+	"time"
+)
+
+func TestPrepareWikiRows(t *testing.T) {
+	mapping := migrationMapping{Projects: []projectMapping{
+		{GitlabPath: "group/app", GitlabURL: "https://gitlab.com/group/app", AzdoProject: "Apps", AzdoRepositoryName: "app", AzdoRepositoryURL: "https://dev.azure.com/org/Apps/_git/app", MergeRequests: []mergeRequestMapping{{}, {}}},
+		{GitlabPath: "group/lib", GitlabURL: "https://gitlab.com/group/lib", AzdoProject: "Libs", AzdoRepositoryName: "lib", AzdoRepositoryURL: "https://dev.azure.com/org/Libs/_git/lib"},
+	}}
+	expect := map[string][]string{
+		"Apps": {"| [group/app](https://gitlab.com/group/app) | [app](https://dev.azure.com/org/Apps/_git/app) | 2020-03-01 | 2 |"},
+		"Libs": {"| [group/lib](https://gitlab.com/group/lib) | [lib](https://dev.azure.com/org/Libs/_git/lib) | 2020-03-01 | 0 |"},
+	}
+	if diff := deep.Equal(prepareWikiRows(mapping, time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)), expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestMergeWikiPage(t *testing.T) {
+	existing := wikiHeader +
+		"| [group/old](https://gitlab.com/group/old) | [old](https://dev.azure.com/org/Apps/_git/old) | 2020-01-01 | 1 |\n" +
+		"| [group/app](https://gitlab.com/group/app) | [app](https://dev.azure.com/org/Apps/_git/app) | 2020-01-01 | 1 |\n" +
+		"text added by hand\n"
+	rows := []string{"| [group/app](https://gitlab.com/group/app) | [app](https://dev.azure.com/org/Apps/_git/app) | 2020-03-01 | 2 |"}
+	expect := wikiHeader +
+		"| [group/app](https://gitlab.com/group/app) | [app](https://dev.azure.com/org/Apps/_git/app) | 2020-03-01 | 2 |\n" +
+		"| [group/old](https://gitlab.com/group/old) | [old](https://dev.azure.com/org/Apps/_git/old) | 2020-01-01 | 1 |\n"
+	if diff := deep.Equal(mergeWikiPage(existing, rows), expect); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(mergeWikiPage("", rows), wikiHeader+rows[0]+"\n"); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
+	"github.com/xanzy/go-gitlab"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const issueReference = `(?:[\w.\-]+(?:/[\w.\-]+)+)?#\d+|https?://[^\s)\]]+/issues/\d+`
+
+var (
+	workItemMapFile = kingpin.Flag("work-item-map", "JSON file mapping web URLs of gitlab issues to IDs of AzDO work items they were migrated to, pull requests are linked to work items of issues their merge request closes").Default("").String()
+	workItems       = map[string]int{}
+	// closingPattern is the default gitlab issue closing pattern, one keyword can close a list of issues
+	closingPattern = regexp.MustCompile(`(?i)\b(?:clos(?:e[sd]?|ing)|fix(?:e[sd]|ing)?|resolv(?:e[sd]?|ing)|implement(?:s|ed|ing)?):? +((?:(?:issues? +)?(?:` + issueReference + `)(?: *,? +and +| *, *)?)+)`)
+	issueMatcher   = regexp.MustCompile(`([\w.\-]+(?:/[\w.\-]+)+)?#(\d+)|(https?://[^\s)\]]+/issues/\d+)`)
+)
+
+func loadWorkItemMap(path string) (map[string]int, error) {
+	if path == "" {
+		return map[string]int{}, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mapped := map[string]int{}
+	if err := json.Unmarshal(content, &mapped); err != nil {
+		return nil, fmt.Errorf("cannot parse work item map %s: %s", path, err)
+	}
+	return mapped, nil
+}
+
+// findClosedIssues returns web URLs of issues the description closes, references are relative to the project
+func findClosedIssues(description string, projectURL string, projectPath string, issuePath string) []string {
+	var issues []string
+	found := map[string]bool{}
+	for _, closing := range closingPattern.FindAllStringSubmatch(description, -1) {
+		for _, reference := range issueMatcher.FindAllStringSubmatch(closing[1], -1) {
+			issue := resolveIssueReference(reference, projectURL, projectPath, issuePath)
+			if !found[issue] {
+				found[issue] = true
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// resolveIssueReference returns web URL of the issue matched by issueMatcher as #1, group/project#1 or by its URL
+func resolveIssueReference(reference []string, projectURL string, projectPath string, issuePath string) string {
+	switch {
+	case reference[3] != "":
+		return reference[3]
+	case reference[1] != "":
+		instanceURL := strings.TrimSuffix(strings.TrimSuffix(projectURL, "/"), "/"+projectPath)
+		return instanceURL + "/" + reference[1] + issuePath + reference[2]
+	default:
+		return strings.TrimSuffix(projectURL, "/") + issuePath + reference[2]
+	}
+}
+
+// prepareWorkItemRefs links the pull request to work items of issues the merge request closes, issues missing in the
+// work item map are reported
+func prepareWorkItemRefs(project project, mr *MergeRequest) *[]webapi.ResourceRef {
+	if len(workItems) == 0 {
+		return nil
+	}
+	var refs []webapi.ResourceRef
+	for _, issue := range findClosedIssues(mr.Description, project.gitlabProject.WebURL, project.gitlabProject.PathWithNamespace, project.source.IssuePath()) {
+		id, ok := workItems[issue]
+		if !ok {
+			project.report.problem("merge request %d closes %s which is not in the work item map, it is not linked", mr.IID, issue)
+			continue
+		}
+		refs = append(refs, webapi.ResourceRef{Id: gitlab.String(strconv.Itoa(id))})
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	return &refs
+}
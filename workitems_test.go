@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/go-test/deep"
+	"github.com/xanzy/go-gitlab"
+	"testing"
+)
+
+func TestFindClosedIssues(t *testing.T) {
+	projectURL := "https://gitlab.com/group/app"
+	cases := []struct {
+		label       string
+		description string
+		expected    []string
+	}{
+		{"no closing keyword", "Relates to #1", nil},
+		{"single issue", "Closes #12", []string{"https://gitlab.com/group/app/-/issues/12"}},
+		{"list of issues", "fixes: #1, #2 and issue #3.", []string{
+			"https://gitlab.com/group/app/-/issues/1",
+			"https://gitlab.com/group/app/-/issues/2",
+			"https://gitlab.com/group/app/-/issues/3",
+		}},
+		{"other project", "Resolves group/lib#4", []string{"https://gitlab.com/group/lib/-/issues/4"}},
+		{"issue URL", "Implements https://gitlab.com/other/api/-/issues/5", []string{"https://gitlab.com/other/api/-/issues/5"}},
+		{"repeated issue", "Closes #6\n\nFixed #6", []string{"https://gitlab.com/group/app/-/issues/6"}},
+	}
+	for _, c := range cases {
+		if diff := deep.Equal(findClosedIssues(c.description, projectURL, "group/app", "/-/issues/"), c.expected); diff != nil {
+			t.Errorf("%s: %+v", c.label, diff)
+		}
+	}
+}
+
+func TestPrepareWorkItemRefs(t *testing.T) {
+	workItems = map[string]int{"https://gitlab.com/group/app/-/issues/1": 101}
+	defer func() { workItems = map[string]int{} }()
+	gitlabProject := &gitlab.Project{WebURL: "https://gitlab.com/group/app", PathWithNamespace: "group/app"}
+	project := project{gitlabProject: gitlabProject, source: &gitlabSource{project: gitlabProject}}
+	refs := prepareWorkItemRefs(project, &MergeRequest{IID: 1, Description: "Closes #1 and #2"})
+	if refs == nil || len(*refs) != 1 || *(*refs)[0].Id != "101" {
+		t.Errorf("only issue 1 should be linked, got %+v", refs)
+	}
+	if refs := prepareWorkItemRefs(project, &MergeRequest{IID: 2, Description: "Closes #2"}); refs != nil {
+		t.Errorf("unmapped issue should not be linked, got %+v", refs)
+	}
+}